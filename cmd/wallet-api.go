@@ -3,14 +3,21 @@ package main
 import (
 	"context"
 	"fmt"
+	"github.com/IMBgl/go-wallet-api/internal/domain"
 	"github.com/IMBgl/go-wallet-api/internal/handler"
 	"github.com/IMBgl/go-wallet-api/internal/repository"
 	"github.com/IMBgl/go-wallet-api/internal/service"
+	"github.com/IMBgl/go-wallet-api/pkg/jwt"
 	pgx "github.com/jackc/pgx/v4"
 	"log"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+	// user time zones must load without the zoneinfo of the host
+	_ "time/tzdata"
 )
 
 func main() {
@@ -19,10 +26,22 @@ func main() {
 		log.Printf("Could not connect to database %v", err)
 	}
 
+	// CURRENCIES is a comma separated list of the ISO 4217 codes users can choose, all by default
+	if v := os.Getenv("CURRENCIES"); v != "" {
+		err = domain.EnableCurrencies(strings.Split(v, ",")...)
+		if err != nil {
+			log.Fatalf("Invalid CURRENCIES %s: %v", v, err)
+		}
+	}
+
 	repo := repository.New(conn)
-	srv := service.New(repo)
+	srv := service.New(repo, loadConfig())
 
-	router := handler.ApiHandler(srv).Routes()
+	scheduler := service.NewScheduler(srv.Recurring(), service.SCHEDULER_INTERVAL)
+	go scheduler.Run(context.Background())
+
+	limiter := handler.NewRateLimiter(rateLimitStore(conn), loadRateLimitConfig())
+	router := handler.ApiHandler(srv, limiter).Routes()
 
 	err = http.ListenAndServe(os.Getenv("APP_HOST"), router)
 	if err != nil {
@@ -30,6 +49,183 @@ func main() {
 	}
 }
 
+// loadConfig reads the service settings from the environment, unset values keep their defaults.
+func loadConfig() *service.Config {
+	config := service.DefaultConfig()
+
+	if v := os.Getenv("PASSWORD_HASH_ALGORITHM"); v != "" {
+		config.Password.Algorithm = v
+	}
+	config.Password.Argon2Time = uint32(envInt("PASSWORD_ARGON2_TIME", int(config.Password.Argon2Time)))
+	config.Password.Argon2MemoryKiB = uint32(envInt("PASSWORD_ARGON2_MEMORY_KIB", int(config.Password.Argon2MemoryKiB)))
+	config.Password.Argon2Threads = uint8(envInt("PASSWORD_ARGON2_THREADS", int(config.Password.Argon2Threads)))
+	config.Password.BcryptCost = envInt("PASSWORD_BCRYPT_COST", config.Password.BcryptCost)
+	config.Token.AccessTokenLifetime = envDuration("ACCESS_TOKEN_LIFETIME", config.Token.AccessTokenLifetime)
+	config.Token.RefreshTokenLifetime = envDuration("REFRESH_TOKEN_LIFETIME", config.Token.RefreshTokenLifetime)
+	config.Token.RefreshReuseWindow = envDuration("REFRESH_REUSE_WINDOW", config.Token.RefreshReuseWindow)
+
+	keys, err := loadJwtKeys(os.Getenv("JWT_KEYS"), os.Getenv("JWT_SIGNING_KEY_ID"))
+	if err != nil {
+		log.Fatalf("Could not load jwt keys %v", err)
+	}
+	config.Jwt.Keys = keys
+	config.Jwt.Issuer = os.Getenv("JWT_ISSUER")
+
+	if v := os.Getenv("TOTP_ISSUER"); v != "" {
+		config.TwoFactor.Issuer = v
+	}
+
+	config.Account.Secret = []byte(os.Getenv("ACCOUNT_TOKEN_SECRET"))
+	config.Account.VerifyEmailLifetime = envDuration("VERIFY_EMAIL_LIFETIME", config.Account.VerifyEmailLifetime)
+	config.Account.ResetPasswordLifetime = envDuration("RESET_PASSWORD_LIFETIME", config.Account.ResetPasswordLifetime)
+	config.Account.VerifyEmailUrl = os.Getenv("VERIFY_EMAIL_URL")
+	config.Account.ResetPasswordUrl = os.Getenv("RESET_PASSWORD_URL")
+	config.Account.ChangeEmailUrl = os.Getenv("CHANGE_EMAIL_URL")
+
+	// MAIL_TRANSPORT has no default, the log transport is only meant for local development
+	config.Mail.Transport = os.Getenv("MAIL_TRANSPORT")
+	if v := os.Getenv("MAIL_FROM"); v != "" {
+		config.Mail.From = v
+	}
+	config.Mail.SmtpHost = os.Getenv("SMTP_HOST")
+	config.Mail.SmtpPort = envInt("SMTP_PORT", config.Mail.SmtpPort)
+	config.Mail.SmtpUsername = os.Getenv("SMTP_USERNAME")
+	config.Mail.SmtpPassword = os.Getenv("SMTP_PASSWORD")
+	config.Mail.LogDir = os.Getenv("MAIL_LOG_DIR")
+	switch config.Mail.Transport {
+	case service.MAIL_TRANSPORT_SMTP:
+		if config.Mail.SmtpHost == "" {
+			log.Fatalf("SMTP_HOST is required by the smtp mail transport")
+		}
+	case service.MAIL_TRANSPORT_LOG:
+	default:
+		log.Fatalf("Unknown mail transport %q, set MAIL_TRANSPORT to smtp, or log for local development", config.Mail.Transport)
+	}
+
+	// EXCHANGE_RATES_FILE is a CSV file of date,base,quote,rate rows filling the rates users did not enter
+	if v := os.Getenv("EXCHANGE_RATES_FILE"); v != "" {
+		provider, err := service.NewCsvRateProvider(v)
+		if err != nil {
+			log.Fatalf("Could not load exchange rates %s: %v", v, err)
+		}
+		config.ExchangeRate.Provider = provider
+	}
+	config.ExchangeRate.MaxAge = envDuration("EXCHANGE_RATE_MAX_AGE", config.ExchangeRate.MaxAge)
+
+	return config
+}
+
+// RATE_LIMIT_CLEANUP_INTERVAL is how often the reset counters are deleted from the Postgres store.
+const RATE_LIMIT_CLEANUP_INTERVAL = time.Hour
+
+func loadRateLimitConfig() handler.RateLimitConfig {
+	config := handler.DefaultRateLimitConfig()
+
+	config.AuthLimit = envInt("RATE_LIMIT_AUTH", config.AuthLimit)
+	config.AuthWindow = envDuration("RATE_LIMIT_AUTH_WINDOW", config.AuthWindow)
+	config.AccountLimit = envInt("RATE_LIMIT_ACCOUNT", config.AccountLimit)
+	config.AccountWindow = envDuration("RATE_LIMIT_ACCOUNT_WINDOW", config.AccountWindow)
+	config.WriteLimit = envInt("RATE_LIMIT_WRITE", config.WriteLimit)
+	config.WriteWindow = envDuration("RATE_LIMIT_WRITE_WINDOW", config.WriteWindow)
+	config.LockoutThreshold = envInt("LOCKOUT_THRESHOLD", config.LockoutThreshold)
+	config.LockoutBase = envDuration("LOCKOUT_BASE", config.LockoutBase)
+	config.LockoutMax = envDuration("LOCKOUT_MAX", config.LockoutMax)
+	config.FailureWindow = envDuration("LOCKOUT_FAILURE_WINDOW", config.FailureWindow)
+	config.TrustProxy = os.Getenv("TRUST_PROXY") == "true"
+
+	return config
+}
+
+// rateLimitStore returns the store of RATE_LIMIT_STORE, memory by default.
+// Instances behind a load balancer need postgres to share the limits.
+func rateLimitStore(conn *pgx.Conn) handler.RateLimitStore {
+	switch os.Getenv("RATE_LIMIT_STORE") {
+	case "", "memory":
+		return nil
+	case "postgres":
+		store := repository.RateLimitRepository(conn)
+		go func() {
+			ticker := time.NewTicker(RATE_LIMIT_CLEANUP_INTERVAL)
+			defer ticker.Stop()
+			for range ticker.C {
+				if err := store.DeleteExpired(context.Background(), time.Now()); err != nil {
+					log.Printf("rate limit cleanup err %v", err)
+				}
+			}
+		}()
+
+		return store
+	default:
+		log.Fatalf("Unknown rate limit store %s", os.Getenv("RATE_LIMIT_STORE"))
+		return nil
+	}
+}
+
+// loadJwtKeys reads a comma separated list of kid:algorithm:file, the file
+// holds an HS256 secret or a PEM key. The signing key defaults to the first
+// one, keys listed after it only verify tokens until they expire.
+func loadJwtKeys(spec, signingKeyId string) (*jwt.KeySet, error) {
+	if spec == "" {
+		return nil, nil
+	}
+
+	keys := []*jwt.Key{}
+	for _, item := range strings.Split(spec, ",") {
+		parts := strings.SplitN(strings.TrimSpace(item), ":", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid key %s, expected kid:algorithm:file", item)
+		}
+
+		data, err := os.ReadFile(parts[2])
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := jwt.ParseKey(parts[0], parts[1], data)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", parts[0], err)
+		}
+		keys = append(keys, key)
+	}
+
+	if signingKeyId == "" {
+		signingKeyId = keys[0].Id
+	}
+
+	return jwt.NewKeySet(signingKeyId, keys...)
+}
+
+func envInt(name string, def int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("invalid %s value %s, using %d", name, v, def)
+		return def
+	}
+
+	return i
+}
+
+// envDuration reads a duration like 15m or 720h.
+func envDuration(name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("invalid %s value %s, using %s", name, v, def)
+		return def
+	}
+
+	return d
+}
+
 func connectDB() (*pgx.Conn, error) {
 	conn, err := pgx.Connect(context.Background(), os.Getenv("DB_URL"))
 	if err != nil {
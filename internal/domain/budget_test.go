@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestBudgetPeriodAt(t *testing.T) {
+	limit, _ := MoneyFromString("100", CurrencyUSD())
+	start := time.Date(2022, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	monthly, err := NewBudget("food", uuid.New(), uuid.New(), limit, BudgetPeriodMonthly(), start, nil, false)
+	if err != nil {
+		t.Fatalf("NewBudget err %v", err)
+	}
+
+	if _, _, ok := monthly.PeriodAt(start.AddDate(0, 0, -1)); ok {
+		t.Errorf("period before start date must not exist")
+	}
+
+	from, to, _ := monthly.PeriodAt(time.Date(2022, 3, 20, 12, 0, 0, 0, time.UTC))
+	if !from.Equal(start) || !to.Equal(time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("first monthly period = %v - %v", from, to)
+	}
+
+	weekly, _ := NewBudget("food", uuid.New(), uuid.New(), limit, BudgetPeriodWeekly(), start, nil, false)
+	from, to, _ = weekly.PeriodAt(time.Date(2022, 3, 20, 12, 0, 0, 0, time.UTC))
+	if !from.Equal(time.Date(2022, 3, 14, 0, 0, 0, 0, time.UTC)) || !to.Equal(time.Date(2022, 3, 21, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("weekly period = %v - %v", from, to)
+	}
+
+	if _, err := NewBudget("trip", uuid.New(), uuid.New(), limit, BudgetPeriodCustom(), start, nil, false); err != ErrBudgetInvalidDates {
+		t.Errorf("custom budget without end date err %v, want %v", err, ErrBudgetInvalidDates)
+	}
+}
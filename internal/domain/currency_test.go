@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestCurrencyFromString(t *testing.T) {
+	for in, want := range map[string]string{"usd": "usd", " EUR ": "eur", "rur": "rub", "RUB": "rub", "jpy": "jpy"} {
+		c, err := CurrencyFromString(in)
+		if err != nil || c.Val() != want {
+			t.Errorf("CurrencyFromString(%q) = %q, %v, want %q", in, c.Val(), err, want)
+		}
+	}
+
+	for _, in := range []string{"", "xxx", "usdollar", "xau"} {
+		if _, err := CurrencyFromString(in); err != ErrInvalidCurrency {
+			t.Errorf("CurrencyFromString(%q) err %v, want %v", in, err, ErrInvalidCurrency)
+		}
+	}
+}
+
+func TestEnableCurrencies(t *testing.T) {
+	defer EnableCurrencies()
+
+	if err := EnableCurrencies("usd", "xxx"); err != ErrInvalidCurrency {
+		t.Fatalf("EnableCurrencies with unknown code err %v", err)
+	}
+
+	if err := EnableCurrencies("usd", "RUR"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := CurrencyFromString("eur"); err != ErrCurrencyNotEnabled {
+		t.Errorf("disabled currency err %v, want %v", err, ErrCurrencyNotEnabled)
+	}
+	if _, err := CurrencyFromCode("eur"); err != nil {
+		t.Errorf("stored currencies must parse when disabled, err %v", err)
+	}
+	if list := EnabledCurrencies(); len(list) != 2 || list[0].Code != "RUB" || list[1].Code != "USD" {
+		t.Errorf("EnabledCurrencies() = %v", list)
+	}
+}
+
+func TestMoneyMinorUnits(t *testing.T) {
+	cases := []struct {
+		code, in, want string
+	}{
+		{"jpy", "1500", "1500"},
+		{"kwd", "12.5", "12.500"},
+		{"clf", "-0.0001", "-0.0001"},
+	}
+
+	for _, c := range cases {
+		currency, _ := CurrencyFromCode(c.code)
+		m, err := MoneyFromString(c.in, currency)
+		if err != nil || m.String() != c.want {
+			t.Errorf("MoneyFromString(%q, %s) = %s, %v, want %s", c.in, c.code, m.String(), err, c.want)
+		}
+	}
+
+	jpy, _ := CurrencyFromCode("jpy")
+	if _, err := MoneyFromString("10.5", jpy); err != ErrInvalidAmount {
+		t.Errorf("fractional yen err %v, want %v", err, ErrInvalidAmount)
+	}
+
+	rounded, _ := MoneyFromRatRounded(big.NewRat(21, 2), jpy)
+	if rounded.String() != "11" {
+		t.Errorf("rounded yen = %s, want 11", rounded.String())
+	}
+}
@@ -17,11 +17,42 @@ var (
 	ErrCategoryNotFound    = NewError("Category not found")
 	ErrUserNotFound        = NewError("User not found")
 	ErrTransactionNotFound = NewError("Transaction not found")
+	ErrTransactionChanged  = NewError("Transaction was changed meanwhile, reload it and try again")
+	ErrTransferNotFound    = NewError("Transfer not found")
+	ErrBudgetNotFound      = NewError("Budget not found")
+	ErrRecurringNotFound   = NewError("Recurring transaction not found")
+	ErrOccurrenceNotFound  = NewError("Occurrence not found")
 
 	ErrTransactionWalletCurrencyMismatch   = NewError("Transaction and Wallet must have same currency")
 	ErrTransactionCategoryCurrencyMismatch = NewError("Transaction and Category must have same currency")
 
-	ErrInvalidCurrency = NewError("Invalid currency")
+	ErrInvalidCurrency    = NewError("Invalid currency")
+	ErrCurrencyNotEnabled = NewError("Currency is not enabled")
+	ErrCurrencyMismatch   = NewError("Amounts must have same currency")
+	ErrInvalidAmount      = NewError("Invalid amount")
 
 	ErrInvalidTransactionType = NewError("Invalid transaction type")
+
+	ErrTransferSameWallet      = NewError("Transfer source and destination wallets must differ")
+	ErrTransferRateRequired    = NewError("Transfer between different currencies requires rate or received amount")
+	ErrTransferInvalidRate     = NewError("Transfer rate must be positive and match received amount")
+	ErrTransferAlreadyReversed = NewError("Transfer already reversed")
+	ErrTransactionIsTransfer   = NewError("Transaction belongs to a transfer, use the transfer endpoints")
+
+	ErrExchangeRateNotFound = NewError("Exchange rate not found")
+	ErrInvalidExchangeRate  = NewError("Exchange rate must be positive and between different currencies")
+
+	ErrInvalidBudgetPeriod = NewError("Invalid budget period")
+	ErrBudgetInvalidDates  = NewError("Custom budget requires end date after start date")
+	ErrBudgetInvalidLimit  = NewError("Budget limit must be positive")
+
+	ErrInvalidReportInterval = NewError("Invalid report interval")
+	ErrInvalidReportRange    = NewError("Report start must not be after its end")
+	ErrReportRangeTooLarge   = NewError("Report range has too many intervals")
+	ErrInvalidReportGroup    = NewError("Invalid report group")
+	ErrInvalidTimeZone       = NewError("Invalid time zone")
+
+	ErrInvalidSchedule         = NewError("Invalid schedule")
+	ErrInvalidOccurrenceStatus = NewError("Invalid occurrence status")
+	ErrOccurrenceAlreadyPosted = NewError("Occurrence already posted")
 )
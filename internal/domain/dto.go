@@ -7,15 +7,13 @@ import (
 )
 
 type CreateCategoryDto struct {
-	Name     string
-	UserId   uuid.UUID
-	Total    float32
-	Currency Currency
+	Name   string
+	UserId uuid.UUID
+	Total  Money
 }
 
 type CreateTransactionDto struct {
-	Amount    float32
-	Currency  Currency
+	Amount    Money
 	Comment   string
 	Type      TransactionType
 	WalletId  uuid.UUID
@@ -24,10 +22,9 @@ type CreateTransactionDto struct {
 }
 
 type CreateWalletDto struct {
-	Name     string
-	UserId   uuid.UUID
-	Balance  float32
-	Currency Currency
+	Name    string
+	UserId  uuid.UUID
+	Balance Money
 }
 
 type CreateUserDto struct {
@@ -2,23 +2,97 @@ package domain
 
 import (
 	"github.com/google/uuid"
+	"math/big"
 	"time"
 )
 
 type User struct {
-	Id        uuid.UUID
-	Name      string
-	Email     string
-	Password  string
+	Id              uuid.UUID
+	Name            string
+	Email           string
+	Password        string
+	EmailVerifiedAt *time.Time
+	// BaseCurrency is the currency the reports of the user are converted to
+	BaseCurrency Currency
+	// TimeZone is the zone the days of the reports of the user start in
+	TimeZone  *time.Location
 	CreatedAt time.Time
 }
 
 type Budget struct {
 	Id         uuid.UUID
 	Name       string
+	UserId     uuid.UUID
 	CategoryId uuid.UUID
-	Limit      float32
-	Amount     float32
+	Limit      Money
+	Period     BudgetPeriod
+	StartDate  time.Time
+	EndDate    *time.Time
+	Rollover   bool
+	CreatedAt  time.Time
+}
+
+// PeriodAt returns the budget period containing t, clipped to the budget
+// start and end dates. ok is false when t is outside of the budget.
+func (b *Budget) PeriodAt(t time.Time) (start, end time.Time, ok bool) {
+	if t.Before(b.StartDate) {
+		return time.Time{}, time.Time{}, false
+	}
+
+	if b.Period.IsCustom() {
+		if b.EndDate == nil || !t.Before(*b.EndDate) {
+			return time.Time{}, time.Time{}, false
+		}
+
+		return b.StartDate, *b.EndDate, true
+	}
+
+	if b.EndDate != nil && !t.Before(*b.EndDate) {
+		return time.Time{}, time.Time{}, false
+	}
+
+	start, end = b.Period.Bounds(t)
+	if start.Before(b.StartDate) {
+		start = b.StartDate
+	}
+	if b.EndDate != nil && end.After(*b.EndDate) {
+		end = *b.EndDate
+	}
+
+	return start, end, true
+}
+
+type RecurringTransaction struct {
+	Id         uuid.UUID
+	UserId     uuid.UUID
+	WalletId   uuid.UUID
+	CategoryId uuid.UUID
+	Amount     Money
+	Type       TransactionType
+	Comment    string
+	Schedule   Schedule
+	NextDate   *time.Time
+	CreatedAt  time.Time
+}
+
+// RecurringOccurrence keeps the state of a single occurrence that differs
+// from its template: skipped, edited, already posted as a transaction or
+// failed to post for the reason in Error.
+type RecurringOccurrence struct {
+	RecurringId   uuid.UUID
+	Date          time.Time
+	Status        OccurrenceStatus
+	Amount        *Money
+	Comment       *string
+	TransactionId *uuid.UUID
+	Error         *string
+}
+
+// OccurrenceTransactionId is the id of the transaction posted for the
+// occurrence at date. It is derived from the template and the date so posting
+// the same occurrence twice always collides on the same transaction.
+func (r *RecurringTransaction) OccurrenceTransactionId(date time.Time) uuid.UUID {
+	return uuid.NewSHA1(r.Id, []byte(date.UTC().Format("2006-01-02")))
 }
 
 type Category struct {
@@ -32,20 +106,50 @@ type Category struct {
 
 type Transaction struct {
 	Id         uuid.UUID
-	Amount     float32
-	Currency   Currency
+	Amount     Money
 	Comment    string
 	Type       TransactionType
 	WalletId   uuid.UUID
 	UserId     uuid.UUID
 	CategoryId uuid.UUID
 	CreatedAt  time.Time
+	// ExternalId is the id of an imported statement entry, empty for manual transactions
+	ExternalId string
+	// OriginalAmount is the amount entered in a currency other than the wallet's,
+	// Amount is its conversion at Rate. Both are nil for transactions in the wallet currency.
+	OriginalAmount *Money
+	Rate           *big.Rat
+}
+
+// EnteredAmount returns the amount as it was entered, before any conversion to the wallet currency.
+func (t *Transaction) EnteredAmount() Money {
+	if t.OriginalAmount != nil {
+		return *t.OriginalAmount
+	}
+
+	return t.Amount
+}
+
+// ExchangeRate is the price of one Base in Quote on Date. Rates of a user
+// override the global rates loaded from the rate provider.
+type ExchangeRate struct {
+	Id        uuid.UUID
+	UserId    *uuid.UUID
+	Base      Currency
+	Quote     Currency
+	Rate      *big.Rat
+	Date      time.Time
+	Source    string
+	CreatedAt time.Time
 }
 
 type Transfer struct {
 	Id               uuid.UUID
+	UserId           uuid.UUID
 	OutTransactionId uuid.UUID
 	InTransactionId  uuid.UUID
+	Rate             *big.Rat
+	ReversalOfId     *uuid.UUID
 	CreatedAt        time.Time
 }
 
@@ -53,30 +157,102 @@ type Wallet struct {
 	Id        uuid.UUID
 	Name      string
 	UserId    uuid.UUID
-	Balance   float32
-	Currency  Currency
+	Balance   Money
 	CreatedAt time.Time
 }
 
-func (w *Wallet) updateBalance(t *Transaction) error {
+// ApplyTransaction adds an incoming or subtracts an outgoing transaction from the wallet balance.
+func (w *Wallet) ApplyTransaction(t *Transaction) error {
+	if !w.Balance.SameCurrency(t.Amount) {
+		return ErrTransactionWalletCurrencyMismatch
+	}
+
+	var (
+		balance Money
+		err     error
+	)
 	if t.Type.IsIn() {
-		w.Balance += t.Amount
+		balance, err = w.Balance.Add(t.Amount)
 	} else if t.Type.IsOut() {
-		w.Balance -= t.Amount
+		balance, err = w.Balance.Sub(t.Amount)
 	} else {
 		return ErrInvalidTransactionType
 	}
+	if err != nil {
+		return err
+	}
+
+	w.Balance = balance
 
 	return nil
 }
 
+// BalanceChange returns the amount the transaction adds to the wallet balance, negative for out.
+func (t *Transaction) BalanceChange() (Money, error) {
+	if t.Type.IsIn() {
+		return t.Amount, nil
+	} else if t.Type.IsOut() {
+		return ZeroMoney(t.Amount.Currency()).Sub(t.Amount)
+	}
+
+	return Money{}, ErrInvalidTransactionType
+}
+
+// WalletBalanceChanges sums per wallet what applying the applied transactions
+// and reverting the reverted ones changes in the wallet balances.
+func WalletBalanceChanges(applied, reverted []*Transaction) (map[uuid.UUID]Money, error) {
+	changes := map[uuid.UUID]Money{}
+	for i, list := range [][]*Transaction{applied, reverted} {
+		for _, t := range list {
+			change, err := t.BalanceChange()
+			if err != nil {
+				return nil, err
+			}
+			if i == 1 {
+				change, err = ZeroMoney(change.Currency()).Sub(change)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			sum, ok := changes[t.WalletId]
+			if !ok {
+				sum = ZeroMoney(change.Currency())
+			}
+
+			changes[t.WalletId], err = sum.Add(change)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return changes, nil
+}
+
+// RevertTransaction undoes the effect of a previously applied transaction on the wallet balance.
+func (w *Wallet) RevertTransaction(t *Transaction) error {
+	reverted := *t
+	if t.Type.IsIn() {
+		reverted.Type = TransactionTypeOut()
+	} else if t.Type.IsOut() {
+		reverted.Type = TransactionTypeIn()
+	} else {
+		return ErrInvalidTransactionType
+	}
+
+	return w.ApplyTransaction(&reverted)
+}
+
 func NewUser(name, email, password string) *User {
 	return &User{
-		Id:        uuid.New(),
-		Name:      name,
-		Email:     email,
-		Password:  password,
-		CreatedAt: time.Now(),
+		Id:           uuid.New(),
+		Name:         name,
+		Email:        email,
+		Password:     password,
+		BaseCurrency: DefaultCurrency(),
+		TimeZone:     time.UTC,
+		CreatedAt:    time.Now(),
 	}
 }
 
@@ -91,16 +267,102 @@ func NewCategory(name string, currency Currency, userId uuid.UUID) *Category {
 	}
 }
 
-func NewTransaction(comment string, amount float32, currency Currency, transactionType TransactionType, userId, categoryId, walletId uuid.UUID) *Transaction {
+func NewWallet(name string, balance Money, userId uuid.UUID) *Wallet {
+	return &Wallet{
+		Id:        uuid.New(),
+		Name:      name,
+		UserId:    userId,
+		Balance:   balance,
+		CreatedAt: time.Now(),
+	}
+}
+
+func NewTransaction(comment string, amount Money, transactionType TransactionType, userId, categoryId, walletId uuid.UUID) *Transaction {
 	return &Transaction{
 		Id:         uuid.New(),
 		Amount:     amount,
 		Comment:    comment,
-		Currency:   currency,
 		Type:       transactionType,
 		UserId:     userId,
 		CategoryId: categoryId,
 		WalletId:   walletId,
+		CreatedAt:  time.Now().UTC(),
+	}
+}
+
+func NewTransfer(userId uuid.UUID, out, in *Transaction, rate *big.Rat) *Transfer {
+	return &Transfer{
+		Id:               uuid.New(),
+		UserId:           userId,
+		OutTransactionId: out.Id,
+		InTransactionId:  in.Id,
+		Rate:             rate,
+		CreatedAt:        time.Now().UTC(),
+	}
+}
+
+// NewExchangeRate keeps the UTC day of date only, a rate is valid for the whole day.
+func NewExchangeRate(userId *uuid.UUID, base, quote Currency, rate *big.Rat, date time.Time, source string) (*ExchangeRate, error) {
+	if base == quote || rate == nil || rate.Sign() <= 0 {
+		return nil, ErrInvalidExchangeRate
+	}
+	date = date.UTC()
+
+	return &ExchangeRate{
+		Id:        uuid.New(),
+		UserId:    userId,
+		Base:      base,
+		Quote:     quote,
+		Rate:      rate,
+		Date:      time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC),
+		Source:    source,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+func NewBudget(name string, categoryId, userId uuid.UUID, limit Money, period BudgetPeriod, startDate time.Time, endDate *time.Time, rollover bool) (*Budget, error) {
+	if !limit.IsPositive() {
+		return nil, ErrBudgetInvalidLimit
+	}
+
+	if period.IsCustom() && endDate == nil {
+		return nil, ErrBudgetInvalidDates
+	}
+
+	if endDate != nil && !endDate.After(startDate) {
+		return nil, ErrBudgetInvalidDates
+	}
+
+	return &Budget{
+		Id:         uuid.New(),
+		Name:       name,
+		UserId:     userId,
+		CategoryId: categoryId,
+		Limit:      limit,
+		Period:     period,
+		StartDate:  startDate,
+		EndDate:    endDate,
+		Rollover:   rollover,
+		CreatedAt:  time.Now(),
+	}, nil
+}
+
+func NewRecurringTransaction(comment string, amount Money, transactionType TransactionType, schedule Schedule, userId, categoryId, walletId uuid.UUID) *RecurringTransaction {
+	r := &RecurringTransaction{
+		Id:         uuid.New(),
+		UserId:     userId,
+		WalletId:   walletId,
+		CategoryId: categoryId,
+		Amount:     amount,
+		Type:       transactionType,
+		Comment:    comment,
+		Schedule:   schedule,
 		CreatedAt:  time.Now(),
 	}
+
+	if first, ok := schedule.Occurrence(0); ok {
+		r.NextDate = &first
+	}
+
+	return r
 }
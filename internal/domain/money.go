@@ -0,0 +1,222 @@
+package domain
+
+import (
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// decimalPattern is the plain decimal syntax, big.Rat alone would also accept fractions like "1/4" and exponents like "1e3".
+var decimalPattern = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+// Money is an exact amount stored as integer minor units (cents, kopecks)
+// of its currency. Operations on values of different currencies fail with
+// ErrCurrencyMismatch instead of silently mixing them.
+type Money struct {
+	amount   int64
+	currency Currency
+}
+
+func NewMoney(minor int64, currency Currency) Money {
+	return Money{amount: minor, currency: currency}
+}
+
+func ZeroMoney(currency Currency) Money {
+	return Money{amount: 0, currency: currency}
+}
+
+// MoneyFromString parses a decimal string like "-12.5" or "100.25" into Money.
+// More fractional digits than the currency allows is an error, not a rounding.
+func MoneyFromString(val string, currency Currency) (Money, error) {
+	val = strings.TrimSpace(val)
+	if !decimalPattern.MatchString(val) {
+		return Money{}, ErrInvalidAmount
+	}
+
+	rat, ok := new(big.Rat).SetString(val)
+	if !ok {
+		return Money{}, ErrInvalidAmount
+	}
+
+	return MoneyFromRat(rat, currency)
+}
+
+// MoneyFromRat converts an exact rational amount of major units into Money.
+func MoneyFromRat(rat *big.Rat, currency Currency) (Money, error) {
+	minor := new(big.Rat).Mul(rat, new(big.Rat).SetInt(minorFactor(currency)))
+	if !minor.IsInt() || !minor.Num().IsInt64() {
+		return Money{}, ErrInvalidAmount
+	}
+
+	return Money{amount: minor.Num().Int64(), currency: currency}, nil
+}
+
+// MoneyFromRatRounded converts an amount of major units into Money rounding
+// half away from zero to the currency's minor units.
+func MoneyFromRatRounded(rat *big.Rat, currency Currency) (Money, error) {
+	scaled := new(big.Rat).Mul(rat, new(big.Rat).SetInt(minorFactor(currency)))
+
+	num := new(big.Int).Abs(scaled.Num())
+	quo, rem := new(big.Int).QuoRem(num, scaled.Denom(), new(big.Int))
+	if rem.Mul(rem, big.NewInt(2)).Cmp(scaled.Denom()) >= 0 {
+		quo.Add(quo, big.NewInt(1))
+	}
+	if scaled.Sign() < 0 {
+		quo.Neg(quo)
+	}
+
+	if !quo.IsInt64() {
+		return Money{}, ErrInvalidAmount
+	}
+
+	return Money{amount: quo.Int64(), currency: currency}, nil
+}
+
+func minorFactor(c Currency) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(c.MinorUnits())), nil)
+}
+
+func (m Money) Currency() Currency {
+	return m.currency
+}
+
+// Minor returns the amount in minor units of the currency.
+func (m Money) Minor() int64 {
+	return m.amount
+}
+
+// Rat returns the amount in major units as an exact rational number.
+func (m Money) Rat() *big.Rat {
+	return new(big.Rat).SetFrac(big.NewInt(m.amount), minorFactor(m.currency))
+}
+
+func (m Money) IsZero() bool {
+	return m.amount == 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.amount < 0
+}
+
+func (m Money) IsPositive() bool {
+	return m.amount > 0
+}
+
+func (m Money) SameCurrency(o Money) bool {
+	return m.currency.Equals(&o.currency)
+}
+
+func (m Money) Add(o Money) (Money, error) {
+	if !m.SameCurrency(o) {
+		return Money{}, ErrCurrencyMismatch
+	}
+
+	return Money{amount: m.amount + o.amount, currency: m.currency}, nil
+}
+
+func (m Money) Sub(o Money) (Money, error) {
+	if !m.SameCurrency(o) {
+		return Money{}, ErrCurrencyMismatch
+	}
+
+	return Money{amount: m.amount - o.amount, currency: m.currency}, nil
+}
+
+func (m Money) Neg() Money {
+	return Money{amount: -m.amount, currency: m.currency}
+}
+
+func (m Money) Abs() Money {
+	if m.amount < 0 {
+		return m.Neg()
+	}
+
+	return m
+}
+
+// Compare returns -1, 0 or 1 if m is less than, equal to or greater than o.
+func (m Money) Compare(o Money) (int, error) {
+	if !m.SameCurrency(o) {
+		return 0, ErrCurrencyMismatch
+	}
+
+	switch {
+	case m.amount < o.amount:
+		return -1, nil
+	case m.amount > o.amount:
+		return 1, nil
+	}
+
+	return 0, nil
+}
+
+func (m Money) Equals(o Money) bool {
+	return m.SameCurrency(o) && m.amount == o.amount
+}
+
+// Allocate splits m between the given ratios without losing minor units:
+// the remainder is handed out one unit at a time starting from the first share.
+// The shares are computed with big.Int, large amounts or ratios cannot overflow.
+func (m Money) Allocate(ratios ...int) ([]Money, error) {
+	total := new(big.Int)
+	for _, r := range ratios {
+		if r < 0 {
+			return nil, ErrInvalidAmount
+		}
+		total.Add(total, big.NewInt(int64(r)))
+	}
+
+	if total.Sign() == 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	amount := big.NewInt(m.amount)
+	shares := make([]Money, len(ratios))
+	remainder := m.amount
+	for i, r := range ratios {
+		// no share is larger than the amount, it always fits int64
+		share := new(big.Int).Mul(amount, big.NewInt(int64(r)))
+		share.Quo(share, total)
+		if !share.IsInt64() {
+			return nil, ErrInvalidAmount
+		}
+		shares[i] = Money{amount: share.Int64(), currency: m.currency}
+		remainder -= share.Int64()
+	}
+
+	step := int64(1)
+	if remainder < 0 {
+		step = -1
+	}
+	for i := 0; remainder != 0; i = (i + 1) % len(shares) {
+		if ratios[i] == 0 {
+			continue
+		}
+		shares[i].amount += step
+		remainder -= step
+	}
+
+	return shares, nil
+}
+
+// String renders the amount as a plain decimal with the currency's minor digits, e.g. "-12.50".
+func (m Money) String() string {
+	digits := m.currency.MinorUnits()
+	abs := m.amount
+	sign := ""
+	if abs < 0 {
+		sign = "-"
+		abs = -abs
+	}
+
+	s := new(big.Int).SetInt64(abs).String()
+	if digits == 0 {
+		return sign + s
+	}
+
+	if len(s) <= digits {
+		s = strings.Repeat("0", digits-len(s)+1) + s
+	}
+
+	return sign + s[:len(s)-digits] + "." + s[len(s)-digits:]
+}
@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMoneyFromString(t *testing.T) {
+	cases := map[string]string{
+		"0":       "0.00",
+		"12.5":    "12.50",
+		"-0.01":   "-0.01",
+		"1000.10": "1000.10",
+	}
+
+	for in, want := range cases {
+		m, err := MoneyFromString(in, CurrencyUSD())
+		if err != nil {
+			t.Errorf("MoneyFromString(%q) err %v", in, err)
+			continue
+		}
+		if m.String() != want {
+			t.Errorf("MoneyFromString(%q) = %s, want %s", in, m.String(), want)
+		}
+	}
+
+	for _, in := range []string{"", "abc", "0.001", "1/4", "1e3", "0x10", ".5", "1."} {
+		if _, err := MoneyFromString(in, CurrencyUSD()); err != ErrInvalidAmount {
+			t.Errorf("MoneyFromString(%q) err %v, want %v", in, err, ErrInvalidAmount)
+		}
+	}
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	a, _ := MoneyFromString("0.10", CurrencyEUR())
+	sum := ZeroMoney(CurrencyEUR())
+	for i := 0; i < 1000; i++ {
+		sum, _ = sum.Add(a)
+	}
+	if sum.String() != "100.00" {
+		t.Errorf("sum = %s, want 100.00", sum.String())
+	}
+
+	if _, err := sum.Add(ZeroMoney(CurrencyUSD())); err != ErrCurrencyMismatch {
+		t.Errorf("Add with other currency err %v, want %v", err, ErrCurrencyMismatch)
+	}
+}
+
+func TestMoneyAllocate(t *testing.T) {
+	m, _ := MoneyFromString("100.00", CurrencyUSD())
+	shares, err := m.Allocate(1, 1, 1)
+	if err != nil {
+		t.Fatalf("Allocate err %v", err)
+	}
+
+	want := []string{"33.34", "33.33", "33.33"}
+	for i, s := range shares {
+		if s.String() != want[i] {
+			t.Errorf("share %d = %s, want %s", i, s.String(), want[i])
+		}
+	}
+
+	// the products of the amount and the ratios do not fit int64
+	large := NewMoney(math.MaxInt64, CurrencyUSD())
+	shares, err = large.Allocate(math.MaxInt32, math.MaxInt32)
+	if err != nil {
+		t.Fatalf("Allocate err %v", err)
+	}
+	if shares[0].Minor() != math.MaxInt64/2+1 || shares[1].Minor() != math.MaxInt64/2 {
+		t.Errorf("large shares = %d, %d", shares[0].Minor(), shares[1].Minor())
+	}
+}
@@ -0,0 +1,136 @@
+package domain
+
+import "time"
+
+// MAX_SCHEDULE_ITERATIONS guards schedule walks against unbounded loops.
+const MAX_SCHEDULE_ITERATIONS = 100000
+
+// Schedule describes a simplified RRULE: every Interval days, weeks or months
+// from StartDate, limited by EndDate (exclusive) and/or Count occurrences.
+// Monthly schedules fall on DayOfMonth, moved to the last day of shorter months.
+type Schedule struct {
+	Frequency  Frequency
+	Interval   int
+	DayOfMonth int
+	StartDate  time.Time
+	EndDate    *time.Time
+	Count      *int
+}
+
+func NewSchedule(frequency Frequency, interval, dayOfMonth int, startDate time.Time, endDate *time.Time, count *int) (Schedule, error) {
+	startDate = truncateToDay(startDate)
+
+	if interval <= 0 {
+		return Schedule{}, ErrInvalidSchedule
+	}
+
+	if frequency.IsMonthly() {
+		if dayOfMonth == 0 {
+			dayOfMonth = startDate.Day()
+		}
+		if dayOfMonth < 1 || dayOfMonth > 31 {
+			return Schedule{}, ErrInvalidSchedule
+		}
+	} else {
+		dayOfMonth = 0
+	}
+
+	if endDate != nil && !endDate.After(startDate) {
+		return Schedule{}, ErrInvalidSchedule
+	}
+
+	if count != nil && *count <= 0 {
+		return Schedule{}, ErrInvalidSchedule
+	}
+
+	return Schedule{
+		Frequency:  frequency,
+		Interval:   interval,
+		DayOfMonth: dayOfMonth,
+		StartDate:  startDate,
+		EndDate:    endDate,
+		Count:      count,
+	}, nil
+}
+
+// Occurrence returns the n-th (zero based) date of the schedule, ok is false
+// when the schedule has ended before it.
+func (s Schedule) Occurrence(n int) (date time.Time, ok bool) {
+	if n < 0 || (s.Count != nil && n >= *s.Count) {
+		return time.Time{}, false
+	}
+
+	switch {
+	case s.Frequency.IsDaily():
+		date = s.StartDate.AddDate(0, 0, n*s.Interval)
+	case s.Frequency.IsWeekly():
+		date = s.StartDate.AddDate(0, 0, 7*n*s.Interval)
+	default:
+		// when the start date is past the chosen day the first month is skipped
+		if s.monthlyDate(0).Before(s.StartDate) {
+			n++
+		}
+		date = s.monthlyDate(n * s.Interval)
+	}
+
+	if s.EndDate != nil && !date.Before(*s.EndDate) {
+		return time.Time{}, false
+	}
+
+	return date, true
+}
+
+func (s Schedule) monthlyDate(months int) time.Time {
+	month := time.Date(s.StartDate.Year(), s.StartDate.Month(), 1, 0, 0, 0, 0, s.StartDate.Location()).AddDate(0, months, 0)
+
+	day := s.DayOfMonth
+	if last := month.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+
+	return month.AddDate(0, 0, day-1)
+}
+
+// Between returns up to limit occurrences within [from, to].
+func (s Schedule) Between(from, to time.Time, limit int) []time.Time {
+	list := []time.Time{}
+	for n := 0; n < MAX_SCHEDULE_ITERATIONS && len(list) < limit; n++ {
+		date, ok := s.Occurrence(n)
+		if !ok || date.After(to) {
+			break
+		}
+
+		if !date.Before(from) {
+			list = append(list, date)
+		}
+	}
+
+	return list
+}
+
+// NextAfter returns the first occurrence strictly after t.
+func (s Schedule) NextAfter(t time.Time) (time.Time, bool) {
+	for n := 0; n < MAX_SCHEDULE_ITERATIONS; n++ {
+		date, ok := s.Occurrence(n)
+		if !ok {
+			return time.Time{}, false
+		}
+
+		if date.After(t) {
+			return date, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// Includes reports whether date is one of the schedule occurrences.
+func (s Schedule) Includes(date time.Time) bool {
+	dates := s.Between(date, date, 1)
+
+	return len(dates) == 1 && dates[0].Equal(date)
+}
+
+func truncateToDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleMonthly(t *testing.T) {
+	count := 4
+	start := time.Date(2022, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	schedule, err := NewSchedule(FrequencyMonthly(), 1, 31, start, nil, &count)
+	if err != nil {
+		t.Fatalf("NewSchedule err %v", err)
+	}
+
+	want := []time.Time{
+		time.Date(2022, 1, 31, 0, 0, 0, 0, time.UTC),
+		time.Date(2022, 2, 28, 0, 0, 0, 0, time.UTC),
+		time.Date(2022, 3, 31, 0, 0, 0, 0, time.UTC),
+		time.Date(2022, 4, 30, 0, 0, 0, 0, time.UTC),
+	}
+
+	got := schedule.Between(start, start.AddDate(1, 0, 0), 10)
+	if len(got) != len(want) {
+		t.Fatalf("Between returned %d dates, want %d", len(got), len(want))
+	}
+
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Errorf("occurrence %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	if next, ok := schedule.NextAfter(want[3]); ok {
+		t.Errorf("NextAfter last occurrence = %v, want none", next)
+	}
+}
+
+func TestScheduleWeeklyEndDate(t *testing.T) {
+	start := time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2022, 3, 29, 0, 0, 0, 0, time.UTC)
+
+	schedule, err := NewSchedule(FrequencyWeekly(), 2, 0, start, &end, nil)
+	if err != nil {
+		t.Fatalf("NewSchedule err %v", err)
+	}
+
+	got := schedule.Between(start, end, 10)
+	if len(got) != 2 || !got[1].Equal(time.Date(2022, 3, 15, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Between = %v", got)
+	}
+
+	if !schedule.Includes(time.Date(2022, 3, 15, 0, 0, 0, 0, time.UTC)) || schedule.Includes(time.Date(2022, 3, 8, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Includes mismatch")
+	}
+}
@@ -1,51 +1,35 @@
 package domain
 
-import "strings"
-
-const currencyRUR = "rur"
-const currencyEUR = "eur"
-const currencyUSD = "usd"
+import (
+	"strings"
+	"time"
+)
 
 const transactionIn = "in"
 const transactionOut = "out"
 
-type Currency struct {
-	value string
-}
+const budgetPeriodWeekly = "weekly"
+const budgetPeriodMonthly = "monthly"
+const budgetPeriodYearly = "yearly"
+const budgetPeriodCustom = "custom"
 
-func CurrencyRUR() Currency {
-	return Currency{value: currencyRUR}
-}
+const frequencyDaily = "daily"
+const frequencyWeekly = "weekly"
+const frequencyMonthly = "monthly"
 
-func CurrencyUSD() Currency {
-	return Currency{value: currencyUSD}
-}
+const occurrenceScheduled = "scheduled"
+const occurrenceSkipped = "skipped"
+const occurrencePosted = "posted"
+const occurrenceFailed = "failed"
 
-func CurrencyEUR() Currency {
-	return Currency{value: currencyEUR}
-}
-
-func (c *Currency) Equals(cur *Currency) bool {
-	return c.value == cur.value
-}
+const reportIntervalDay = "day"
+const reportIntervalWeek = "week"
+const reportIntervalMonth = "month"
+const reportIntervalQuarter = "quarter"
+const reportIntervalYear = "year"
 
-func (c *Currency) Val() string {
-	return c.value
-}
-
-func CurrencyFromString(val string) (Currency, error) {
-	val = strings.ToLower(val)
-
-	if val == currencyRUR {
-		return CurrencyRUR(), nil
-	} else if val == currencyEUR {
-		return CurrencyEUR(), nil
-	} else if val == currencyUSD {
-		return CurrencyUSD(), nil
-	}
-
-	return Currency{}, ErrInvalidCurrency
-}
+const reportGroupWallet = "wallet"
+const reportGroupCategory = "category"
 
 type TransactionType struct {
 	value string
@@ -86,3 +70,285 @@ func TransactionTypeFromString(val string) (TransactionType, error) {
 
 	return TransactionType{}, ErrInvalidTransactionType
 }
+
+type BudgetPeriod struct {
+	value string
+}
+
+func BudgetPeriodWeekly() BudgetPeriod {
+	return BudgetPeriod{value: budgetPeriodWeekly}
+}
+
+func BudgetPeriodMonthly() BudgetPeriod {
+	return BudgetPeriod{value: budgetPeriodMonthly}
+}
+
+func BudgetPeriodYearly() BudgetPeriod {
+	return BudgetPeriod{value: budgetPeriodYearly}
+}
+
+func BudgetPeriodCustom() BudgetPeriod {
+	return BudgetPeriod{value: budgetPeriodCustom}
+}
+
+func (p BudgetPeriod) Val() string {
+	return p.value
+}
+
+func (p BudgetPeriod) IsCustom() bool {
+	return p.value == budgetPeriodCustom
+}
+
+func (p BudgetPeriod) Equals(ep *BudgetPeriod) bool {
+	return p.value == ep.value
+}
+
+// Bounds returns the calendar period containing t: an ISO week starting on
+// Monday, a month or a year. The end is exclusive.
+func (p BudgetPeriod) Bounds(t time.Time) (start, end time.Time) {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+
+	switch p.value {
+	case budgetPeriodWeekly:
+		offset := (int(day.Weekday()) + 6) % 7
+		start = day.AddDate(0, 0, -offset)
+		return start, start.AddDate(0, 0, 7)
+	case budgetPeriodYearly:
+		start = time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location())
+		return start, start.AddDate(1, 0, 0)
+	}
+
+	start = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	return start, start.AddDate(0, 1, 0)
+}
+
+func BudgetPeriodFromString(val string) (BudgetPeriod, error) {
+	val = strings.ToLower(val)
+
+	switch val {
+	case budgetPeriodWeekly:
+		return BudgetPeriodWeekly(), nil
+	case budgetPeriodMonthly:
+		return BudgetPeriodMonthly(), nil
+	case budgetPeriodYearly:
+		return BudgetPeriodYearly(), nil
+	case budgetPeriodCustom:
+		return BudgetPeriodCustom(), nil
+	}
+
+	return BudgetPeriod{}, ErrInvalidBudgetPeriod
+}
+
+type Frequency struct {
+	value string
+}
+
+func FrequencyDaily() Frequency {
+	return Frequency{value: frequencyDaily}
+}
+
+func FrequencyWeekly() Frequency {
+	return Frequency{value: frequencyWeekly}
+}
+
+func FrequencyMonthly() Frequency {
+	return Frequency{value: frequencyMonthly}
+}
+
+func (f Frequency) Val() string {
+	return f.value
+}
+
+func (f Frequency) IsDaily() bool {
+	return f.value == frequencyDaily
+}
+
+func (f Frequency) IsWeekly() bool {
+	return f.value == frequencyWeekly
+}
+
+func (f Frequency) IsMonthly() bool {
+	return f.value == frequencyMonthly
+}
+
+func FrequencyFromString(val string) (Frequency, error) {
+	val = strings.ToLower(val)
+
+	switch val {
+	case frequencyDaily:
+		return FrequencyDaily(), nil
+	case frequencyWeekly:
+		return FrequencyWeekly(), nil
+	case frequencyMonthly:
+		return FrequencyMonthly(), nil
+	}
+
+	return Frequency{}, ErrInvalidSchedule
+}
+
+type OccurrenceStatus struct {
+	value string
+}
+
+func OccurrenceScheduled() OccurrenceStatus {
+	return OccurrenceStatus{value: occurrenceScheduled}
+}
+
+func OccurrenceSkipped() OccurrenceStatus {
+	return OccurrenceStatus{value: occurrenceSkipped}
+}
+
+func OccurrencePosted() OccurrenceStatus {
+	return OccurrenceStatus{value: occurrencePosted}
+}
+
+func OccurrenceFailed() OccurrenceStatus {
+	return OccurrenceStatus{value: occurrenceFailed}
+}
+
+func (s OccurrenceStatus) Val() string {
+	return s.value
+}
+
+func (s OccurrenceStatus) IsScheduled() bool {
+	return s.value == occurrenceScheduled
+}
+
+func (s OccurrenceStatus) IsSkipped() bool {
+	return s.value == occurrenceSkipped
+}
+
+func (s OccurrenceStatus) IsPosted() bool {
+	return s.value == occurrencePosted
+}
+
+func (s OccurrenceStatus) IsFailed() bool {
+	return s.value == occurrenceFailed
+}
+
+func OccurrenceStatusFromString(val string) (OccurrenceStatus, error) {
+	switch strings.ToLower(val) {
+	case occurrenceScheduled:
+		return OccurrenceScheduled(), nil
+	case occurrenceSkipped:
+		return OccurrenceSkipped(), nil
+	case occurrencePosted:
+		return OccurrencePosted(), nil
+	case occurrenceFailed:
+		return OccurrenceFailed(), nil
+	}
+
+	return OccurrenceStatus{}, ErrInvalidOccurrenceStatus
+}
+
+type ReportInterval struct {
+	value string
+}
+
+func ReportIntervalDay() ReportInterval {
+	return ReportInterval{value: reportIntervalDay}
+}
+
+func ReportIntervalWeek() ReportInterval {
+	return ReportInterval{value: reportIntervalWeek}
+}
+
+func ReportIntervalMonth() ReportInterval {
+	return ReportInterval{value: reportIntervalMonth}
+}
+
+func ReportIntervalQuarter() ReportInterval {
+	return ReportInterval{value: reportIntervalQuarter}
+}
+
+func ReportIntervalYear() ReportInterval {
+	return ReportInterval{value: reportIntervalYear}
+}
+
+func (i ReportInterval) Val() string {
+	return i.value
+}
+
+// Bounds returns the interval containing t in the location of t: a day, an
+// ISO week starting on Monday, a month, a quarter or a year. The end is exclusive.
+func (i ReportInterval) Bounds(t time.Time) (start, end time.Time) {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+
+	switch i.value {
+	case reportIntervalDay:
+		return day, day.AddDate(0, 0, 1)
+	case reportIntervalWeek:
+		offset := (int(day.Weekday()) + 6) % 7
+		start = day.AddDate(0, 0, -offset)
+		return start, start.AddDate(0, 0, 7)
+	case reportIntervalQuarter:
+		start = time.Date(t.Year(), (t.Month()-1)/3*3+1, 1, 0, 0, 0, 0, t.Location())
+		return start, start.AddDate(0, 3, 0)
+	case reportIntervalYear:
+		start = time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location())
+		return start, start.AddDate(1, 0, 0)
+	}
+
+	start = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	return start, start.AddDate(0, 1, 0)
+}
+
+func ReportIntervalFromString(val string) (ReportInterval, error) {
+	switch strings.ToLower(val) {
+	case reportIntervalDay:
+		return ReportIntervalDay(), nil
+	case reportIntervalWeek:
+		return ReportIntervalWeek(), nil
+	case reportIntervalMonth:
+		return ReportIntervalMonth(), nil
+	case reportIntervalQuarter:
+		return ReportIntervalQuarter(), nil
+	case reportIntervalYear:
+		return ReportIntervalYear(), nil
+	}
+
+	return ReportInterval{}, ErrInvalidReportInterval
+}
+
+// ReportGroup is what the amounts of a report series are split by.
+type ReportGroup struct {
+	value string
+}
+
+func ReportGroupWallet() ReportGroup {
+	return ReportGroup{value: reportGroupWallet}
+}
+
+func ReportGroupCategory() ReportGroup {
+	return ReportGroup{value: reportGroupCategory}
+}
+
+func (g ReportGroup) Val() string {
+	return g.value
+}
+
+func ReportGroupFromString(val string) (ReportGroup, error) {
+	switch strings.ToLower(val) {
+	case reportGroupWallet:
+		return ReportGroupWallet(), nil
+	case reportGroupCategory:
+		return ReportGroupCategory(), nil
+	}
+
+	return ReportGroup{}, ErrInvalidReportGroup
+}
+
+// TimeZoneFromString loads an IANA time zone such as Europe/Berlin. The
+// zone of the server is not a valid zone for a user.
+func TimeZoneFromString(val string) (*time.Location, error) {
+	if val == "" || val == "Local" {
+		return nil, ErrInvalidTimeZone
+	}
+
+	loc, err := time.LoadLocation(val)
+	if err != nil {
+		return nil, ErrInvalidTimeZone
+	}
+
+	return loc, nil
+}
@@ -0,0 +1,99 @@
+package export
+
+import (
+	"encoding/csv"
+	"github.com/IMBgl/go-wallet-api/internal/domain"
+	"github.com/google/uuid"
+	"io"
+)
+
+type csvWriter struct {
+	w          *csv.Writer
+	entity     string
+	wallets    map[uuid.UUID]string
+	categories map[uuid.UUID][]string
+}
+
+// NewCsvWriter writes one entity per file: transactions, wallets or categories.
+func NewCsvWriter(w io.Writer, entity string) (Writer, error) {
+	switch entity {
+	case "":
+		entity = ENTITY_TRANSACTIONS
+	case ENTITY_TRANSACTIONS, ENTITY_WALLETS, ENTITY_CATEGORIES:
+	default:
+		return nil, ErrUnsupportedEntity
+	}
+
+	return &csvWriter{w: csv.NewWriter(w), entity: entity}, nil
+}
+
+func (c *csvWriter) WriteHeader(d *Data) error {
+	c.wallets = walletNames(d.Wallets)
+	c.categories = categoryPaths(d.Categories)
+
+	switch c.entity {
+	case ENTITY_WALLETS:
+		err := c.w.Write([]string{"id", "name", "balance", "currency", "created_at"})
+		if err != nil {
+			return err
+		}
+
+		for _, w := range d.exportedWallets() {
+			err = c.w.Write([]string{w.Id.String(), w.Name, w.Balance.String(), w.Balance.Currency().Val(), w.CreatedAt.Format(dateTimeFormat)})
+			if err != nil {
+				return err
+			}
+		}
+	case ENTITY_CATEGORIES:
+		err := c.w.Write([]string{"id", "name", "parent_id", "path", "created_at"})
+		if err != nil {
+			return err
+		}
+
+		for _, category := range d.Categories {
+			parentId := ""
+			if category.ParentId != nil {
+				parentId = category.ParentId.String()
+			}
+
+			err = c.w.Write([]string{category.Id.String(), category.Name, parentId, joinPath(c.categories[category.Id], "/"), category.CreatedAt.Format(dateTimeFormat)})
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		return c.w.Write([]string{"id", "date", "wallet_id", "wallet", "category_id", "category", "type", "amount", "currency", "comment"})
+	}
+
+	return c.w.Error()
+}
+
+func (c *csvWriter) WriteTransaction(t *domain.Transaction) error {
+	if c.entity != ENTITY_TRANSACTIONS {
+		return nil
+	}
+
+	categoryId := ""
+	if t.CategoryId != uuid.Nil {
+		categoryId = t.CategoryId.String()
+	}
+
+	return c.w.Write([]string{
+		t.Id.String(),
+		t.CreatedAt.Format(dateTimeFormat),
+		t.WalletId.String(),
+		c.wallets[t.WalletId],
+		categoryId,
+		joinPath(c.categories[t.CategoryId], "/"),
+		t.Type.Val(),
+		t.Amount.String(),
+		t.Amount.Currency().Val(),
+		t.Comment,
+	})
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+
+	return c.w.Error()
+}
@@ -0,0 +1,118 @@
+// Package export writes wallets, categories and transactions of a user to
+// formats understood by spreadsheets and accounting tools.
+package export
+
+import (
+	"errors"
+	"github.com/IMBgl/go-wallet-api/internal/domain"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+const (
+	FORMAT_CSV    = "csv"
+	FORMAT_JSON   = "json"
+	FORMAT_LEDGER = "ledger"
+)
+
+const (
+	ENTITY_TRANSACTIONS = "transactions"
+	ENTITY_WALLETS      = "wallets"
+	ENTITY_CATEGORIES   = "categories"
+)
+
+var ErrUnsupportedFormat = errors.New("export: unsupported format")
+var ErrUnsupportedEntity = errors.New("export: unsupported entity")
+
+// Data holds everything an export refers to besides the transactions, which
+// are streamed one by one. Wallets contains every wallet of the user so
+// transfers to wallets outside of WalletId can still be named.
+type Data struct {
+	ExportedAt time.Time
+	WalletId   *uuid.UUID
+	Wallets    []*domain.Wallet
+	Categories []*domain.Category
+	Transfers  []*domain.Transfer
+}
+
+// Writer receives the export header first, then every transaction ordered by date.
+type Writer interface {
+	WriteHeader(d *Data) error
+	WriteTransaction(t *domain.Transaction) error
+	Close() error
+}
+
+// ContentType returns the MIME type of an export format.
+func ContentType(format string) string {
+	switch format {
+	case FORMAT_CSV:
+		return "text/csv; charset=utf-8"
+	case FORMAT_JSON:
+		return "application/json"
+	default:
+		return "text/plain; charset=utf-8"
+	}
+}
+
+// FileExtension returns the file name extension of an export format.
+func FileExtension(format string) string {
+	if format == FORMAT_LEDGER {
+		return "journal"
+	}
+
+	return format
+}
+
+// exportedWallets returns the wallets selected by the wallet filter.
+func (d *Data) exportedWallets() []*domain.Wallet {
+	if d.WalletId == nil {
+		return d.Wallets
+	}
+
+	for _, w := range d.Wallets {
+		if w.Id == *d.WalletId {
+			return []*domain.Wallet{w}
+		}
+	}
+
+	return []*domain.Wallet{}
+}
+
+func walletNames(wallets []*domain.Wallet) map[uuid.UUID]string {
+	names := map[uuid.UUID]string{}
+	for _, w := range wallets {
+		names[w.Id] = w.Name
+	}
+
+	return names
+}
+
+// categoryPaths returns the full path of every category from the root, e.g. ["Food", "Coffee"].
+func categoryPaths(categories []*domain.Category) map[uuid.UUID][]string {
+	byId := map[uuid.UUID]*domain.Category{}
+	for _, c := range categories {
+		byId[c.Id] = c
+	}
+
+	paths := map[uuid.UUID][]string{}
+	for _, c := range categories {
+		var path []string
+		seen := map[uuid.UUID]bool{}
+		for current := c; current != nil && !seen[current.Id]; {
+			seen[current.Id] = true
+			path = append([]string{current.Name}, path...)
+			if current.ParentId == nil {
+				break
+			}
+			current = byId[*current.ParentId]
+		}
+		paths[c.Id] = path
+	}
+
+	return paths
+}
+
+func joinPath(path []string, separator string) string {
+	return strings.Join(path, separator)
+}
@@ -0,0 +1,163 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/IMBgl/go-wallet-api/internal/domain"
+	"github.com/google/uuid"
+	"io"
+)
+
+const dateTimeFormat = "2006-01-02 15:04:05"
+
+type jsonWriter struct {
+	w     *bufio.Writer
+	count int
+}
+
+type jsonWallet struct {
+	Id        string      `json:"id"`
+	Name      string      `json:"name"`
+	Balance   json.Number `json:"balance"`
+	Currency  string      `json:"currency"`
+	CreatedAt string      `json:"createdAt"`
+}
+
+type jsonCategory struct {
+	Id        string   `json:"id"`
+	Name      string   `json:"name"`
+	ParentId  *string  `json:"parentId"`
+	Path      []string `json:"path"`
+	CreatedAt string   `json:"createdAt"`
+}
+
+type jsonTransfer struct {
+	Id               string       `json:"id"`
+	OutTransactionId string       `json:"outTransactionId"`
+	InTransactionId  string       `json:"inTransactionId"`
+	Rate             *json.Number `json:"rate"`
+	ReversalOfId     *string      `json:"reversalOfId"`
+	CreatedAt        string       `json:"createdAt"`
+}
+
+type jsonTransaction struct {
+	Id         string      `json:"id"`
+	Date       string      `json:"date"`
+	WalletId   string      `json:"walletId"`
+	CategoryId *string     `json:"categoryId"`
+	Type       string      `json:"type"`
+	Amount     json.Number `json:"amount"`
+	Currency   string      `json:"currency"`
+	Comment    string      `json:"comment"`
+	ExternalId string      `json:"externalId,omitempty"`
+}
+
+// NewJsonWriter writes a single JSON document with all exported data. The
+// transactions array is written incrementally so the archive is never held in memory.
+func NewJsonWriter(w io.Writer) Writer {
+	return &jsonWriter{w: bufio.NewWriter(w)}
+}
+
+func (j *jsonWriter) WriteHeader(d *Data) error {
+	paths := categoryPaths(d.Categories)
+
+	wallets := []*jsonWallet{}
+	for _, w := range d.exportedWallets() {
+		wallets = append(wallets, &jsonWallet{
+			Id:        w.Id.String(),
+			Name:      w.Name,
+			Balance:   json.Number(w.Balance.String()),
+			Currency:  w.Balance.Currency().Val(),
+			CreatedAt: w.CreatedAt.Format(dateTimeFormat),
+		})
+	}
+
+	categories := []*jsonCategory{}
+	for _, c := range d.Categories {
+		category := &jsonCategory{Id: c.Id.String(), Name: c.Name, Path: paths[c.Id], CreatedAt: c.CreatedAt.Format(dateTimeFormat)}
+		if c.ParentId != nil {
+			parentId := c.ParentId.String()
+			category.ParentId = &parentId
+		}
+		categories = append(categories, category)
+	}
+
+	transfers := []*jsonTransfer{}
+	for _, t := range d.Transfers {
+		transfer := &jsonTransfer{
+			Id:               t.Id.String(),
+			OutTransactionId: t.OutTransactionId.String(),
+			InTransactionId:  t.InTransactionId.String(),
+			CreatedAt:        t.CreatedAt.Format(dateTimeFormat),
+		}
+		if t.Rate != nil {
+			rate := json.Number(t.Rate.FloatString(10))
+			transfer.Rate = &rate
+		}
+		if t.ReversalOfId != nil {
+			reversalOfId := t.ReversalOfId.String()
+			transfer.ReversalOfId = &reversalOfId
+		}
+		transfers = append(transfers, transfer)
+	}
+
+	header := []struct {
+		key   string
+		value interface{}
+	}{
+		{"exportedAt", d.ExportedAt.Format(dateTimeFormat)},
+		{"wallets", wallets},
+		{"categories", categories},
+		{"transfers", transfers},
+	}
+
+	j.w.WriteString("{")
+	for _, field := range header {
+		value, err := json.Marshal(field.value)
+		if err != nil {
+			return err
+		}
+		j.w.WriteString(`"` + field.key + `":`)
+		j.w.Write(value)
+		j.w.WriteString(",")
+	}
+	_, err := j.w.WriteString(`"transactions":[`)
+
+	return err
+}
+
+func (j *jsonWriter) WriteTransaction(t *domain.Transaction) error {
+	transaction := &jsonTransaction{
+		Id:         t.Id.String(),
+		Date:       t.CreatedAt.Format(dateTimeFormat),
+		WalletId:   t.WalletId.String(),
+		Type:       t.Type.Val(),
+		Amount:     json.Number(t.Amount.String()),
+		Currency:   t.Amount.Currency().Val(),
+		Comment:    t.Comment,
+		ExternalId: t.ExternalId,
+	}
+	if t.CategoryId != uuid.Nil {
+		categoryId := t.CategoryId.String()
+		transaction.CategoryId = &categoryId
+	}
+
+	value, err := json.Marshal(transaction)
+	if err != nil {
+		return err
+	}
+
+	if j.count > 0 {
+		j.w.WriteString(",")
+	}
+	j.count++
+	_, err = j.w.Write(value)
+
+	return err
+}
+
+func (j *jsonWriter) Close() error {
+	j.w.WriteString("]}\n")
+
+	return j.w.Flush()
+}
@@ -0,0 +1,190 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/IMBgl/go-wallet-api/internal/domain"
+	"github.com/google/uuid"
+	"io"
+	"strings"
+)
+
+const (
+	ledgerAssets        = "Assets"
+	ledgerExpenses      = "Expenses"
+	ledgerIncome        = "Income"
+	ledgerUncategorized = "Uncategorized"
+)
+
+type ledgerWriter struct {
+	w          *bufio.Writer
+	wallets    map[uuid.UUID]string
+	categories map[uuid.UUID][]string
+	transfers  map[uuid.UUID]*domain.Transfer
+	// pending keeps the first leg of a transfer until the second one arrives
+	pending map[uuid.UUID]*domain.Transaction
+	order   []uuid.UUID
+}
+
+// NewLedgerWriter writes a ledger-cli journal, also readable by hledger.
+// Wallets are asset accounts, categories are expense or income accounts and
+// both legs of a transfer are written as one entry.
+func NewLedgerWriter(w io.Writer) Writer {
+	return &ledgerWriter{w: bufio.NewWriter(w)}
+}
+
+func (l *ledgerWriter) WriteHeader(d *Data) error {
+	l.wallets = walletNames(d.Wallets)
+	l.categories = categoryPaths(d.Categories)
+	l.transfers = map[uuid.UUID]*domain.Transfer{}
+	l.pending = map[uuid.UUID]*domain.Transaction{}
+
+	for _, t := range d.Transfers {
+		l.transfers[t.OutTransactionId] = t
+		l.transfers[t.InTransactionId] = t
+	}
+
+	fmt.Fprintf(l.w, "; exported at %s\n\n", d.ExportedAt.Format(dateTimeFormat))
+	for _, w := range d.exportedWallets() {
+		fmt.Fprintf(l.w, "account %s\n", l.walletAccount(w.Id))
+	}
+	_, err := l.w.WriteString("\n")
+
+	return err
+}
+
+func (l *ledgerWriter) WriteTransaction(t *domain.Transaction) error {
+	transfer, ok := l.transfers[t.Id]
+	if !ok {
+		return l.writeEntry(t, []ledgerPosting{
+			{account: l.categoryAccount(t), amount: ledgerAmount(t.Amount, t.Type.IsIn())},
+			{account: l.walletAccount(t.WalletId), amount: ledgerAmount(t.Amount, t.Type.IsOut())},
+		})
+	}
+
+	first, ok := l.pending[transfer.Id]
+	if !ok {
+		l.pending[transfer.Id] = t
+		l.order = append(l.order, transfer.Id)
+		return nil
+	}
+	delete(l.pending, transfer.Id)
+
+	out, in := first, t
+	if first.Id != transfer.OutTransactionId {
+		out, in = t, first
+	}
+
+	postings := []ledgerPosting{
+		{account: l.walletAccount(in.WalletId), amount: ledgerAmount(in.Amount, false)},
+		{account: l.walletAccount(out.WalletId), amount: ledgerAmount(out.Amount, true)},
+	}
+	if !in.Amount.SameCurrency(out.Amount) {
+		// the total price lets ledger balance an entry in two commodities
+		postings[0].amount += " @@ " + ledgerAmount(out.Amount, false)
+	}
+
+	return l.writeEntry(out, postings)
+}
+
+// Close writes transfer legs whose counterpart was filtered out of the export.
+func (l *ledgerWriter) Close() error {
+	for _, transferId := range l.order {
+		t, ok := l.pending[transferId]
+		if !ok {
+			continue
+		}
+
+		transfer := l.transfers[t.Id]
+		other := transfer.InTransactionId
+		if t.Id == transfer.InTransactionId {
+			other = transfer.OutTransactionId
+		}
+
+		err := l.writeEntry(t, []ledgerPosting{
+			{account: l.walletAccount(t.WalletId), amount: ledgerAmount(t.Amount, t.Type.IsOut())},
+			{account: ledgerAssets + ":Transfers:" + other.String()},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return l.w.Flush()
+}
+
+type ledgerPosting struct {
+	account string
+	amount  string
+}
+
+func (l *ledgerWriter) writeEntry(t *domain.Transaction, postings []ledgerPosting) error {
+	payee := ledgerText(t.Comment)
+	if payee == "" {
+		payee = t.Type.Val()
+	}
+
+	fmt.Fprintf(l.w, "%s * %s\n", t.CreatedAt.Format("2006/01/02"), payee)
+	fmt.Fprintf(l.w, "    ; id: %s\n", t.Id)
+	for _, p := range postings {
+		if p.amount == "" {
+			fmt.Fprintf(l.w, "    %s\n", p.account)
+			continue
+		}
+		fmt.Fprintf(l.w, "    %-40s  %s\n", p.account, p.amount)
+	}
+	_, err := l.w.WriteString("\n")
+
+	return err
+}
+
+func (l *ledgerWriter) walletAccount(walletId uuid.UUID) string {
+	name, ok := l.wallets[walletId]
+	if !ok {
+		name = walletId.String()
+	}
+
+	return ledgerAssets + ":" + ledgerAccountName(name)
+}
+
+func (l *ledgerWriter) categoryAccount(t *domain.Transaction) string {
+	root := ledgerExpenses
+	if t.Type.IsIn() {
+		root = ledgerIncome
+	}
+
+	path, ok := l.categories[t.CategoryId]
+	if !ok {
+		return root + ":" + ledgerUncategorized
+	}
+
+	names := make([]string, len(path))
+	for i, name := range path {
+		names[i] = ledgerAccountName(name)
+	}
+
+	return root + ":" + joinPath(names, ":")
+}
+
+func ledgerAmount(m domain.Money, negative bool) string {
+	if negative {
+		m = m.Neg()
+	}
+
+	return m.String() + " " + strings.ToUpper(m.Currency().Val())
+}
+
+// ledgerAccountName removes characters that end or split an account name.
+func ledgerAccountName(name string) string {
+	name = strings.ReplaceAll(ledgerText(name), ":", "-")
+	if name == "" {
+		return "-"
+	}
+
+	return name
+}
+
+// ledgerText collapses whitespace, two spaces separate an account from its amount.
+func ledgerText(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
@@ -0,0 +1,70 @@
+package export
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IMBgl/go-wallet-api/internal/domain"
+	"github.com/google/uuid"
+)
+
+func TestLedgerWriter(t *testing.T) {
+	userId := uuid.New()
+	usd, _ := domain.MoneyFromString("0", domain.CurrencyUSD())
+	eur, _ := domain.MoneyFromString("0", domain.CurrencyEUR())
+	cash := domain.NewWallet("Cash", usd, userId)
+	travel := domain.NewWallet("Travel: EU", eur, userId)
+
+	food := domain.NewCategory("Food", domain.CurrencyUSD(), userId)
+	coffee := domain.NewCategory("Coffee", domain.CurrencyUSD(), userId)
+	coffee.ParentId = &food.Id
+
+	date := time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC)
+	amount, _ := domain.MoneyFromString("3.50", domain.CurrencyUSD())
+	spent := domain.NewTransaction("Latte", amount, domain.TransactionTypeOut(), userId, coffee.Id, cash.Id)
+	spent.CreatedAt = date
+
+	sent, _ := domain.MoneyFromString("100", domain.CurrencyUSD())
+	received, _ := domain.MoneyFromString("90", domain.CurrencyEUR())
+	out := domain.NewTransaction("", sent, domain.TransactionTypeOut(), userId, uuid.Nil, cash.Id)
+	in := domain.NewTransaction("", received, domain.TransactionTypeIn(), userId, uuid.Nil, travel.Id)
+	out.CreatedAt, in.CreatedAt = date, date
+	transfer := domain.NewTransfer(userId, out, in, big.NewRat(9, 10))
+
+	b := &strings.Builder{}
+	w := NewLedgerWriter(b)
+	err := w.WriteHeader(&Data{
+		ExportedAt: date,
+		Wallets:    []*domain.Wallet{cash, travel},
+		Categories: []*domain.Category{food, coffee},
+		Transfers:  []*domain.Transfer{transfer},
+	})
+	if err != nil {
+		t.Fatalf("WriteHeader err %v", err)
+	}
+
+	for _, tr := range []*domain.Transaction{spent, in, out} {
+		if err := w.WriteTransaction(tr); err != nil {
+			t.Fatalf("WriteTransaction err %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close err %v", err)
+	}
+
+	journal := b.String()
+	for _, want := range []string{
+		"account Assets:Travel- EU\n",
+		"2022/03/01 * Latte\n",
+		"    Expenses:Food:Coffee                      3.50 USD\n",
+		"    Assets:Cash                               -3.50 USD\n",
+		"    Assets:Travel- EU                         90.00 EUR @@ 100.00 USD\n",
+		"    Assets:Cash                               -100.00 USD\n",
+	} {
+		if !strings.Contains(journal, want) {
+			t.Errorf("journal does not contain %q:\n%s", want, journal)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/IMBgl/go-wallet-api/internal/domain"
 	"github.com/IMBgl/go-wallet-api/internal/service"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -13,7 +14,7 @@ import (
 	"github.com/go-playground/validator/v10"
 	en_translations "github.com/go-playground/validator/v10/translations/en"
 	"github.com/google/uuid"
-	"strconv"
+	"time"
 
 	"github.com/go-chi/render"
 	"net/http"
@@ -21,14 +22,20 @@ import (
 
 type apiHandler struct {
 	service service.Service
+	limiter *rateLimiter
 }
 
 var (
 	rValidator *requestValidator
 )
 
-func ApiHandler(s service.Service) *apiHandler {
-	return &apiHandler{service: s}
+// ApiHandler serves the api, a nil limiter limits the requests with the defaults and the memory store.
+func ApiHandler(s service.Service, l *rateLimiter) *apiHandler {
+	if l == nil {
+		l = NewRateLimiter(nil, DefaultRateLimitConfig())
+	}
+
+	return &apiHandler{service: s, limiter: l}
 }
 
 type requestValidator struct {
@@ -38,16 +45,32 @@ type requestValidator struct {
 }
 
 func (h *apiHandler) Routes() *chi.Mux {
-	mv := NewApiMiddleware(h.service)
-	userHandler := &UserHandler{userService: h.service.User()}
-	walletHandler := &WalletHandler{walletService: h.service.Wallet(), middleware: mv}
+	mv := NewApiMiddleware(h.service, h.limiter)
+	userHandler := &UserHandler{userService: h.service.User(), tokenService: h.service.Token(), accountService: h.service.Account(), middleware: mv}
+	walletHandler := &WalletHandler{walletService: h.service.Wallet(), reportService: h.service.Report(), middleware: mv}
 	categoryHandler := &CategoryHandler{categoryService: h.service.Category(), middleware: mv}
 	transactionHandler := &TransactionHandler{transactionService: h.service.Transaction(), middleware: mv}
+	transferHandler := &TransferHandler{transferService: h.service.Transfer(), middleware: mv}
+	budgetHandler := &BudgetHandler{budgetService: h.service.Budget(), middleware: mv}
+	recurringHandler := &RecurringHandler{recurringService: h.service.Recurring(), middleware: mv}
+	importHandler := &ImportHandler{importService: h.service.Import(), middleware: mv}
+	exportHandler := &ExportHandler{exportService: h.service.Export(), middleware: mv}
+	apiKeyHandler := &ApiKeyHandler{apiKeyService: h.service.ApiKey(), middleware: mv}
+	currencyHandler := &CurrencyHandler{}
+	exchangeHandler := &ExchangeHandler{exchangeService: h.service.Exchange(), middleware: mv}
+	reportHandler := &ReportHandler{reportService: h.service.Report(), middleware: mv}
 
 	r := chi.NewRouter()
+	if h.limiter.config.TrustProxy {
+		r.Use(middleware.RealIP)
+	}
 	r.Use(middleware.Logger)
 	r.Use(render.SetContentType(render.ContentTypeJSON))
 
+	r.Get("/.well-known/jwks.json", func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, r, h.service.Token().Jwks())
+	})
+
 	r.Route("/api/v1", func(r chi.Router) {
 		r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
 			render.JSON(w, r, map[string]string{"status": "ok"})
@@ -57,6 +80,15 @@ func (h *apiHandler) Routes() *chi.Mux {
 		r.Mount("/wallet", walletHandler.Routes())
 		r.Mount("/category", categoryHandler.Routes())
 		r.Mount("/transaction", transactionHandler.Routes())
+		r.Mount("/transfer", transferHandler.Routes())
+		r.Mount("/budget", budgetHandler.Routes())
+		r.Mount("/recurring", recurringHandler.Routes())
+		r.Mount("/import", importHandler.Routes())
+		r.Mount("/export", exportHandler.Routes())
+		r.Mount("/apiKey", apiKeyHandler.Routes())
+		r.Mount("/currency", currencyHandler.Routes())
+		r.Mount("/exchangeRate", exchangeHandler.Routes())
+		r.Mount("/report", reportHandler.Routes())
 	})
 
 	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
@@ -71,6 +103,30 @@ func DateTimeFormat() string {
 	return fmt.Sprintf("2006-01-02 15:04:05")
 }
 
+func DateFormat() string {
+	return "2006-01-02"
+}
+
+// parseDateParam accepts either a date or a date with time in DateTimeFormat.
+func parseDateParam(value, name string) (time.Time, error) {
+	for _, layout := range []string{DateTimeFormat(), DateFormat(), time.RFC3339} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, errors.New(fmt.Sprintf("%s value must be date in format %s or %s", name, DateFormat(), DateTimeFormat()))
+}
+
+// currencyError explains why a currency of the request was rejected.
+func currencyError(name string, err error) error {
+	if err == domain.ErrCurrencyNotEnabled {
+		return errors.New(fmt.Sprintf("%s value is not enabled, see /api/v1/currency for the enabled ones", name))
+	}
+
+	return errors.New(fmt.Sprintf("%s value must be an ISO 4217 currency code", name))
+}
+
 func unmarshallRequest(r *http.Request, data interface{}) error {
 	defer r.Body.Close()
 	if err := json.NewDecoder(r.Body).Decode(data); err != nil {
@@ -132,23 +188,6 @@ func validateRequest(request interface{}) (err error, validationErrors []string)
 	return
 }
 
-func validateFloat32(value interface{}, name string) (float32, error) {
-	switch t := value.(type) {
-	case string:
-		floatVal, err := strconv.ParseFloat(t, 32)
-		if err != nil {
-			return 0.0, errors.New(fmt.Sprintf("%s value must be float", name))
-		}
-		return float32(floatVal), nil
-	case float32:
-		return t, nil
-	case float64:
-		return float32(t), nil
-	}
-
-	return 0.0, errors.New(fmt.Sprintf("%s value must be float", name))
-}
-
 func retrieveTokenOrFail(w http.ResponseWriter, r *http.Request) *service.UserToken {
 	ctx := r.Context()
 	token, ok := ctx.Value("token").(*service.UserToken)
@@ -160,6 +199,13 @@ func retrieveTokenOrFail(w http.ResponseWriter, r *http.Request) *service.UserTo
 	return token
 }
 
+// retrieveToken returns the token of an authenticated request, nil otherwise.
+func retrieveToken(r *http.Request) *service.UserToken {
+	token, _ := r.Context().Value("token").(*service.UserToken)
+
+	return token
+}
+
 func retrieveUuidOrFail(w http.ResponseWriter, r *http.Request, paramName string) (uuidVal uuid.UUID) {
 	param := chi.URLParam(r, paramName)
 	uuidVal, err := uuid.Parse(param)
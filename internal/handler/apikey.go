@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"github.com/IMBgl/go-wallet-api/internal/service"
+	"github.com/IMBgl/go-wallet-api/pkg/validator"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"net/http"
+	"time"
+)
+
+type ApiKeyHandler struct {
+	apiKeyService service.ApiKeyService
+	middleware    *apiMiddleware
+}
+
+func (h ApiKeyHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+	r.Use(h.middleware.Auth)
+	r.Use(h.middleware.limiter.Write)
+	r.Use(h.middleware.SessionOnly)
+	r.Post("/", h.create)
+	r.Get("/", h.getList)
+	r.Delete("/{apiKeyId}", h.revoke)
+
+	return r
+}
+
+type ApiKeyCreateRequest struct {
+	Name         string      `json:"name"`
+	Scopes       []string    `json:"scopes"`
+	WalletIds    []string    `json:"walletIds"`
+	ExpiresAt    string      `json:"expiresAt"`
+	WalletIdsVal []uuid.UUID `json:"-"`
+	ExpiresAtVal *time.Time  `json:"-"`
+}
+
+type ApiKeyResponse struct {
+	Id         string   `json:"id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	Key        string   `json:"key,omitempty"`
+	Scopes     []string `json:"scopes"`
+	WalletIds  []string `json:"walletIds"`
+	ExpiresAt  string   `json:"expiresAt,omitempty"`
+	LastUsedAt string   `json:"lastUsedAt,omitempty"`
+	CreatedAt  string   `json:"createdAt"`
+}
+
+func (data *ApiKeyCreateRequest) Bind(r *http.Request) error {
+	if data.Name == "" {
+		return errors.New("name field required")
+	}
+
+	if len(data.Scopes) == 0 {
+		return errors.New("scopes field required")
+	}
+
+	for _, v := range data.WalletIds {
+		walletId, err := validator.Uuid(v, "walletIds")
+		if err != nil {
+			return err
+		}
+		data.WalletIdsVal = append(data.WalletIdsVal, walletId)
+	}
+
+	if data.ExpiresAt != "" {
+		expiresAt, err := parseDateParam(data.ExpiresAt, "expiresAt")
+		if err != nil {
+			return err
+		}
+		data.ExpiresAtVal = &expiresAt
+	}
+
+	return nil
+}
+
+// NewApiKeyResponse contains the key value only right after it is created.
+func NewApiKeyResponse(k *service.ApiKey) *ApiKeyResponse {
+	response := &ApiKeyResponse{
+		Id:        k.Id.String(),
+		Name:      k.Name,
+		Prefix:    k.Prefix,
+		Key:       k.Value,
+		Scopes:    k.Scopes,
+		WalletIds: []string{},
+		CreatedAt: k.CreatedAt.Format(DateTimeFormat()),
+	}
+
+	for _, id := range k.WalletIds {
+		response.WalletIds = append(response.WalletIds, id.String())
+	}
+
+	if k.ExpiresAt != nil {
+		response.ExpiresAt = k.ExpiresAt.Format(DateTimeFormat())
+	}
+
+	if k.LastUsedAt != nil {
+		response.LastUsedAt = k.LastUsedAt.Format(DateTimeFormat())
+	}
+
+	return response
+}
+
+func NewApiKeyListResponse(list []*service.ApiKey) []*ApiKeyResponse {
+	responseList := []*ApiKeyResponse{}
+	for _, k := range list {
+		responseList = append(responseList, NewApiKeyResponse(k))
+	}
+
+	return responseList
+}
+
+func (h *ApiKeyHandler) create(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+
+	data := &ApiKeyCreateRequest{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	createRequest := &service.ApiKeyCreateRequest{
+		UserId:    token.UserId,
+		Name:      data.Name,
+		Scopes:    data.Scopes,
+		WalletIds: data.WalletIdsVal,
+		ExpiresAt: data.ExpiresAtVal,
+	}
+
+	key, err := h.apiKeyService.Create(context.Background(), createRequest)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, NewApiKeyResponse(key))
+}
+
+func (h *ApiKeyHandler) getList(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+
+	list, err := h.apiKeyService.GetList(context.Background(), &service.ApiKeyGetListRequest{UserId: token.UserId})
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, NewApiKeyListResponse(list))
+}
+
+func (h *ApiKeyHandler) revoke(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+	apiKeyId := retrieveUuidOrFail(w, r, "apiKeyId")
+
+	err := h.apiKeyService.Revoke(context.Background(), &service.ApiKeyRevokeRequest{UserId: token.UserId, ApiKeyId: apiKeyId})
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, map[string]string{})
+}
@@ -0,0 +1,387 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/IMBgl/go-wallet-api/internal/domain"
+	"github.com/IMBgl/go-wallet-api/internal/service"
+	"github.com/IMBgl/go-wallet-api/pkg/validator"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+type BudgetHandler struct {
+	budgetService service.BudgetService
+	middleware    *apiMiddleware
+}
+
+func (h BudgetHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+	r.Use(h.middleware.Auth)
+	r.Use(h.middleware.limiter.Write)
+	r.Use(h.middleware.Scope(service.SCOPE_BUDGET_READ, service.SCOPE_BUDGET_WRITE))
+	// budgets count the spending of every wallet
+	r.Use(h.middleware.Unrestricted)
+	r.Post("/", h.create)
+	r.Get("/", h.getList)
+	r.Get("/status", h.status)
+
+	r.Route("/{budgetId}", func(r chi.Router) {
+		r.Get("/", h.getOne)
+		r.Put("/", h.update)
+		r.Delete("/", h.delete)
+		r.Get("/status", h.status)
+	})
+
+	return r
+}
+
+type BudgetCreateRequest struct {
+	Name          string              `json:"name"`
+	CategoryId    string              `json:"categoryId"`
+	Limit         json.Number         `json:"limit"`
+	Currency      string              `json:"currency"`
+	Period        string              `json:"period"`
+	StartDate     string              `json:"startDate"`
+	EndDate       *string             `json:"endDate,omitempty"`
+	Rollover      bool                `json:"rollover"`
+	CategoryIdVal uuid.UUID           `json:"-"`
+	LimitVal      domain.Money        `json:"-"`
+	PeriodVal     domain.BudgetPeriod `json:"-"`
+	StartDateVal  time.Time           `json:"-"`
+	EndDateVal    *time.Time          `json:"-"`
+}
+
+type BudgetUpdateRequest struct {
+	Name       *string      `json:"name,omitempty"`
+	Limit      *json.Number `json:"limit,omitempty"`
+	EndDate    *string      `json:"endDate,omitempty"`
+	Rollover   *bool        `json:"rollover,omitempty"`
+	LimitVal   *big.Rat     `json:"-"`
+	EndDateVal *time.Time   `json:"-"`
+}
+
+type BudgetResponse struct {
+	Id         string      `json:"id"`
+	Name       string      `json:"name"`
+	CategoryId string      `json:"categoryId"`
+	Limit      json.Number `json:"limit"`
+	Currency   string      `json:"currency"`
+	Period     string      `json:"period"`
+	StartDate  string      `json:"startDate"`
+	EndDate    *string     `json:"endDate"`
+	Rollover   bool        `json:"rollover"`
+	CreatedAt  string      `json:"createdAt"`
+}
+
+type BudgetStatusResponse struct {
+	*BudgetResponse
+	Active         bool        `json:"active"`
+	PeriodStart    *string     `json:"periodStart"`
+	PeriodEnd      *string     `json:"periodEnd"`
+	RolloverAmount json.Number `json:"rolloverAmount"`
+	Available      json.Number `json:"available"`
+	Spent          json.Number `json:"spent"`
+	Remaining      json.Number `json:"remaining"`
+	Percent        json.Number `json:"percent"`
+	Overspent      bool        `json:"overspent"`
+}
+
+func NewBudgetListResponse(list []*domain.Budget) []*BudgetResponse {
+	responseList := []*BudgetResponse{}
+	for _, b := range list {
+		responseList = append(responseList, NewBudgetResponse(b))
+	}
+
+	return responseList
+}
+
+func NewBudgetResponse(b *domain.Budget) *BudgetResponse {
+	response := &BudgetResponse{
+		Id:         b.Id.String(),
+		Name:       b.Name,
+		CategoryId: b.CategoryId.String(),
+		Limit:      json.Number(b.Limit.String()),
+		Currency:   b.Limit.Currency().Val(),
+		Period:     b.Period.Val(),
+		StartDate:  b.StartDate.Format(DateFormat()),
+		Rollover:   b.Rollover,
+		CreatedAt:  b.CreatedAt.Format(DateTimeFormat()),
+	}
+
+	if b.EndDate != nil {
+		endDate := b.EndDate.AddDate(0, 0, -1).Format(DateFormat())
+		response.EndDate = &endDate
+	}
+
+	return response
+}
+
+func NewBudgetStatusListResponse(list []*service.BudgetStatus) []*BudgetStatusResponse {
+	responseList := []*BudgetStatusResponse{}
+	for _, s := range list {
+		response := &BudgetStatusResponse{
+			BudgetResponse: NewBudgetResponse(s.Budget),
+			Active:         s.Active,
+			RolloverAmount: json.Number(s.Rollover.String()),
+			Available:      json.Number(s.Available.String()),
+			Spent:          json.Number(s.Spent.String()),
+			Remaining:      json.Number(s.Remaining.String()),
+			Percent:        json.Number(s.Percent.FloatString(2)),
+			Overspent:      s.Overspent,
+		}
+
+		if s.Active {
+			periodStart := s.PeriodStart.Format(DateFormat())
+			periodEnd := s.PeriodEnd.AddDate(0, 0, -1).Format(DateFormat())
+			response.PeriodStart = &periodStart
+			response.PeriodEnd = &periodEnd
+		}
+
+		responseList = append(responseList, response)
+	}
+
+	return responseList
+}
+
+func (data *BudgetCreateRequest) Bind(r *http.Request) error {
+	if data.Name == "" {
+		return errors.New("name field required")
+	}
+
+	categoryId, err := validator.Uuid(data.CategoryId, "categoryId")
+	if err != nil {
+		return err
+	}
+	data.CategoryIdVal = categoryId
+
+	currency, err := domain.CurrencyFromString(data.Currency)
+	if err != nil {
+		return currencyError("currency", err)
+	}
+
+	limit, err := parsePositiveDecimal(data.Limit, "limit")
+	if err != nil {
+		return err
+	}
+
+	data.LimitVal, err = domain.MoneyFromRat(limit, currency)
+	if err != nil {
+		return err
+	}
+
+	data.PeriodVal, err = domain.BudgetPeriodFromString(data.Period)
+	if err != nil {
+		return errors.New("period value must be one of 'weekly', 'monthly', 'yearly', 'custom'")
+	}
+
+	data.StartDateVal = time.Now().UTC().Truncate(24 * time.Hour)
+	if data.StartDate != "" {
+		data.StartDateVal, err = time.Parse(DateFormat(), data.StartDate)
+		if err != nil {
+			return errors.New("startDate value must be date in format " + DateFormat())
+		}
+	}
+
+	if data.EndDate != nil {
+		data.EndDateVal, err = parseBudgetEndDate(*data.EndDate)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (data *BudgetUpdateRequest) Bind(r *http.Request) error {
+	if data.Name != nil && *data.Name == "" {
+		return errors.New("name field required")
+	}
+
+	if data.Limit != nil {
+		limit, err := parsePositiveDecimal(*data.Limit, "limit")
+		if err != nil {
+			return err
+		}
+		data.LimitVal = limit
+	}
+
+	if data.EndDate != nil {
+		endDate, err := parseBudgetEndDate(*data.EndDate)
+		if err != nil {
+			return err
+		}
+		data.EndDateVal = endDate
+	}
+
+	return nil
+}
+
+// parseBudgetEndDate turns the inclusive last day of a budget into the exclusive end time.
+func parseBudgetEndDate(value string) (*time.Time, error) {
+	endDate, err := time.Parse(DateFormat(), value)
+	if err != nil {
+		return nil, errors.New("endDate value must be date in format " + DateFormat())
+	}
+	endDate = endDate.AddDate(0, 0, 1)
+
+	return &endDate, nil
+}
+
+func (h *BudgetHandler) create(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+
+	data := &BudgetCreateRequest{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	createRequest := &service.BudgetCreateRequest{
+		UserId:     token.UserId,
+		Name:       data.Name,
+		CategoryId: data.CategoryIdVal,
+		Limit:      data.LimitVal,
+		Period:     data.PeriodVal,
+		StartDate:  data.StartDateVal,
+		EndDate:    data.EndDateVal,
+		Rollover:   data.Rollover,
+	}
+
+	budget, err := h.budgetService.Create(context.Background(), createRequest)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, NewBudgetResponse(budget))
+}
+
+func (h *BudgetHandler) getList(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+
+	budgetList, err := h.budgetService.GetList(context.Background(), &service.BudgetGetListRequest{UserId: token.UserId})
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, NewBudgetListResponse(budgetList))
+}
+
+func (h *BudgetHandler) getOne(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+	budgetId := retrieveUuidOrFail(w, r, "budgetId")
+
+	getOneRequest := &service.BudgetGetOneRequest{
+		UserId:   token.UserId,
+		BudgetId: budgetId,
+	}
+
+	budget, err := h.budgetService.GetOne(context.Background(), getOneRequest)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, NewBudgetResponse(budget))
+}
+
+func (h *BudgetHandler) update(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+	budgetId := retrieveUuidOrFail(w, r, "budgetId")
+
+	data := &BudgetUpdateRequest{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	updateRequest := &service.BudgetUpdateRequest{
+		UserId:   token.UserId,
+		BudgetId: budgetId,
+		Name:     data.Name,
+		Limit:    data.LimitVal,
+		Rollover: data.Rollover,
+		EndDate:  data.EndDateVal,
+	}
+
+	budget, err := h.budgetService.Update(context.Background(), updateRequest)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, NewBudgetResponse(budget))
+}
+
+func (h *BudgetHandler) delete(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+	budgetId := retrieveUuidOrFail(w, r, "budgetId")
+
+	deleteRequest := &service.BudgetDeleteRequest{
+		UserId:   token.UserId,
+		BudgetId: budgetId,
+	}
+
+	err := h.budgetService.Delete(context.Background(), deleteRequest)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, map[string]string{})
+}
+
+func (h *BudgetHandler) status(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+
+	statusRequest := &service.BudgetStatusRequest{
+		UserId: token.UserId,
+	}
+
+	if chi.URLParam(r, "budgetId") != "" {
+		budgetId := retrieveUuidOrFail(w, r, "budgetId")
+		statusRequest.BudgetId = &budgetId
+	}
+
+	if v := r.URL.Query().Get("at"); v != "" {
+		at, err := parseDateParam(v, "at")
+		if err != nil {
+			render.Render(w, r, ErrInvalidRequest(err))
+			return
+		}
+		statusRequest.At = at
+	}
+
+	statusList, err := h.budgetService.Status(context.Background(), statusRequest)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, NewBudgetStatusListResponse(statusList))
+}
@@ -21,6 +21,8 @@ type CategoryHandler struct {
 func (h CategoryHandler) Routes() chi.Router {
 	r := chi.NewRouter()
 	r.Use(h.middleware.Auth)
+	r.Use(h.middleware.limiter.Write)
+	r.Use(h.middleware.Scope(service.SCOPE_CATEGORY_READ, service.SCOPE_CATEGORY_WRITE))
 	r.Post("/", h.create)
 	r.Get("/", h.getList)
 
@@ -105,7 +107,7 @@ func (data *CategoryCreateRequest) Bind(r *http.Request) error {
 
 	currency, err := domain.CurrencyFromString(data.Currency)
 	if err != nil {
-		return errors.New("currency format must be one of 'rur', 'eur, 'usd'")
+		return currencyError("currency", err)
 	}
 	data.CurrencyVal = currency
 
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"github.com/IMBgl/go-wallet-api/internal/domain"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"net/http"
+	"strings"
+)
+
+type CurrencyHandler struct{}
+
+func (h CurrencyHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+	r.Get("/", h.getList)
+
+	return r
+}
+
+type CurrencyResponse struct {
+	Code       string `json:"code"`
+	Name       string `json:"name"`
+	Symbol     string `json:"symbol"`
+	MinorUnits int    `json:"minorUnits"`
+}
+
+func NewCurrencyListResponse(list []domain.CurrencyInfo) []*CurrencyResponse {
+	responseList := []*CurrencyResponse{}
+	for _, c := range list {
+		responseList = append(responseList, &CurrencyResponse{
+			Code:       strings.ToLower(c.Code),
+			Name:       c.Name,
+			Symbol:     c.Symbol,
+			MinorUnits: c.MinorUnits,
+		})
+	}
+
+	return responseList
+}
+
+// getList returns the currencies enabled in this deployment.
+func (h *CurrencyHandler) getList(w http.ResponseWriter, r *http.Request) {
+	render.JSON(w, r, NewCurrencyListResponse(domain.EnabledCurrencies()))
+}
@@ -45,3 +45,4 @@ func ErrRender(err error) render.Renderer {
 }
 
 var ErrNotFound = &ErrResponse{HTTPStatusCode: 404, StatusText: "Resource not found."}
+var ErrForbidden = &ErrResponse{HTTPStatusCode: 403, StatusText: "Access denied."}
@@ -0,0 +1,286 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/IMBgl/go-wallet-api/internal/domain"
+	"github.com/IMBgl/go-wallet-api/internal/service"
+	"github.com/IMBgl/go-wallet-api/pkg/validator"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"math/big"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type ExchangeHandler struct {
+	exchangeService service.ExchangeService
+	middleware      *apiMiddleware
+}
+
+func (h ExchangeHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+	r.Use(h.middleware.Auth)
+	r.Use(h.middleware.limiter.Write)
+	r.Use(h.middleware.Scope(service.SCOPE_RATE_READ, service.SCOPE_RATE_WRITE))
+	r.Post("/", h.create)
+	r.Get("/", h.getList)
+	r.Get("/convert", h.convert)
+	r.Delete("/{rateId}", h.delete)
+
+	return r
+}
+
+type ExchangeRateCreateRequest struct {
+	Base     string          `json:"base"`
+	Quote    string          `json:"quote"`
+	Rate     json.Number     `json:"rate"`
+	Date     string          `json:"date,omitempty"`
+	BaseVal  domain.Currency `json:"-"`
+	QuoteVal domain.Currency `json:"-"`
+	RateVal  *big.Rat        `json:"-"`
+	DateVal  time.Time       `json:"-"`
+}
+
+type ExchangeRateResponse struct {
+	Id     string      `json:"id"`
+	Base   string      `json:"base"`
+	Quote  string      `json:"quote"`
+	Rate   json.Number `json:"rate"`
+	Date   string      `json:"date"`
+	Source string      `json:"source"`
+	// Manual is set for the rates entered by the user, the others are shared by all users
+	Manual bool `json:"manual"`
+}
+
+type ConversionResponse struct {
+	Amount           json.Number `json:"amount"`
+	Currency         string      `json:"currency"`
+	OriginalAmount   json.Number `json:"originalAmount"`
+	OriginalCurrency string      `json:"originalCurrency"`
+	Rate             json.Number `json:"rate"`
+	RateDate         string      `json:"rateDate"`
+	Source           string      `json:"source"`
+}
+
+func NewExchangeRateListResponse(list []*domain.ExchangeRate) []*ExchangeRateResponse {
+	responseList := []*ExchangeRateResponse{}
+	for _, rate := range list {
+		responseList = append(responseList, NewExchangeRateResponse(rate))
+	}
+
+	return responseList
+}
+
+func NewExchangeRateResponse(e *domain.ExchangeRate) *ExchangeRateResponse {
+	return &ExchangeRateResponse{
+		Id:     e.Id.String(),
+		Base:   e.Base.Val(),
+		Quote:  e.Quote.Val(),
+		Rate:   json.Number(e.Rate.FloatString(service.EXCHANGE_RATE_SCALE)),
+		Date:   e.Date.Format(DateFormat()),
+		Source: e.Source,
+		Manual: e.UserId != nil,
+	}
+}
+
+func NewConversionResponse(c *service.Conversion) *ConversionResponse {
+	return &ConversionResponse{
+		Amount:           json.Number(c.Amount.String()),
+		Currency:         c.Amount.Currency().Val(),
+		OriginalAmount:   json.Number(c.Original.String()),
+		OriginalCurrency: c.Original.Currency().Val(),
+		Rate:             json.Number(c.Rate.FloatString(service.EXCHANGE_RATE_SCALE)),
+		RateDate:         c.RateDate.Format(DateFormat()),
+		Source:           c.Source,
+	}
+}
+
+func (data *ExchangeRateCreateRequest) Bind(r *http.Request) error {
+	var err error
+
+	// rates of disabled currencies still convert the transactions made before
+	data.BaseVal, err = domain.CurrencyFromCode(data.Base)
+	if err != nil {
+		return currencyError("base", err)
+	}
+
+	data.QuoteVal, err = domain.CurrencyFromCode(data.Quote)
+	if err != nil {
+		return currencyError("quote", err)
+	}
+
+	if data.BaseVal == data.QuoteVal {
+		return errors.New("base and quote values must differ")
+	}
+
+	data.RateVal, err = parsePositiveDecimal(data.Rate, "rate")
+	if err != nil {
+		return err
+	}
+
+	if data.Date != "" {
+		data.DateVal, err = time.Parse(DateFormat(), data.Date)
+		if err != nil {
+			return errors.New("date value must be date in format " + DateFormat())
+		}
+	}
+
+	return nil
+}
+
+func (h *ExchangeHandler) create(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+
+	data := &ExchangeRateCreateRequest{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	setRequest := &service.ExchangeRateSetRequest{
+		UserId: token.UserId,
+		Base:   data.BaseVal,
+		Quote:  data.QuoteVal,
+		Rate:   data.RateVal,
+		Date:   data.DateVal,
+	}
+
+	rate, err := h.exchangeService.SetRate(context.Background(), setRequest)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, NewExchangeRateResponse(rate))
+}
+
+func (h *ExchangeHandler) getList(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+
+	listRequest, err := newExchangeRateGetListRequest(r.URL.Query())
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	listRequest.UserId = token.UserId
+
+	list, err := h.exchangeService.GetList(context.Background(), listRequest)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, NewExchangeRateListResponse(list))
+}
+
+func newExchangeRateGetListRequest(q url.Values) (*service.ExchangeRateGetListRequest, error) {
+	request := &service.ExchangeRateGetListRequest{}
+
+	for name, dst := range map[string]**domain.Currency{"base": &request.Base, "quote": &request.Quote} {
+		if v := q.Get(name); v != "" {
+			currency, err := domain.CurrencyFromCode(v)
+			if err != nil {
+				return nil, currencyError(name, err)
+			}
+			*dst = &currency
+		}
+	}
+
+	for name, dst := range map[string]**time.Time{"dateFrom": &request.DateFrom, "dateTo": &request.DateTo} {
+		if v := q.Get(name); v != "" {
+			date, err := time.Parse(DateFormat(), v)
+			if err != nil {
+				return nil, errors.New(name + " value must be date in format " + DateFormat())
+			}
+			*dst = &date
+		}
+	}
+
+	return request, nil
+}
+
+// convert converts amount of from to the to currency at the rate of date, today when unset.
+func (h *ExchangeHandler) convert(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+
+	convertRequest, err := newConvertRequest(r.URL.Query())
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	convertRequest.UserId = token.UserId
+
+	conversion, err := h.exchangeService.Convert(context.Background(), convertRequest)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, NewConversionResponse(conversion))
+}
+
+func newConvertRequest(q url.Values) (*service.ConvertRequest, error) {
+	from, err := domain.CurrencyFromCode(q.Get("from"))
+	if err != nil {
+		return nil, currencyError("from", err)
+	}
+
+	to, err := domain.CurrencyFromCode(q.Get("to"))
+	if err != nil {
+		return nil, currencyError("to", err)
+	}
+
+	amount, err := validator.Decimal(q.Get("amount"), "amount")
+	if err != nil {
+		return nil, err
+	}
+
+	amountVal, err := domain.MoneyFromString(amount, from)
+	if err != nil {
+		return nil, err
+	}
+
+	request := &service.ConvertRequest{Amount: amountVal, To: to}
+
+	if v := q.Get("date"); v != "" {
+		request.Date, err = parseDateParam(v, "date")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return request, nil
+}
+
+func (h *ExchangeHandler) delete(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+	rateId := retrieveUuidOrFail(w, r, "rateId")
+
+	deleteRequest := &service.ExchangeRateDeleteRequest{
+		UserId: token.UserId,
+		RateId: rateId,
+	}
+
+	err := h.exchangeService.Delete(context.Background(), deleteRequest)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, map[string]string{})
+}
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"github.com/IMBgl/go-wallet-api/internal/export"
+	"github.com/IMBgl/go-wallet-api/internal/service"
+	"github.com/IMBgl/go-wallet-api/pkg/validator"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"log"
+	"net/http"
+	"time"
+)
+
+type ExportHandler struct {
+	exportService service.ExportService
+	middleware    *apiMiddleware
+}
+
+func (h ExportHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+	r.Use(h.middleware.Auth)
+	r.Use(h.middleware.limiter.Write)
+	r.Use(h.middleware.Scope(service.SCOPE_REPORT_READ, service.SCOPE_REPORT_READ))
+	r.Get("/{format}", h.export)
+
+	return r
+}
+
+func (h *ExportHandler) export(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+
+	q := r.URL.Query()
+	request := &service.ExportRequest{
+		UserId: token.UserId,
+		Format: chi.URLParam(r, "format"),
+		Entity: q.Get("entity"),
+	}
+
+	if v := q.Get("walletId"); v != "" {
+		walletId, err := validator.Uuid(v, "walletId")
+		if err != nil {
+			render.Render(w, r, ErrInvalidRequest(err))
+			return
+		}
+		request.WalletId = &walletId
+	}
+
+	// archives and ledgers name every wallet, a restricted key exports the csv transactions of its wallets only
+	if token.IsWalletRestricted() && (request.Format != export.FORMAT_CSV || (request.Entity != "" && request.Entity != export.ENTITY_TRANSACTIONS)) {
+		render.Render(w, r, ErrForbidden)
+		return
+	}
+
+	walletId, ok := restrictWalletFilter(w, r, token, request.WalletId)
+	if !ok {
+		return
+	}
+	request.WalletId = walletId
+
+	for name, dst := range map[string]**time.Time{"dateFrom": &request.DateFrom, "dateTo": &request.DateTo} {
+		if v := q.Get(name); v != "" {
+			date, err := parseDateParam(v, name)
+			if err != nil {
+				render.Render(w, r, ErrInvalidRequest(err))
+				return
+			}
+			*dst = &date
+		}
+	}
+
+	// a plain date includes the whole day
+	if request.DateTo != nil && len(q.Get("dateTo")) == len(DateFormat()) {
+		dateTo := request.DateTo.Add(24*time.Hour - time.Nanosecond)
+		request.DateTo = &dateTo
+	}
+
+	fileName := fmt.Sprintf("wallet-export-%s.%s", time.Now().UTC().Format("20060102"), export.FileExtension(request.Format))
+	body := &exportResponseWriter{ResponseWriter: w, contentType: export.ContentType(request.Format), fileName: fileName}
+
+	writer, err := h.exportService.NewWriter(request, body)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	err = h.exportService.Export(context.Background(), request, writer)
+	if err != nil {
+		if !body.started {
+			render.Render(w, r, ErrInvalidRequest(err))
+			return
+		}
+		// the response is partially sent, the error can only be logged
+		log.Printf("export err %v", err)
+	}
+}
+
+// exportResponseWriter sends the file headers with the first written byte,
+// so errors found before that are still returned as a JSON error response.
+type exportResponseWriter struct {
+	http.ResponseWriter
+	contentType string
+	fileName    string
+	started     bool
+}
+
+func (w *exportResponseWriter) Write(p []byte) (int, error) {
+	if !w.started {
+		w.started = true
+		w.Header().Set("Content-Type", w.contentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="`+w.fileName+`"`)
+	}
+
+	return w.ResponseWriter.Write(p)
+}
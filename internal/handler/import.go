@@ -0,0 +1,276 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/IMBgl/go-wallet-api/internal/service"
+	"github.com/IMBgl/go-wallet-api/pkg/validator"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const IMPORT_MAX_FILE_SIZE = 10 << 20
+
+// IMPORT_MAX_REQUEST_SIZE leaves room for the form fields next to the file.
+const IMPORT_MAX_REQUEST_SIZE = IMPORT_MAX_FILE_SIZE + 1<<20
+
+type ImportHandler struct {
+	importService service.ImportService
+	middleware    *apiMiddleware
+}
+
+func (h ImportHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+	r.Use(h.middleware.Auth)
+	r.Use(h.middleware.limiter.Write)
+	r.Use(h.middleware.Scope(service.SCOPE_TRANSACTION_WRITE, service.SCOPE_TRANSACTION_WRITE))
+	r.Post("/csv/preview", h.preview(service.IMPORT_FORMAT_CSV))
+	r.Post("/csv", h.commit(service.IMPORT_FORMAT_CSV))
+	r.Post("/ofx/preview", h.preview(service.IMPORT_FORMAT_OFX))
+	r.Post("/ofx", h.commit(service.IMPORT_FORMAT_OFX))
+	r.Post("/qfx/preview", h.preview(service.IMPORT_FORMAT_OFX))
+	r.Post("/qfx", h.commit(service.IMPORT_FORMAT_OFX))
+	r.Post("/qif/preview", h.preview(service.IMPORT_FORMAT_QIF))
+	r.Post("/qif", h.commit(service.IMPORT_FORMAT_QIF))
+
+	return r
+}
+
+type ImportRowResponse struct {
+	Line        int          `json:"line"`
+	Date        string       `json:"date,omitempty"`
+	Type        string       `json:"type,omitempty"`
+	Amount      *json.Number `json:"amount,omitempty"`
+	Currency    string       `json:"currency,omitempty"`
+	Comment     string       `json:"comment"`
+	DuplicateOf *string      `json:"duplicateOf"`
+	Error       *string      `json:"error"`
+}
+
+type ImportReconciliationResponse struct {
+	Date             *string     `json:"date"`
+	StatementBalance json.Number `json:"statementBalance"`
+	WalletBalance    json.Number `json:"walletBalance"`
+	Difference       json.Number `json:"difference"`
+	Reconciled       bool        `json:"reconciled"`
+}
+
+type ImportPreviewResponse struct {
+	WalletId       string                        `json:"walletId"`
+	AccountId      string                        `json:"accountId,omitempty"`
+	Rows           []*ImportRowResponse          `json:"rows"`
+	Valid          int                           `json:"valid"`
+	Duplicates     int                           `json:"duplicates"`
+	Invalid        int                           `json:"invalid"`
+	Reconciliation *ImportReconciliationResponse `json:"reconciliation"`
+}
+
+type ImportResultResponse struct {
+	Wallet         *WalletResponse               `json:"wallet"`
+	Imported       []*TransactionResponse        `json:"imported"`
+	Skipped        int                           `json:"skipped"`
+	Reconciliation *ImportReconciliationResponse `json:"reconciliation"`
+}
+
+func NewImportReconciliationResponse(r *service.ImportReconciliation) *ImportReconciliationResponse {
+	if r == nil {
+		return nil
+	}
+
+	response := &ImportReconciliationResponse{
+		StatementBalance: json.Number(r.StatementBalance.String()),
+		WalletBalance:    json.Number(r.WalletBalance.String()),
+		Difference:       json.Number(r.Difference.String()),
+		Reconciled:       r.Reconciled,
+	}
+
+	if !r.Date.IsZero() {
+		date := r.Date.Format(DateFormat())
+		response.Date = &date
+	}
+
+	return response
+}
+
+func NewImportPreviewResponse(p *service.ImportPreview) *ImportPreviewResponse {
+	response := &ImportPreviewResponse{
+		WalletId:       p.Wallet.Id.String(),
+		AccountId:      p.AccountId,
+		Rows:           []*ImportRowResponse{},
+		Reconciliation: NewImportReconciliationResponse(p.Reconciliation),
+	}
+	for _, row := range p.Rows {
+		rowResponse := &ImportRowResponse{Line: row.Line}
+
+		switch {
+		case row.Err != nil:
+			errorText := row.Err.Error()
+			rowResponse.Error = &errorText
+			response.Invalid++
+		case row.DuplicateOf != nil:
+			duplicateOf := row.DuplicateOf.String()
+			rowResponse.DuplicateOf = &duplicateOf
+			response.Duplicates++
+		default:
+			response.Valid++
+		}
+
+		if t := row.Transaction; t != nil {
+			amount := json.Number(t.Amount.String())
+			rowResponse.Date = t.CreatedAt.Format(DateFormat())
+			rowResponse.Type = t.Type.Val()
+			rowResponse.Amount = &amount
+			rowResponse.Currency = t.Amount.Currency().Val()
+			rowResponse.Comment = t.Comment
+		}
+
+		response.Rows = append(response.Rows, rowResponse)
+	}
+
+	return response
+}
+
+func NewImportResultResponse(r *service.ImportResult) *ImportResultResponse {
+	response := &ImportResultResponse{
+		Wallet:         NewWalletResponse(r.Wallet),
+		Imported:       []*TransactionResponse{},
+		Skipped:        r.Skipped,
+		Reconciliation: NewImportReconciliationResponse(r.Reconciliation),
+	}
+
+	for _, t := range r.Imported {
+		response.Imported = append(response.Imported, NewTransactionResponse(t))
+	}
+
+	return response
+}
+
+// parseImportRequest reads a multipart upload with the statement in the "file"
+// field, the target "walletId", an optional "categoryId", the CSV column
+// "mapping" as JSON, the QIF "dateFormat" and the accepted "lines" as a comma
+// separated list. walletId may be omitted for OFX and QIF files whose account
+// was imported before.
+func parseImportRequest(w http.ResponseWriter, r *http.Request, format string) (*service.ImportRequest, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, IMPORT_MAX_REQUEST_SIZE)
+
+	err := r.ParseMultipartForm(IMPORT_MAX_FILE_SIZE)
+	if err != nil {
+		return nil, errors.New("request must be multipart/form-data with a file field of at most 10MB")
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		return nil, errors.New("file field required")
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, errors.New("file field could not be read")
+	}
+
+	request := &service.ImportRequest{Format: format, Data: bytes.NewReader(data)}
+
+	if v := r.FormValue("walletId"); v != "" || format == service.IMPORT_FORMAT_CSV {
+		walletId, err := validator.Uuid(v, "walletId")
+		if err != nil {
+			return nil, err
+		}
+		request.WalletId = &walletId
+	}
+
+	if v := r.FormValue("categoryId"); v != "" {
+		categoryId, err := validator.Uuid(v, "categoryId")
+		if err != nil {
+			return nil, err
+		}
+		request.CategoryId = &categoryId
+	}
+
+	if format == service.IMPORT_FORMAT_CSV {
+		err = json.Unmarshal([]byte(r.FormValue("mapping")), &request.CsvMapping)
+		if err != nil {
+			return nil, errors.New("mapping field must be JSON object")
+		}
+	}
+
+	request.DateFormat = r.FormValue("dateFormat")
+
+	if v := r.FormValue("lines"); v != "" {
+		for _, part := range strings.Split(v, ",") {
+			line, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil || line <= 0 {
+				return nil, errors.New("lines value must be comma separated list of line numbers")
+			}
+			request.Lines = append(request.Lines, line)
+		}
+	}
+
+	return request, nil
+}
+
+func (h *ImportHandler) preview(format string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := retrieveTokenOrFail(w, r)
+		if token == nil {
+			return
+		}
+
+		request, err := parseImportRequest(w, r, format)
+		if err != nil {
+			render.Render(w, r, ErrInvalidRequest(err))
+			return
+		}
+		request.UserId = token.UserId
+
+		walletId, ok := restrictWalletFilter(w, r, token, request.WalletId)
+		if !ok {
+			return
+		}
+		request.WalletId = walletId
+
+		preview, err := h.importService.Preview(context.Background(), request)
+		if err != nil {
+			render.Render(w, r, ErrInvalidRequest(err))
+			return
+		}
+
+		render.JSON(w, r, NewImportPreviewResponse(preview))
+	}
+}
+
+func (h *ImportHandler) commit(format string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := retrieveTokenOrFail(w, r)
+		if token == nil {
+			return
+		}
+
+		request, err := parseImportRequest(w, r, format)
+		if err != nil {
+			render.Render(w, r, ErrInvalidRequest(err))
+			return
+		}
+		request.UserId = token.UserId
+
+		walletId, ok := restrictWalletFilter(w, r, token, request.WalletId)
+		if !ok {
+			return
+		}
+		request.WalletId = walletId
+
+		result, err := h.importService.Commit(context.Background(), request)
+		if err != nil {
+			render.Render(w, r, ErrInvalidRequest(err))
+			return
+		}
+
+		render.JSON(w, r, NewImportResultResponse(result))
+	}
+}
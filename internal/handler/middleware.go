@@ -3,24 +3,31 @@ package handler
 import (
 	"context"
 	"github.com/IMBgl/go-wallet-api/internal/service"
+	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
+	"github.com/google/uuid"
 	"log"
 	"net/http"
+	"strings"
 )
 
 const AUTH_HEADER = "X-Api-Key"
 
+// AUTHORIZATION_HEADER carries the token as "Bearer <token>", the usual way to send JWTs.
+const AUTHORIZATION_HEADER = "Authorization"
+
 type apiMiddleware struct {
 	service service.Service
+	limiter *rateLimiter
 }
 
-func NewApiMiddleware(service service.Service) *apiMiddleware {
-	return &apiMiddleware{service: service}
+func NewApiMiddleware(service service.Service, limiter *rateLimiter) *apiMiddleware {
+	return &apiMiddleware{service: service, limiter: limiter}
 }
 
 func (m *apiMiddleware) Auth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		tokenHeader := r.Header.Get(AUTH_HEADER)
+		tokenHeader := tokenFromRequest(r)
 		if tokenHeader == "" {
 			log.Printf("invalid token header %s", tokenHeader)
 			render.Render(w, r, ErrNotFound)
@@ -28,7 +35,7 @@ func (m *apiMiddleware) Auth(next http.Handler) http.Handler {
 		}
 
 		token, err := m.service.Token().GetValidByValue(context.Background(), tokenHeader)
-		if err != nil || token == nil {
+		if err != nil || token == nil || !token.Matches(tokenHeader) {
 			log.Printf("GetToken by value err %v", err)
 			render.Render(w, r, ErrNotFound)
 			return
@@ -38,3 +45,131 @@ func (m *apiMiddleware) Auth(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// Scope lets through tokens with readScope for safe methods and with
+// writeScope for the others. Session tokens have every scope.
+func (m *apiMiddleware) Scope(readScope, writeScope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := retrieveTokenOrFail(w, r)
+			if token == nil {
+				return
+			}
+
+			scope := writeScope
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				scope = readScope
+			}
+
+			if !token.HasScope(scope) {
+				render.Render(w, r, ErrForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// SessionOnly rejects api keys, they can not manage sessions or other keys.
+func (m *apiMiddleware) SessionOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := retrieveTokenOrFail(w, r)
+		if token == nil {
+			return
+		}
+
+		if token.IsApiKey() {
+			render.Render(w, r, ErrForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Unrestricted rejects api keys limited to some wallets on routes that span all wallets of the user.
+func (m *apiMiddleware) Unrestricted(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := retrieveTokenOrFail(w, r)
+		if token == nil {
+			return
+		}
+
+		if token.IsWalletRestricted() {
+			render.Render(w, r, ErrForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// WalletParam checks the wallet of the url parameter against the wallets the token allows.
+func (m *apiMiddleware) WalletParam(name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := retrieveTokenOrFail(w, r)
+			if token == nil {
+				return
+			}
+
+			walletId, err := uuid.Parse(chi.URLParam(r, name))
+			if err != nil {
+				render.Render(w, r, ErrNotFound)
+				return
+			}
+
+			if !token.AllowsWallet(walletId) {
+				render.Render(w, r, ErrForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// allowWalletOrFail renders forbidden when the token may not access the wallet.
+func allowWalletOrFail(w http.ResponseWriter, r *http.Request, token *service.UserToken, walletId uuid.UUID) bool {
+	if !token.AllowsWallet(walletId) {
+		render.Render(w, r, ErrForbidden)
+		return false
+	}
+
+	return true
+}
+
+// restrictWalletFilter applies the wallet restriction of the token to an
+// optional wallet filter. A key limited to a single wallet defaults to it,
+// otherwise a restricted key has to name one of its wallets.
+func restrictWalletFilter(w http.ResponseWriter, r *http.Request, token *service.UserToken, walletId *uuid.UUID) (*uuid.UUID, bool) {
+	if !token.IsWalletRestricted() {
+		return walletId, true
+	}
+
+	if walletId != nil {
+		return walletId, allowWalletOrFail(w, r, token, *walletId)
+	}
+
+	if len(token.WalletIds) == 1 {
+		return &token.WalletIds[0], true
+	}
+
+	render.Render(w, r, ErrForbidden)
+	return nil, false
+}
+
+// tokenFromRequest reads X-Api-Key and falls back to a bearer Authorization header.
+func tokenFromRequest(r *http.Request) string {
+	if v := r.Header.Get(AUTH_HEADER); v != "" {
+		return v
+	}
+
+	v := r.Header.Get(AUTHORIZATION_HEADER)
+	if len(v) > 7 && strings.EqualFold(v[:7], "Bearer ") {
+		return strings.TrimSpace(v[7:])
+	}
+
+	return ""
+}
@@ -0,0 +1,340 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-chi/render"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	RATE_LIMIT_LIMIT_HEADER     = "X-RateLimit-Limit"
+	RATE_LIMIT_REMAINING_HEADER = "X-RateLimit-Remaining"
+	RATE_LIMIT_RESET_HEADER     = "X-RateLimit-Reset"
+	RETRY_AFTER_HEADER          = "Retry-After"
+)
+
+// MEMORY_RATE_LIMIT_SWEEP_INTERVAL is how often the memory store drops reset counters.
+const MEMORY_RATE_LIMIT_SWEEP_INTERVAL = time.Minute
+
+var ErrTooManyRequests = &ErrResponse{HTTPStatusCode: 429, StatusText: "Too many requests."}
+var ErrAccountLocked = &ErrResponse{HTTPStatusCode: 429, StatusText: "Too many failed attempts, try again later."}
+
+// RateLimitStore keeps fixed window counters. Instances of the api sharing
+// a store share the limits.
+type RateLimitStore interface {
+	// Increment adds a hit to the counter of key and returns the count with the
+	// time the counter resets. A counter past its reset starts over for window.
+	Increment(ctx context.Context, key string, window time.Duration, now time.Time) (int, time.Time, error)
+	// Get returns the counter of key, zero when there is none or it has reset.
+	Get(ctx context.Context, key string, now time.Time) (int, time.Time, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// RateLimitConfig sets the buckets. Auth limits the public user routes per
+// ip, Account limits sign in and password reset attempts per email and Write
+// limits the changes each user makes. After LockoutThreshold wrong passwords
+// within FailureWindow the account is locked for LockoutBase, doubled with
+// every next failure up to LockoutMax.
+type RateLimitConfig struct {
+	AuthLimit        int
+	AuthWindow       time.Duration
+	AccountLimit     int
+	AccountWindow    time.Duration
+	WriteLimit       int
+	WriteWindow      time.Duration
+	LockoutThreshold int
+	LockoutBase      time.Duration
+	LockoutMax       time.Duration
+	FailureWindow    time.Duration
+	// TrustProxy takes the client ip from the X-Forwarded-For and X-Real-IP headers
+	TrustProxy bool
+}
+
+func DefaultRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{
+		AuthLimit:        30,
+		AuthWindow:       time.Minute,
+		AccountLimit:     10,
+		AccountWindow:    time.Minute * 15,
+		WriteLimit:       120,
+		WriteWindow:      time.Minute,
+		LockoutThreshold: 5,
+		LockoutBase:      time.Minute,
+		LockoutMax:       time.Hour,
+		FailureWindow:    time.Hour * 24,
+	}
+}
+
+type rateLimiter struct {
+	store  RateLimitStore
+	config RateLimitConfig
+	now    func() time.Time
+}
+
+// NewRateLimiter fills the zero values of c with the defaults, a nil store keeps the counters in memory.
+func NewRateLimiter(store RateLimitStore, c RateLimitConfig) *rateLimiter {
+	d := DefaultRateLimitConfig()
+	if c.AuthLimit <= 0 || c.AuthWindow <= 0 {
+		c.AuthLimit, c.AuthWindow = d.AuthLimit, d.AuthWindow
+	}
+	if c.AccountLimit <= 0 || c.AccountWindow <= 0 {
+		c.AccountLimit, c.AccountWindow = d.AccountLimit, d.AccountWindow
+	}
+	if c.WriteLimit <= 0 || c.WriteWindow <= 0 {
+		c.WriteLimit, c.WriteWindow = d.WriteLimit, d.WriteWindow
+	}
+	if c.LockoutThreshold <= 0 {
+		c.LockoutThreshold = d.LockoutThreshold
+	}
+	if c.LockoutBase <= 0 {
+		c.LockoutBase = d.LockoutBase
+	}
+	if c.LockoutMax < c.LockoutBase {
+		c.LockoutMax = d.LockoutMax
+	}
+	if c.FailureWindow <= 0 {
+		c.FailureWindow = d.FailureWindow
+	}
+
+	if store == nil {
+		store = NewMemoryRateLimitStore()
+	}
+
+	return &rateLimiter{store: store, config: c, now: time.Now}
+}
+
+// Auth limits the requests of a client ip.
+func (l *rateLimiter) Auth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !l.allow(w, r, "auth:"+clientIp(r), l.config.AuthLimit, l.config.AuthWindow) {
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Write limits the requests changing data per user, it goes after Auth of apiMiddleware.
+func (l *rateLimiter) Write(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := "write:" + clientIp(r)
+		if token := retrieveToken(r); token != nil {
+			key = "write:" + token.UserId.String()
+		}
+
+		if !l.allow(w, r, key, l.config.WriteLimit, l.config.WriteWindow) {
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowAccount checks the attempts bucket and the lockout of the account, it
+// renders the error and returns false when the request must not go on.
+func (l *rateLimiter) allowAccount(w http.ResponseWriter, r *http.Request, account string) bool {
+	account = normalizeAccount(account)
+
+	locked, until, err := l.store.Get(r.Context(), "lock:"+account, l.now())
+	if err != nil {
+		log.Printf("rate limit store err %v", err)
+		return true
+	}
+
+	if locked > 0 {
+		w.Header().Set(RETRY_AFTER_HEADER, retryAfter(until, l.now()))
+		render.Render(w, r, ErrAccountLocked)
+		return false
+	}
+
+	return l.allow(w, r, "account:"+account, l.config.AccountLimit, l.config.AccountWindow)
+}
+
+// allowPasswordReset limits the reset emails sent to the account. It skips
+// the lockout, a reset is the way out of it.
+func (l *rateLimiter) allowPasswordReset(w http.ResponseWriter, r *http.Request, account string) bool {
+	return l.allow(w, r, "reset:"+normalizeAccount(account), l.config.AccountLimit, l.config.AccountWindow)
+}
+
+// failure counts a wrong password for the account and locks it once the failures reach the threshold.
+func (l *rateLimiter) failure(ctx context.Context, account string) {
+	account = normalizeAccount(account)
+	now := l.now()
+
+	failures, _, err := l.store.Increment(ctx, "fail:"+account, l.config.FailureWindow, now)
+	if err != nil {
+		log.Printf("rate limit store err %v", err)
+		return
+	}
+
+	if failures < l.config.LockoutThreshold {
+		return
+	}
+
+	_, _, err = l.store.Increment(ctx, "lock:"+account, l.lockoutDuration(failures), now)
+	if err != nil {
+		log.Printf("rate limit store err %v", err)
+	}
+}
+
+// success forgets the failures of the account after a correct password.
+func (l *rateLimiter) success(ctx context.Context, account string) {
+	account = normalizeAccount(account)
+	for _, key := range []string{"fail:" + account, "lock:" + account} {
+		if err := l.store.Delete(ctx, key); err != nil {
+			log.Printf("rate limit store err %v", err)
+		}
+	}
+}
+
+func (l *rateLimiter) lockoutDuration(failures int) time.Duration {
+	exponent := failures - l.config.LockoutThreshold
+	if exponent > 30 {
+		return l.config.LockoutMax
+	}
+
+	d := l.config.LockoutBase << uint(exponent)
+	if d <= 0 || d > l.config.LockoutMax {
+		return l.config.LockoutMax
+	}
+
+	return d
+}
+
+// allow counts the request in the bucket key and sets the rate limit headers.
+// The request is let through if the store fails, an unavailable store must
+// not take the api down.
+func (l *rateLimiter) allow(w http.ResponseWriter, r *http.Request, key string, limit int, window time.Duration) bool {
+	now := l.now()
+	count, reset, err := l.store.Increment(r.Context(), key, window, now)
+	if err != nil {
+		log.Printf("rate limit store err %v", err)
+		return true
+	}
+
+	remaining := limit - count
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	w.Header().Set(RATE_LIMIT_LIMIT_HEADER, strconv.Itoa(limit))
+	w.Header().Set(RATE_LIMIT_REMAINING_HEADER, strconv.Itoa(remaining))
+	w.Header().Set(RATE_LIMIT_RESET_HEADER, strconv.FormatInt(reset.Unix(), 10))
+
+	if count > limit {
+		w.Header().Set(RETRY_AFTER_HEADER, retryAfter(reset, now))
+		render.Render(w, r, ErrTooManyRequests)
+		return false
+	}
+
+	return true
+}
+
+// retryAfter returns the whole seconds until t, at least one.
+func retryAfter(t, now time.Time) string {
+	seconds := int64(math.Ceil(t.Sub(now).Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	return strconv.FormatInt(seconds, 10)
+}
+
+func normalizeAccount(account string) string {
+	return strings.ToLower(strings.TrimSpace(account))
+}
+
+// clientIp returns the host of RemoteAddr, it is the real client ip once
+// middleware.RealIP rewrote it from the proxy headers.
+func clientIp(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+type rateLimitCounter struct {
+	count int
+	reset time.Time
+}
+
+type memoryRateLimitStore struct {
+	mu       sync.Mutex
+	counters map[string]*rateLimitCounter
+	sweepAt  time.Time
+}
+
+// NewMemoryRateLimitStore keeps the counters in the process, each instance of the api has its own limits.
+func NewMemoryRateLimitStore() *memoryRateLimitStore {
+	return &memoryRateLimitStore{counters: map[string]*rateLimitCounter{}}
+}
+
+func (s *memoryRateLimitStore) Increment(ctx context.Context, key string, window time.Duration, now time.Time) (int, time.Time, error) {
+	if window <= 0 {
+		return 0, time.Time{}, errors.New(fmt.Sprintf("invalid rate limit window %s", window))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	c, ok := s.counters[key]
+	if !ok || !c.reset.After(now) {
+		c = &rateLimitCounter{reset: now.Add(window)}
+		s.counters[key] = c
+	}
+	c.count++
+
+	return c.count, c.reset, nil
+}
+
+func (s *memoryRateLimitStore) Get(ctx context.Context, key string, now time.Time) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.counters[key]
+	if !ok || !c.reset.After(now) {
+		return 0, time.Time{}, nil
+	}
+
+	return c.count, c.reset, nil
+}
+
+func (s *memoryRateLimitStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.counters, key)
+
+	return nil
+}
+
+func (s *memoryRateLimitStore) sweep(now time.Time) {
+	if now.Before(s.sweepAt) {
+		return
+	}
+
+	for key, c := range s.counters {
+		if !c.reset.After(now) {
+			delete(s.counters, key)
+		}
+	}
+	s.sweepAt = now.Add(MEMORY_RATE_LIMIT_SWEEP_INTERVAL)
+}
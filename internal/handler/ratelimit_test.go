@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterAuthBucket(t *testing.T) {
+	l := NewRateLimiter(nil, RateLimitConfig{AuthLimit: 2, AuthWindow: time.Minute})
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+
+	h := l.Auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve := func(addr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/signIn", nil)
+		r.RemoteAddr = addr
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := serve("10.0.0.1:1000"); w.Code != http.StatusOK {
+			t.Fatalf("request %d: got status %d", i, w.Code)
+		}
+	}
+
+	w := serve("10.0.0.1:1001")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the third request to be limited, got %d", w.Code)
+	}
+	if w.Header().Get(RETRY_AFTER_HEADER) != "60" || w.Header().Get(RATE_LIMIT_REMAINING_HEADER) != "0" {
+		t.Fatalf("unexpected headers %v", w.Header())
+	}
+
+	if w := serve("10.0.0.2:1000"); w.Code != http.StatusOK {
+		t.Fatalf("another ip must have its own bucket, got %d", w.Code)
+	}
+
+	now = now.Add(time.Minute)
+	if w := serve("10.0.0.1:1000"); w.Code != http.StatusOK {
+		t.Fatalf("the bucket must reset after the window, got %d", w.Code)
+	}
+}
+
+func TestRateLimiterLockout(t *testing.T) {
+	l := NewRateLimiter(nil, RateLimitConfig{LockoutThreshold: 3, LockoutBase: time.Minute, LockoutMax: time.Minute * 3, AccountLimit: 100, AccountWindow: time.Hour})
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	allowed := func() (bool, string) {
+		w := httptest.NewRecorder()
+		ok := l.allowAccount(w, httptest.NewRequest(http.MethodPost, "/signIn", nil), " User@example.com")
+		return ok, w.Header().Get(RETRY_AFTER_HEADER)
+	}
+
+	for i := 0; i < 2; i++ {
+		l.failure(ctx, "user@example.com")
+	}
+	if ok, _ := allowed(); !ok {
+		t.Fatal("locked before the threshold")
+	}
+
+	// every next failure doubles the lockout up to the max
+	for _, expected := range []string{"60", "120", "180"} {
+		l.failure(ctx, "user@example.com")
+		ok, retry := allowed()
+		if ok || retry != expected {
+			t.Fatalf("expected a lockout of %s seconds, got %v %s", expected, ok, retry)
+		}
+		now = now.Add(time.Hour)
+	}
+
+	l.success(ctx, "USER@example.com")
+	l.failure(ctx, "user@example.com")
+	if ok, _ := allowed(); !ok {
+		t.Fatal("failures must be forgotten after a success")
+	}
+}
@@ -0,0 +1,539 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/IMBgl/go-wallet-api/internal/domain"
+	"github.com/IMBgl/go-wallet-api/internal/service"
+	"github.com/IMBgl/go-wallet-api/pkg/validator"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"math/big"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type RecurringHandler struct {
+	recurringService service.RecurringService
+	middleware       *apiMiddleware
+}
+
+func (h RecurringHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+	r.Use(h.middleware.Auth)
+	r.Use(h.middleware.limiter.Write)
+	r.Use(h.middleware.Scope(service.SCOPE_TRANSACTION_READ, service.SCOPE_TRANSACTION_WRITE))
+	r.Post("/", h.create)
+	r.Get("/", h.getList)
+
+	r.Route("/{recurringId}", func(r chi.Router) {
+		r.Use(h.allowRecurring)
+		r.Get("/", h.getOne)
+		r.Patch("/", h.update)
+		r.Delete("/", h.delete)
+		r.Get("/occurrences", h.preview)
+		r.Patch("/occurrences/{date}", h.updateOccurrence)
+		r.Post("/occurrences/{date}/skip", h.skip)
+	})
+
+	return r
+}
+
+type RecurringCreateRequest struct {
+	Comment       string                 `json:"comment,omitempty"`
+	Currency      string                 `json:"currency"`
+	Type          string                 `json:"type"`
+	Amount        json.Number            `json:"amount"`
+	CategoryId    string                 `json:"categoryId"`
+	WalletId      string                 `json:"walletId"`
+	Frequency     string                 `json:"frequency"`
+	Interval      int                    `json:"interval,omitempty"`
+	DayOfMonth    int                    `json:"dayOfMonth,omitempty"`
+	StartDate     string                 `json:"startDate"`
+	EndDate       *string                `json:"endDate,omitempty"`
+	Count         *int                   `json:"count,omitempty"`
+	AmountVal     domain.Money           `json:"-"`
+	WalletIdVal   uuid.UUID              `json:"-"`
+	CategoryIdVal uuid.UUID              `json:"-"`
+	TypeVal       domain.TransactionType `json:"-"`
+	FrequencyVal  domain.Frequency       `json:"-"`
+	StartDateVal  time.Time              `json:"-"`
+	EndDateVal    *time.Time             `json:"-"`
+}
+
+type RecurringUpdateRequest struct {
+	Comment       *string      `json:"comment,omitempty"`
+	Amount        *json.Number `json:"amount,omitempty"`
+	CategoryId    *string      `json:"categoryId,omitempty"`
+	AmountVal     *big.Rat     `json:"-"`
+	CategoryIdVal *uuid.UUID   `json:"-"`
+}
+
+type OccurrenceUpdateRequest struct {
+	Comment   *string      `json:"comment,omitempty"`
+	Amount    *json.Number `json:"amount,omitempty"`
+	AmountVal *big.Rat     `json:"-"`
+}
+
+type RecurringResponse struct {
+	Id         string      `json:"id"`
+	Comment    string      `json:"comment"`
+	Currency   string      `json:"currency"`
+	Type       string      `json:"type"`
+	Amount     json.Number `json:"amount"`
+	CategoryId string      `json:"categoryId"`
+	WalletId   string      `json:"walletId"`
+	Frequency  string      `json:"frequency"`
+	Interval   int         `json:"interval"`
+	DayOfMonth *int        `json:"dayOfMonth"`
+	StartDate  string      `json:"startDate"`
+	EndDate    *string     `json:"endDate"`
+	Count      *int        `json:"count"`
+	NextDate   *string     `json:"nextDate"`
+	CreatedAt  string      `json:"createdAt"`
+}
+
+type OccurrenceResponse struct {
+	Date          string      `json:"date"`
+	Status        string      `json:"status"`
+	Amount        json.Number `json:"amount"`
+	Comment       string      `json:"comment"`
+	TransactionId *string     `json:"transactionId"`
+	Error         *string     `json:"error"`
+}
+
+func NewRecurringListResponse(list []*domain.RecurringTransaction) []*RecurringResponse {
+	responseList := []*RecurringResponse{}
+	for _, rt := range list {
+		responseList = append(responseList, NewRecurringResponse(rt))
+	}
+
+	return responseList
+}
+
+func NewRecurringResponse(rt *domain.RecurringTransaction) *RecurringResponse {
+	response := &RecurringResponse{
+		Id:         rt.Id.String(),
+		Comment:    rt.Comment,
+		Currency:   rt.Amount.Currency().Val(),
+		Type:       rt.Type.Val(),
+		Amount:     json.Number(rt.Amount.String()),
+		CategoryId: rt.CategoryId.String(),
+		WalletId:   rt.WalletId.String(),
+		Frequency:  rt.Schedule.Frequency.Val(),
+		Interval:   rt.Schedule.Interval,
+		StartDate:  rt.Schedule.StartDate.Format(DateFormat()),
+		Count:      rt.Schedule.Count,
+		CreatedAt:  rt.CreatedAt.Format(DateTimeFormat()),
+	}
+
+	if rt.Schedule.Frequency.IsMonthly() {
+		dayOfMonth := rt.Schedule.DayOfMonth
+		response.DayOfMonth = &dayOfMonth
+	}
+
+	if rt.Schedule.EndDate != nil {
+		endDate := rt.Schedule.EndDate.AddDate(0, 0, -1).Format(DateFormat())
+		response.EndDate = &endDate
+	}
+
+	if rt.NextDate != nil {
+		nextDate := rt.NextDate.Format(DateFormat())
+		response.NextDate = &nextDate
+	}
+
+	return response
+}
+
+func NewOccurrenceListResponse(list []*service.Occurrence) []*OccurrenceResponse {
+	responseList := []*OccurrenceResponse{}
+	for _, o := range list {
+		responseList = append(responseList, NewOccurrenceResponse(o))
+	}
+
+	return responseList
+}
+
+func NewOccurrenceResponse(o *service.Occurrence) *OccurrenceResponse {
+	response := &OccurrenceResponse{
+		Date:    o.Date.Format(DateFormat()),
+		Status:  o.Status.Val(),
+		Amount:  json.Number(o.Amount.String()),
+		Comment: o.Comment,
+	}
+
+	if o.TransactionId != nil {
+		transactionId := o.TransactionId.String()
+		response.TransactionId = &transactionId
+	}
+
+	response.Error = o.Error
+
+	return response
+}
+
+func (data *RecurringCreateRequest) Bind(r *http.Request) error {
+	currency, err := domain.CurrencyFromString(data.Currency)
+	if err != nil {
+		return currencyError("currency", err)
+	}
+
+	data.TypeVal, err = domain.TransactionTypeFromString(data.Type)
+	if err != nil {
+		return errors.New("type value must be one of 'in', 'out")
+	}
+
+	amount, err := parsePositiveDecimal(data.Amount, "amount")
+	if err != nil {
+		return err
+	}
+
+	data.AmountVal, err = domain.MoneyFromRat(amount, currency)
+	if err != nil {
+		return err
+	}
+
+	data.WalletIdVal, err = validator.Uuid(data.WalletId, "walletId")
+	if err != nil {
+		return err
+	}
+
+	data.CategoryIdVal, err = validator.Uuid(data.CategoryId, "categoryId")
+	if err != nil {
+		return err
+	}
+
+	data.FrequencyVal, err = domain.FrequencyFromString(data.Frequency)
+	if err != nil {
+		return errors.New("frequency value must be one of 'daily', 'weekly', 'monthly'")
+	}
+
+	if data.Interval == 0 {
+		data.Interval = 1
+	}
+
+	data.StartDateVal = time.Now().UTC().Truncate(24 * time.Hour)
+	if data.StartDate != "" {
+		data.StartDateVal, err = time.Parse(DateFormat(), data.StartDate)
+		if err != nil {
+			return errors.New("startDate value must be date in format " + DateFormat())
+		}
+	}
+
+	if data.EndDate != nil {
+		// the last day is inclusive for the client
+		endDate, err := time.Parse(DateFormat(), *data.EndDate)
+		if err != nil {
+			return errors.New("endDate value must be date in format " + DateFormat())
+		}
+		endDate = endDate.AddDate(0, 0, 1)
+		data.EndDateVal = &endDate
+	}
+
+	return nil
+}
+
+func (data *RecurringUpdateRequest) Bind(r *http.Request) error {
+	if data.Amount != nil {
+		amount, err := parsePositiveDecimal(*data.Amount, "amount")
+		if err != nil {
+			return err
+		}
+		data.AmountVal = amount
+	}
+
+	if data.CategoryId != nil {
+		categoryId, err := validator.Uuid(*data.CategoryId, "categoryId")
+		if err != nil {
+			return err
+		}
+		data.CategoryIdVal = &categoryId
+	}
+
+	return nil
+}
+
+func (data *OccurrenceUpdateRequest) Bind(r *http.Request) error {
+	if data.Amount != nil {
+		amount, err := parsePositiveDecimal(*data.Amount, "amount")
+		if err != nil {
+			return err
+		}
+		data.AmountVal = amount
+	}
+
+	return nil
+}
+
+func (h *RecurringHandler) create(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+
+	data := &RecurringCreateRequest{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	if !allowWalletOrFail(w, r, token, data.WalletIdVal) {
+		return
+	}
+
+	createRequest := &service.RecurringCreateRequest{
+		UserId:          token.UserId,
+		WalletId:        data.WalletIdVal,
+		CategoryId:      data.CategoryIdVal,
+		Amount:          data.AmountVal,
+		TransactionType: data.TypeVal,
+		Comment:         data.Comment,
+		Frequency:       data.FrequencyVal,
+		Interval:        data.Interval,
+		DayOfMonth:      data.DayOfMonth,
+		StartDate:       data.StartDateVal,
+		EndDate:         data.EndDateVal,
+		Count:           data.Count,
+	}
+
+	recurring, err := h.recurringService.Create(context.Background(), createRequest)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, NewRecurringResponse(recurring))
+}
+
+func (h *RecurringHandler) getList(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+
+	list, err := h.recurringService.GetList(context.Background(), &service.RecurringGetListRequest{UserId: token.UserId})
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	allowed := []*domain.RecurringTransaction{}
+	for _, rt := range list {
+		if token.AllowsWallet(rt.WalletId) {
+			allowed = append(allowed, rt)
+		}
+	}
+
+	render.JSON(w, r, NewRecurringListResponse(allowed))
+}
+
+// allowRecurring checks the wallet of the recurring transaction for api keys restricted to some wallets.
+func (h *RecurringHandler) allowRecurring(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := retrieveTokenOrFail(w, r)
+		if token == nil {
+			return
+		}
+
+		if token.IsWalletRestricted() {
+			recurringId := retrieveUuidOrFail(w, r, "recurringId")
+			recurring, err := h.recurringService.GetOne(context.Background(), &service.RecurringGetOneRequest{UserId: token.UserId, RecurringId: recurringId})
+			if err != nil {
+				render.Render(w, r, ErrInvalidRequest(err))
+				return
+			}
+
+			if !allowWalletOrFail(w, r, token, recurring.WalletId) {
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *RecurringHandler) getOne(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+	recurringId := retrieveUuidOrFail(w, r, "recurringId")
+
+	getOneRequest := &service.RecurringGetOneRequest{
+		UserId:      token.UserId,
+		RecurringId: recurringId,
+	}
+
+	recurring, err := h.recurringService.GetOne(context.Background(), getOneRequest)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, NewRecurringResponse(recurring))
+}
+
+func (h *RecurringHandler) update(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+	recurringId := retrieveUuidOrFail(w, r, "recurringId")
+
+	data := &RecurringUpdateRequest{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	updateRequest := &service.RecurringUpdateRequest{
+		UserId:      token.UserId,
+		RecurringId: recurringId,
+		CategoryId:  data.CategoryIdVal,
+		Amount:      data.AmountVal,
+		Comment:     data.Comment,
+	}
+
+	recurring, err := h.recurringService.Update(context.Background(), updateRequest)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, NewRecurringResponse(recurring))
+}
+
+func (h *RecurringHandler) delete(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+	recurringId := retrieveUuidOrFail(w, r, "recurringId")
+
+	deleteRequest := &service.RecurringDeleteRequest{
+		UserId:      token.UserId,
+		RecurringId: recurringId,
+	}
+
+	err := h.recurringService.Delete(context.Background(), deleteRequest)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, map[string]string{})
+}
+
+func (h *RecurringHandler) preview(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+	recurringId := retrieveUuidOrFail(w, r, "recurringId")
+
+	previewRequest := &service.RecurringPreviewRequest{
+		UserId:      token.UserId,
+		RecurringId: recurringId,
+	}
+
+	query := r.URL.Query()
+	var err error
+	if v := query.Get("from"); v != "" {
+		previewRequest.From, err = parseDateParam(v, "from")
+		if err != nil {
+			render.Render(w, r, ErrInvalidRequest(err))
+			return
+		}
+	}
+
+	if v := query.Get("to"); v != "" {
+		previewRequest.To, err = parseDateParam(v, "to")
+		if err != nil {
+			render.Render(w, r, ErrInvalidRequest(err))
+			return
+		}
+	}
+
+	if v := query.Get("limit"); v != "" {
+		previewRequest.Limit, err = strconv.Atoi(v)
+		if err != nil || previewRequest.Limit <= 0 {
+			render.Render(w, r, ErrInvalidRequest(errors.New("limit value must be positive integer")))
+			return
+		}
+	}
+
+	list, err := h.recurringService.Preview(context.Background(), previewRequest)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, NewOccurrenceListResponse(list))
+}
+
+func (h *RecurringHandler) skip(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+	recurringId := retrieveUuidOrFail(w, r, "recurringId")
+
+	date, err := time.Parse(DateFormat(), chi.URLParam(r, "date"))
+	if err != nil {
+		render.Render(w, r, ErrNotFound)
+		return
+	}
+
+	skipRequest := &service.OccurrenceSkipRequest{
+		UserId:      token.UserId,
+		RecurringId: recurringId,
+		Date:        date,
+	}
+
+	occurrence, err := h.recurringService.Skip(context.Background(), skipRequest)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, NewOccurrenceResponse(occurrence))
+}
+
+func (h *RecurringHandler) updateOccurrence(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+	recurringId := retrieveUuidOrFail(w, r, "recurringId")
+
+	date, err := time.Parse(DateFormat(), chi.URLParam(r, "date"))
+	if err != nil {
+		render.Render(w, r, ErrNotFound)
+		return
+	}
+
+	data := &OccurrenceUpdateRequest{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	updateRequest := &service.OccurrenceUpdateRequest{
+		UserId:      token.UserId,
+		RecurringId: recurringId,
+		Date:        date,
+		Amount:      data.AmountVal,
+		Comment:     data.Comment,
+	}
+
+	occurrence, err := h.recurringService.UpdateOccurrence(context.Background(), updateRequest)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, NewOccurrenceResponse(occurrence))
+}
@@ -0,0 +1,479 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/IMBgl/go-wallet-api/internal/domain"
+	"github.com/IMBgl/go-wallet-api/internal/service"
+	"github.com/IMBgl/go-wallet-api/pkg/validator"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type ReportHandler struct {
+	reportService service.ReportService
+	middleware    *apiMiddleware
+}
+
+func (h ReportHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+	r.Use(h.middleware.Auth)
+	r.Use(h.middleware.Scope(service.SCOPE_REPORT_READ, service.SCOPE_REPORT_READ))
+	// reports span every wallet of the user
+	r.Use(h.middleware.Unrestricted)
+	r.Get("/net-worth", h.netWorth)
+	r.Get("/net-worth/history", h.netWorthHistory)
+	r.Get("/category", h.category)
+	r.Get("/cashflow", h.cashflow)
+
+	return r
+}
+
+type WalletWorthResponse struct {
+	WalletId string       `json:"walletId"`
+	Name     string       `json:"name"`
+	Currency string       `json:"currency"`
+	Balance  json.Number  `json:"balance"`
+	Amount   json.Number  `json:"amount"`
+	Rate     *json.Number `json:"rate"`
+}
+
+type NetWorthResponse struct {
+	Currency string                 `json:"currency"`
+	Date     string                 `json:"date"`
+	Total    json.Number            `json:"total"`
+	Wallets  []*WalletWorthResponse `json:"wallets"`
+}
+
+type NetWorthPointResponse struct {
+	Start string      `json:"start"`
+	End   string      `json:"end"`
+	Total json.Number `json:"total"`
+}
+
+type NetWorthHistoryResponse struct {
+	Currency string                   `json:"currency"`
+	Interval string                   `json:"interval"`
+	Items    []*NetWorthPointResponse `json:"items"`
+}
+
+type CategoryAmountResponse struct {
+	Own           json.Number  `json:"own"`
+	Total         json.Number  `json:"total"`
+	Percent       json.Number  `json:"percent"`
+	Previous      json.Number  `json:"previous"`
+	Change        json.Number  `json:"change"`
+	ChangePercent *json.Number `json:"changePercent"`
+}
+
+type CategoryReportNodeResponse struct {
+	*CategoryResponse
+	Parent   *CategoryResponse             `json:"parent"`
+	Children []*CategoryReportNodeResponse `json:"children"`
+	In       *CategoryAmountResponse       `json:"in"`
+	Out      *CategoryAmountResponse       `json:"out"`
+}
+
+type CategoryReportResponse struct {
+	Currency     string                        `json:"currency"`
+	DateFrom     string                        `json:"dateFrom"`
+	DateTo       string                        `json:"dateTo"`
+	PreviousFrom string                        `json:"previousFrom"`
+	PreviousTo   string                        `json:"previousTo"`
+	In           *CategoryAmountResponse       `json:"in"`
+	Out          *CategoryAmountResponse       `json:"out"`
+	Items        []*CategoryReportNodeResponse `json:"items"`
+}
+
+type CashflowGroupResponse struct {
+	Id       *string     `json:"id"`
+	Name     string      `json:"name"`
+	In       json.Number `json:"in"`
+	Out      json.Number `json:"out"`
+	Transfer json.Number `json:"transfer"`
+	Net      json.Number `json:"net"`
+}
+
+type CashflowPeriodResponse struct {
+	Start    string                   `json:"start"`
+	End      string                   `json:"end"`
+	In       json.Number              `json:"in"`
+	Out      json.Number              `json:"out"`
+	Transfer json.Number              `json:"transfer"`
+	Net      json.Number              `json:"net"`
+	Groups   []*CashflowGroupResponse `json:"groups"`
+}
+
+type CashflowResponse struct {
+	Currency string                    `json:"currency"`
+	Interval string                    `json:"interval"`
+	GroupBy  string                    `json:"groupBy"`
+	TimeZone string                    `json:"timeZone"`
+	Items    []*CashflowPeriodResponse `json:"items"`
+}
+
+func NewNetWorthResponse(n *service.NetWorth) *NetWorthResponse {
+	response := &NetWorthResponse{
+		Currency: n.Currency.Val(),
+		Date:     n.Date.Format(DateFormat()),
+		Total:    json.Number(n.Total.String()),
+		Wallets:  []*WalletWorthResponse{},
+	}
+
+	for _, w := range n.Wallets {
+		wallet := &WalletWorthResponse{
+			WalletId: w.Wallet.Id.String(),
+			Name:     w.Wallet.Name,
+			Currency: w.Balance.Currency().Val(),
+			Balance:  json.Number(w.Balance.String()),
+			Amount:   json.Number(w.Amount.String()),
+		}
+		if w.Rate != nil {
+			rate := json.Number(w.Rate.FloatString(service.EXCHANGE_RATE_SCALE))
+			wallet.Rate = &rate
+		}
+		response.Wallets = append(response.Wallets, wallet)
+	}
+
+	return response
+}
+
+func NewNetWorthHistoryResponse(h *service.NetWorthHistory) *NetWorthHistoryResponse {
+	response := &NetWorthHistoryResponse{
+		Currency: h.Currency.Val(),
+		Interval: h.Interval.Val(),
+		Items:    []*NetWorthPointResponse{},
+	}
+
+	for _, p := range h.Points {
+		response.Items = append(response.Items, &NetWorthPointResponse{
+			Start: p.Start.Format(DateFormat()),
+			End:   p.End.AddDate(0, 0, -1).Format(DateFormat()),
+			Total: json.Number(p.Total.String()),
+		})
+	}
+
+	return response
+}
+
+func NewCategoryAmountResponse(a *service.CategoryAmount) *CategoryAmountResponse {
+	response := &CategoryAmountResponse{
+		Own:      json.Number(a.Own.String()),
+		Total:    json.Number(a.Total.String()),
+		Percent:  json.Number(a.Percent.FloatString(2)),
+		Previous: json.Number(a.Previous.String()),
+		Change:   json.Number(a.Change.String()),
+	}
+	if a.ChangePercent != nil {
+		changePercent := json.Number(a.ChangePercent.FloatString(2))
+		response.ChangePercent = &changePercent
+	}
+
+	return response
+}
+
+func NewCategoryReportNodeResponse(n *service.CategoryReportNode) *CategoryReportNodeResponse {
+	response := &CategoryReportNodeResponse{
+		CategoryResponse: NewCategoryResponse(n.Category),
+		Parent:           NewCategoryResponse(n.Parent),
+		Children:         []*CategoryReportNodeResponse{},
+		In:               NewCategoryAmountResponse(n.In),
+		Out:              NewCategoryAmountResponse(n.Out),
+	}
+
+	for _, child := range n.Children {
+		response.Children = append(response.Children, NewCategoryReportNodeResponse(child))
+	}
+
+	return response
+}
+
+func NewCategoryReportResponse(c *service.CategoryReport) *CategoryReportResponse {
+	response := &CategoryReportResponse{
+		Currency:     c.Currency.Val(),
+		DateFrom:     c.DateFrom.Format(DateFormat()),
+		DateTo:       c.DateTo.AddDate(0, 0, -1).Format(DateFormat()),
+		PreviousFrom: c.PreviousFrom.Format(DateFormat()),
+		PreviousTo:   c.PreviousTo.AddDate(0, 0, -1).Format(DateFormat()),
+		In:           NewCategoryAmountResponse(c.In),
+		Out:          NewCategoryAmountResponse(c.Out),
+		Items:        []*CategoryReportNodeResponse{},
+	}
+
+	for _, item := range c.Items {
+		response.Items = append(response.Items, NewCategoryReportNodeResponse(item))
+	}
+
+	return response
+}
+
+func NewCashflowResponse(c *service.Cashflow) *CashflowResponse {
+	response := &CashflowResponse{
+		Currency: c.Currency.Val(),
+		Interval: c.Interval.Val(),
+		GroupBy:  c.GroupBy.Val(),
+		TimeZone: c.TimeZone.String(),
+		Items:    []*CashflowPeriodResponse{},
+	}
+
+	for _, p := range c.Items {
+		period := &CashflowPeriodResponse{
+			Start:    p.Start.Format(DateFormat()),
+			End:      p.End.AddDate(0, 0, -1).Format(DateFormat()),
+			In:       json.Number(p.In.String()),
+			Out:      json.Number(p.Out.String()),
+			Transfer: json.Number(p.Transfer.String()),
+			Net:      json.Number(p.Net.String()),
+			Groups:   []*CashflowGroupResponse{},
+		}
+
+		for _, g := range p.Groups {
+			group := &CashflowGroupResponse{
+				Name:     g.Name,
+				In:       json.Number(g.In.String()),
+				Out:      json.Number(g.Out.String()),
+				Transfer: json.Number(g.Transfer.String()),
+				Net:      json.Number(g.Net.String()),
+			}
+			if g.Id != nil {
+				id := g.Id.String()
+				group.Id = &id
+			}
+			period.Groups = append(period.Groups, group)
+		}
+
+		response.Items = append(response.Items, period)
+	}
+
+	return response
+}
+
+func (h *ReportHandler) netWorth(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+
+	q := r.URL.Query()
+	request := &service.NetWorthRequest{UserId: token.UserId}
+
+	currency, err := reportCurrency(q)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	request.Currency = currency
+
+	if v := q.Get("date"); v != "" {
+		date, err := time.Parse(DateFormat(), v)
+		if err != nil {
+			render.Render(w, r, ErrInvalidRequest(errors.New("date value must be date in format "+DateFormat())))
+			return
+		}
+		request.Date = &date
+	}
+
+	netWorth, err := h.reportService.NetWorth(context.Background(), request)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, NewNetWorthResponse(netWorth))
+}
+
+// netWorthHistory returns the net worth at the end of each interval, monthly
+// for the last year by default.
+func (h *ReportHandler) netWorthHistory(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+
+	q := r.URL.Query()
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	request := &service.NetWorthHistoryRequest{
+		UserId:   token.UserId,
+		DateFrom: today.AddDate(-1, 0, 0),
+		DateTo:   today,
+		Interval: domain.ReportIntervalMonth(),
+	}
+
+	currency, err := reportCurrency(q)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	request.Currency = currency
+
+	for name, dst := range map[string]*time.Time{"dateFrom": &request.DateFrom, "dateTo": &request.DateTo} {
+		if v := q.Get(name); v != "" {
+			date, err := time.Parse(DateFormat(), v)
+			if err != nil {
+				render.Render(w, r, ErrInvalidRequest(errors.New(name+" value must be date in format "+DateFormat())))
+				return
+			}
+			*dst = date
+		}
+	}
+
+	if v := q.Get("interval"); v != "" {
+		request.Interval, err = domain.ReportIntervalFromString(v)
+		if err != nil {
+			render.Render(w, r, ErrInvalidRequest(errors.New("interval value must be one of 'day', 'week', 'month', 'quarter', 'year'")))
+			return
+		}
+	}
+
+	history, err := h.reportService.NetWorthHistory(context.Background(), request)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, NewNetWorthHistoryResponse(history))
+}
+
+// category returns the totals per category for the dates from dateFrom to
+// dateTo inclusive, the current month by default.
+func (h *ReportHandler) category(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+
+	q := r.URL.Query()
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	request := &service.CategoryReportRequest{
+		UserId:   token.UserId,
+		DateFrom: today.AddDate(0, 0, 1-today.Day()),
+	}
+	request.DateTo = request.DateFrom.AddDate(0, 1, 0)
+
+	currency, err := reportCurrency(q)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	request.Currency = currency
+
+	for name, dst := range map[string]*time.Time{"dateFrom": &request.DateFrom, "dateTo": &request.DateTo} {
+		if v := q.Get(name); v != "" {
+			date, err := time.Parse(DateFormat(), v)
+			if err != nil {
+				render.Render(w, r, ErrInvalidRequest(errors.New(name+" value must be date in format "+DateFormat())))
+				return
+			}
+			if name == "dateTo" {
+				date = date.AddDate(0, 0, 1)
+			}
+			*dst = date
+		}
+	}
+
+	if v := q.Get("walletId"); v != "" {
+		walletId, err := validator.Uuid(v, "walletId")
+		if err != nil {
+			render.Render(w, r, ErrInvalidRequest(err))
+			return
+		}
+		request.WalletId = &walletId
+	}
+
+	if v := q.Get("categoryId"); v != "" {
+		categoryId, err := validator.Uuid(v, "categoryId")
+		if err != nil {
+			render.Render(w, r, ErrInvalidRequest(err))
+			return
+		}
+		request.CategoryId = &categoryId
+	}
+
+	report, err := h.reportService.CategoryReport(context.Background(), request)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, NewCategoryReportResponse(report))
+}
+
+// cashflow returns the money in and out per interval split by wallet or
+// category, monthly by wallet for the last year by default.
+func (h *ReportHandler) cashflow(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+
+	q := r.URL.Query()
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	request := &service.CashflowRequest{
+		UserId:   token.UserId,
+		DateFrom: today.AddDate(-1, 0, 0),
+		DateTo:   today,
+		Interval: domain.ReportIntervalMonth(),
+		GroupBy:  domain.ReportGroupWallet(),
+	}
+
+	currency, err := reportCurrency(q)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	request.Currency = currency
+
+	for name, dst := range map[string]*time.Time{"dateFrom": &request.DateFrom, "dateTo": &request.DateTo} {
+		if v := q.Get(name); v != "" {
+			date, err := time.Parse(DateFormat(), v)
+			if err != nil {
+				render.Render(w, r, ErrInvalidRequest(errors.New(name+" value must be date in format "+DateFormat())))
+				return
+			}
+			*dst = date
+		}
+	}
+
+	if v := q.Get("interval"); v != "" {
+		request.Interval, err = domain.ReportIntervalFromString(v)
+		if err != nil {
+			render.Render(w, r, ErrInvalidRequest(errors.New("interval value must be one of 'day', 'week', 'month', 'quarter', 'year'")))
+			return
+		}
+	}
+
+	if v := q.Get("groupBy"); v != "" {
+		request.GroupBy, err = domain.ReportGroupFromString(v)
+		if err != nil {
+			render.Render(w, r, ErrInvalidRequest(errors.New("groupBy value must be one of 'wallet', 'category'")))
+			return
+		}
+	}
+
+	cashflow, err := h.reportService.Cashflow(context.Background(), request)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, NewCashflowResponse(cashflow))
+}
+
+// reportCurrency reads the currency a report is converted to, nil for the base currency of the user.
+func reportCurrency(q url.Values) (*domain.Currency, error) {
+	v := q.Get("currency")
+	if v == "" {
+		return nil, nil
+	}
+
+	currency, err := domain.CurrencyFromCode(v)
+	if err != nil {
+		return nil, currencyError("currency", err)
+	}
+
+	return &currency, nil
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"github.com/IMBgl/go-wallet-api/internal/domain"
 	"github.com/IMBgl/go-wallet-api/internal/service"
@@ -9,7 +10,12 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
 	"github.com/google/uuid"
+	"math/big"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type TransactionHandler struct {
@@ -20,7 +26,16 @@ type TransactionHandler struct {
 func (h TransactionHandler) Routes() chi.Router {
 	r := chi.NewRouter()
 	r.Use(h.middleware.Auth)
+	r.Use(h.middleware.limiter.Write)
+	r.Use(h.middleware.Scope(service.SCOPE_TRANSACTION_READ, service.SCOPE_TRANSACTION_WRITE))
 	r.Post("/", h.create)
+	r.Get("/", h.getList)
+
+	r.Route("/{transactionId}", func(r chi.Router) {
+		r.Put("/", h.update)
+		r.Patch("/", h.patch)
+		r.Delete("/", h.delete)
+	})
 
 	return r
 }
@@ -29,48 +44,93 @@ type TransactionCreateRequest struct {
 	Comment       string                 `json:"comment,omitempty"`
 	Currency      string                 `json:"currency"`
 	Type          string                 `json:"type"`
-	Amount        interface{}            `json:"amount"`
+	Amount        json.Number            `json:"amount"`
 	CategoryId    string                 `json:"categoryId"`
 	WalletId      string                 `json:"walletId"`
-	AmountVal     float32                `json:"-"`
+	Rate          *json.Number           `json:"rate,omitempty"`
+	AmountVal     domain.Money           `json:"-"`
 	WalletIdVal   uuid.UUID              `json:"-"`
 	CategoryIdVal uuid.UUID              `json:"-"`
 	TypeVal       domain.TransactionType `json:"-"`
-	CurrencyVal   domain.Currency        `json:"-"`
+	RateVal       *big.Rat               `json:"-"`
+}
+
+type TransactionPatchRequest struct {
+	Comment       *string                 `json:"comment,omitempty"`
+	Currency      *string                 `json:"currency,omitempty"`
+	Type          *string                 `json:"type,omitempty"`
+	Amount        *json.Number            `json:"amount,omitempty"`
+	CategoryId    *string                 `json:"categoryId,omitempty"`
+	WalletId      *string                 `json:"walletId,omitempty"`
+	Rate          *json.Number            `json:"rate,omitempty"`
+	AmountVal     *big.Rat                `json:"-"`
+	WalletIdVal   *uuid.UUID              `json:"-"`
+	CategoryIdVal *uuid.UUID              `json:"-"`
+	TypeVal       *domain.TransactionType `json:"-"`
+	CurrencyVal   *domain.Currency        `json:"-"`
+	RateVal       *big.Rat                `json:"-"`
 }
 
 type TransactionResponse struct {
-	Id         string  `json:"id"`
-	Comment    string  `json:"comment"`
-	Currency   string  `json:"currency"`
-	Type       string  `json:"type"`
-	Amount     float32 `json:"amount"`
-	CategoryId string  `json:"categoryId"`
-	WalletId   string  `json:"walletId"`
-	UserId     string  `json:"userId"`
-	CreatedAt  string  `json:"createdAt"`
+	Id         string      `json:"id"`
+	Comment    string      `json:"comment"`
+	Currency   string      `json:"currency"`
+	Type       string      `json:"type"`
+	Amount     json.Number `json:"amount"`
+	CategoryId string      `json:"categoryId"`
+	WalletId   string      `json:"walletId"`
+	UserId     string      `json:"userId"`
+	CreatedAt  string      `json:"createdAt"`
+	ExternalId string      `json:"externalId,omitempty"`
+	// OriginalAmount, OriginalCurrency and Rate are set when the amount was entered in another currency
+	OriginalAmount   *json.Number `json:"originalAmount,omitempty"`
+	OriginalCurrency string       `json:"originalCurrency,omitempty"`
+	Rate             *json.Number `json:"rate,omitempty"`
+}
+
+type TransactionListResponse struct {
+	Items      []*TransactionResponse `json:"items"`
+	NextCursor string                 `json:"nextCursor,omitempty"`
+}
+
+func NewTransactionListResponse(l *service.TransactionList) *TransactionListResponse {
+	response := &TransactionListResponse{Items: []*TransactionResponse{}}
+	for _, t := range l.Items {
+		response.Items = append(response.Items, NewTransactionResponse(t))
+	}
+	response.NextCursor = l.NextCursor
+
+	return response
 }
 
 func NewTransactionResponse(e *domain.Transaction) *TransactionResponse {
-	return &TransactionResponse{
+	response := &TransactionResponse{
 		Id:         e.Id.String(),
 		UserId:     e.UserId.String(),
 		CategoryId: e.CategoryId.String(),
 		WalletId:   e.WalletId.String(),
-		Currency:   e.Currency.Val(),
+		Currency:   e.Amount.Currency().Val(),
 		Type:       e.Type.Val(),
 		Comment:    e.Comment,
 		CreatedAt:  e.CreatedAt.Format(DateTimeFormat()),
-		Amount:     e.Amount,
+		Amount:     json.Number(e.Amount.String()),
+		ExternalId: e.ExternalId,
+	}
+
+	if e.OriginalAmount != nil && e.Rate != nil {
+		amount := json.Number(e.OriginalAmount.String())
+		rate := json.Number(e.Rate.FloatString(service.EXCHANGE_RATE_SCALE))
+		response.OriginalAmount, response.OriginalCurrency, response.Rate = &amount, e.OriginalAmount.Currency().Val(), &rate
 	}
+
+	return response
 }
 
 func (data *TransactionCreateRequest) Bind(r *http.Request) error {
 	currency, err := domain.CurrencyFromString(data.Currency)
 	if err != nil {
-		return errors.New("currency value must be one of 'rur', 'eur, 'usd'")
+		return currencyError("currency", err)
 	}
-	data.CurrencyVal = currency
 
 	transactionType, err := domain.TransactionTypeFromString(data.Type)
 	if err != nil {
@@ -78,11 +138,19 @@ func (data *TransactionCreateRequest) Bind(r *http.Request) error {
 	}
 	data.TypeVal = transactionType
 
-	amountVal, err := validator.Float32(data.Amount, "amount")
+	amount, err := validator.Decimal(data.Amount, "amount")
+	if err != nil {
+		return err
+	}
+
+	amountVal, err := domain.MoneyFromString(amount, currency)
 	if err != nil {
 		return err
 	}
-	data.AmountVal = float32(amountVal)
+	if !amountVal.IsPositive() {
+		return errors.New("amount value must be positive")
+	}
+	data.AmountVal = amountVal
 
 	walletIdVal, err := validator.Uuid(data.WalletId, "walletId")
 	if err != nil {
@@ -96,6 +164,68 @@ func (data *TransactionCreateRequest) Bind(r *http.Request) error {
 	}
 	data.CategoryIdVal = categoryIdVal
 
+	if data.Rate != nil {
+		data.RateVal, err = parsePositiveDecimal(*data.Rate, "rate")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (data *TransactionPatchRequest) Bind(r *http.Request) error {
+	if data.Currency != nil {
+		currency, err := domain.CurrencyFromString(*data.Currency)
+		if err != nil {
+			return currencyError("currency", err)
+		}
+		data.CurrencyVal = &currency
+	}
+
+	if data.Type != nil {
+		transactionType, err := domain.TransactionTypeFromString(*data.Type)
+		if err != nil {
+			return errors.New("type value must be one of 'in', 'out")
+		}
+		data.TypeVal = &transactionType
+	}
+
+	if data.Amount != nil {
+		amount, err := validator.Decimal(*data.Amount, "amount")
+		if err != nil {
+			return err
+		}
+		data.AmountVal, _ = new(big.Rat).SetString(amount)
+		if data.AmountVal.Sign() <= 0 {
+			return errors.New("amount value must be positive")
+		}
+	}
+
+	if data.WalletId != nil {
+		walletIdVal, err := validator.Uuid(*data.WalletId, "walletId")
+		if err != nil {
+			return err
+		}
+		data.WalletIdVal = &walletIdVal
+	}
+
+	if data.CategoryId != nil {
+		categoryIdVal, err := validator.Uuid(*data.CategoryId, "categoryId")
+		if err != nil {
+			return err
+		}
+		data.CategoryIdVal = &categoryIdVal
+	}
+
+	if data.Rate != nil {
+		rate, err := parsePositiveDecimal(*data.Rate, "rate")
+		if err != nil {
+			return err
+		}
+		data.RateVal = rate
+	}
+
 	return nil
 }
 
@@ -113,14 +243,18 @@ func (h *TransactionHandler) create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !allowWalletOrFail(w, r, token, data.WalletIdVal) {
+		return
+	}
+
 	createRequest := &service.TransactionCreateRequest{
 		Comment:         data.Comment,
-		Currency:        data.CurrencyVal,
 		UserId:          token.UserId,
 		WalletId:        data.WalletIdVal,
 		CategoryId:      data.CategoryIdVal,
 		Amount:          data.AmountVal,
 		TransactionType: data.TypeVal,
+		Rate:            data.RateVal,
 	}
 
 	category, err := h.transactionService.Create(context.Background(), createRequest)
@@ -131,3 +265,215 @@ func (h *TransactionHandler) create(w http.ResponseWriter, r *http.Request) {
 
 	render.JSON(w, r, NewTransactionResponse(category))
 }
+
+func (h *TransactionHandler) getList(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+
+	listRequest, err := newTransactionGetListRequest(r.URL.Query())
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	listRequest.UserId = token.UserId
+
+	walletId, ok := restrictWalletFilter(w, r, token, listRequest.WalletId)
+	if !ok {
+		return
+	}
+	listRequest.WalletId = walletId
+
+	list, err := h.transactionService.GetList(context.Background(), listRequest)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, NewTransactionListResponse(list))
+}
+
+func newTransactionGetListRequest(q url.Values) (*service.TransactionGetListRequest, error) {
+	request := &service.TransactionGetListRequest{
+		Comment: q.Get("comment"),
+		Cursor:  q.Get("cursor"),
+	}
+
+	if v := q.Get("walletId"); v != "" {
+		walletId, err := validator.Uuid(v, "walletId")
+		if err != nil {
+			return nil, err
+		}
+		request.WalletId = &walletId
+	}
+
+	if v := q.Get("categoryId"); v != "" {
+		categoryId, err := validator.Uuid(v, "categoryId")
+		if err != nil {
+			return nil, err
+		}
+		request.CategoryId = &categoryId
+	}
+
+	if v := q.Get("subcategories"); v != "" {
+		includeSubcategories, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, errors.New("subcategories value must be boolean")
+		}
+		request.IncludeSubcategories = includeSubcategories
+	}
+
+	if v := q.Get("type"); v != "" {
+		transactionType, err := domain.TransactionTypeFromString(v)
+		if err != nil {
+			return nil, errors.New("type value must be one of 'in', 'out")
+		}
+		request.Type = &transactionType
+	}
+
+	if v := q.Get("currency"); v != "" {
+		// disabled currencies still filter the transactions made before
+		currency, err := domain.CurrencyFromCode(v)
+		if err != nil {
+			return nil, currencyError("currency", err)
+		}
+		request.Currency = &currency
+	}
+
+	for name, dst := range map[string]**big.Rat{"amountFrom": &request.AmountFrom, "amountTo": &request.AmountTo} {
+		if v := q.Get(name); v != "" {
+			amount, err := validator.Decimal(v, name)
+			if err != nil {
+				return nil, err
+			}
+			*dst, _ = new(big.Rat).SetString(amount)
+		}
+	}
+
+	for name, dst := range map[string]**time.Time{"dateFrom": &request.DateFrom, "dateTo": &request.DateTo} {
+		if v := q.Get(name); v != "" {
+			date, err := parseDateParam(v, name)
+			if err != nil {
+				return nil, err
+			}
+			*dst = &date
+		}
+	}
+	// a plain date as the upper bound includes the whole day
+	if request.DateTo != nil && len(q.Get("dateTo")) == len(DateFormat()) {
+		dateTo := request.DateTo.Add(24*time.Hour - time.Nanosecond)
+		request.DateTo = &dateTo
+	}
+
+	if v := q.Get("sort"); v != "" {
+		sort := service.TransactionSort{Field: service.TransactionSortField(strings.TrimPrefix(v, "-")), Desc: strings.HasPrefix(v, "-")}
+		if sort.Field != service.TransactionSortDate && sort.Field != service.TransactionSortAmount {
+			return nil, errors.New("sort value must be one of 'date', '-date', 'amount', '-amount'")
+		}
+		request.Sort = sort
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return nil, errors.New("limit value must be positive integer")
+		}
+		request.Limit = limit
+	}
+
+	return request, nil
+}
+
+func (h *TransactionHandler) update(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+	transactionId := retrieveUuidOrFail(w, r, "transactionId")
+
+	data := &TransactionCreateRequest{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	currency := data.AmountVal.Currency()
+	updateRequest := &service.TransactionUpdateRequest{
+		UserId:           token.UserId,
+		AllowedWalletIds: token.WalletIds,
+		TransactionId:    transactionId,
+		WalletId:         &data.WalletIdVal,
+		CategoryId:       &data.CategoryIdVal,
+		Comment:          &data.Comment,
+		Amount:           data.AmountVal.Rat(),
+		Currency:         &currency,
+		TransactionType:  &data.TypeVal,
+		Rate:             data.RateVal,
+	}
+
+	transaction, err := h.transactionService.Update(context.Background(), updateRequest)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, NewTransactionResponse(transaction))
+}
+
+func (h *TransactionHandler) patch(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+	transactionId := retrieveUuidOrFail(w, r, "transactionId")
+
+	data := &TransactionPatchRequest{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	updateRequest := &service.TransactionUpdateRequest{
+		UserId:           token.UserId,
+		AllowedWalletIds: token.WalletIds,
+		TransactionId:    transactionId,
+		WalletId:         data.WalletIdVal,
+		CategoryId:       data.CategoryIdVal,
+		Comment:          data.Comment,
+		Amount:           data.AmountVal,
+		Currency:         data.CurrencyVal,
+		TransactionType:  data.TypeVal,
+		Rate:             data.RateVal,
+	}
+
+	transaction, err := h.transactionService.Update(context.Background(), updateRequest)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, NewTransactionResponse(transaction))
+}
+
+func (h *TransactionHandler) delete(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+	transactionId := retrieveUuidOrFail(w, r, "transactionId")
+
+	deleteRequest := &service.TransactionDeleteRequest{
+		UserId:           token.UserId,
+		TransactionId:    transactionId,
+		AllowedWalletIds: token.WalletIds,
+	}
+
+	err := h.transactionService.Delete(context.Background(), deleteRequest)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, map[string]string{})
+}
@@ -0,0 +1,295 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/IMBgl/go-wallet-api/internal/service"
+	"github.com/IMBgl/go-wallet-api/pkg/validator"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"math/big"
+	"net/http"
+)
+
+type TransferHandler struct {
+	transferService service.TransferService
+	middleware      *apiMiddleware
+}
+
+func (h TransferHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+	r.Use(h.middleware.Auth)
+	r.Use(h.middleware.limiter.Write)
+	r.Use(h.middleware.Scope(service.SCOPE_TRANSACTION_READ, service.SCOPE_TRANSACTION_WRITE))
+	r.Post("/", h.create)
+	r.Get("/", h.getList)
+
+	r.Route("/{transferId}", func(r chi.Router) {
+		r.Get("/", h.getOne)
+		r.Post("/reverse", h.reverse)
+	})
+
+	return r
+}
+
+type TransferCreateRequest struct {
+	FromWalletId      string       `json:"fromWalletId"`
+	ToWalletId        string       `json:"toWalletId"`
+	CategoryId        *string      `json:"categoryId,omitempty"`
+	Comment           string       `json:"comment,omitempty"`
+	Amount            json.Number  `json:"amount"`
+	Rate              *json.Number `json:"rate,omitempty"`
+	ReceivedAmount    *json.Number `json:"receivedAmount,omitempty"`
+	FromWalletIdVal   uuid.UUID    `json:"-"`
+	ToWalletIdVal     uuid.UUID    `json:"-"`
+	CategoryIdVal     *uuid.UUID   `json:"-"`
+	AmountVal         *big.Rat     `json:"-"`
+	RateVal           *big.Rat     `json:"-"`
+	ReceivedAmountVal *big.Rat     `json:"-"`
+}
+
+type TransferResponse struct {
+	Id               string               `json:"id"`
+	FromWalletId     string               `json:"fromWalletId"`
+	ToWalletId       string               `json:"toWalletId"`
+	Amount           json.Number          `json:"amount"`
+	Currency         string               `json:"currency"`
+	ReceivedAmount   json.Number          `json:"receivedAmount"`
+	ReceivedCurrency string               `json:"receivedCurrency"`
+	Rate             *json.Number         `json:"rate"`
+	ReversalOfId     *uuid.UUID           `json:"reversalOfId"`
+	CreatedAt        string               `json:"createdAt"`
+	OutTransaction   *TransactionResponse `json:"outTransaction"`
+	InTransaction    *TransactionResponse `json:"inTransaction"`
+}
+
+func NewTransferListResponse(list []*service.TransferDetails) []*TransferResponse {
+	responseList := []*TransferResponse{}
+	for _, t := range list {
+		responseList = append(responseList, NewTransferResponse(t))
+	}
+
+	return responseList
+}
+
+func NewTransferResponse(t *service.TransferDetails) *TransferResponse {
+	response := &TransferResponse{
+		Id:               t.Id.String(),
+		FromWalletId:     t.Out.WalletId.String(),
+		ToWalletId:       t.In.WalletId.String(),
+		Amount:           json.Number(t.Out.Amount.String()),
+		Currency:         t.Out.Amount.Currency().Val(),
+		ReceivedAmount:   json.Number(t.In.Amount.String()),
+		ReceivedCurrency: t.In.Amount.Currency().Val(),
+		ReversalOfId:     t.ReversalOfId,
+		CreatedAt:        t.CreatedAt.Format(DateTimeFormat()),
+		OutTransaction:   NewTransactionResponse(t.Out),
+		InTransaction:    NewTransactionResponse(t.In),
+	}
+
+	if t.Rate != nil {
+		rate := json.Number(t.Rate.FloatString(service.TRANSFER_RATE_SCALE))
+		response.Rate = &rate
+	}
+
+	return response
+}
+
+func (data *TransferCreateRequest) Bind(r *http.Request) error {
+	fromWalletId, err := validator.Uuid(data.FromWalletId, "fromWalletId")
+	if err != nil {
+		return err
+	}
+	data.FromWalletIdVal = fromWalletId
+
+	toWalletId, err := validator.Uuid(data.ToWalletId, "toWalletId")
+	if err != nil {
+		return err
+	}
+	data.ToWalletIdVal = toWalletId
+
+	if data.CategoryId != nil {
+		categoryId, err := validator.Uuid(*data.CategoryId, "categoryId")
+		if err != nil {
+			return err
+		}
+		data.CategoryIdVal = &categoryId
+	}
+
+	data.AmountVal, err = parsePositiveDecimal(data.Amount, "amount")
+	if err != nil {
+		return err
+	}
+
+	if data.Rate != nil {
+		data.RateVal, err = parsePositiveDecimal(*data.Rate, "rate")
+		if err != nil {
+			return err
+		}
+	}
+
+	if data.ReceivedAmount != nil {
+		data.ReceivedAmountVal, err = parsePositiveDecimal(*data.ReceivedAmount, "receivedAmount")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func parsePositiveDecimal(value json.Number, name string) (*big.Rat, error) {
+	decimal, err := validator.Decimal(value, name)
+	if err != nil {
+		return nil, err
+	}
+
+	rat, _ := new(big.Rat).SetString(decimal)
+	if rat.Sign() <= 0 {
+		return nil, errors.New(name + " value must be positive")
+	}
+
+	return rat, nil
+}
+
+func (h *TransferHandler) create(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+
+	data := &TransferCreateRequest{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	if !allowWalletOrFail(w, r, token, data.FromWalletIdVal) || !allowWalletOrFail(w, r, token, data.ToWalletIdVal) {
+		return
+	}
+
+	createRequest := &service.TransferCreateRequest{
+		UserId:         token.UserId,
+		FromWalletId:   data.FromWalletIdVal,
+		ToWalletId:     data.ToWalletIdVal,
+		CategoryId:     data.CategoryIdVal,
+		Comment:        data.Comment,
+		Amount:         data.AmountVal,
+		Rate:           data.RateVal,
+		ReceivedAmount: data.ReceivedAmountVal,
+	}
+
+	transfer, err := h.transferService.Create(context.Background(), createRequest)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, NewTransferResponse(transfer))
+}
+
+func (h *TransferHandler) getList(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+
+	listRequest := &service.TransferGetListRequest{
+		UserId: token.UserId,
+	}
+
+	if v := r.URL.Query().Get("walletId"); v != "" {
+		walletId, err := validator.Uuid(v, "walletId")
+		if err != nil {
+			render.Render(w, r, ErrInvalidRequest(err))
+			return
+		}
+		listRequest.WalletId = &walletId
+	}
+
+	walletId, ok := restrictWalletFilter(w, r, token, listRequest.WalletId)
+	if !ok {
+		return
+	}
+	listRequest.WalletId = walletId
+
+	list, err := h.transferService.GetList(context.Background(), listRequest)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, NewTransferListResponse(list))
+}
+
+func (h *TransferHandler) getOne(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+	transferId := retrieveUuidOrFail(w, r, "transferId")
+
+	getOneRequest := &service.TransferGetOneRequest{
+		UserId:     token.UserId,
+		TransferId: transferId,
+	}
+
+	transfer, err := h.transferService.GetOne(context.Background(), getOneRequest)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	if !allowTransferOrFail(w, r, token, transfer, false) {
+		return
+	}
+
+	render.JSON(w, r, NewTransferResponse(transfer))
+}
+
+func (h *TransferHandler) reverse(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+	transferId := retrieveUuidOrFail(w, r, "transferId")
+
+	if token.IsWalletRestricted() {
+		transfer, err := h.transferService.GetOne(context.Background(), &service.TransferGetOneRequest{UserId: token.UserId, TransferId: transferId})
+		if err != nil {
+			render.Render(w, r, ErrInvalidRequest(err))
+			return
+		}
+
+		if !allowTransferOrFail(w, r, token, transfer, true) {
+			return
+		}
+	}
+
+	reverseRequest := &service.TransferReverseRequest{
+		UserId:     token.UserId,
+		TransferId: transferId,
+	}
+
+	transfer, err := h.transferService.Reverse(context.Background(), reverseRequest)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, NewTransferResponse(transfer))
+}
+
+// allowTransferOrFail lets a restricted key see transfers of any of its
+// wallets like the filtered list does, changes need both wallets.
+func allowTransferOrFail(w http.ResponseWriter, r *http.Request, token *service.UserToken, transfer *service.TransferDetails, both bool) bool {
+	out, in := token.AllowsWallet(transfer.Out.WalletId), token.AllowsWallet(transfer.In.WalletId)
+	if (both && out && in) || (!both && (out || in)) {
+		return true
+	}
+
+	render.Render(w, r, ErrForbidden)
+	return false
+}
@@ -12,19 +12,54 @@ import (
 )
 
 type UserHandler struct {
-	userService service.UserService
+	userService    service.UserService
+	tokenService   service.TokenService
+	accountService service.AccountService
+	middleware     *apiMiddleware
 }
 
 func (h UserHandler) Routes() chi.Router {
 	r := chi.NewRouter()
-	r.Post("/singUp", h.singUp)
-	r.Post("/singIn", h.signIn)
+
+	r.Group(func(r chi.Router) {
+		r.Use(h.middleware.limiter.Auth)
+		r.Post("/singUp", h.singUp)
+		r.Post("/singIn", h.signIn)
+		r.Post("/signIn", h.signIn)
+		r.Post("/signIn/verify", h.signInVerify)
+		r.Post("/token/refresh", h.refreshToken)
+		r.Post("/email/verify/confirm", h.confirmEmail)
+		r.Post("/password/reset", h.requestPasswordReset)
+		r.Post("/password/reset/confirm", h.resetPassword)
+		r.Post("/email/change/confirm", h.confirmEmailChange)
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(h.middleware.Auth)
+		r.Use(h.middleware.limiter.Write)
+		r.Use(h.middleware.SessionOnly)
+		r.Post("/signOut", h.signOut)
+		r.Post("/signOutAll", h.signOutAll)
+		r.Post("/email/verify", h.requestEmailVerification)
+		r.Get("/sessions", h.getSessions)
+		r.Delete("/sessions/{sessionId}", h.revokeSession)
+		r.Post("/twoFactor/enroll", h.enrollTwoFactor)
+		r.Post("/twoFactor/confirm", h.confirmTwoFactor)
+		r.Post("/twoFactor/disable", h.disableTwoFactor)
+		r.Post("/twoFactor/recoveryCodes", h.regenerateRecoveryCodes)
+		r.Get("/me", h.getProfile)
+		r.Patch("/me", h.updateProfile)
+		r.Delete("/me", h.deleteAccount)
+		r.Post("/me/password", h.changePassword)
+		r.Post("/me/email", h.requestEmailChange)
+	})
+
 	return r
 }
 
 type UserSignInRequest struct {
-	Email    string `json:"email" binding:"required,alphaunicode,email"`
-	Password string `json:"password" binding:"required,min=5,max=100"`
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,max=100"`
 }
 
 type UserSignUpRequest struct {
@@ -33,21 +68,171 @@ type UserSignUpRequest struct {
 	Password interface{} `json:"password" validate:"required,min=5,max=100"`
 }
 
+type TokenRefreshRequest struct {
+	RefreshToken string `json:"refreshToken" validate:"required,max=100"`
+}
+
+type SignInVerifyRequest struct {
+	Challenge    string `json:"challenge" validate:"required,max=100"`
+	Code         string `json:"code" validate:"required_without=RecoveryCode,omitempty,numeric,len=6"`
+	RecoveryCode string `json:"recoveryCode" validate:"omitempty,max=25"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required,numeric,len=6"`
+}
+
+type TwoFactorDisableRequest struct {
+	Password     string `json:"password" validate:"required,max=100"`
+	Code         string `json:"code" validate:"required_without=RecoveryCode,omitempty,numeric,len=6"`
+	RecoveryCode string `json:"recoveryCode" validate:"omitempty,max=25"`
+}
+
+type ActionTokenRequest struct {
+	Token string `json:"token" validate:"required,max=200"`
+}
+
+type PasswordResetRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type PasswordResetConfirmRequest struct {
+	Token    string `json:"token" validate:"required,max=200"`
+	Password string `json:"password" validate:"required,min=5,max=100"`
+}
+
+type ProfileUpdateRequest struct {
+	Name         *string `json:"name" validate:"omitempty,ascii,max=25,min=5"`
+	BaseCurrency *string `json:"baseCurrency"`
+	TimeZone     *string `json:"timeZone"`
+}
+
+type PasswordChangeRequest struct {
+	CurrentPassword string `json:"currentPassword" validate:"required,max=100"`
+	NewPassword     string `json:"newPassword" validate:"required,min=5,max=100"`
+}
+
+type EmailChangeRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,max=100"`
+}
+
+type AccountDeleteRequest struct {
+	Password     string `json:"password" validate:"required,max=100"`
+	Code         string `json:"code" validate:"omitempty,numeric,len=6"`
+	RecoveryCode string `json:"recoveryCode" validate:"omitempty,max=25"`
+}
+
+type ProfileResponse struct {
+	Id               string  `json:"id"`
+	Name             string  `json:"name"`
+	Email            string  `json:"email"`
+	EmailVerified    bool    `json:"emailVerified"`
+	EmailVerifiedAt  *string `json:"emailVerifiedAt"`
+	TwoFactorEnabled bool    `json:"twoFactorEnabled"`
+	BaseCurrency     string  `json:"baseCurrency"`
+	TimeZone         string  `json:"timeZone"`
+	CreatedAt        string  `json:"createdAt"`
+}
+
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"twoFactorRequired"`
+	Challenge         string `json:"challenge"`
+	ChallengeExp      string `json:"challengeExp"`
+}
+
+type TotpEnrollmentResponse struct {
+	Secret string `json:"secret"`
+	Uri    string `json:"uri"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
 type CredentialsResponse struct {
-	UserId   string `json:"userId"`
-	Name     string `json:"name"`
-	Email    string `json:"email"`
-	Token    string `json:"token"`
-	TokenExp string `json:"tokenExp"`
+	UserId string `json:"userId"`
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+	*TokenPairResponse
+}
+
+type TokenPairResponse struct {
+	Token           string `json:"token"`
+	TokenExp        string `json:"tokenExp"`
+	RefreshToken    string `json:"refreshToken"`
+	RefreshTokenExp string `json:"refreshTokenExp"`
+}
+
+type SessionResponse struct {
+	Id        string `json:"id"`
+	CreatedAt string `json:"createdAt"`
+	ExpiresAt string `json:"expiresAt"`
+	Current   bool   `json:"current"`
 }
 
-func NewCredentialsResponse(u *domain.User, t *service.UserToken) *CredentialsResponse {
+func NewSessionListResponse(list []*service.UserToken, current *service.UserToken) []*SessionResponse {
+	responseList := []*SessionResponse{}
+	for _, t := range list {
+		responseList = append(responseList, &SessionResponse{
+			Id:        t.FamilyId.String(),
+			CreatedAt: t.CreatedAt.Format(DateTimeFormat()),
+			ExpiresAt: t.Exp.Format(DateTimeFormat()),
+			Current:   t.FamilyId == current.FamilyId,
+		})
+	}
+
+	return responseList
+}
+
+func NewProfileResponse(p *service.Profile) *ProfileResponse {
+	response := &ProfileResponse{
+		Id:               p.User.Id.String(),
+		Name:             p.User.Name,
+		Email:            p.User.Email,
+		EmailVerified:    p.User.EmailVerifiedAt != nil,
+		TwoFactorEnabled: p.TwoFactorEnabled,
+		BaseCurrency:     p.User.BaseCurrency.Val(),
+		TimeZone:         p.User.TimeZone.String(),
+		CreatedAt:        p.User.CreatedAt.Format(DateTimeFormat()),
+	}
+
+	if p.User.EmailVerifiedAt != nil {
+		verifiedAt := p.User.EmailVerifiedAt.Format(DateTimeFormat())
+		response.EmailVerifiedAt = &verifiedAt
+	}
+
+	return response
+}
+
+func NewCredentialsResponse(u *domain.User, p *service.TokenPair) *CredentialsResponse {
 	return &CredentialsResponse{
-		UserId:   u.Id.String(),
-		Email:    u.Email,
-		Name:     u.Name,
-		Token:    t.Value,
-		TokenExp: t.Exp.Format(DateTimeFormat()),
+		UserId:            u.Id.String(),
+		Email:             u.Email,
+		Name:              u.Name,
+		TokenPairResponse: NewTokenPairResponse(p),
+	}
+}
+
+// NewSignInResponse returns the credentials or the challenge of a user with two-factor authentication.
+func NewSignInResponse(result *service.SignInResult) interface{} {
+	if result.Challenge != nil {
+		return &TwoFactorChallengeResponse{
+			TwoFactorRequired: true,
+			Challenge:         result.Challenge.Value,
+			ChallengeExp:      result.Challenge.Exp.Format(DateTimeFormat()),
+		}
+	}
+
+	return NewCredentialsResponse(result.User, result.Tokens)
+}
+
+func NewTokenPairResponse(p *service.TokenPair) *TokenPairResponse {
+	return &TokenPairResponse{
+		Token:           p.Access.Value,
+		TokenExp:        p.Access.Exp.Format(DateTimeFormat()),
+		RefreshToken:    p.Refresh.Value,
+		RefreshTokenExp: p.Refresh.Exp.Format(DateTimeFormat()),
 	}
 }
 
@@ -78,32 +263,492 @@ func (h *UserHandler) singUp(w http.ResponseWriter, r *http.Request) {
 		Password: request.Password.(string),
 	}
 
-	us, token, err := h.userService.SingUp(ctx, serviceRequest)
+	us, pair, err := h.userService.SingUp(ctx, serviceRequest)
 	if err != nil {
 		render.Render(w, r, ErrInvalidRequest(err))
 		return
 	}
 
-	render.JSON(w, r, NewCredentialsResponse(us, token))
+	render.JSON(w, r, NewCredentialsResponse(us, pair))
 }
 
 func (h *UserHandler) signIn(w http.ResponseWriter, r *http.Request) {
-	//request := &UserSignInRequest{}
-	//if err := render.Bind(r, request); err != nil {
-	//	render.Render(w, r, ErrInvalidRequest(err))
-	//	return
-	//}
-	//
-	//dto := service.SignInRequest{
-	//	Email:    request.Email,
-	//	Password: request.Password,
-	//}
-	//
-	//us, token, err := h.userService.SingIn(context.Background(), dto)
-	//if err != nil {
-	//	render.Render(w, r, ErrInvalidRequest(err))
-	//	return
-	//}
-	//
-	//render.JSON(w, r, NewCredentialsResponse(us, token))
+	request := &UserSignInRequest{}
+
+	err := unmarshallRequest(r, request)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	err, verrs := validateRequest(request)
+	if err != nil {
+		log.Printf("validation process err %v", err)
+		render.Render(w, r, ErrInvalidRequest(errors.New("invalid request")))
+		return
+	}
+
+	if len(verrs) > 0 {
+		render.JSON(w, r, verrs)
+		return
+	}
+
+	if !h.middleware.limiter.allowAccount(w, r, request.Email) {
+		return
+	}
+
+	serviceRequest := service.SignInRequest{
+		Email:    request.Email,
+		Password: request.Password,
+	}
+
+	result, err := h.userService.SingIn(context.Background(), serviceRequest)
+	if err == service.ErrInvalidCredentials {
+		h.middleware.limiter.failure(r.Context(), request.Email)
+	}
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	// the failures are kept until the second factor is verified too
+	if result.Challenge == nil {
+		h.middleware.limiter.success(r.Context(), request.Email)
+	}
+
+	render.JSON(w, r, NewSignInResponse(result))
+}
+
+func (h *UserHandler) refreshToken(w http.ResponseWriter, r *http.Request) {
+	request := &TokenRefreshRequest{}
+
+	err := unmarshallRequest(r, request)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	err, verrs := validateRequest(request)
+	if err != nil {
+		log.Printf("validation process err %v", err)
+		render.Render(w, r, ErrInvalidRequest(errors.New("invalid request")))
+		return
+	}
+
+	if len(verrs) > 0 {
+		render.JSON(w, r, verrs)
+		return
+	}
+
+	pair, err := h.tokenService.Refresh(context.Background(), request.RefreshToken)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, NewTokenPairResponse(pair))
+}
+
+func (h *UserHandler) signOut(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+
+	err := h.userService.SignOut(context.Background(), &service.SignOutRequest{UserId: token.UserId, SessionId: token.FamilyId})
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, map[string]string{})
+}
+
+func (h *UserHandler) signOutAll(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+
+	err := h.userService.SignOutAll(context.Background(), &service.SignOutAllRequest{UserId: token.UserId})
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, map[string]string{})
+}
+
+func (h *UserHandler) getSessions(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+
+	sessions, err := h.userService.GetSessions(context.Background(), &service.SessionGetListRequest{UserId: token.UserId})
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, NewSessionListResponse(sessions, token))
+}
+
+func (h *UserHandler) revokeSession(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+	sessionId := retrieveUuidOrFail(w, r, "sessionId")
+
+	err := h.userService.SignOut(context.Background(), &service.SignOutRequest{UserId: token.UserId, SessionId: sessionId})
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, map[string]string{})
+}
+
+// bindValidated decodes and validates the request body, it renders the errors and returns false on failure.
+func bindValidated(w http.ResponseWriter, r *http.Request, request interface{}) bool {
+	err := unmarshallRequest(r, request)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return false
+	}
+
+	err, verrs := validateRequest(request)
+	if err != nil {
+		log.Printf("validation process err %v", err)
+		render.Render(w, r, ErrInvalidRequest(errors.New("invalid request")))
+		return false
+	}
+
+	if len(verrs) > 0 {
+		render.JSON(w, r, verrs)
+		return false
+	}
+
+	return true
+}
+
+func (h *UserHandler) signInVerify(w http.ResponseWriter, r *http.Request) {
+	request := &SignInVerifyRequest{}
+	if !bindValidated(w, r, request) {
+		return
+	}
+
+	// wrong codes count toward the lockout of the account like wrong passwords
+	user, err := h.userService.SignInChallengeUser(context.Background(), request.Challenge)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	if !h.middleware.limiter.allowAccount(w, r, user.Email) {
+		return
+	}
+
+	result, err := h.userService.SignInVerify(context.Background(), &service.SignInVerifyRequest{
+		Challenge:    request.Challenge,
+		Code:         request.Code,
+		RecoveryCode: request.RecoveryCode,
+	})
+	if err == service.ErrInvalidTwoFactorCode {
+		h.middleware.limiter.failure(r.Context(), user.Email)
+	}
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	h.middleware.limiter.success(r.Context(), user.Email)
+
+	render.JSON(w, r, NewSignInResponse(result))
+}
+
+func (h *UserHandler) enrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+
+	enrollment, err := h.userService.EnrollTotp(context.Background(), &service.TwoFactorEnrollRequest{UserId: token.UserId})
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, &TotpEnrollmentResponse{Secret: enrollment.Secret, Uri: enrollment.Uri})
+}
+
+func (h *UserHandler) confirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+
+	request := &TwoFactorCodeRequest{}
+	if !bindValidated(w, r, request) {
+		return
+	}
+
+	codes, err := h.userService.ConfirmTotp(context.Background(), &service.TwoFactorConfirmRequest{UserId: token.UserId, Code: request.Code})
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, &RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func (h *UserHandler) disableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+
+	request := &TwoFactorDisableRequest{}
+	if !bindValidated(w, r, request) {
+		return
+	}
+
+	err := h.userService.DisableTotp(context.Background(), &service.TwoFactorDisableRequest{
+		UserId:       token.UserId,
+		Password:     request.Password,
+		Code:         request.Code,
+		RecoveryCode: request.RecoveryCode,
+	})
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, map[string]string{})
+}
+
+func (h *UserHandler) regenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+
+	request := &TwoFactorCodeRequest{}
+	if !bindValidated(w, r, request) {
+		return
+	}
+
+	codes, err := h.userService.RegenerateRecoveryCodes(context.Background(), &service.RecoveryCodesRegenerateRequest{UserId: token.UserId, Code: request.Code})
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, &RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func (h *UserHandler) requestEmailVerification(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+
+	err := h.accountService.RequestEmailVerification(context.Background(), &service.EmailVerificationRequest{UserId: token.UserId})
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, map[string]string{})
+}
+
+func (h *UserHandler) confirmEmail(w http.ResponseWriter, r *http.Request) {
+	request := &ActionTokenRequest{}
+	if !bindValidated(w, r, request) {
+		return
+	}
+
+	_, err := h.accountService.ConfirmEmail(context.Background(), &service.EmailConfirmRequest{Token: request.Token})
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, map[string]string{})
+}
+
+func (h *UserHandler) requestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	request := &PasswordResetRequest{}
+	if !bindValidated(w, r, request) {
+		return
+	}
+
+	if !h.middleware.limiter.allowPasswordReset(w, r, request.Email) {
+		return
+	}
+
+	err := h.accountService.RequestPasswordReset(context.Background(), &service.PasswordResetRequest{Email: request.Email})
+	if err != nil {
+		log.Printf("password reset request err %v", err)
+		render.Render(w, r, ErrInvalidRequest(errors.New("could not send password reset email")))
+		return
+	}
+
+	render.JSON(w, r, map[string]string{})
+}
+
+func (h *UserHandler) resetPassword(w http.ResponseWriter, r *http.Request) {
+	request := &PasswordResetConfirmRequest{}
+	if !bindValidated(w, r, request) {
+		return
+	}
+
+	err := h.accountService.ResetPassword(context.Background(), &service.PasswordResetConfirmRequest{Token: request.Token, Password: request.Password})
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, map[string]string{})
+}
+
+func (h *UserHandler) confirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	request := &ActionTokenRequest{}
+	if !bindValidated(w, r, request) {
+		return
+	}
+
+	_, err := h.accountService.ConfirmEmailChange(context.Background(), &service.EmailConfirmRequest{Token: request.Token})
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, map[string]string{})
+}
+
+func (h *UserHandler) getProfile(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+
+	profile, err := h.userService.GetProfile(context.Background(), &service.ProfileGetRequest{UserId: token.UserId})
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, NewProfileResponse(profile))
+}
+
+func (h *UserHandler) updateProfile(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+
+	request := &ProfileUpdateRequest{}
+	if !bindValidated(w, r, request) {
+		return
+	}
+
+	updateRequest := &service.ProfileUpdateRequest{UserId: token.UserId, Name: request.Name}
+	if request.BaseCurrency != nil {
+		currency, err := domain.CurrencyFromString(*request.BaseCurrency)
+		if err != nil {
+			render.Render(w, r, ErrInvalidRequest(currencyError("baseCurrency", err)))
+			return
+		}
+		updateRequest.BaseCurrency = &currency
+	}
+	if request.TimeZone != nil {
+		loc, err := domain.TimeZoneFromString(*request.TimeZone)
+		if err != nil {
+			render.Render(w, r, ErrInvalidRequest(errors.New("timeZone value must be IANA time zone name like Europe/Berlin")))
+			return
+		}
+		updateRequest.TimeZone = loc
+	}
+
+	profile, err := h.userService.UpdateProfile(context.Background(), updateRequest)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, NewProfileResponse(profile))
+}
+
+func (h *UserHandler) changePassword(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+
+	request := &PasswordChangeRequest{}
+	if !bindValidated(w, r, request) {
+		return
+	}
+
+	err := h.userService.ChangePassword(context.Background(), &service.PasswordChangeRequest{
+		UserId:          token.UserId,
+		SessionId:       token.FamilyId,
+		CurrentPassword: request.CurrentPassword,
+		NewPassword:     request.NewPassword,
+	})
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, map[string]string{})
+}
+
+func (h *UserHandler) requestEmailChange(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+
+	request := &EmailChangeRequest{}
+	if !bindValidated(w, r, request) {
+		return
+	}
+
+	err := h.userService.RequestEmailChange(context.Background(), &service.EmailChangeRequest{
+		UserId:   token.UserId,
+		Email:    request.Email,
+		Password: request.Password,
+	})
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, map[string]string{})
+}
+
+func (h *UserHandler) deleteAccount(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	if token == nil {
+		return
+	}
+
+	request := &AccountDeleteRequest{}
+	if !bindValidated(w, r, request) {
+		return
+	}
+
+	err := h.userService.DeleteAccount(context.Background(), &service.AccountDeleteRequest{
+		UserId:       token.UserId,
+		Password:     request.Password,
+		Code:         request.Code,
+		RecoveryCode: request.RecoveryCode,
+	})
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, map[string]string{})
 }
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"github.com/IMBgl/go-wallet-api/internal/domain"
 	"github.com/IMBgl/go-wallet-api/internal/service"
@@ -9,32 +10,39 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
 	"net/http"
+	"time"
 )
 
 type WalletHandler struct {
 	walletService service.WalletService
+	reportService service.ReportService
 	middleware    *apiMiddleware
 }
 
 func (h WalletHandler) Routes() chi.Router {
 	r := chi.NewRouter()
 	r.Use(h.middleware.Auth)
-	r.Post("/", h.create)
+	r.Use(h.middleware.limiter.Write)
+	r.Use(h.middleware.Scope(service.SCOPE_WALLET_READ, service.SCOPE_WALLET_WRITE))
+	r.With(h.middleware.Unrestricted).Post("/", h.create)
 	r.Get("/", h.getList)
 
 	r.Route("/{walletId}", func(r chi.Router) {
+		r.Use(h.middleware.WalletParam("walletId"))
 		r.Delete("/", h.delete)
 		r.Put("/", h.update)
+		r.Get("/balance", h.balance)
+		r.Get("/balance/history", h.balanceHistory)
 	})
 
 	return r
 }
 
 type WalletCreateRequest struct {
-	Name       string      `json:"name"`
-	Balance    interface{} `json:"balance,string"`
-	Currency   string      `json:"currency"`
-	BalanceVal float32     `json:"-"`
+	Name       string       `json:"name"`
+	Balance    json.Number  `json:"balance"`
+	Currency   string       `json:"currency"`
+	BalanceVal domain.Money `json:"-"`
 }
 
 type WalletUpdateRequest struct {
@@ -42,11 +50,33 @@ type WalletUpdateRequest struct {
 }
 
 type WalletResponse struct {
-	Id       string  `json:"id"`
-	Name     string  `json:"name"`
-	Balance  float32 `json:"balance"`
-	Currency string  `json:"currency"`
-	UserId   string  `json:"userId"`
+	Id       string      `json:"id"`
+	Name     string      `json:"name"`
+	Balance  json.Number `json:"balance"`
+	Currency string      `json:"currency"`
+	UserId   string      `json:"userId"`
+}
+
+type WalletBalanceResponse struct {
+	WalletId string      `json:"walletId"`
+	Currency string      `json:"currency"`
+	At       string      `json:"at"`
+	Balance  json.Number `json:"balance"`
+}
+
+type WalletBalancePointResponse struct {
+	Start   string      `json:"start"`
+	End     string      `json:"end"`
+	Change  json.Number `json:"change"`
+	Balance json.Number `json:"balance"`
+}
+
+type WalletBalanceHistoryResponse struct {
+	WalletId string                        `json:"walletId"`
+	Currency string                        `json:"currency"`
+	Interval string                        `json:"interval"`
+	Opening  json.Number                   `json:"opening"`
+	Items    []*WalletBalancePointResponse `json:"items"`
 }
 
 func NewWalletListResponse(wl []*domain.Wallet) []*WalletResponse {
@@ -61,11 +91,42 @@ func NewWalletResponse(w *domain.Wallet) *WalletResponse {
 	return &WalletResponse{
 		Id:       w.Id.String(),
 		UserId:   w.UserId.String(),
-		Currency: w.Currency.Val(),
+		Currency: w.Balance.Currency().Val(),
 
 		Name:    w.Name,
-		Balance: w.Balance,
+		Balance: json.Number(w.Balance.String()),
+	}
+}
+
+// NewWalletBalanceResponse shows the last second the balance counts, the balance time is exclusive.
+func NewWalletBalanceResponse(b *service.WalletBalance) *WalletBalanceResponse {
+	return &WalletBalanceResponse{
+		WalletId: b.Wallet.Id.String(),
+		Currency: b.Balance.Currency().Val(),
+		At:       b.At.Add(-time.Second).Format(DateTimeFormat()),
+		Balance:  json.Number(b.Balance.String()),
+	}
+}
+
+func NewWalletBalanceHistoryResponse(h *service.WalletBalanceHistory) *WalletBalanceHistoryResponse {
+	response := &WalletBalanceHistoryResponse{
+		WalletId: h.Wallet.Id.String(),
+		Currency: h.Opening.Currency().Val(),
+		Interval: h.Interval.Val(),
+		Opening:  json.Number(h.Opening.String()),
+		Items:    []*WalletBalancePointResponse{},
+	}
+
+	for _, p := range h.Points {
+		response.Items = append(response.Items, &WalletBalancePointResponse{
+			Start:   p.Start.Format(DateFormat()),
+			End:     p.End.AddDate(0, 0, -1).Format(DateFormat()),
+			Change:  json.Number(p.Change.String()),
+			Balance: json.Number(p.Balance.String()),
+		})
 	}
+
+	return response
 }
 
 func (data *WalletCreateRequest) Bind(r *http.Request) error {
@@ -73,7 +134,17 @@ func (data *WalletCreateRequest) Bind(r *http.Request) error {
 		return errors.New("name field required")
 	}
 
-	balanceVal, err := validator.Float32(data.Balance, "balance")
+	currency, err := domain.CurrencyFromString(data.Currency)
+	if err != nil {
+		return currencyError("currency", err)
+	}
+
+	balance, err := validator.Decimal(data.Balance, "balance")
+	if err != nil {
+		return err
+	}
+
+	balanceVal, err := domain.MoneyFromString(balance, currency)
 	if err != nil {
 		return err
 	}
@@ -104,10 +175,9 @@ func (h *WalletHandler) create(w http.ResponseWriter, r *http.Request) {
 	}
 
 	createRequest := &service.WalletCreateRequest{
-		Name:     data.Name,
-		Balance:  data.BalanceVal,
-		Currency: data.Currency,
-		UserId:   token.UserId,
+		Name:    data.Name,
+		Balance: data.BalanceVal,
+		UserId:  token.UserId,
 	}
 
 	wallet, err := h.walletService.Create(context.Background(), createRequest)
@@ -150,7 +220,14 @@ func (h *WalletHandler) getList(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	render.JSON(w, r, NewWalletListResponse(walletList))
+	allowed := []*domain.Wallet{}
+	for _, wallet := range walletList {
+		if token.AllowsWallet(wallet.Id) {
+			allowed = append(allowed, wallet)
+		}
+	}
+
+	render.JSON(w, r, NewWalletListResponse(allowed))
 }
 
 func (h *WalletHandler) update(w http.ResponseWriter, r *http.Request) {
@@ -177,3 +254,79 @@ func (h *WalletHandler) update(w http.ResponseWriter, r *http.Request) {
 
 	render.JSON(w, r, NewWalletResponse(wallet))
 }
+
+// balance returns the balance at the end of the day or the second given by
+// at, in the time zone of the user. The current balance without at.
+func (h *WalletHandler) balance(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	walletId := retrieveUuidOrFail(w, r, "walletId")
+
+	request := &service.WalletBalanceRequest{UserId: token.UserId, WalletId: walletId}
+	if v := r.URL.Query().Get("at"); v != "" {
+		at, err := time.Parse(DateTimeFormat(), v)
+		if err == nil {
+			at = at.Add(time.Second)
+		} else {
+			at, err = time.Parse(DateFormat(), v)
+			if err != nil {
+				render.Render(w, r, ErrInvalidRequest(errors.New("at value must be date in format "+DateFormat()+" or "+DateTimeFormat())))
+				return
+			}
+			at = at.AddDate(0, 0, 1)
+		}
+		request.At = &at
+	}
+
+	balance, err := h.reportService.WalletBalance(context.Background(), request)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, NewWalletBalanceResponse(balance))
+}
+
+// balanceHistory returns the balance at the end of each interval, daily for
+// the last month by default.
+func (h *WalletHandler) balanceHistory(w http.ResponseWriter, r *http.Request) {
+	token := retrieveTokenOrFail(w, r)
+	walletId := retrieveUuidOrFail(w, r, "walletId")
+
+	q := r.URL.Query()
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	request := &service.WalletBalanceHistoryRequest{
+		UserId:   token.UserId,
+		WalletId: walletId,
+		DateFrom: today.AddDate(0, -1, 0),
+		DateTo:   today,
+		Interval: domain.ReportIntervalDay(),
+	}
+
+	for name, dst := range map[string]*time.Time{"dateFrom": &request.DateFrom, "dateTo": &request.DateTo} {
+		if v := q.Get(name); v != "" {
+			date, err := time.Parse(DateFormat(), v)
+			if err != nil {
+				render.Render(w, r, ErrInvalidRequest(errors.New(name+" value must be date in format "+DateFormat())))
+				return
+			}
+			*dst = date
+		}
+	}
+
+	if v := q.Get("interval"); v != "" {
+		interval, err := domain.ReportIntervalFromString(v)
+		if err != nil {
+			render.Render(w, r, ErrInvalidRequest(errors.New("interval value must be one of 'day', 'week', 'month', 'quarter', 'year'")))
+			return
+		}
+		request.Interval = interval
+	}
+
+	history, err := h.reportService.WalletBalanceHistory(context.Background(), request)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	render.JSON(w, r, NewWalletBalanceHistoryResponse(history))
+}
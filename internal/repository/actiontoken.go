@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"github.com/IMBgl/go-wallet-api/internal/domain"
+	"github.com/IMBgl/go-wallet-api/internal/service"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"time"
+)
+
+type actionTokenRepository struct {
+	repository
+}
+
+func ActionTokenRepository(conn *pgx.Conn) *actionTokenRepository {
+	return &actionTokenRepository{repository{Conn: conn}}
+}
+
+func (r *actionTokenRepository) GetById(ctx context.Context, id uuid.UUID) (*service.ActionToken, error) {
+	t := service.ActionToken{}
+
+	err := r.Conn.QueryRow(ctx, "select id, user_id, action, email, expires_at, used_at, created_at from user_action_tokens where id=$1", id).Scan(&t.Id, &t.UserId, &t.Action, &t.Email, &t.Exp, &t.UsedAt, &t.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+func (r *actionTokenRepository) Save(ctx context.Context, t *service.ActionToken) error {
+	_, err := r.Conn.Exec(ctx, "insert into user_action_tokens (id, user_id, action, email, expires_at, used_at, created_at) values($1,$2,$3,$4,$5,$6,$7)", t.Id, t.UserId, t.Action, t.Email, t.Exp, t.UsedAt, t.CreatedAt)
+
+	return err
+}
+
+func (r *actionTokenRepository) Use(ctx context.Context, t *service.ActionToken) (bool, error) {
+	now := time.Now()
+	tag, err := r.Conn.Exec(ctx, "update user_action_tokens set used_at=$2 where id=$1 and used_at is null", t.Id, now)
+	if err != nil {
+		return false, err
+	}
+
+	if tag.RowsAffected() != 1 {
+		return false, nil
+	}
+	t.UsedAt = &now
+
+	return true, nil
+}
+
+func (r *actionTokenRepository) DeleteForUser(ctx context.Context, userId uuid.UUID, action string) error {
+	_, err := r.Conn.Exec(ctx, "delete from user_action_tokens where user_id=$1 and action=$2", userId, action)
+
+	return err
+}
+
+func (r *actionTokenRepository) ResetPassword(ctx context.Context, u *domain.User) error {
+	tx, err := r.Conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, "update users set password=$2, email_verified_at=$3, updated_at=$4 where id=$1", u.Id, u.Password, u.EmailVerifiedAt, time.Now())
+	if err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+
+	_, err = tx.Exec(ctx, "delete from user_action_tokens where user_id=$1 and action=$2", u.Id, service.ACTION_RESET_PASSWORD)
+	if err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+
+	_, err = tx.Exec(ctx, "delete from user_tokens where user_id=$1", u.Id)
+	if err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"github.com/IMBgl/go-wallet-api/internal/service"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"time"
+)
+
+type apiKeyRepository struct {
+	repository
+}
+
+func ApiKeyRepository(conn *pgx.Conn) *apiKeyRepository {
+	return &apiKeyRepository{repository{Conn: conn}}
+}
+
+const apiKeyColumns = "id, user_id, name, prefix, hash, scopes, wallet_ids, expires_at, last_used_at, created_at"
+
+func (r *apiKeyRepository) GetByIdAndUserId(ctx context.Context, id, userId uuid.UUID) (*service.ApiKey, error) {
+	rows, err := r.Conn.Query(ctx, "select "+apiKeyColumns+" from api_keys where id=$1 and user_id=$2", id, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := scanApiKeys(rows)
+	if err != nil || len(list) == 0 {
+		return nil, err
+	}
+
+	return list[0], nil
+}
+
+func (r *apiKeyRepository) GetByValue(ctx context.Context, value string) (*service.ApiKey, error) {
+	rows, err := r.Conn.Query(ctx, "select "+apiKeyColumns+" from api_keys where hash=$1", service.HashTokenValue(value))
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := scanApiKeys(rows)
+	if err != nil || len(list) == 0 {
+		return nil, err
+	}
+
+	return list[0], nil
+}
+
+func (r *apiKeyRepository) FindByUserId(ctx context.Context, userId uuid.UUID) ([]*service.ApiKey, error) {
+	rows, err := r.Conn.Query(ctx, "select "+apiKeyColumns+" from api_keys where user_id=$1 order by created_at", userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanApiKeys(rows)
+}
+
+func (r *apiKeyRepository) Save(ctx context.Context, k *service.ApiKey) error {
+	walletIds := make([]string, 0, len(k.WalletIds))
+	for _, id := range k.WalletIds {
+		walletIds = append(walletIds, id.String())
+	}
+
+	_, err := r.Conn.Exec(ctx, `
+				insert into api_keys (id, user_id, name, prefix, hash, scopes, wallet_ids, expires_at, last_used_at, created_at, updated_at)
+				values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
+				on conflict (id) do update
+				set name = $3, scopes = $6, wallet_ids = $7, expires_at = $8, updated_at = $11;`,
+		k.Id, k.UserId, k.Name, k.Prefix, k.Hash, k.Scopes, walletIds, k.ExpiresAt, k.LastUsedAt, k.CreatedAt, time.Now())
+
+	return err
+}
+
+func (r *apiKeyRepository) Delete(ctx context.Context, k *service.ApiKey) error {
+	_, err := r.Conn.Exec(ctx, "delete from api_keys where id=$1", k.Id)
+
+	return err
+}
+
+func (r *apiKeyRepository) UpdateLastUsed(ctx context.Context, k *service.ApiKey, at time.Time) error {
+	_, err := r.Conn.Exec(ctx, "update api_keys set last_used_at=$2 where id=$1", k.Id, at)
+	if err != nil {
+		return err
+	}
+	k.LastUsedAt = &at
+
+	return nil
+}
+
+func scanApiKeys(rows pgx.Rows) ([]*service.ApiKey, error) {
+	defer rows.Close()
+
+	list := []*service.ApiKey{}
+	for rows.Next() {
+		k := &service.ApiKey{}
+		walletIds := []string{}
+		err := rows.Scan(&k.Id, &k.UserId, &k.Name, &k.Prefix, &k.Hash, &k.Scopes, &walletIds, &k.ExpiresAt, &k.LastUsedAt, &k.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, v := range walletIds {
+			id, err := uuid.Parse(v)
+			if err != nil {
+				return nil, err
+			}
+			k.WalletIds = append(k.WalletIds, id)
+		}
+
+		list = append(list, k)
+	}
+
+	return list, rows.Err()
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"github.com/IMBgl/go-wallet-api/internal/domain"
+	"github.com/IMBgl/go-wallet-api/internal/service"
+	"github.com/google/uuid"
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+	"time"
+)
+
+type budgetRepository struct {
+	repository
+}
+
+func BudgetRepository(conn *pgx.Conn) *budgetRepository {
+	return &budgetRepository{repository{Conn: conn}}
+}
+
+const budgetColumns = `id, "name", user_id, category_id, limit_amount, currency, period, start_date, end_date, rollover, created_at`
+
+func (r *budgetRepository) Save(ctx context.Context, b *domain.Budget) error {
+	_, err := r.Conn.Exec(ctx, `
+				insert into budgets (id, "name", user_id, category_id, limit_amount, currency, period, start_date, end_date, rollover, created_at, updated_at)
+													values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)
+													on conflict (id) do update
+													set name = $2, limit_amount = $5, end_date = $9, rollover = $10, updated_at = $12;`,
+		b.Id, b.Name, b.UserId, b.CategoryId, numericFromMoney(b.Limit), b.Limit.Currency().Val(), b.Period.Val(), b.StartDate, b.EndDate, b.Rollover, b.CreatedAt, time.Now())
+
+	return err
+}
+
+func (r *budgetRepository) Delete(ctx context.Context, b *domain.Budget) error {
+	_, err := r.Conn.Exec(ctx, "delete from budgets where id=$1", b.Id)
+
+	return err
+}
+
+func (r *budgetRepository) GetByIdAndUserId(ctx context.Context, id, userId uuid.UUID) (*domain.Budget, error) {
+	rows, err := r.Conn.Query(ctx, "select "+budgetColumns+" from budgets where id=$1 and user_id=$2", id, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := scanBudgets(rows)
+	if err != nil || len(list) == 0 {
+		return nil, err
+	}
+
+	return list[0], nil
+}
+
+func (r *budgetRepository) FindByUserId(ctx context.Context, userId uuid.UUID) ([]*domain.Budget, error) {
+	rows, err := r.Conn.Query(ctx, "select "+budgetColumns+" from budgets where user_id=$1 order by created_at", userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanBudgets(rows)
+}
+
+func scanBudgets(rows pgx.Rows) (list []*domain.Budget, err error) {
+	defer rows.Close()
+
+	for rows.Next() {
+		i := domain.Budget{}
+		limitVal := pgtype.Numeric{}
+		currencyVal := ""
+		periodVal := ""
+
+		err := rows.Scan(&i.Id, &i.Name, &i.UserId, &i.CategoryId, &limitVal, &currencyVal, &periodVal, &i.StartDate, &i.EndDate, &i.Rollover, &i.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		currency, err := domain.CurrencyFromCode(currencyVal)
+		if err != nil {
+			return nil, err
+		}
+
+		i.Limit, err = moneyFromNumeric(limitVal, currency)
+		if err != nil {
+			return nil, err
+		}
+
+		i.Period, err = domain.BudgetPeriodFromString(periodVal)
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, &i)
+	}
+
+	return list, rows.Err()
+}
+
+// SpentByPeriods sums the outgoing minus the incoming transactions of each
+// period and currency in one query. Transfers between wallets are not
+// spending and are left out.
+func (r *budgetRepository) SpentByPeriods(ctx context.Context, f *service.BudgetSpentFilter) ([][]domain.Money, error) {
+	starts := make([]time.Time, len(f.Periods))
+	ends := make([]time.Time, len(f.Periods))
+	for i, p := range f.Periods {
+		starts[i], ends[i] = p[0].UTC(), p[1].UTC()
+	}
+
+	out := domain.TransactionTypeOut()
+	rows, err := r.Conn.Query(ctx, `select p.i, t.currency, sum(case when t."type" = $3 then t.amount else -t.amount end)
+									from unnest($4::timestamp[], $5::timestamp[]) with ordinality as p(period_start, period_end, i)
+									join transactions t on t.user_id = $1 and t.category_id = any($2)
+										and t.created_at >= p.period_start and t.created_at < p.period_end
+										and `+notTransferSql+`
+									group by p.i, t.currency`,
+		f.UserId, f.CategoryIds, out.Val(), starts, ends)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	spent := make([][]domain.Money, len(f.Periods))
+	for rows.Next() {
+		i := int64(0)
+		currencyVal := ""
+		amountVal := pgtype.Numeric{}
+
+		err := rows.Scan(&i, &currencyVal, &amountVal)
+		if err != nil {
+			return nil, err
+		}
+
+		currency, err := domain.CurrencyFromCode(currencyVal)
+		if err != nil {
+			return nil, err
+		}
+
+		amount, err := moneyFromNumeric(amountVal, currency)
+		if err != nil {
+			return nil, err
+		}
+		spent[i-1] = append(spent[i-1], amount)
+	}
+
+	return spent, rows.Err()
+}
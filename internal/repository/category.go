@@ -70,7 +70,7 @@ func (r *categoryRepository) GetById(ctx context.Context, id uuid.UUID) (*domain
 		}
 		return nil, err
 	}
-	currency, err := domain.CurrencyFromString(currencyVal)
+	currency, err := domain.CurrencyFromCode(currencyVal)
 	if err != nil {
 		return nil, err
 	}
@@ -92,7 +92,7 @@ func (r *categoryRepository) FindByUserId(ctx context.Context, userId uuid.UUID)
 			return nil, err
 		}
 
-		currency, err := domain.CurrencyFromString(currencyVal)
+		currency, err := domain.CurrencyFromCode(currencyVal)
 		if err != nil {
 			return nil, err
 		}
@@ -116,7 +116,7 @@ func (r *categoryRepository) FindByUserIdWithNullParent(ctx context.Context, use
 			return nil, err
 		}
 
-		currency, err := domain.CurrencyFromString(currencyVal)
+		currency, err := domain.CurrencyFromCode(currencyVal)
 		if err != nil {
 			return nil, err
 		}
@@ -138,7 +138,7 @@ func (r *categoryRepository) FindByIdAndUserId(ctx context.Context, id, userId u
 		}
 		return nil, err
 	}
-	currency, err := domain.CurrencyFromString(currencyVal)
+	currency, err := domain.CurrencyFromCode(currencyVal)
 	if err != nil {
 		return nil, err
 	}
@@ -166,7 +166,7 @@ func (r *categoryRepository) GetChildren(ctx context.Context, c *domain.Category
 			return nil, err
 		}
 
-		currency, err := domain.CurrencyFromString(currencyVal)
+		currency, err := domain.CurrencyFromCode(currencyVal)
 		if err != nil {
 			return nil, err
 		}
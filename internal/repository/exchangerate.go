@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/IMBgl/go-wallet-api/internal/domain"
+	"github.com/IMBgl/go-wallet-api/internal/service"
+	"github.com/google/uuid"
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+	"math/big"
+	"strings"
+	"time"
+)
+
+type exchangeRateRepository struct {
+	repository
+}
+
+func ExchangeRateRepository(conn *pgx.Conn) *exchangeRateRepository {
+	return &exchangeRateRepository{repository{Conn: conn}}
+}
+
+const exchangeRateColumns = `id, user_id, base, quote, rate, rate_date, "source", created_at`
+
+// the unique indexes are partial, so user and global rates conflict on different columns
+const (
+	upsertUserExchangeRateConflict   = "(user_id, base, quote, rate_date) where user_id is not null"
+	upsertGlobalExchangeRateConflict = "(base, quote, rate_date) where user_id is null"
+)
+
+func (r *exchangeRateRepository) Save(ctx context.Context, rate *domain.ExchangeRate) error {
+	conflict := upsertGlobalExchangeRateConflict
+	if rate.UserId != nil {
+		conflict = upsertUserExchangeRateConflict
+	}
+
+	return r.Conn.QueryRow(ctx, `insert into exchange_rates (id, user_id, base, quote, rate, rate_date, "source", created_at, updated_at)
+									values($1,$2,$3,$4,$5,$6,$7,$8,$9)
+									on conflict `+conflict+` do update
+									set rate = $5, "source" = $7, updated_at = $9
+									returning id`,
+		rate.Id, rate.UserId, rate.Base.Val(), rate.Quote.Val(), numericFromRat(rate.Rate), rate.Date, rate.Source, rate.CreatedAt, time.Now()).Scan(&rate.Id)
+}
+
+func (r *exchangeRateRepository) GetByIdAndUserId(ctx context.Context, id, userId uuid.UUID) (*domain.ExchangeRate, error) {
+	rows, err := r.Conn.Query(ctx, "select "+exchangeRateColumns+" from exchange_rates where id=$1 and user_id=$2", id, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := scanExchangeRates(rows)
+	if err != nil || len(list) == 0 {
+		return nil, err
+	}
+
+	return list[0], nil
+}
+
+func (r *exchangeRateRepository) FindByFilter(ctx context.Context, f *service.ExchangeRateFilter) ([]*domain.ExchangeRate, error) {
+	args := &queryArgs{}
+	where := []string{fmt.Sprintf("(user_id = %s or user_id is null)", args.add(f.UserId))}
+
+	if f.Base != nil {
+		where = append(where, "base = "+args.add(f.Base.Val()))
+	}
+	if f.Quote != nil {
+		where = append(where, "quote = "+args.add(f.Quote.Val()))
+	}
+	if f.DateFrom != nil {
+		where = append(where, "rate_date >= "+args.add(*f.DateFrom))
+	}
+	if f.DateTo != nil {
+		where = append(where, "rate_date <= "+args.add(*f.DateTo))
+	}
+
+	rows, err := r.Conn.Query(ctx, "select "+exchangeRateColumns+" from exchange_rates where "+strings.Join(where, " and ")+" order by rate_date desc, base, quote, user_id nulls last", *args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanExchangeRates(rows)
+}
+
+func (r *exchangeRateRepository) FindLatest(ctx context.Context, userId uuid.UUID, base, quote domain.Currency, from, to time.Time) (*domain.ExchangeRate, error) {
+	rows, err := r.Conn.Query(ctx, "select "+exchangeRateColumns+` from exchange_rates
+									where (user_id = $1 or user_id is null) and base = $2 and quote = $3 and rate_date between $4 and $5
+									order by rate_date desc, user_id nulls last limit 1`,
+		userId, base.Val(), quote.Val(), from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := scanExchangeRates(rows)
+	if err != nil || len(list) == 0 {
+		return nil, err
+	}
+
+	return list[0], nil
+}
+
+func (r *exchangeRateRepository) Delete(ctx context.Context, rate *domain.ExchangeRate) error {
+	_, err := r.Conn.Exec(ctx, "delete from exchange_rates where id=$1", rate.Id)
+
+	return err
+}
+
+func scanExchangeRates(rows pgx.Rows) (list []*domain.ExchangeRate, err error) {
+	defer rows.Close()
+
+	for rows.Next() {
+		i := domain.ExchangeRate{}
+		baseVal, quoteVal := "", ""
+		rateVal := pgtype.Numeric{}
+
+		err := rows.Scan(&i.Id, &i.UserId, &baseVal, &quoteVal, &rateVal, &i.Date, &i.Source, &i.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		i.Base, err = domain.CurrencyFromCode(baseVal)
+		if err != nil {
+			return nil, err
+		}
+
+		i.Quote, err = domain.CurrencyFromCode(quoteVal)
+		if err != nil {
+			return nil, err
+		}
+
+		i.Rate = new(big.Rat)
+		err = rateVal.AssignTo(i.Rate)
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, &i)
+	}
+
+	return list, rows.Err()
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4"
+	"time"
+)
+
+type rateLimitRepository struct {
+	repository
+}
+
+// RateLimitRepository keeps the rate limit counters in Postgres, so all
+// instances of the api share the limits.
+func RateLimitRepository(conn *pgx.Conn) *rateLimitRepository {
+	return &rateLimitRepository{repository{Conn: conn}}
+}
+
+func (r *rateLimitRepository) Increment(ctx context.Context, key string, window time.Duration, now time.Time) (int, time.Time, error) {
+	var count int
+	var reset time.Time
+
+	err := r.Conn.QueryRow(ctx, `insert into rate_limits (key, count, reset_at) values($1, 1, $2)
+									on conflict (key) do update
+									set count = case when rate_limits.reset_at <= $3 then 1 else rate_limits.count + 1 end,
+										reset_at = case when rate_limits.reset_at <= $3 then $2 else rate_limits.reset_at end
+									returning count, reset_at`, key, now.Add(window).UTC(), now.UTC()).Scan(&count, &reset)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	return count, reset, nil
+}
+
+func (r *rateLimitRepository) Get(ctx context.Context, key string, now time.Time) (int, time.Time, error) {
+	var count int
+	var reset time.Time
+
+	err := r.Conn.QueryRow(ctx, "select count, reset_at from rate_limits where key=$1 and reset_at > $2", key, now.UTC()).Scan(&count, &reset)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return 0, time.Time{}, nil
+		}
+		return 0, time.Time{}, err
+	}
+
+	return count, reset, nil
+}
+
+func (r *rateLimitRepository) Delete(ctx context.Context, key string) error {
+	_, err := r.Conn.Exec(ctx, "delete from rate_limits where key=$1", key)
+
+	return err
+}
+
+// DeleteExpired drops the counters that reset before now.
+func (r *rateLimitRepository) DeleteExpired(ctx context.Context, now time.Time) error {
+	_, err := r.Conn.Exec(ctx, "delete from rate_limits where reset_at <= $1", now.UTC())
+
+	return err
+}
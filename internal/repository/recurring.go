@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"context"
+	"github.com/IMBgl/go-wallet-api/internal/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+	"time"
+)
+
+type recurringRepository struct {
+	repository
+}
+
+func RecurringRepository(conn *pgx.Conn) *recurringRepository {
+	return &recurringRepository{repository{Conn: conn}}
+}
+
+const recurringColumns = `id, user_id, wallet_id, category_id, amount, currency, "type", coalesce("comment", ''), frequency, "interval", day_of_month, start_date, end_date, count, next_date, created_at`
+
+const occurrenceColumns = `o.recurring_id, o.occurrence_date, o.status, o.amount, o."comment", o.transaction_id, o.error`
+
+func (r *recurringRepository) Save(ctx context.Context, t *domain.RecurringTransaction) error {
+	_, err := r.Conn.Exec(ctx, `
+				insert into recurring_transactions (id, user_id, wallet_id, category_id, amount, currency, "type", "comment", frequency, "interval", day_of_month, start_date, end_date, count, next_date, created_at, updated_at)
+													values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17)
+													on conflict (id) do update
+													set category_id = $4, amount = $5, "comment" = $8, next_date = $15, updated_at = $17;`,
+		t.Id, t.UserId, t.WalletId, t.CategoryId, numericFromMoney(t.Amount), t.Amount.Currency().Val(), t.Type.Val(), t.Comment,
+		t.Schedule.Frequency.Val(), t.Schedule.Interval, t.Schedule.DayOfMonth, t.Schedule.StartDate, t.Schedule.EndDate, t.Schedule.Count,
+		t.NextDate, t.CreatedAt, time.Now())
+
+	return err
+}
+
+func (r *recurringRepository) Delete(ctx context.Context, t *domain.RecurringTransaction) error {
+	_, err := r.Conn.Exec(ctx, "delete from recurring_transactions where id=$1", t.Id)
+
+	return err
+}
+
+func (r *recurringRepository) GetByIdAndUserId(ctx context.Context, id, userId uuid.UUID) (*domain.RecurringTransaction, error) {
+	rows, err := r.Conn.Query(ctx, "select "+recurringColumns+" from recurring_transactions where id=$1 and user_id=$2", id, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := scanRecurring(rows)
+	if err != nil || len(list) == 0 {
+		return nil, err
+	}
+
+	return list[0], nil
+}
+
+func (r *recurringRepository) FindByUserId(ctx context.Context, userId uuid.UUID) ([]*domain.RecurringTransaction, error) {
+	rows, err := r.Conn.Query(ctx, "select "+recurringColumns+" from recurring_transactions where user_id=$1 order by created_at", userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanRecurring(rows)
+}
+
+func (r *recurringRepository) FindDue(ctx context.Context, at time.Time) ([]*domain.RecurringTransaction, error) {
+	rows, err := r.Conn.Query(ctx, "select "+recurringColumns+" from recurring_transactions where next_date <= $1 order by next_date", at)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanRecurring(rows)
+}
+
+func (r *recurringRepository) SaveOccurrence(ctx context.Context, o *domain.RecurringOccurrence) error {
+	var amount interface{}
+	if o.Amount != nil {
+		amount = numericFromMoney(*o.Amount)
+	}
+
+	_, err := r.Conn.Exec(ctx, `
+				insert into recurring_occurrences (recurring_id, occurrence_date, status, amount, "comment", transaction_id, error, updated_at)
+													values($1,$2,$3,$4,$5,$6,$7,$8)
+													on conflict (recurring_id, occurrence_date) do update
+													set status = $3, amount = $4, "comment" = $5, transaction_id = $6, error = $7, updated_at = $8;`,
+		o.RecurringId, o.Date, o.Status.Val(), amount, o.Comment, o.TransactionId, o.Error, time.Now())
+
+	return err
+}
+
+func (r *recurringRepository) GetOccurrence(ctx context.Context, recurringId uuid.UUID, date time.Time) (*domain.RecurringOccurrence, error) {
+	rows, err := r.Conn.Query(ctx, `
+				select `+occurrenceColumns+`, t.currency from recurring_occurrences o
+				join recurring_transactions t on t.id = o.recurring_id
+				where o.recurring_id=$1 and o.occurrence_date=$2`, recurringId, date)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := scanOccurrences(rows)
+	if err != nil || len(list) == 0 {
+		return nil, err
+	}
+
+	return list[0], nil
+}
+
+func (r *recurringRepository) FindOccurrences(ctx context.Context, recurringId uuid.UUID, from, to time.Time) ([]*domain.RecurringOccurrence, error) {
+	rows, err := r.Conn.Query(ctx, `
+				select `+occurrenceColumns+`, t.currency from recurring_occurrences o
+				join recurring_transactions t on t.id = o.recurring_id
+				where o.recurring_id=$1 and o.occurrence_date >= $2 and o.occurrence_date <= $3
+				order by o.occurrence_date`, recurringId, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanOccurrences(rows)
+}
+
+func scanRecurring(rows pgx.Rows) (list []*domain.RecurringTransaction, err error) {
+	defer rows.Close()
+
+	for rows.Next() {
+		i := domain.RecurringTransaction{}
+		amountVal := pgtype.Numeric{}
+		currencyVal := ""
+		typeVal := ""
+		frequencyVal := ""
+
+		err := rows.Scan(&i.Id, &i.UserId, &i.WalletId, &i.CategoryId, &amountVal, &currencyVal, &typeVal, &i.Comment,
+			&frequencyVal, &i.Schedule.Interval, &i.Schedule.DayOfMonth, &i.Schedule.StartDate, &i.Schedule.EndDate, &i.Schedule.Count,
+			&i.NextDate, &i.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		currency, err := domain.CurrencyFromCode(currencyVal)
+		if err != nil {
+			return nil, err
+		}
+
+		i.Amount, err = moneyFromNumeric(amountVal, currency)
+		if err != nil {
+			return nil, err
+		}
+
+		i.Type, err = domain.TransactionTypeFromString(typeVal)
+		if err != nil {
+			return nil, err
+		}
+
+		i.Schedule.Frequency, err = domain.FrequencyFromString(frequencyVal)
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, &i)
+	}
+
+	return list, rows.Err()
+}
+
+func scanOccurrences(rows pgx.Rows) (list []*domain.RecurringOccurrence, err error) {
+	defer rows.Close()
+
+	for rows.Next() {
+		i := domain.RecurringOccurrence{}
+		statusVal := ""
+		amountVal := pgtype.Numeric{}
+		currencyVal := ""
+
+		err := rows.Scan(&i.RecurringId, &i.Date, &statusVal, &amountVal, &i.Comment, &i.TransactionId, &i.Error, &currencyVal)
+		if err != nil {
+			return nil, err
+		}
+
+		i.Status, err = domain.OccurrenceStatusFromString(statusVal)
+		if err != nil {
+			return nil, err
+		}
+
+		if amountVal.Status == pgtype.Present {
+			currency, err := domain.CurrencyFromCode(currencyVal)
+			if err != nil {
+				return nil, err
+			}
+
+			amount, err := moneyFromNumeric(amountVal, currency)
+			if err != nil {
+				return nil, err
+			}
+			i.Amount = &amount
+		}
+
+		list = append(list, &i)
+	}
+
+	return list, rows.Err()
+}
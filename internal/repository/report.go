@@ -0,0 +1,237 @@
+package repository
+
+import (
+	"context"
+	"github.com/IMBgl/go-wallet-api/internal/domain"
+	"github.com/IMBgl/go-wallet-api/internal/service"
+	"github.com/google/uuid"
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+	"strings"
+	"time"
+)
+
+type reportRepository struct {
+	repository
+}
+
+// ReportRepository aggregates the transactions in the database, reports do not load them one by one.
+func ReportRepository(conn *pgx.Conn) *reportRepository {
+	return &reportRepository{repository{Conn: conn}}
+}
+
+// intervalStartSql truncates the UTC created_at to the interval start in the
+// time zone given by the argument. The result is the local wall time.
+func intervalStartSql(interval, zone string) string {
+	return "date_trunc(" + interval + ", (created_at at time zone 'UTC') at time zone " + zone + ")"
+}
+
+// WalletChangesAt sums the transactions of the wallet made before at and the
+// ones made at or after it, the start of each sum is its first transaction.
+func (r *reportRepository) WalletChangesAt(ctx context.Context, walletId uuid.UUID, at time.Time) ([]*service.WalletChange, error) {
+	in := domain.TransactionTypeIn()
+	rows, err := r.Conn.Query(ctx, `select wallet_id, currency, min(created_at),
+									sum(case when "type" = $3 then amount else -amount end)
+									from transactions where wallet_id = $1
+									group by wallet_id, currency, created_at >= $2`,
+		walletId, at, in.Val())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []*service.WalletChange{}
+	for rows.Next() {
+		i := service.WalletChange{}
+		currencyVal := ""
+		amountVal := pgtype.Numeric{}
+
+		err := rows.Scan(&i.WalletId, &currencyVal, &i.Start, &amountVal)
+		if err != nil {
+			return nil, err
+		}
+
+		currency, err := domain.CurrencyFromCode(currencyVal)
+		if err != nil {
+			return nil, err
+		}
+
+		i.Amount, err = moneyFromNumeric(amountVal, currency)
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, &i)
+	}
+
+	return list, rows.Err()
+}
+
+// notTransferSql leaves out the transactions t which are a side of a transfer.
+const notTransferSql = "not exists (select 1 from transfers tr where tr.out_transaction_id = t.id or tr.in_transaction_id = t.id)"
+
+func (r *reportRepository) WalletChanges(ctx context.Context, f *service.WalletChangesFilter) ([]*service.WalletChange, error) {
+	in := domain.TransactionTypeIn()
+	args := &queryArgs{}
+	where := []string{"user_id = " + args.add(f.UserId)}
+	if f.WalletId != nil {
+		where = append(where, "wallet_id = "+args.add(*f.WalletId))
+	}
+	start := intervalStartSql(args.add(f.Interval.Val()), args.add(f.Location.String()))
+
+	rows, err := r.Conn.Query(ctx, `select wallet_id, currency, `+start+` as start,
+									sum(case when "type" = `+args.add(in.Val())+` then amount else -amount end)
+									from transactions where `+strings.Join(where, " and ")+`
+									group by wallet_id, currency, start`, *args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []*service.WalletChange{}
+	for rows.Next() {
+		i := service.WalletChange{}
+		currencyVal := ""
+		amountVal := pgtype.Numeric{}
+
+		err := rows.Scan(&i.WalletId, &currencyVal, &i.Start, &amountVal)
+		if err != nil {
+			return nil, err
+		}
+
+		currency, err := domain.CurrencyFromCode(currencyVal)
+		if err != nil {
+			return nil, err
+		}
+
+		i.Amount, err = moneyFromNumeric(amountVal, currency)
+		if err != nil {
+			return nil, err
+		}
+		i.Start = inLocation(i.Start, f.Location)
+
+		list = append(list, &i)
+	}
+
+	return list, rows.Err()
+}
+
+func (r *reportRepository) CategoryTotals(ctx context.Context, f *service.CategoryTotalsFilter) ([]*service.CategoryTotal, error) {
+	args := &queryArgs{}
+	where := []string{
+		"t.user_id = " + args.add(f.UserId),
+		"t.category_id is not null",
+		"t.created_at >= " + args.add(f.DateFrom),
+		"t.created_at < " + args.add(f.DateTo),
+		notTransferSql,
+	}
+	if f.WalletId != nil {
+		where = append(where, "t.wallet_id = "+args.add(*f.WalletId))
+	}
+
+	rows, err := r.Conn.Query(ctx, `select t.category_id, t."type", t.currency, sum(t.amount) from transactions t
+									where `+strings.Join(where, " and ")+`
+									group by t.category_id, t."type", t.currency`, *args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []*service.CategoryTotal{}
+	for rows.Next() {
+		i := service.CategoryTotal{}
+		typeVal := ""
+		currencyVal := ""
+		amountVal := pgtype.Numeric{}
+
+		err := rows.Scan(&i.CategoryId, &typeVal, &currencyVal, &amountVal)
+		if err != nil {
+			return nil, err
+		}
+
+		i.Type, err = domain.TransactionTypeFromString(typeVal)
+		if err != nil {
+			return nil, err
+		}
+
+		currency, err := domain.CurrencyFromCode(currencyVal)
+		if err != nil {
+			return nil, err
+		}
+
+		i.Amount, err = moneyFromNumeric(amountVal, currency)
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, &i)
+	}
+
+	return list, rows.Err()
+}
+
+// Cashflow sums the in and out transactions per interval and wallet or
+// category. Transfers move money between wallets, they are summed apart in
+// the wallet split and are left out of the category split.
+func (r *reportRepository) Cashflow(ctx context.Context, f *service.CashflowFilter) ([]*service.CashflowTotal, error) {
+	args := &queryArgs{}
+	where := []string{
+		"t.user_id = " + args.add(f.UserId),
+		"t.created_at >= " + args.add(f.DateFrom),
+		"t.created_at < " + args.add(f.DateTo),
+	}
+	start := intervalStartSql(args.add(f.Interval.Val()), args.add(f.Location.String()))
+
+	group := "t.wallet_id"
+	if f.GroupBy == domain.ReportGroupCategory() {
+		group = "t.category_id"
+		where = append(where, notTransferSql)
+	}
+
+	rows, err := r.Conn.Query(ctx, `select `+start+` as start, `+group+`, t."type", not (`+notTransferSql+`) as transfer, t.currency, sum(t.amount)
+									from transactions t
+									where `+strings.Join(where, " and ")+`
+									group by start, `+group+`, t."type", transfer, t.currency`, *args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []*service.CashflowTotal{}
+	for rows.Next() {
+		i := service.CashflowTotal{}
+		typeVal := ""
+		currencyVal := ""
+		amountVal := pgtype.Numeric{}
+
+		err := rows.Scan(&i.Start, &i.GroupId, &typeVal, &i.Transfer, &currencyVal, &amountVal)
+		if err != nil {
+			return nil, err
+		}
+
+		i.Type, err = domain.TransactionTypeFromString(typeVal)
+		if err != nil {
+			return nil, err
+		}
+
+		currency, err := domain.CurrencyFromCode(currencyVal)
+		if err != nil {
+			return nil, err
+		}
+
+		i.Amount, err = moneyFromNumeric(amountVal, currency)
+		if err != nil {
+			return nil, err
+		}
+		i.Start = inLocation(i.Start, f.Location)
+
+		list = append(list, &i)
+	}
+
+	return list, rows.Err()
+}
+
+// inLocation reads the wall time of a timestamp column as a time in loc.
+func inLocation(t time.Time, loc *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+}
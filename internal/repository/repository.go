@@ -12,6 +12,14 @@ type repository struct {
 	wallet      *walletRepository
 	category    *categoryRepository
 	transaction *transactionRepository
+	transfer    *transferRepository
+	budget      *budgetRepository
+	recurring   *recurringRepository
+	apiKey      *apiKeyRepository
+	twoFactor   *twoFactorRepository
+	actionToken *actionTokenRepository
+	rate        *exchangeRateRepository
+	report      *reportRepository
 }
 
 //func (r *repository) AsTransaction(ctx context.Context, payload func() error) error {
@@ -50,6 +58,38 @@ func (r *repository) Transaction() service.TransactionRepository {
 	return r.transaction
 }
 
+func (r *repository) Transfer() service.TransferRepository {
+	return r.transfer
+}
+
+func (r *repository) Budget() service.BudgetRepository {
+	return r.budget
+}
+
+func (r *repository) Recurring() service.RecurringRepository {
+	return r.recurring
+}
+
+func (r *repository) ApiKey() service.ApiKeyRepository {
+	return r.apiKey
+}
+
+func (r *repository) TwoFactor() service.TwoFactorRepository {
+	return r.twoFactor
+}
+
+func (r *repository) ActionToken() service.ActionTokenRepository {
+	return r.actionToken
+}
+
+func (r *repository) ExchangeRate() service.ExchangeRateRepository {
+	return r.rate
+}
+
+func (r *repository) Report() service.ReportRepository {
+	return r.report
+}
+
 func New(conn *pgx.Conn) *repository {
 	return &repository{
 		Conn:        conn,
@@ -58,5 +98,13 @@ func New(conn *pgx.Conn) *repository {
 		wallet:      WalletRepository(conn),
 		category:    CategoryRepository(conn),
 		transaction: TransactionRepository(conn),
+		transfer:    TransferRepository(conn),
+		budget:      BudgetRepository(conn),
+		recurring:   RecurringRepository(conn),
+		apiKey:      ApiKeyRepository(conn),
+		twoFactor:   TwoFactorRepository(conn),
+		actionToken: ActionTokenRepository(conn),
+		rate:        ExchangeRateRepository(conn),
+		report:      ReportRepository(conn),
 	}
 }
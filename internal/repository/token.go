@@ -14,7 +14,10 @@ type TokenModel struct {
 	gorm.Model
 	Id        uuid.UUID
 	UserId    uuid.UUID
+	Kind      string
+	FamilyId  uuid.UUID
 	ExpiresAt time.Time
+	UsedAt    *time.Time
 	Hash      string
 }
 
@@ -26,8 +29,11 @@ func (m *TokenModel) Entity() (*service.UserToken, error) {
 	return &service.UserToken{
 		Id:        m.Id,
 		UserId:    m.UserId,
+		Kind:      m.Kind,
+		FamilyId:  m.FamilyId,
 		Exp:       m.ExpiresAt,
-		Value:     m.Hash,
+		UsedAt:    m.UsedAt,
+		Hash:      m.Hash,
 		CreatedAt: m.CreatedAt,
 	}, nil
 }
@@ -35,8 +41,11 @@ func (m *TokenModel) Entity() (*service.UserToken, error) {
 func (m *TokenModel) FromEntity(u *service.UserToken) *TokenModel {
 	m.Id = u.Id
 	m.UserId = u.UserId
+	m.Kind = u.Kind
+	m.FamilyId = u.FamilyId
 	m.ExpiresAt = u.Exp
-	m.Hash = u.Value
+	m.UsedAt = u.UsedAt
+	m.Hash = u.Hash
 	m.CreatedAt = u.CreatedAt
 	m.UpdatedAt = time.Now()
 
@@ -56,6 +65,10 @@ func MapUserTokenList(modelLsit []TokenModel) ([]*service.UserToken, error) {
 	return list, nil
 }
 
+const tokenColumns = "id, user_id, kind, family_id, hash, expires_at, used_at, created_at"
+
+const insertTokenSql = "insert into user_tokens (id, user_id, kind, family_id, hash, expires_at, used_at, created_at, updated_at) values($1,$2,$3,$4,$5,$6,$7,$8,$9)"
+
 type tokenRepository struct {
 	repository
 }
@@ -64,10 +77,29 @@ func TokenRepository(conn *pgx.Conn) *tokenRepository {
 	return &tokenRepository{repository{Conn: conn}}
 }
 
-func (r *tokenRepository) GetById(ctx context.Context, id uuid.UUID) (*service.UserToken, error) {
+func scanToken(row pgx.Row) (*service.UserToken, error) {
 	token := service.UserToken{}
+	err := row.Scan(&token.Id, &token.UserId, &token.Kind, &token.FamilyId, &token.Hash, &token.Exp, &token.UsedAt, &token.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func insertTokens(ctx context.Context, tx pgx.Tx, tokens []*service.UserToken) error {
+	for _, t := range tokens {
+		_, err := tx.Exec(ctx, insertTokenSql, t.Id, t.UserId, t.Kind, t.FamilyId, t.Hash, t.Exp, t.UsedAt, t.CreatedAt, time.Now())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
 
-	err := r.Conn.QueryRow(ctx, "select id, user_id, hash, expires_at, created_at, updated_at from user_tokens where id=$1", id).Scan(&token.Id, &token.UserId, &token.Value, &token.Exp, &token.CreatedAt)
+func (r *tokenRepository) GetById(ctx context.Context, id uuid.UUID) (*service.UserToken, error) {
+	token, err := scanToken(r.Conn.QueryRow(ctx, "select "+tokenColumns+" from user_tokens where id=$1", id))
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, service.ErrNotFound
@@ -75,13 +107,23 @@ func (r *tokenRepository) GetById(ctx context.Context, id uuid.UUID) (*service.U
 		return nil, err
 	}
 
-	return &token, nil
+	return token, nil
 }
 
-func (r *tokenRepository) GetByValue(ctx context.Context, value string) (*service.UserToken, error) {
-	token := service.UserToken{}
+func (r *tokenRepository) GetByIdAndUserId(ctx context.Context, id, userId uuid.UUID) (*service.UserToken, error) {
+	token, err := scanToken(r.Conn.QueryRow(ctx, "select "+tokenColumns+" from user_tokens where id=$1 and user_id=$2", id, userId))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
 
-	err := r.Conn.QueryRow(ctx, "select id, user_id, hash, expires_at, created_at from user_tokens where hash=$1", value).Scan(&token.Id, &token.UserId, &token.Value, &token.Exp, &token.CreatedAt)
+	return token, nil
+}
+
+func (r *tokenRepository) GetByValue(ctx context.Context, value string) (*service.UserToken, error) {
+	token, err := scanToken(r.Conn.QueryRow(ctx, "select "+tokenColumns+" from user_tokens where hash=$1", service.HashTokenValue(value)))
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, nil
@@ -89,29 +131,41 @@ func (r *tokenRepository) GetByValue(ctx context.Context, value string) (*servic
 		return nil, err
 	}
 
-	return &token, nil
+	return token, nil
 }
 
 func (r *tokenRepository) FindByUser(ctx context.Context, user *domain.User) ([]*service.UserToken, error) {
 	list := []*service.UserToken{}
-	rows, _ := r.Conn.Query(ctx, "select id, user_id, hash, expires_at, created_at from user_tokens where user_id=$1", user.Id)
+	rows, err := r.Conn.Query(ctx, "select "+tokenColumns+" from user_tokens where user_id=$1 order by created_at", user.Id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
 	for rows.Next() {
-		token := service.UserToken{}
-		err := rows.Scan(&token.Id, &token.UserId, &token.Value, &token.Exp, &token.CreatedAt)
+		token, err := scanToken(rows)
 		if err != nil {
 			return nil, err
 		}
-		list = append(list, &token)
+		list = append(list, token)
 	}
 
-	return list, nil
+	return list, rows.Err()
 }
 
-func (r *tokenRepository) Save(ctx context.Context, t *service.UserToken) error {
-	_, err := r.Conn.Exec(ctx, "insert into user_tokens (id, user_id, hash, expires_at, created_at, updated_at) values($1,$2,$3,$4,$5,$6)", t.Id, t.UserId, t.Value, t.Exp, t.CreatedAt, time.Now())
+func (r *tokenRepository) Save(ctx context.Context, tokens ...*service.UserToken) error {
+	tx, err := r.Conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
 
-	return err
+	err = insertTokens(ctx, tx, tokens)
+	if err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+
+	return tx.Commit(ctx)
 }
 
 func (r *tokenRepository) Delete(ctx context.Context, t *service.UserToken) error {
@@ -120,7 +174,19 @@ func (r *tokenRepository) Delete(ctx context.Context, t *service.UserToken) erro
 	return err
 }
 
-func (r *tokenRepository) DeleteAllForUserAndSave(ctx context.Context, u *domain.User, t *service.UserToken) error {
+func (r *tokenRepository) DeleteFamily(ctx context.Context, userId, familyId uuid.UUID) error {
+	_, err := r.Conn.Exec(ctx, "delete from user_tokens where user_id = $1 and family_id = $2", userId, familyId)
+
+	return err
+}
+
+func (r *tokenRepository) DeleteAllForUser(ctx context.Context, userId uuid.UUID) error {
+	_, err := r.Conn.Exec(ctx, "delete from user_tokens where user_id = $1", userId)
+
+	return err
+}
+
+func (r *tokenRepository) DeleteAllForUserAndSave(ctx context.Context, u *domain.User, tokens ...*service.UserToken) error {
 	tx, err := r.Conn.Begin(ctx)
 	if err != nil {
 		return err
@@ -132,7 +198,42 @@ func (r *tokenRepository) DeleteAllForUserAndSave(ctx context.Context, u *domain
 		return err
 	}
 
-	_, err = tx.Exec(ctx, "insert into user_tokens (id, user_id, hash, expires_at, created_at, updated_at) values($1,$2,$3,$4,$5,$6)", t.Id, t.UserId, t.Value, t.Exp, t.CreatedAt, time.Now())
+	err = insertTokens(ctx, tx, tokens)
+	if err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Rotate keeps the used refresh token to detect its reuse until usedBefore
+// passes it or it expires, the access tokens issued before are revoked with
+// the rotation.
+func (r *tokenRepository) Rotate(ctx context.Context, used *service.UserToken, pair *service.TokenPair, usedBefore time.Time) error {
+	tx, err := r.Conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	tag, err := tx.Exec(ctx, "update user_tokens set used_at = $2, updated_at = $2 where id = $1 and used_at is null", used.Id, time.Now())
+	if err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		tx.Rollback(ctx)
+		return service.ErrRefreshTokenReused
+	}
+
+	_, err = tx.Exec(ctx, "delete from user_tokens where family_id = $1 and (kind = $2 or expires_at < $3 or used_at < $4)", used.FamilyId, service.TOKEN_KIND_ACCESS, time.Now(), usedBefore)
+	if err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+
+	err = insertTokens(ctx, tx, pair.Stored())
 	if err != nil {
 		tx.Rollback(ctx)
 		return err
@@ -2,17 +2,23 @@ package repository
 
 import (
 	"context"
+	"fmt"
 	"github.com/IMBgl/go-wallet-api/internal/domain"
+	"github.com/IMBgl/go-wallet-api/internal/service"
 	"github.com/google/uuid"
+	"github.com/jackc/pgtype"
 	"github.com/jackc/pgx/v4"
 	"gorm.io/gorm"
+	"math/big"
+	"sort"
+	"strings"
 	"time"
 )
 
 type TransactionModel struct {
 	gorm.Model
 	Id         uuid.UUID
-	Amount     float32
+	Amount     string
 	Currency   CurrencyValue
 	Comment    string
 	Type       TransactionTypeValue
@@ -25,17 +31,22 @@ func (TransactionModel) TableName() string {
 	return "transactions"
 }
 
-func (m *TransactionModel) Entity() *domain.Transaction {
-	return domain.NewTransaction(m.Comment, m.Amount, m.Currency.Currency, m.Type.TransactionType, m.UserId, m.CategoryId, m.WalletId)
+func (m *TransactionModel) Entity() (*domain.Transaction, error) {
+	amount, err := domain.MoneyFromString(m.Amount, m.Currency.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	return domain.NewTransaction(m.Comment, amount, m.Type.TransactionType, m.UserId, m.CategoryId, m.WalletId), nil
 }
 
 func (m *TransactionModel) FromEntity(e *domain.Transaction) *TransactionModel {
 	m.Id = e.Id
 	m.Comment = e.Comment
 	m.UserId = e.UserId
-	m.Currency = CurrencyValue{e.Currency}
+	m.Currency = CurrencyValue{e.Amount.Currency()}
 	m.Type = TransactionTypeValue{e.Type}
-	m.Amount = e.Amount
+	m.Amount = e.Amount.String()
 	m.CategoryId = e.CategoryId
 	m.WalletId = e.WalletId
 	m.CreatedAt = e.CreatedAt
@@ -51,27 +62,176 @@ func TransactionRepository(conn *pgx.Conn) *transactionRepository {
 	return &transactionRepository{repository{Conn: conn}}
 }
 
+const insertTransactionQuery = `insert into transactions (id, amount, user_id, wallet_id, category_id, currency, "comment", "type", created_at, updated_at, external_id, original_amount, original_currency, rate) values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14)`
+
+// insertTransactionArgs stores created_at in UTC, the column has no time zone
+// and the reports read it as UTC.
+func insertTransactionArgs(t *domain.Transaction) []interface{} {
+	originalAmount, originalCurrency, rate := transactionConversionArgs(t)
+
+	return []interface{}{t.Id, numericFromMoney(t.Amount), t.UserId, t.WalletId, nullableUuid(t.CategoryId), t.Amount.Currency().Val(), t.Comment, t.Type.Val(), t.CreatedAt.UTC(), time.Now(), nullableString(t.ExternalId), originalAmount, originalCurrency, rate}
+}
+
+// transactionConversionArgs returns the entered amount, its currency and the rate of a converted transaction, all NULL otherwise.
+func transactionConversionArgs(t *domain.Transaction) (*pgtype.Numeric, *string, *pgtype.Numeric) {
+	if t.OriginalAmount == nil || t.Rate == nil {
+		return nil, nil, nil
+	}
+
+	amount := numericFromMoney(*t.OriginalAmount)
+	currency := t.OriginalAmount.Currency().Val()
+	rate := numericFromRat(t.Rate)
+
+	return &amount, &currency, &rate
+}
+
 func (r *transactionRepository) Save(ctx context.Context, t *domain.Transaction) error {
-	_, err := r.Conn.Exec(ctx, "insert into transactions (id, amount, user_id, wallet_id, category_id, currency, \"comment\", \"type\", created_at, updated_at) values($1,$2,$3,$4,$5,$6, $7)",
-		t.Id, t.Amount, t.UserId, t.WalletId, t.CategoryId, t.Currency.Val(), t.Comment, t.Type.Val(), t.CreatedAt, time.Now())
+	_, err := r.Conn.Exec(ctx, insertTransactionQuery, insertTransactionArgs(t)...)
 
 	return err
 }
 
+const transactionColumns = `id, amount, user_id, wallet_id, category_id, currency, coalesce("comment", ''), "type", created_at, coalesce(external_id, ''), original_amount, original_currency, rate`
+
 func (r *transactionRepository) FindByWalletIdAndUserId(ctx context.Context, walletId, userId uuid.UUID) (list []*domain.Transaction, err error) {
-	rows, _ := r.Conn.Query(ctx, "select id, amount, user_id, wallet_id, category_id, currency, \"comment\", \"type\", created_at from transactions where id =$1 and user_id = $2", walletId, userId)
+	rows, err := r.Conn.Query(ctx, "select "+transactionColumns+" from transactions where wallet_id = $1 and user_id = $2", walletId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanTransactions(rows)
+}
+
+func (r *transactionRepository) GetByIdAndUserId(ctx context.Context, id, userId uuid.UUID) (*domain.Transaction, error) {
+	rows, err := r.Conn.Query(ctx, "select "+transactionColumns+" from transactions where id = $1 and user_id = $2", id, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := scanTransactions(rows)
+	if err != nil || len(list) == 0 {
+		return nil, err
+	}
+
+	return list[0], nil
+}
+
+func (r *transactionRepository) FindByFilter(ctx context.Context, f *service.TransactionFilter) ([]*domain.Transaction, error) {
+	args := &queryArgs{}
+	where := transactionFilterWhere(f, args)
+
+	column := "created_at"
+	if f.Sort.Field == service.TransactionSortAmount {
+		column = "amount"
+	}
+
+	direction, cmp := "asc", ">"
+	if f.Sort.Desc {
+		direction, cmp = "desc", "<"
+	}
+
+	if f.After != nil {
+		var value interface{} = f.After.Date.UTC()
+		if f.Sort.Field == service.TransactionSortAmount {
+			rat, _ := new(big.Rat).SetString(f.After.Amount)
+			value = numericFromRat(rat)
+		}
+		where = append(where, fmt.Sprintf("(%s, id) %s (%s, %s)", column, cmp, args.add(value), args.add(f.After.Id)))
+	}
+
+	query := fmt.Sprintf("select %s from transactions where %s order by %s %s, id %s",
+		transactionColumns, strings.Join(where, " and "), column, direction, direction)
+	if f.Limit > 0 {
+		query += " limit " + args.add(f.Limit)
+	}
+
+	rows, err := r.Conn.Query(ctx, query, *args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanTransactions(rows)
+}
+
+// SumByFilter returns the total amount of the matching transactions. The
+// filter must be restricted to one currency.
+func (r *transactionRepository) SumByFilter(ctx context.Context, f *service.TransactionFilter) (domain.Money, error) {
+	if f.Currency == nil {
+		return domain.Money{}, domain.ErrInvalidCurrency
+	}
+
+	args := &queryArgs{}
+	where := transactionFilterWhere(f, args)
+
+	sumVal := pgtype.Numeric{}
+	err := r.Conn.QueryRow(ctx, "select coalesce(sum(amount), 0) from transactions where "+strings.Join(where, " and "), *args...).Scan(&sumVal)
+	if err != nil {
+		return domain.Money{}, err
+	}
+
+	return moneyFromNumeric(sumVal, *f.Currency)
+}
+
+func transactionFilterWhere(f *service.TransactionFilter, args *queryArgs) []string {
+	where := []string{"user_id = " + args.add(f.UserId)}
+
+	if f.WalletId != nil {
+		where = append(where, "wallet_id = "+args.add(*f.WalletId))
+	}
+	if len(f.CategoryIds) > 0 {
+		where = append(where, "category_id = any("+args.add(f.CategoryIds)+")")
+	}
+	if f.Type != nil {
+		where = append(where, `"type" = `+args.add(f.Type.Val()))
+	}
+	if f.Currency != nil {
+		where = append(where, "currency = "+args.add(f.Currency.Val()))
+	}
+	if f.AmountFrom != nil {
+		where = append(where, "amount >= "+args.add(numericFromRat(f.AmountFrom)))
+	}
+	if f.AmountTo != nil {
+		where = append(where, "amount <= "+args.add(numericFromRat(f.AmountTo)))
+	}
+	if f.DateFrom != nil {
+		where = append(where, "created_at >= "+args.add(f.DateFrom.UTC()))
+	}
+	if f.DateTo != nil {
+		where = append(where, "created_at <= "+args.add(f.DateTo.UTC()))
+	}
+	if f.DateBefore != nil {
+		where = append(where, "created_at < "+args.add(f.DateBefore.UTC()))
+	}
+	if f.Comment != "" {
+		where = append(where, `"comment" ilike `+args.add("%"+escapeLike(f.Comment)+"%"))
+	}
+	if len(f.ExternalIds) > 0 {
+		where = append(where, "external_id = any("+args.add(f.ExternalIds)+")")
+	}
+
+	return where
+}
+
+func scanTransactions(rows pgx.Rows) (list []*domain.Transaction, err error) {
+	defer rows.Close()
 
 	for rows.Next() {
 		i := domain.Transaction{}
 		currencyVal := ""
 		typeVal := ""
+		amountVal := pgtype.Numeric{}
 
-		err := rows.Scan(&i.Id, &i.Amount, &i.UserId, &i.WalletId, &i.CategoryId, &currencyVal, &i.Comment, &typeVal, &i.CreatedAt)
+		categoryId := (*uuid.UUID)(nil)
+		originalAmountVal := pgtype.Numeric{}
+		originalCurrencyVal := (*string)(nil)
+		rateVal := pgtype.Numeric{}
+
+		err := rows.Scan(&i.Id, &amountVal, &i.UserId, &i.WalletId, &categoryId, &currencyVal, &i.Comment, &typeVal, &i.CreatedAt, &i.ExternalId, &originalAmountVal, &originalCurrencyVal, &rateVal)
 		if err != nil {
 			return nil, err
 		}
 
-		currency, err := domain.CurrencyFromString(currencyVal)
+		currency, err := domain.CurrencyFromCode(currencyVal)
 		if err != nil {
 			return nil, err
 		}
@@ -81,29 +241,187 @@ func (r *transactionRepository) FindByWalletIdAndUserId(ctx context.Context, wal
 			return nil, err
 		}
 
-		i.Currency = currency
+		i.Amount, err = moneyFromNumeric(amountVal, currency)
+		if err != nil {
+			return nil, err
+		}
 		i.Type = transactionType
+		if categoryId != nil {
+			i.CategoryId = *categoryId
+		}
+
+		if originalCurrencyVal != nil && originalAmountVal.Status == pgtype.Present && rateVal.Status == pgtype.Present {
+			originalCurrency, err := domain.CurrencyFromCode(*originalCurrencyVal)
+			if err != nil {
+				return nil, err
+			}
+
+			originalAmount, err := moneyFromNumeric(originalAmountVal, originalCurrency)
+			if err != nil {
+				return nil, err
+			}
+			i.OriginalAmount = &originalAmount
+
+			i.Rate = new(big.Rat)
+			err = rateVal.AssignTo(i.Rate)
+			if err != nil {
+				return nil, err
+			}
+		}
 
 		list = append(list, &i)
 	}
 
-	return
+	return list, rows.Err()
+}
+
+// addWalletBalanceQuery changes the stored balance by a difference, requests
+// writing the same wallet at once do not overwrite each other.
+const addWalletBalanceQuery = "update wallets set balance = balance + $1, updated_at = $2 where id = $3"
+
+// updateWalletBalances adds the applied transactions to the balances of their
+// wallets and takes the reverted ones back. The wallets are updated in the
+// order of their ids so concurrent requests lock them in the same order.
+func updateWalletBalances(ctx context.Context, tx pgx.Tx, applied, reverted []*domain.Transaction) error {
+	changes, err := domain.WalletBalanceChanges(applied, reverted)
+	if err != nil {
+		return err
+	}
+
+	wallets := make([]uuid.UUID, 0, len(changes))
+	for walletId := range changes {
+		wallets = append(wallets, walletId)
+	}
+	sort.Slice(wallets, func(i, j int) bool {
+		return wallets[i].String() < wallets[j].String()
+	})
+
+	for _, walletId := range wallets {
+		_, err := tx.Exec(ctx, addWalletBalanceQuery, numericFromMoney(changes[walletId]), time.Now(), walletId)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-func (r *transactionRepository) SaveAndUpdateWalletBalance(ctx context.Context, t *domain.Transaction, balance float32) error {
+func (r *transactionRepository) SaveAndUpdateWalletBalance(ctx context.Context, t *domain.Transaction) error {
 	tx, err := r.Conn.Begin(ctx)
 	if err != nil {
 		return err
 	}
 
-	_, err = tx.Exec(ctx, "insert into transactions (id, amount, user_id, wallet_id, category_id, currency, \"comment\", \"type\", created_at, updated_at,) values($1,$2,$3,$4,$5,$6, $7)",
-		t.Id, t.Amount, t.UserId, t.WalletId, t.CategoryId, t.Currency.Val(), t.Comment, t.Type.Val(), t.CreatedAt, time.Now())
+	_, err = tx.Exec(ctx, insertTransactionQuery, insertTransactionArgs(t)...)
+	if err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+
+	err = updateWalletBalances(ctx, tx, []*domain.Transaction{t}, nil)
+	if err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// unchangedTransactionSql matches the transaction only while the columns the
+// wallet balance depends on still have the values the change was made from.
+const unchangedTransactionSql = `wallet_id = $%d and amount = $%d and currency = $%d and "type" = $%d`
+
+func unchangedTransactionArgs(t *domain.Transaction) []interface{} {
+	return []interface{}{t.WalletId, numericFromMoney(t.Amount), t.Amount.Currency().Val(), t.Type.Val()}
+}
+
+// UpdateAndUpdateWalletBalances fails with ErrTransactionChanged when old is
+// no longer the stored transaction, its balance change was taken back already.
+func (r *transactionRepository) UpdateAndUpdateWalletBalances(ctx context.Context, old, t *domain.Transaction) error {
+	tx, err := r.Conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	originalAmount, originalCurrency, rate := transactionConversionArgs(t)
+	args := []interface{}{numericFromMoney(t.Amount), t.WalletId, nullableUuid(t.CategoryId), t.Amount.Currency().Val(), t.Comment, t.Type.Val(), time.Now(), originalAmount, originalCurrency, rate, t.Id}
+	tag, err := tx.Exec(ctx, "update transactions set amount = $1, wallet_id = $2, category_id = $3, currency = $4, \"comment\" = $5, \"type\" = $6, updated_at = $7, original_amount = $8, original_currency = $9, rate = $10 where id = $11 and "+fmt.Sprintf(unchangedTransactionSql, 12, 13, 14, 15),
+		append(args, unchangedTransactionArgs(old)...)...)
+	if err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		tx.Rollback(ctx)
+		return domain.ErrTransactionChanged
+	}
+
+	err = updateWalletBalances(ctx, tx, []*domain.Transaction{t}, []*domain.Transaction{old})
+	if err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// DeleteAndUpdateWalletBalance fails with ErrTransactionNotFound when t was
+// deleted or changed meanwhile, its balance change was taken back already.
+func (r *transactionRepository) DeleteAndUpdateWalletBalance(ctx context.Context, t *domain.Transaction) error {
+	tx, err := r.Conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	tag, err := tx.Exec(ctx, "delete from transactions where id = $1 and "+fmt.Sprintf(unchangedTransactionSql, 2, 3, 4, 5),
+		append([]interface{}{t.Id}, unchangedTransactionArgs(t)...)...)
+	if err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		tx.Rollback(ctx)
+		return domain.ErrTransactionNotFound
+	}
+
+	err = updateWalletBalances(ctx, tx, nil, []*domain.Transaction{t})
+	if err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// SaveImport books the imported transactions and the account mapping in a single database transaction.
+func (r *transactionRepository) SaveImport(ctx context.Context, w *domain.Wallet, accountId string, list []*domain.Transaction) error {
+	tx, err := r.Conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	if accountId != "" {
+		_, err = tx.Exec(ctx, saveWalletAccountQuery, w.UserId, accountId, w.Id, time.Now())
+		if err != nil {
+			tx.Rollback(ctx)
+			return err
+		}
+	}
+
+	batch := &pgx.Batch{}
+	for _, t := range list {
+		batch.Queue(insertTransactionQuery, insertTransactionArgs(t)...)
+	}
+
+	err = tx.SendBatch(ctx, batch).Close()
 	if err != nil {
 		tx.Rollback(ctx)
 		return err
 	}
 
-	_, err = tx.Exec(ctx, "update wallets set balance = $1 where id=$2", balance, t.WalletId)
+	err = updateWalletBalances(ctx, tx, list, nil)
 	if err != nil {
 		tx.Rollback(ctx)
 		return err
@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"context"
+	"github.com/IMBgl/go-wallet-api/internal/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+	"gorm.io/gorm"
+	"math/big"
+	"time"
+)
+
+type TransferModel struct {
+	gorm.Model
+	Id               uuid.UUID
+	UserId           uuid.UUID
+	OutTransactionId uuid.UUID
+	InTransactionId  uuid.UUID
+	Rate             *string
+	ReversalOfId     *uuid.UUID
+}
+
+func (TransferModel) TableName() string {
+	return "transfers"
+}
+
+func (m *TransferModel) Entity() (*domain.Transfer, error) {
+	transfer := &domain.Transfer{
+		Id:               m.Id,
+		UserId:           m.UserId,
+		OutTransactionId: m.OutTransactionId,
+		InTransactionId:  m.InTransactionId,
+		ReversalOfId:     m.ReversalOfId,
+		CreatedAt:        m.CreatedAt,
+	}
+
+	if m.Rate != nil {
+		rate, ok := new(big.Rat).SetString(*m.Rate)
+		if !ok {
+			return nil, domain.ErrTransferInvalidRate
+		}
+		transfer.Rate = rate
+	}
+
+	return transfer, nil
+}
+
+func (m *TransferModel) FromEntity(e *domain.Transfer) *TransferModel {
+	m.Id = e.Id
+	m.UserId = e.UserId
+	m.OutTransactionId = e.OutTransactionId
+	m.InTransactionId = e.InTransactionId
+	m.ReversalOfId = e.ReversalOfId
+	m.CreatedAt = e.CreatedAt
+
+	if e.Rate != nil {
+		rate := e.Rate.FloatString(maxNumericScale)
+		m.Rate = &rate
+	}
+
+	return m
+}
+
+type transferRepository struct {
+	repository
+}
+
+func TransferRepository(conn *pgx.Conn) *transferRepository {
+	return &transferRepository{repository{Conn: conn}}
+}
+
+const transferColumns = `id, user_id, out_transaction_id, in_transaction_id, rate, reversal_of_id, created_at`
+
+func (r *transferRepository) GetByIdAndUserId(ctx context.Context, id, userId uuid.UUID) (*domain.Transfer, error) {
+	rows, err := r.Conn.Query(ctx, "select "+transferColumns+" from transfers where id = $1 and user_id = $2", id, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := scanTransfers(rows)
+	if err != nil || len(list) == 0 {
+		return nil, err
+	}
+
+	return list[0], nil
+}
+
+func (r *transferRepository) GetByReversalOfId(ctx context.Context, id uuid.UUID) (*domain.Transfer, error) {
+	rows, err := r.Conn.Query(ctx, "select "+transferColumns+" from transfers where reversal_of_id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := scanTransfers(rows)
+	if err != nil || len(list) == 0 {
+		return nil, err
+	}
+
+	return list[0], nil
+}
+
+func (r *transferRepository) GetByTransactionId(ctx context.Context, transactionId uuid.UUID) (*domain.Transfer, error) {
+	rows, err := r.Conn.Query(ctx, "select "+transferColumns+" from transfers where out_transaction_id = $1 or in_transaction_id = $1", transactionId)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := scanTransfers(rows)
+	if err != nil || len(list) == 0 {
+		return nil, err
+	}
+
+	return list[0], nil
+}
+
+func (r *transferRepository) FindByUserId(ctx context.Context, userId uuid.UUID, walletId *uuid.UUID) ([]*domain.Transfer, error) {
+	rows, err := r.Conn.Query(ctx, `select `+transferColumns+` from transfers tr
+		where tr.user_id = $1 and ($2::uuid is null or exists (
+			select 1 from transactions t
+			where t.id in (tr.out_transaction_id, tr.in_transaction_id) and t.wallet_id = $2
+		))
+		order by tr.created_at desc, tr.id desc`, userId, walletId)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanTransfers(rows)
+}
+
+func (r *transferRepository) SaveWithTransactions(ctx context.Context, t *domain.Transfer, out, in *domain.Transaction) error {
+	tx, err := r.Conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, transaction := range []*domain.Transaction{out, in} {
+		_, err = tx.Exec(ctx, insertTransactionQuery, insertTransactionArgs(transaction)...)
+		if err != nil {
+			tx.Rollback(ctx)
+			return err
+		}
+	}
+
+	err = updateWalletBalances(ctx, tx, []*domain.Transaction{out, in}, nil)
+	if err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+
+	var rate *pgtype.Numeric
+	if t.Rate != nil {
+		n := numericFromRat(t.Rate)
+		rate = &n
+	}
+
+	_, err = tx.Exec(ctx, "insert into transfers (id, user_id, out_transaction_id, in_transaction_id, rate, reversal_of_id, created_at, updated_at) values($1,$2,$3,$4,$5,$6,$7,$8)",
+		t.Id, t.UserId, t.OutTransactionId, t.InTransactionId, rate, t.ReversalOfId, t.CreatedAt.UTC(), time.Now())
+	if err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func scanTransfers(rows pgx.Rows) (list []*domain.Transfer, err error) {
+	defer rows.Close()
+
+	for rows.Next() {
+		i := domain.Transfer{}
+		rateVal := pgtype.Numeric{}
+
+		err := rows.Scan(&i.Id, &i.UserId, &i.OutTransactionId, &i.InTransactionId, &rateVal, &i.ReversalOfId, &i.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		if rateVal.Status == pgtype.Present {
+			i.Rate = new(big.Rat)
+			err = rateVal.AssignTo(i.Rate)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		list = append(list, &i)
+	}
+
+	return list, rows.Err()
+}
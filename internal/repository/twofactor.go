@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"github.com/IMBgl/go-wallet-api/internal/service"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"time"
+)
+
+type twoFactorRepository struct {
+	repository
+}
+
+func TwoFactorRepository(conn *pgx.Conn) *twoFactorRepository {
+	return &twoFactorRepository{repository{Conn: conn}}
+}
+
+func (r *twoFactorRepository) GetTotpByUserId(ctx context.Context, userId uuid.UUID) (*service.UserTotp, error) {
+	t := service.UserTotp{}
+
+	err := r.Conn.QueryRow(ctx, "select user_id, secret, enabled, last_step, created_at from user_totp where user_id=$1", userId).Scan(&t.UserId, &t.Secret, &t.Enabled, &t.LastStep, &t.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+func (r *twoFactorRepository) SaveTotp(ctx context.Context, t *service.UserTotp) error {
+	_, err := r.Conn.Exec(ctx, `
+				insert into user_totp (user_id, secret, enabled, last_step, created_at, updated_at)
+				values($1,$2,$3,$4,$5,$6)
+				on conflict (user_id) do update
+				set secret = $2, enabled = $3, last_step = $4, created_at = $5, updated_at = $6;`,
+		t.UserId, t.Secret, t.Enabled, t.LastStep, t.CreatedAt, time.Now())
+
+	return err
+}
+
+func (r *twoFactorRepository) UseTotpStep(ctx context.Context, userId uuid.UUID, step int64) (bool, error) {
+	tag, err := r.Conn.Exec(ctx, "update user_totp set last_step = $2, updated_at = $3 where user_id = $1 and last_step < $2", userId, step, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *twoFactorRepository) DeleteTotp(ctx context.Context, userId uuid.UUID) error {
+	tx, err := r.Conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, "delete from user_recovery_codes where user_id=$1", userId)
+	if err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+
+	_, err = tx.Exec(ctx, "delete from user_totp where user_id=$1", userId)
+	if err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *twoFactorRepository) ReplaceRecoveryCodes(ctx context.Context, userId uuid.UUID, codes []*service.RecoveryCode) error {
+	tx, err := r.Conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, "delete from user_recovery_codes where user_id=$1", userId)
+	if err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+
+	for _, c := range codes {
+		_, err = tx.Exec(ctx, "insert into user_recovery_codes (id, user_id, hash, used_at, created_at) values($1,$2,$3,$4,$5)", c.Id, c.UserId, c.Hash, c.UsedAt, c.CreatedAt)
+		if err != nil {
+			tx.Rollback(ctx)
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *twoFactorRepository) UseRecoveryCode(ctx context.Context, userId uuid.UUID, value string) (bool, error) {
+	tag, err := r.Conn.Exec(ctx, "update user_recovery_codes set used_at=$3 where user_id=$1 and hash=$2 and used_at is null", userId, service.HashTokenValue(value), time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *twoFactorRepository) SaveChallenge(ctx context.Context, c *service.SignInChallenge) error {
+	_, err := r.Conn.Exec(ctx, "insert into sign_in_challenges (id, user_id, hash, attempts, expires_at, created_at) values($1,$2,$3,$4,$5,$6)", c.Id, c.UserId, c.Hash, c.Attempts, c.Exp, c.CreatedAt)
+
+	return err
+}
+
+func (r *twoFactorRepository) GetChallengeByValue(ctx context.Context, value string) (*service.SignInChallenge, error) {
+	c := service.SignInChallenge{}
+
+	err := r.Conn.QueryRow(ctx, "select id, user_id, hash, attempts, expires_at, created_at from sign_in_challenges where hash=$1", service.HashTokenValue(value)).Scan(&c.Id, &c.UserId, &c.Hash, &c.Attempts, &c.Exp, &c.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+func (r *twoFactorRepository) IncrementChallengeAttempts(ctx context.Context, c *service.SignInChallenge, max int) (bool, error) {
+	tag, err := r.Conn.Exec(ctx, "update sign_in_challenges set attempts = attempts + 1 where id=$1 and attempts < $2", c.Id, max)
+	if err != nil {
+		return false, err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+	c.Attempts++
+
+	return true, nil
+}
+
+// DeleteChallenge removes the challenge together with the expired challenges of all users.
+func (r *twoFactorRepository) DeleteChallenge(ctx context.Context, c *service.SignInChallenge) error {
+	_, err := r.Conn.Exec(ctx, "delete from sign_in_challenges where id=$1 or expires_at < $2", c.Id, time.Now())
+
+	return err
+}
@@ -5,8 +5,15 @@ import (
 	"errors"
 	"fmt"
 	"github.com/IMBgl/go-wallet-api/internal/domain"
+	"github.com/google/uuid"
+	"github.com/jackc/pgtype"
+	"math/big"
+	"strings"
 )
 
+// maxNumericScale is the largest number of fractional digits written to numeric columns.
+const maxNumericScale = 18
+
 type CurrencyValue struct {
 	Currency domain.Currency
 }
@@ -17,7 +24,7 @@ func (t *CurrencyValue) Scan(value interface{}) error {
 		return errors.New(fmt.Sprint("Failed to decode CurrencyValue:", value))
 	}
 
-	val, err := domain.CurrencyFromString(stringVal)
+	val, err := domain.CurrencyFromCode(stringVal)
 	t.Currency = val
 	return err
 }
@@ -44,3 +51,61 @@ func (t *TransactionTypeValue) Scan(value interface{}) error {
 func (t TransactionTypeValue) Value() (driver.Value, error) {
 	return t.TransactionType.Val(), nil
 }
+
+func numericFromMoney(m domain.Money) pgtype.Numeric {
+	return pgtype.Numeric{
+		Int:    big.NewInt(m.Minor()),
+		Exp:    -int32(m.Currency().MinorUnits()),
+		Status: pgtype.Present,
+	}
+}
+
+func moneyFromNumeric(n pgtype.Numeric, c domain.Currency) (domain.Money, error) {
+	rat := new(big.Rat)
+	err := n.AssignTo(rat)
+	if err != nil {
+		return domain.Money{}, err
+	}
+
+	// amounts are stored with the scale of the currency, rounding only matters
+	// for rows written before the currency got its own minor units
+	return domain.MoneyFromRatRounded(rat, c)
+}
+
+func numericFromRat(r *big.Rat) pgtype.Numeric {
+	n := pgtype.Numeric{}
+	// values come from validated decimal input, so the string form is exact
+	_ = n.Set(r.FloatString(maxNumericScale))
+
+	return n
+}
+
+func escapeLike(val string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(val)
+}
+
+// nullableUuid maps uuid.Nil to NULL for optional references.
+func nullableUuid(id uuid.UUID) *uuid.UUID {
+	if id == uuid.Nil {
+		return nil
+	}
+
+	return &id
+}
+
+func nullableString(v string) *string {
+	if v == "" {
+		return nil
+	}
+
+	return &v
+}
+
+// queryArgs collects positional arguments of a dynamically built query.
+type queryArgs []interface{}
+
+func (a *queryArgs) add(v interface{}) string {
+	*a = append(*a, v)
+
+	return fmt.Sprintf("$%d", len(*a))
+}
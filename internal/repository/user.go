@@ -26,11 +26,11 @@ func (UserModel) TableName() string {
 
 func (ur *UserModel) Entity() (*domain.User, error) {
 	return &domain.User{
-		ur.Id,
-		ur.Name,
-		ur.Email,
-		ur.Password,
-		ur.CreatedAt,
+		Id:        ur.Id,
+		Name:      ur.Name,
+		Email:     ur.Email,
+		Password:  ur.Password,
+		CreatedAt: ur.CreatedAt,
 	}, nil
 }
 
@@ -53,58 +53,126 @@ func UserRepository(conn *pgx.Conn) *userRepository {
 	return &userRepository{repository{Conn: conn}}
 }
 
-func (r *userRepository) GetById(ctx context.Context, id uuid.UUID) (*domain.User, error) {
-	user := domain.User{}
+const userColumns = "id, name, email, password, email_verified_at, base_currency, time_zone, created_at"
 
-	err := r.Conn.QueryRow(ctx, "select id, name, email, password, created_at from users where id=$1", id).Scan(&user.Id, &user.Name, &user.Email, &user.Password, &user.CreatedAt)
+func scanUser(row pgx.Row) (*domain.User, error) {
+	user := domain.User{}
+	baseCurrencyVal := ""
+	timeZoneVal := ""
+	err := row.Scan(&user.Id, &user.Name, &user.Email, &user.Password, &user.EmailVerifiedAt, &baseCurrencyVal, &timeZoneVal, &user.CreatedAt)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, nil
 		}
+		return nil, err
 	}
 
-	return &user, nil
-}
-
-func (r *userRepository) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
-	user := domain.User{}
+	user.BaseCurrency, err = domain.CurrencyFromCode(baseCurrencyVal)
+	if err != nil {
+		return nil, err
+	}
 
-	err := r.Conn.QueryRow(ctx, "select id, name, email, password, created_at from users where email=$1", email).Scan(&user.Id, &user.Name, &user.Email, &user.Password, &user.CreatedAt)
+	user.TimeZone, err = domain.TimeZoneFromString(timeZoneVal)
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, nil
-		}
 		return nil, err
 	}
 
 	return &user, nil
 }
 
+func (r *userRepository) GetById(ctx context.Context, id uuid.UUID) (*domain.User, error) {
+	return scanUser(r.Conn.QueryRow(ctx, "select "+userColumns+" from users where id=$1", id))
+}
+
+func (r *userRepository) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
+	return scanUser(r.Conn.QueryRow(ctx, "select "+userColumns+" from users where email=$1", email))
+}
+
 func (r *userRepository) Save(ctx context.Context, u *domain.User) error {
-	_, err := r.Conn.Exec(ctx, "insert into users (id, name, email, password, created_at, updated_at) values($1,$2,$3,$4,$5,$6)", u.Id, u.Name, u.Email, u.Password, u.CreatedAt, time.Now())
+	_, err := r.Conn.Exec(ctx, `insert into users (id, name, email, password, email_verified_at, created_at, updated_at, base_currency, time_zone) values($1,$2,$3,$4,$5,$6,$7,$8,$9)
+									on conflict (id) do update
+									set name = $2, email = $3, password = $4, email_verified_at = $5, updated_at = $7, base_currency = $8, time_zone = $9;`, u.Id, u.Name, u.Email, u.Password, u.EmailVerifiedAt, u.CreatedAt, time.Now(), u.BaseCurrency.Val(), u.TimeZone.String())
 
 	return err
 }
 
+// userDeleteSql removes the rows of the user children first, recurring
+// occurrences and subcategories go with their parents by the foreign keys.
+var userDeleteSql = []string{
+	"delete from transfers where user_id = $1",
+	"delete from recurring_transactions where user_id = $1",
+	"delete from budgets where user_id = $1",
+	"delete from wallet_accounts where user_id = $1",
+	"delete from transactions where user_id = $1",
+	"delete from categories where user_id = $1",
+	"delete from wallets where user_id = $1",
+	"delete from exchange_rates where user_id = $1",
+	"delete from api_keys where user_id = $1",
+	"delete from user_recovery_codes where user_id = $1",
+	"delete from user_totp where user_id = $1",
+	"delete from sign_in_challenges where user_id = $1",
+	"delete from user_action_tokens where user_id = $1",
+	"delete from user_tokens where user_id = $1",
+	"delete from users where id = $1",
+}
+
 func (r *userRepository) Delete(ctx context.Context, u *domain.User) error {
-	_, err := r.Conn.Exec(ctx, "delete from users where id = $1", u.Id)
+	tx, err := r.Conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
 
-	return err
+	for _, sql := range userDeleteSql {
+		_, err = tx.Exec(ctx, sql, u.Id)
+		if err != nil {
+			tx.Rollback(ctx)
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *userRepository) ChangePassword(ctx context.Context, u *domain.User, keepSessionId uuid.UUID) error {
+	tx, err := r.Conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, "update users set password=$2, updated_at=$3 where id=$1", u.Id, u.Password, time.Now())
+	if err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+
+	_, err = tx.Exec(ctx, "delete from user_tokens where user_id=$1 and family_id<>$2", u.Id, keepSessionId)
+	if err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+
+	_, err = tx.Exec(ctx, "delete from user_action_tokens where user_id=$1 and action=$2", u.Id, service.ACTION_RESET_PASSWORD)
+	if err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+
+	return tx.Commit(ctx)
 }
 
-func (r *userRepository) SaveUserWithToken(ctx context.Context, u *domain.User, t *service.UserToken) error {
+func (r *userRepository) SaveUserWithToken(ctx context.Context, u *domain.User, tokens ...*service.UserToken) error {
 	tx, err := r.Conn.Begin(ctx)
 	if err != nil {
 		return err
 	}
 
-	_, err = tx.Exec(ctx, "insert into users (id, name, email, password, created_at, updated_at) values($1,$2,$3,$4,$5,$6)", u.Id, u.Name, u.Email, u.Password, u.CreatedAt, time.Now())
+	_, err = tx.Exec(ctx, "insert into users (id, name, email, password, created_at, updated_at, base_currency, time_zone) values($1,$2,$3,$4,$5,$6,$7,$8)", u.Id, u.Name, u.Email, u.Password, u.CreatedAt, time.Now(), u.BaseCurrency.Val(), u.TimeZone.String())
 	if err != nil {
 		tx.Rollback(ctx)
 		return err
 	}
 
-	_, err = tx.Exec(ctx, "insert into user_tokens (id, user_id, hash, expires_at, created_at, updated_at) values($1,$2,$3,$4,$5,$6)", t.Id, t.UserId, t.Value, t.Exp, t.CreatedAt, time.Now())
+	err = insertTokens(ctx, tx, tokens)
 	if err != nil {
 		tx.Rollback(ctx)
 		return err
@@ -4,6 +4,7 @@ import (
 	"context"
 	"github.com/IMBgl/go-wallet-api/internal/domain"
 	"github.com/google/uuid"
+	"github.com/jackc/pgtype"
 	"github.com/jackc/pgx/v4"
 	"gorm.io/gorm"
 	"time"
@@ -15,7 +16,7 @@ type WalletModel struct {
 	Name     string
 	UserId   uuid.UUID
 	Currency CurrencyValue
-	Balance  float32
+	Balance  string
 }
 
 func (WalletModel) TableName() string {
@@ -23,13 +24,17 @@ func (WalletModel) TableName() string {
 }
 
 func (m *WalletModel) Entity() (*domain.Wallet, error) {
+	balance, err := domain.MoneyFromString(m.Balance, m.Currency.Currency)
+	if err != nil {
+		return nil, err
+	}
+
 	return &domain.Wallet{
-		m.Id,
-		m.Name,
-		m.UserId,
-		m.Balance,
-		m.Currency.Currency,
-		m.CreatedAt,
+		Id:        m.Id,
+		Name:      m.Name,
+		UserId:    m.UserId,
+		Balance:   balance,
+		CreatedAt: m.CreatedAt,
 	}, nil
 }
 
@@ -37,8 +42,8 @@ func (m *WalletModel) FromEntity(e *domain.Wallet) *WalletModel {
 	m.Id = e.Id
 	m.Name = e.Name
 	m.UserId = e.UserId
-	m.Balance = e.Balance
-	m.Currency = CurrencyValue{e.Currency}
+	m.Balance = e.Balance.String()
+	m.Currency = CurrencyValue{e.Balance.Currency()}
 
 	return m
 }
@@ -55,7 +60,7 @@ func (r *walletRepository) Save(ctx context.Context, w *domain.Wallet) error {
 	_, err := r.Conn.Exec(ctx, `insert into wallets (id, "name", user_id, currency,balance, created_at, updated_at)
 									values($1,$2,$3,$4,$5,$6, $7)
 									on conflict (id) do update 
-									set name = $2, updated_at = $7;`, w.Id, w.Name, w.UserId, w.Currency.Val(), w.Balance, w.CreatedAt, time.Now())
+									set name = $2, updated_at = $7;`, w.Id, w.Name, w.UserId, w.Balance.Currency().Val(), numericFromMoney(w.Balance), w.CreatedAt, time.Now())
 
 	return err
 }
@@ -69,17 +74,21 @@ func (r *walletRepository) Delete(ctx context.Context, w *domain.Wallet) error {
 func (r *walletRepository) GetById(ctx context.Context, id uuid.UUID) (*domain.Wallet, error) {
 	wallet := domain.Wallet{}
 	currencyVal := ""
+	balanceVal := pgtype.Numeric{}
 
-	err := r.Conn.QueryRow(ctx, "select id, \"name\", user_id, currency,balance, created_at from wallets where id=$1", id).Scan(&wallet.Id, &wallet.Name, &wallet.UserId, &currencyVal, &wallet.Balance, &wallet.CreatedAt)
+	err := r.Conn.QueryRow(ctx, "select id, \"name\", user_id, currency,balance, created_at from wallets where id=$1", id).Scan(&wallet.Id, &wallet.Name, &wallet.UserId, &currencyVal, &balanceVal, &wallet.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
-	currency, err := domain.CurrencyFromString(currencyVal)
+	currency, err := domain.CurrencyFromCode(currencyVal)
 	if err != nil {
 		return nil, err
 	}
 
-	wallet.Currency = currency
+	wallet.Balance, err = moneyFromNumeric(balanceVal, currency)
+	if err != nil {
+		return nil, err
+	}
 
 	return &wallet, nil
 }
@@ -87,20 +96,24 @@ func (r *walletRepository) GetById(ctx context.Context, id uuid.UUID) (*domain.W
 func (r *walletRepository) GetByIdAndUserId(ctx context.Context, id, userId uuid.UUID) (*domain.Wallet, error) {
 	wallet := domain.Wallet{}
 	currencyVal := ""
+	balanceVal := pgtype.Numeric{}
 
-	err := r.Conn.QueryRow(ctx, "select id, \"name\", user_id, currency,balance, created_at from wallets where id=$1 and user_id=$2", id, userId).Scan(&wallet.Id, &wallet.Name, &wallet.UserId, &currencyVal, &wallet.Balance, &wallet.CreatedAt)
+	err := r.Conn.QueryRow(ctx, "select id, \"name\", user_id, currency,balance, created_at from wallets where id=$1 and user_id=$2", id, userId).Scan(&wallet.Id, &wallet.Name, &wallet.UserId, &currencyVal, &balanceVal, &wallet.CreatedAt)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, nil
 		}
 		return nil, err
 	}
-	currency, err := domain.CurrencyFromString(currencyVal)
+	currency, err := domain.CurrencyFromCode(currencyVal)
 	if err != nil {
 		return nil, err
 	}
 
-	wallet.Currency = currency
+	wallet.Balance, err = moneyFromNumeric(balanceVal, currency)
+	if err != nil {
+		return nil, err
+	}
 
 	return &wallet, nil
 }
@@ -111,20 +124,46 @@ func (r *walletRepository) FindByUserId(ctx context.Context, userId uuid.UUID) (
 	for rows.Next() {
 		i := domain.Wallet{}
 		currencyVal := ""
+		balanceVal := pgtype.Numeric{}
 
-		err := rows.Scan(&i.Id, &i.Name, &i.UserId, &currencyVal, &i.Balance, &i.CreatedAt)
+		err := rows.Scan(&i.Id, &i.Name, &i.UserId, &currencyVal, &balanceVal, &i.CreatedAt)
 		if err != nil {
 			return nil, err
 		}
 
-		currency, err := domain.CurrencyFromString(currencyVal)
+		currency, err := domain.CurrencyFromCode(currencyVal)
+		if err != nil {
+			return nil, err
+		}
+
+		i.Balance, err = moneyFromNumeric(balanceVal, currency)
 		if err != nil {
 			return nil, err
 		}
 
-		i.Currency = currency
 		list = append(list, &i)
 	}
 
 	return
 }
+
+// GetByAccountIdAndUserId returns the wallet mapped to a bank account of imported statements.
+func (r *walletRepository) GetByAccountIdAndUserId(ctx context.Context, accountId string, userId uuid.UUID) (*domain.Wallet, error) {
+	var walletId uuid.UUID
+
+	err := r.Conn.QueryRow(ctx, "select wallet_id from wallet_accounts where account_id=$1 and user_id=$2", accountId, userId).Scan(&walletId)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return r.GetByIdAndUserId(ctx, walletId, userId)
+}
+
+// saveWalletAccountQuery maps a bank account of imported statements to a wallet.
+const saveWalletAccountQuery = `insert into wallet_accounts (user_id, account_id, wallet_id, created_at)
+									values($1,$2,$3,$4)
+									on conflict (user_id, account_id) do update
+									set wallet_id = $3;`
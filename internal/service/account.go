@@ -0,0 +1,390 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/IMBgl/go-wallet-api/internal/domain"
+	"github.com/google/uuid"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	ACTION_VERIFY_EMAIL   = "verify_email"
+	ACTION_RESET_PASSWORD = "reset_password"
+	ACTION_CHANGE_EMAIL   = "change_email"
+)
+
+const (
+	DEFAULT_VERIFY_EMAIL_LIFETIME   = time.Hour * 48
+	DEFAULT_RESET_PASSWORD_LIFETIME = time.Hour
+)
+
+var ErrInvalidActionToken = errors.New("Invalid or expired token")
+var ErrEmailAlreadyVerified = errors.New("Email already verified")
+
+// AccountConfig signs the email verification and password reset tokens.
+// Without Secret a random one is used and links die with the process.
+// The urls get the token appended as the "token" query parameter, without
+// them the token itself is sent. ChangeEmailUrl is a separate page because
+// its tokens are confirmed at /user/email/change/confirm.
+type AccountConfig struct {
+	Secret                []byte
+	VerifyEmailLifetime   time.Duration
+	ResetPasswordLifetime time.Duration
+	VerifyEmailUrl        string
+	ResetPasswordUrl      string
+	ChangeEmailUrl        string
+}
+
+func DefaultAccountConfig() AccountConfig {
+	return AccountConfig{
+		VerifyEmailLifetime:   DEFAULT_VERIFY_EMAIL_LIFETIME,
+		ResetPasswordLifetime: DEFAULT_RESET_PASSWORD_LIFETIME,
+	}
+}
+
+// ActionToken is a single-use token sent by email. Email binds a
+// verification token to the address it was sent to, for an email change
+// it is the new address.
+type ActionToken struct {
+	Id        uuid.UUID
+	UserId    uuid.UUID
+	Action    string
+	Email     string
+	Exp       time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+type EmailVerificationRequest struct {
+	UserId uuid.UUID
+}
+
+type EmailConfirmRequest struct {
+	Token string
+}
+
+type PasswordResetRequest struct {
+	Email string
+}
+
+type PasswordResetConfirmRequest struct {
+	Token    string
+	Password string
+}
+
+type ActionTokenRepository interface {
+	GetById(ctx context.Context, id uuid.UUID) (*ActionToken, error)
+	Save(ctx context.Context, t *ActionToken) error
+	// Use marks the token used, ok is false if it was used already
+	Use(ctx context.Context, t *ActionToken) (bool, error)
+	// DeleteForUser drops the earlier tokens of the action, only the latest link works
+	DeleteForUser(ctx context.Context, userId uuid.UUID, action string) error
+	// ResetPassword saves the user, consumes its reset tokens and revokes all its UserTokens in one transaction
+	ResetPassword(ctx context.Context, u *domain.User) error
+}
+
+type accountService struct {
+	repo      Repository
+	passwords *passwordHasher
+	mailer    Mailer
+	config    AccountConfig
+}
+
+func NewAccountService(r Repository, ph *passwordHasher, m Mailer, c AccountConfig) *accountService {
+	d := DefaultAccountConfig()
+	if c.VerifyEmailLifetime <= 0 {
+		c.VerifyEmailLifetime = d.VerifyEmailLifetime
+	}
+	if c.ResetPasswordLifetime <= 0 {
+		c.ResetPasswordLifetime = d.ResetPasswordLifetime
+	}
+	if len(c.Secret) == 0 {
+		log.Printf("account token secret is not set, using a random one")
+		c.Secret = make([]byte, 32)
+		if _, err := rand.Read(c.Secret); err != nil {
+			panic(err)
+		}
+	}
+
+	return &accountService{repo: r, passwords: ph, mailer: m, config: c}
+}
+
+// RequestEmailVerification sends a link to confirm the current email of the user.
+func (s *accountService) RequestEmailVerification(ctx context.Context, request *EmailVerificationRequest) error {
+	user, err := s.repo.User().GetById(ctx, request.UserId)
+	if err != nil {
+		return err
+	}
+
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	if user.EmailVerifiedAt != nil {
+		return ErrEmailAlreadyVerified
+	}
+
+	return s.SendEmailVerification(ctx, user)
+}
+
+func (s *accountService) SendEmailVerification(ctx context.Context, user *domain.User) error {
+	value, err := s.issue(ctx, user, ACTION_VERIFY_EMAIL, user.Email, s.config.VerifyEmailLifetime)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, &MailMessage{
+		To:      user.Email,
+		Subject: "Confirm your email",
+		Body: fmt.Sprintf("Hello %s,\n\nconfirm your email address with the link below, it expires in %s.\n\n%s\n",
+			user.Name, s.config.VerifyEmailLifetime, actionLink(s.config.VerifyEmailUrl, value)),
+	})
+}
+
+func (s *accountService) ConfirmEmail(ctx context.Context, request *EmailConfirmRequest) (*domain.User, error) {
+	token, user, err := s.consume(ctx, request.Token, ACTION_VERIFY_EMAIL)
+	if err != nil {
+		return nil, err
+	}
+
+	// the email was changed after the link was sent
+	if token.Email != user.Email {
+		return nil, ErrInvalidActionToken
+	}
+
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+	err = s.repo.User().Save(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// RequestPasswordReset sends a reset link. Unknown emails are not reported
+// to not leak which emails are registered.
+func (s *accountService) RequestPasswordReset(ctx context.Context, request *PasswordResetRequest) error {
+	user, err := s.repo.User().GetByEmail(ctx, request.Email)
+	if err != nil {
+		return err
+	}
+
+	if user == nil {
+		return nil
+	}
+
+	value, err := s.issue(ctx, user, ACTION_RESET_PASSWORD, user.Email, s.config.ResetPasswordLifetime)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, &MailMessage{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hello %s,\n\nset a new password with the link below, it expires in %s.\nIgnore this email if you did not ask for it.\n\n%s\n",
+			user.Name, s.config.ResetPasswordLifetime, actionLink(s.config.ResetPasswordUrl, value)),
+	})
+}
+
+// ResetPassword sets the new password and signs the user out everywhere.
+func (s *accountService) ResetPassword(ctx context.Context, request *PasswordResetConfirmRequest) error {
+	token, user, err := s.consume(ctx, request.Token, ACTION_RESET_PASSWORD)
+	if err != nil {
+		return err
+	}
+
+	password, err := s.passwords.Hash(request.Password)
+	if err != nil {
+		return err
+	}
+	user.Password = password
+
+	// the link was sent to this address, so it is verified now
+	if user.EmailVerifiedAt == nil && token.Email == user.Email {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+
+	return s.repo.ActionToken().ResetPassword(ctx, user)
+}
+
+// SendEmailChange sends the confirmation link to the new email and a notice to the current one.
+func (s *accountService) SendEmailChange(ctx context.Context, user *domain.User, email string) error {
+	value, err := s.issue(ctx, user, ACTION_CHANGE_EMAIL, email, s.config.VerifyEmailLifetime)
+	if err != nil {
+		return err
+	}
+
+	err = s.mailer.Send(ctx, &MailMessage{
+		To:      email,
+		Subject: "Confirm your new email",
+		Body: fmt.Sprintf("Hello %s,\n\nconfirm your new email address with the link below, it expires in %s.\n\n%s\n",
+			user.Name, s.config.VerifyEmailLifetime, actionLink(s.config.ChangeEmailUrl, value)),
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, &MailMessage{
+		To:      user.Email,
+		Subject: "Your email is being changed",
+		Body: fmt.Sprintf("Hello %s,\n\na change of your account email to %s was requested. Reset your password if it was not you.\n",
+			user.Name, email),
+	})
+}
+
+// ConfirmEmailChange replaces the email of the user with the confirmed one.
+func (s *accountService) ConfirmEmailChange(ctx context.Context, request *EmailConfirmRequest) (*domain.User, error) {
+	token, user, err := s.consume(ctx, request.Token, ACTION_CHANGE_EMAIL)
+	if err != nil {
+		return nil, err
+	}
+
+	// the address could be taken while the link was waiting
+	found, err := s.repo.User().GetByEmail(ctx, token.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	if found != nil {
+		return nil, ErrEmailAlreadyInUse
+	}
+
+	now := time.Now()
+	user.Email = token.Email
+	user.EmailVerifiedAt = &now
+	err = s.repo.User().Save(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *accountService) issue(ctx context.Context, user *domain.User, action, email string, lifetime time.Duration) (string, error) {
+	token := &ActionToken{
+		Id:        uuid.New(),
+		UserId:    user.Id,
+		Action:    action,
+		Email:     email,
+		Exp:       time.Now().Add(lifetime).Truncate(time.Second),
+		CreatedAt: time.Now(),
+	}
+
+	err := s.repo.ActionToken().DeleteForUser(ctx, user.Id, action)
+	if err != nil {
+		return "", err
+	}
+
+	err = s.repo.ActionToken().Save(ctx, token)
+	if err != nil {
+		return "", err
+	}
+
+	return s.sign(token), nil
+}
+
+// consume checks the signature before the database, so forged tokens are
+// rejected without a query, then marks the token used.
+func (s *accountService) consume(ctx context.Context, value, action string) (*ActionToken, *domain.User, error) {
+	id, exp, ok := s.verify(value, action)
+	if !ok || !exp.After(time.Now()) {
+		return nil, nil, ErrInvalidActionToken
+	}
+
+	token, err := s.repo.ActionToken().GetById(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if token == nil || token.Action != action || token.UsedAt != nil || !token.Exp.After(time.Now()) {
+		return nil, nil, ErrInvalidActionToken
+	}
+
+	user, err := s.repo.User().GetById(ctx, token.UserId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if user == nil {
+		return nil, nil, ErrInvalidActionToken
+	}
+
+	ok, err = s.repo.ActionToken().Use(ctx, token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !ok {
+		return nil, nil, ErrInvalidActionToken
+	}
+
+	return token, user, nil
+}
+
+// sign encodes the token id and expiry with an HMAC over the action, so a
+// verification token can not be used to reset a password.
+func (s *accountService) sign(t *ActionToken) string {
+	payload := make([]byte, 24)
+	copy(payload, t.Id[:])
+	binary.BigEndian.PutUint64(payload[16:], uint64(t.Exp.Unix()))
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(s.mac(t.Action, payload))
+}
+
+func (s *accountService) verify(value, action string) (uuid.UUID, time.Time, bool) {
+	parts := strings.Split(value, ".")
+	if len(parts) != 2 {
+		return uuid.Nil, time.Time{}, false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || len(payload) != 24 {
+		return uuid.Nil, time.Time{}, false
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(sig, s.mac(action, payload)) {
+		return uuid.Nil, time.Time{}, false
+	}
+
+	id, err := uuid.FromBytes(payload[:16])
+	if err != nil {
+		return uuid.Nil, time.Time{}, false
+	}
+
+	return id, time.Unix(int64(binary.BigEndian.Uint64(payload[16:])), 0), true
+}
+
+func (s *accountService) mac(action string, payload []byte) []byte {
+	mac := hmac.New(sha256.New, s.config.Secret)
+	mac.Write([]byte(action))
+	mac.Write([]byte{0})
+	mac.Write(payload)
+
+	return mac.Sum(nil)
+}
+
+func actionLink(baseUrl, token string) string {
+	if baseUrl == "" {
+		return token
+	}
+
+	separator := "?"
+	if strings.Contains(baseUrl, "?") {
+		separator = "&"
+	}
+
+	return baseUrl + separator + "token=" + url.QueryEscape(token)
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func TestActionTokenSignature(t *testing.T) {
+	s := NewAccountService(nil, nil, nil, AccountConfig{Secret: []byte("secret")})
+	token := &ActionToken{Id: uuid.New(), Action: ACTION_VERIFY_EMAIL, Exp: time.Now().Add(time.Hour).Truncate(time.Second)}
+	value := s.sign(token)
+
+	id, exp, ok := s.verify(value, ACTION_VERIFY_EMAIL)
+	if !ok || id != token.Id || !exp.Equal(token.Exp) {
+		t.Fatalf("verify(%q) = %v, %v, %v", value, id, exp, ok)
+	}
+
+	if _, _, ok := s.verify(value, ACTION_RESET_PASSWORD); ok {
+		t.Errorf("verification token accepted for password reset")
+	}
+
+	other := NewAccountService(nil, nil, nil, AccountConfig{Secret: []byte("other")})
+	if _, _, ok := other.verify(value, ACTION_VERIFY_EMAIL); ok {
+		t.Errorf("token accepted with another secret")
+	}
+
+	for _, v := range []string{"", "abc", value + "x", "x" + value} {
+		if _, _, ok := s.verify(v, ACTION_VERIFY_EMAIL); ok {
+			t.Errorf("verify(%q) accepted", v)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+// API_KEY_PREFIX tells personal API keys from session tokens.
+const API_KEY_PREFIX = "wk_"
+
+const API_KEY_MAX_COUNT = 20
+
+// API_KEY_LAST_USED_RESOLUTION limits the writes of last used time to one per key and interval.
+const API_KEY_LAST_USED_RESOLUTION = time.Minute
+
+const TOKEN_KIND_API_KEY = "api_key"
+
+const (
+	SCOPE_WALLET_READ       = "wallet:read"
+	SCOPE_WALLET_WRITE      = "wallet:write"
+	SCOPE_CATEGORY_READ     = "category:read"
+	SCOPE_CATEGORY_WRITE    = "category:write"
+	SCOPE_TRANSACTION_READ  = "transaction:read"
+	SCOPE_TRANSACTION_WRITE = "transaction:write"
+	SCOPE_BUDGET_READ       = "budget:read"
+	SCOPE_BUDGET_WRITE      = "budget:write"
+	SCOPE_REPORT_READ       = "report:read"
+	SCOPE_RATE_READ         = "rate:read"
+	SCOPE_RATE_WRITE        = "rate:write"
+)
+
+var Scopes = []string{
+	SCOPE_WALLET_READ,
+	SCOPE_WALLET_WRITE,
+	SCOPE_CATEGORY_READ,
+	SCOPE_CATEGORY_WRITE,
+	SCOPE_TRANSACTION_READ,
+	SCOPE_TRANSACTION_WRITE,
+	SCOPE_BUDGET_READ,
+	SCOPE_BUDGET_WRITE,
+	SCOPE_REPORT_READ,
+	SCOPE_RATE_READ,
+	SCOPE_RATE_WRITE,
+}
+
+var ErrInvalidScope = errors.New("Invalid scope")
+var ErrApiKeyLimit = errors.New("Too many api keys")
+var ErrApiKeyNameRequired = errors.New("Api key name required")
+
+// ApiKey is a long-lived personal token for scripts. Like UserToken only
+// Hash is stored and Value is known right after creation.
+// Empty WalletIds allow every wallet of the user.
+type ApiKey struct {
+	Id         uuid.UUID
+	UserId     uuid.UUID
+	Name       string
+	Prefix     string
+	Value      string
+	Hash       string
+	Scopes     []string
+	WalletIds  []uuid.UUID
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+}
+
+type ApiKeyCreateRequest struct {
+	UserId    uuid.UUID
+	Name      string
+	Scopes    []string
+	WalletIds []uuid.UUID
+	ExpiresAt *time.Time
+}
+
+type ApiKeyGetListRequest struct {
+	UserId uuid.UUID
+}
+
+type ApiKeyRevokeRequest struct {
+	UserId   uuid.UUID
+	ApiKeyId uuid.UUID
+}
+
+type ApiKeyRepository interface {
+	GetByIdAndUserId(ctx context.Context, id, userId uuid.UUID) (*ApiKey, error)
+	// GetByValue finds the key by the hash of value
+	GetByValue(ctx context.Context, value string) (*ApiKey, error)
+	FindByUserId(ctx context.Context, userId uuid.UUID) ([]*ApiKey, error)
+	Save(ctx context.Context, k *ApiKey) error
+	Delete(ctx context.Context, k *ApiKey) error
+	UpdateLastUsed(ctx context.Context, k *ApiKey, at time.Time) error
+}
+
+type apiKeyService struct {
+	repo Repository
+}
+
+func NewApiKeyService(r Repository) *apiKeyService {
+	return &apiKeyService{repo: r}
+}
+
+func (s *apiKeyService) Create(ctx context.Context, request *ApiKeyCreateRequest) (*ApiKey, error) {
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		return nil, ErrApiKeyNameRequired
+	}
+
+	scopes, err := normalizeScopes(request.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("expiresAt must be in the future")
+	}
+
+	for _, walletId := range request.WalletIds {
+		wallet, err := s.repo.Wallet().GetByIdAndUserId(ctx, walletId, request.UserId)
+		if err != nil {
+			return nil, err
+		}
+		if wallet == nil {
+			return nil, ErrWalletNotFound
+		}
+	}
+
+	list, err := s.repo.ApiKey().FindByUserId(ctx, request.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(list) >= API_KEY_MAX_COUNT {
+		return nil, ErrApiKeyLimit
+	}
+
+	random, err := GenerateTokenValue()
+	if err != nil {
+		return nil, err
+	}
+	value := API_KEY_PREFIX + random
+
+	key := &ApiKey{
+		Id:        uuid.New(),
+		UserId:    request.UserId,
+		Name:      name,
+		Prefix:    value[:len(API_KEY_PREFIX)+6],
+		Value:     value,
+		Hash:      HashTokenValue(value),
+		Scopes:    scopes,
+		WalletIds: request.WalletIds,
+		ExpiresAt: request.ExpiresAt,
+		CreatedAt: time.Now(),
+	}
+
+	err = s.repo.ApiKey().Save(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+func (s *apiKeyService) GetList(ctx context.Context, request *ApiKeyGetListRequest) ([]*ApiKey, error) {
+	return s.repo.ApiKey().FindByUserId(ctx, request.UserId)
+}
+
+func (s *apiKeyService) Revoke(ctx context.Context, request *ApiKeyRevokeRequest) error {
+	key, err := s.repo.ApiKey().GetByIdAndUserId(ctx, request.ApiKeyId, request.UserId)
+	if err != nil {
+		return err
+	}
+
+	if key == nil {
+		return ErrNotFound
+	}
+
+	return s.repo.ApiKey().Delete(ctx, key)
+}
+
+// normalizeScopes drops duplicates and rejects unknown scopes, an api key
+// never gets SCOPE_ALL.
+func normalizeScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, ErrInvalidScope
+	}
+
+	result := []string{}
+	seen := map[string]bool{}
+	for _, scope := range scopes {
+		if !isKnownScope(scope) {
+			return nil, ErrInvalidScope
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			result = append(result, scope)
+		}
+	}
+
+	return result, nil
+}
+
+func isKnownScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// walletAllowed reports whether walletId is in allowed, an empty list allows every wallet.
+func walletAllowed(allowed []uuid.UUID, walletId uuid.UUID) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	for _, id := range allowed {
+		if id == walletId {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (k *ApiKey) IsValid() bool {
+	return k.ExpiresAt == nil || k.ExpiresAt.After(time.Now())
+}
+
+// getApiKeyToken authenticates a request made with an api key, it is
+// represented as a UserToken limited to the scopes and wallets of the key.
+func (s *tokenServiсe) getApiKeyToken(ctx context.Context, v string) (*UserToken, error) {
+	key, err := s.repo.ApiKey().GetByValue(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+
+	if key == nil || !key.IsValid() {
+		return nil, ErrNotFound
+	}
+
+	now := time.Now()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > API_KEY_LAST_USED_RESOLUTION {
+		err = s.repo.ApiKey().UpdateLastUsed(ctx, key, now)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	token := &UserToken{
+		Id:        key.Id,
+		UserId:    key.UserId,
+		Kind:      TOKEN_KIND_API_KEY,
+		FamilyId:  key.Id,
+		Scopes:    key.Scopes,
+		WalletIds: key.WalletIds,
+		Hash:      key.Hash,
+		Value:     v,
+		CreatedAt: key.CreatedAt,
+	}
+	if key.ExpiresAt != nil {
+		token.Exp = *key.ExpiresAt
+	}
+
+	return token, nil
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"github.com/google/uuid"
+	"testing"
+)
+
+func TestNormalizeScopes(t *testing.T) {
+	scopes, err := normalizeScopes([]string{SCOPE_WALLET_READ, SCOPE_REPORT_READ, SCOPE_WALLET_READ})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scopes) != 2 {
+		t.Fatalf("expected duplicates to be dropped, got %v", scopes)
+	}
+
+	for _, invalid := range [][]string{nil, {SCOPE_ALL}, {"wallet:delete"}} {
+		if _, err := normalizeScopes(invalid); err != ErrInvalidScope {
+			t.Fatalf("%v: expected invalid scope, got %v", invalid, err)
+		}
+	}
+}
+
+func TestUserTokenRestrictions(t *testing.T) {
+	allowed, other := uuid.New(), uuid.New()
+	key := &UserToken{Kind: TOKEN_KIND_API_KEY, Scopes: []string{SCOPE_TRANSACTION_WRITE}, WalletIds: []uuid.UUID{allowed}}
+
+	if !key.HasScope(SCOPE_TRANSACTION_WRITE) || key.HasScope(SCOPE_WALLET_READ) {
+		t.Fatalf("unexpected scopes check for %v", key.Scopes)
+	}
+
+	if !key.AllowsWallet(allowed) || key.AllowsWallet(other) {
+		t.Fatal("unexpected wallet check")
+	}
+
+	session := &UserToken{Kind: TOKEN_KIND_ACCESS, Scopes: []string{SCOPE_ALL}}
+	if !session.HasScope(SCOPE_REPORT_READ) || !session.AllowsWallet(other) || session.IsWalletRestricted() {
+		t.Fatal("session token must not be restricted")
+	}
+}
@@ -0,0 +1,392 @@
+package service
+
+import (
+	"context"
+	"github.com/IMBgl/go-wallet-api/internal/domain"
+	"github.com/google/uuid"
+	"math/big"
+	"time"
+)
+
+type budgetService struct {
+	repo     Repository
+	exchange ExchangeService
+}
+
+type BudgetCreateRequest struct {
+	UserId     uuid.UUID
+	Name       string
+	CategoryId uuid.UUID
+	Limit      domain.Money
+	Period     domain.BudgetPeriod
+	StartDate  time.Time
+	EndDate    *time.Time
+	Rollover   bool
+}
+
+// BudgetUpdateRequest changes only the fields that are set.
+type BudgetUpdateRequest struct {
+	UserId   uuid.UUID
+	BudgetId uuid.UUID
+	Name     *string
+	Limit    *big.Rat
+	Rollover *bool
+	EndDate  *time.Time
+}
+
+type BudgetGetListRequest struct {
+	UserId uuid.UUID
+}
+
+type BudgetGetOneRequest struct {
+	UserId   uuid.UUID
+	BudgetId uuid.UUID
+}
+
+type BudgetDeleteRequest struct {
+	UserId   uuid.UUID
+	BudgetId uuid.UUID
+}
+
+type BudgetStatusRequest struct {
+	UserId   uuid.UUID
+	BudgetId *uuid.UUID
+	At       time.Time
+}
+
+// BudgetStatus is the state of a budget in the period containing the requested date.
+type BudgetStatus struct {
+	*domain.Budget
+	Active      bool
+	PeriodStart time.Time
+	PeriodEnd   time.Time
+	Rollover    domain.Money
+	Available   domain.Money
+	Spent       domain.Money
+	Remaining   domain.Money
+	Percent     *big.Rat
+	Overspent   bool
+}
+
+type BudgetRepository interface {
+	Save(ctx context.Context, b *domain.Budget) error
+	Delete(ctx context.Context, b *domain.Budget) error
+	GetByIdAndUserId(ctx context.Context, id, userId uuid.UUID) (*domain.Budget, error)
+	FindByUserId(ctx context.Context, userId uuid.UUID) ([]*domain.Budget, error)
+	// SpentByPeriods returns the spending per currency of every period of the filter in its order
+	SpentByPeriods(ctx context.Context, filter *BudgetSpentFilter) ([][]domain.Money, error)
+}
+
+// BudgetSpentFilter selects the transactions of the categories, Periods are
+// start and exclusive end pairs.
+type BudgetSpentFilter struct {
+	UserId      uuid.UUID
+	CategoryIds []uuid.UUID
+	Periods     [][2]time.Time
+}
+
+func NewBudgetService(r Repository, es ExchangeService) *budgetService {
+	return &budgetService{repo: r, exchange: es}
+}
+
+func (s *budgetService) Create(ctx context.Context, request *BudgetCreateRequest) (*domain.Budget, error) {
+	user, err := s.repo.User().GetById(ctx, request.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	category, err := s.repo.Category().FindByIdAndUserId(ctx, request.CategoryId, user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if category == nil {
+		return nil, domain.ErrCategoryNotFound
+	}
+
+	budget, err := domain.NewBudget(request.Name, category.Id, user.Id, request.Limit, request.Period, request.StartDate, request.EndDate, request.Rollover)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repo.Budget().Save(ctx, budget)
+	if err != nil {
+		return nil, err
+	}
+
+	return budget, nil
+}
+
+func (s *budgetService) Update(ctx context.Context, request *BudgetUpdateRequest) (*domain.Budget, error) {
+	budget, err := s.GetOne(ctx, &BudgetGetOneRequest{UserId: request.UserId, BudgetId: request.BudgetId})
+	if err != nil {
+		return nil, err
+	}
+
+	if request.Name != nil {
+		budget.Name = *request.Name
+	}
+
+	if request.Rollover != nil {
+		budget.Rollover = *request.Rollover
+	}
+
+	if request.Limit != nil {
+		budget.Limit, err = domain.MoneyFromRat(request.Limit, budget.Limit.Currency())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if request.EndDate != nil {
+		budget.EndDate = request.EndDate
+	}
+
+	// revalidate the changed budget with the same rules as a new one
+	_, err = domain.NewBudget(budget.Name, budget.CategoryId, budget.UserId, budget.Limit, budget.Period, budget.StartDate, budget.EndDate, budget.Rollover)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repo.Budget().Save(ctx, budget)
+	if err != nil {
+		return nil, err
+	}
+
+	return budget, nil
+}
+
+func (s *budgetService) GetList(ctx context.Context, request *BudgetGetListRequest) ([]*domain.Budget, error) {
+	user, err := s.repo.User().GetById(ctx, request.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	return s.repo.Budget().FindByUserId(ctx, user.Id)
+}
+
+func (s *budgetService) GetOne(ctx context.Context, request *BudgetGetOneRequest) (*domain.Budget, error) {
+	user, err := s.repo.User().GetById(ctx, request.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	budget, err := s.repo.Budget().GetByIdAndUserId(ctx, request.BudgetId, user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if budget == nil {
+		return nil, domain.ErrBudgetNotFound
+	}
+
+	return budget, nil
+}
+
+func (s *budgetService) Delete(ctx context.Context, request *BudgetDeleteRequest) error {
+	budget, err := s.GetOne(ctx, &BudgetGetOneRequest{UserId: request.UserId, BudgetId: request.BudgetId})
+	if err != nil {
+		return err
+	}
+
+	return s.repo.Budget().Delete(ctx, budget)
+}
+
+func (s *budgetService) Status(ctx context.Context, request *BudgetStatusRequest) ([]*BudgetStatus, error) {
+	var budgets []*domain.Budget
+	if request.BudgetId != nil {
+		budget, err := s.GetOne(ctx, &BudgetGetOneRequest{UserId: request.UserId, BudgetId: *request.BudgetId})
+		if err != nil {
+			return nil, err
+		}
+		budgets = append(budgets, budget)
+	} else {
+		list, err := s.GetList(ctx, &BudgetGetListRequest{UserId: request.UserId})
+		if err != nil {
+			return nil, err
+		}
+		budgets = list
+	}
+
+	at := request.At
+	if at.IsZero() {
+		at = time.Now().UTC()
+	}
+
+	statusList := []*BudgetStatus{}
+	for _, budget := range budgets {
+		status, err := s.getStatus(ctx, budget, at)
+		if err != nil {
+			return nil, err
+		}
+
+		statusList = append(statusList, status)
+	}
+
+	return statusList, nil
+}
+
+func (s *budgetService) getStatus(ctx context.Context, budget *domain.Budget, at time.Time) (*BudgetStatus, error) {
+	currency := budget.Limit.Currency()
+	status := &BudgetStatus{
+		Budget:    budget,
+		Rollover:  domain.ZeroMoney(currency),
+		Available: budget.Limit,
+		Spent:     domain.ZeroMoney(currency),
+		Remaining: budget.Limit,
+		Percent:   new(big.Rat),
+	}
+
+	start, end, ok := budget.PeriodAt(at)
+	if !ok {
+		return status, nil
+	}
+	status.Active = true
+	status.PeriodStart = start
+	status.PeriodEnd = end
+
+	category, err := s.repo.Category().FindByIdAndUserId(ctx, budget.CategoryId, budget.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if category == nil {
+		return nil, domain.ErrCategoryNotFound
+	}
+
+	categoryIds, err := categoryIdsWithDescendants(ctx, s.repo.Category(), category)
+	if err != nil {
+		return nil, err
+	}
+
+	// the past periods carry the rollover, they are summed with the current one
+	periods := [][2]time.Time{}
+	if budget.Rollover && !budget.Period.IsCustom() {
+		periods = budgetPeriodsBefore(budget, start)
+	}
+	periods = append(periods, [2]time.Time{start, end})
+
+	spent, err := s.spentByPeriods(ctx, budget, categoryIds, periods)
+	if err != nil {
+		return nil, err
+	}
+
+	status.Rollover, err = budgetRollover(budget.Limit, spent[:len(spent)-1])
+	if err != nil {
+		return nil, err
+	}
+	status.Spent = spent[len(spent)-1]
+
+	status.Available, err = budget.Limit.Add(status.Rollover)
+	if err != nil {
+		return nil, err
+	}
+
+	status.Remaining, err = status.Available.Sub(status.Spent)
+	if err != nil {
+		return nil, err
+	}
+	status.Overspent = status.Remaining.IsNegative()
+
+	if status.Available.IsPositive() {
+		status.Percent = new(big.Rat).Mul(new(big.Rat).Quo(status.Spent.Rat(), status.Available.Rat()), big.NewRat(100, 1))
+	}
+
+	return status, nil
+}
+
+// spentByPeriods sums the spending of each period in the budget currency.
+// Spending in the other currencies is converted at the rate of the last day
+// of its period, like the reports do.
+func (s *budgetService) spentByPeriods(ctx context.Context, budget *domain.Budget, categoryIds []uuid.UUID, periods [][2]time.Time) ([]domain.Money, error) {
+	sums, err := s.repo.Budget().SpentByPeriods(ctx, &BudgetSpentFilter{
+		UserId:      budget.UserId,
+		CategoryIds: categoryIds,
+		Periods:     periods,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	currency := budget.Limit.Currency()
+	spent := make([]domain.Money, len(periods))
+	for i := range periods {
+		spent[i] = domain.ZeroMoney(currency)
+		for _, amount := range sums[i] {
+			if amount.IsZero() {
+				continue
+			}
+
+			if amount.Currency() != currency {
+				conversion, err := s.exchange.Convert(ctx, &ConvertRequest{
+					UserId: budget.UserId,
+					Amount: amount,
+					To:     currency,
+					Date:   reportRateDate(periods[i][1], now),
+				})
+				if err != nil {
+					return nil, err
+				}
+				amount = conversion.Amount
+			}
+
+			spent[i], err = spent[i].Add(amount)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return spent, nil
+}
+
+// budgetPeriodsBefore returns the periods of the budget from its start until current.
+func budgetPeriodsBefore(budget *domain.Budget, current time.Time) [][2]time.Time {
+	periods := [][2]time.Time{}
+
+	start, end, ok := budget.PeriodAt(budget.StartDate)
+	for ok && start.Before(current) {
+		periods = append(periods, [2]time.Time{start, end})
+		start, end, ok = budget.PeriodAt(end)
+	}
+
+	return periods
+}
+
+// budgetRollover carries the unused part of each past period into the next
+// one. Overspending does not reduce the following periods.
+func budgetRollover(limit domain.Money, spent []domain.Money) (domain.Money, error) {
+	rollover := domain.ZeroMoney(limit.Currency())
+
+	for _, s := range spent {
+		available, err := limit.Add(rollover)
+		if err != nil {
+			return domain.Money{}, err
+		}
+
+		unused, err := available.Sub(s)
+		if err != nil {
+			return domain.Money{}, err
+		}
+
+		rollover = domain.ZeroMoney(limit.Currency())
+		if unused.IsPositive() {
+			rollover = unused
+		}
+	}
+
+	return rollover, nil
+}
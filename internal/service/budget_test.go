@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"github.com/IMBgl/go-wallet-api/internal/domain"
+	"github.com/google/uuid"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestBudgetRollover(t *testing.T) {
+	usd := func(minor int64) domain.Money {
+		return domain.NewMoney(minor, domain.CurrencyUSD())
+	}
+
+	start := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	budget, err := domain.NewBudget("food", uuid.New(), uuid.New(), usd(10000), domain.BudgetPeriodMonthly(), start, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	periods := budgetPeriodsBefore(budget, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC))
+	if len(periods) != 3 || !periods[0][0].Equal(start) || !periods[2][1].Equal(time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("budgetPeriodsBefore() = %v", periods)
+	}
+
+	// 40.00 unused, then 20.00 of 140.00 unused, then overspent: nothing carried
+	tests := []struct {
+		spent []domain.Money
+		want  int64
+	}{
+		{[]domain.Money{}, 0},
+		{[]domain.Money{usd(6000)}, 4000},
+		{[]domain.Money{usd(6000), usd(12000)}, 2000},
+		{[]domain.Money{usd(6000), usd(12000), usd(15000)}, 0},
+	}
+
+	for _, tt := range tests {
+		rollover, err := budgetRollover(budget.Limit, tt.spent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rollover.Minor() != tt.want {
+			t.Errorf("budgetRollover(%d periods) = %s, want %d", len(tt.spent), rollover, tt.want)
+		}
+	}
+}
+
+type budgetTestRepository struct {
+	Repository
+	budget *budgetTestBudgetRepository
+}
+
+func (r *budgetTestRepository) Budget() BudgetRepository { return r.budget }
+
+type budgetTestBudgetRepository struct {
+	BudgetRepository
+	spent [][]domain.Money
+}
+
+func (r *budgetTestBudgetRepository) SpentByPeriods(ctx context.Context, filter *BudgetSpentFilter) ([][]domain.Money, error) {
+	return r.spent, nil
+}
+
+// budgetTestExchangeService converts at a fixed rate and records the rate dates.
+type budgetTestExchangeService struct {
+	ExchangeService
+	rate  *big.Rat
+	dates []time.Time
+}
+
+func (s *budgetTestExchangeService) Convert(ctx context.Context, request *ConvertRequest) (*Conversion, error) {
+	s.dates = append(s.dates, request.Date)
+	amount, err := domain.MoneyFromRatRounded(new(big.Rat).Mul(request.Amount.Rat(), s.rate), request.To)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Conversion{Original: request.Amount, Amount: amount, Rate: s.rate}, nil
+}
+
+func TestBudgetSpentInOtherCurrencies(t *testing.T) {
+	eur := func(minor int64) domain.Money {
+		return domain.NewMoney(minor, domain.CurrencyEUR())
+	}
+	usd := func(minor int64) domain.Money {
+		return domain.NewMoney(minor, domain.CurrencyUSD())
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	budget, err := domain.NewBudget("food", uuid.New(), uuid.New(), eur(10000), domain.BudgetPeriodMonthly(), start, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 10.00 EUR and 20.00 USD at 0.5 in January, nothing in February
+	repo := &budgetTestBudgetRepository{spent: [][]domain.Money{{eur(1000), usd(2000)}, nil}}
+	exchange := &budgetTestExchangeService{rate: big.NewRat(1, 2)}
+	s := NewBudgetService(&budgetTestRepository{budget: repo}, exchange)
+
+	february := start.AddDate(0, 1, 0)
+	spent, err := s.spentByPeriods(context.Background(), budget, nil, [][2]time.Time{{start, february}, {february, february.AddDate(0, 1, 0)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(spent) != 2 || spent[0].Minor() != 2000 || !spent[1].IsZero() || spent[1].Currency() != domain.CurrencyEUR() {
+		t.Errorf("spentByPeriods() = %v, want 20.00 and 0.00 EUR", spent)
+	}
+	if len(exchange.dates) != 1 || !exchange.dates[0].Equal(february.Add(-time.Nanosecond)) {
+		t.Errorf("converted at %v, want the last day of January", exchange.dates)
+	}
+}
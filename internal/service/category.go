@@ -221,3 +221,24 @@ func (s *categoryService) getCategoryTree(ctx context.Context, c *domain.Categor
 
 	return node, nil
 }
+
+// categoryIdsWithDescendants returns the id of the category followed by the ids of all its subcategories.
+func categoryIdsWithDescendants(ctx context.Context, repo CategoryRepository, c *domain.Category) ([]uuid.UUID, error) {
+	ids := []uuid.UUID{c.Id}
+
+	children, err := repo.GetChildren(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, child := range children {
+		childIds, err := categoryIdsWithDescendants(ctx, repo, child)
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, childIds...)
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,25 @@
+package service
+
+// Config holds the deployment settings of the services. Zero values are
+// replaced by the defaults of DefaultConfig.
+type Config struct {
+	Password  PasswordConfig
+	Token     TokenConfig
+	Jwt       JwtConfig
+	TwoFactor TwoFactorConfig
+	Account   AccountConfig
+	Mail      MailConfig
+	// ExchangeRate converts the transactions entered in a currency other than the wallet's
+	ExchangeRate ExchangeRateConfig
+}
+
+func DefaultConfig() *Config {
+	return &Config{
+		Password:     DefaultPasswordConfig(),
+		Token:        DefaultTokenConfig(),
+		TwoFactor:    DefaultTwoFactorConfig(),
+		Account:      DefaultAccountConfig(),
+		Mail:         DefaultMailConfig(),
+		ExchangeRate: DefaultExchangeRateConfig(),
+	}
+}
@@ -8,3 +8,5 @@ var ErrUserNotFound = errors.New("User not found")
 var ErrNotFound = errors.New("Not found")
 var ErrCategoryNotFound = errors.New("Category not found")
 var ErrWalletNotFound = errors.New("Wallet not found")
+var ErrInvalidCursor = errors.New("Invalid cursor")
+var ErrInvalidSort = errors.New("Invalid sort")
@@ -0,0 +1,289 @@
+package service
+
+import (
+	"context"
+	"github.com/IMBgl/go-wallet-api/internal/domain"
+	"github.com/google/uuid"
+	"log"
+	"math/big"
+	"time"
+)
+
+// EXCHANGE_RATE_SCALE is the number of fractional digits a derived exchange rate is rounded to.
+const EXCHANGE_RATE_SCALE = TRANSFER_RATE_SCALE
+
+const EXCHANGE_RATE_SOURCE_MANUAL = "manual"
+
+// DEFAULT_EXCHANGE_RATE_MAX_AGE is how old the latest rate of a pair may be to still convert an amount.
+const DEFAULT_EXCHANGE_RATE_MAX_AGE = time.Hour * 24 * 7
+
+// RateProvider loads exchange rates from an outside source. Its rates are
+// stored as global rates, so every pair and day is asked for once.
+type RateProvider interface {
+	// Name is the source recorded on the stored rates
+	Name() string
+	// Rate returns the price of one base in quote on the latest day not after
+	// date together with that day, a nil rate when the pair is unknown.
+	Rate(ctx context.Context, base, quote domain.Currency, date time.Time) (*big.Rat, time.Time, error)
+}
+
+// ExchangeRateConfig sets where the rates missing from the table come from,
+// without Provider only the rates entered manually are used.
+type ExchangeRateConfig struct {
+	Provider RateProvider
+	MaxAge   time.Duration
+}
+
+func DefaultExchangeRateConfig() ExchangeRateConfig {
+	return ExchangeRateConfig{MaxAge: DEFAULT_EXCHANGE_RATE_MAX_AGE}
+}
+
+type exchangeService struct {
+	repo   Repository
+	config ExchangeRateConfig
+}
+
+type ExchangeRateSetRequest struct {
+	UserId uuid.UUID
+	Base   domain.Currency
+	Quote  domain.Currency
+	Rate   *big.Rat
+	// Date is optional: the current day is used when unset
+	Date time.Time
+}
+
+type ExchangeRateGetListRequest struct {
+	UserId   uuid.UUID
+	Base     *domain.Currency
+	Quote    *domain.Currency
+	DateFrom *time.Time
+	DateTo   *time.Time
+}
+
+type ExchangeRateDeleteRequest struct {
+	UserId uuid.UUID
+	RateId uuid.UUID
+}
+
+type ExchangeRateGetRequest struct {
+	UserId uuid.UUID
+	Base   domain.Currency
+	Quote  domain.Currency
+	// Date is optional: the current day is used when unset
+	Date time.Time
+}
+
+type ConvertRequest struct {
+	UserId uuid.UUID
+	Amount domain.Money
+	To     domain.Currency
+	Date   time.Time
+	// Rate is applied instead of the stored rates when set
+	Rate *big.Rat
+}
+
+// Conversion is Original converted to Amount at Rate, the rate of RateDate.
+type Conversion struct {
+	Original domain.Money
+	Amount   domain.Money
+	Rate     *big.Rat
+	RateDate time.Time
+	Source   string
+}
+
+// ExchangeRateFilter selects the rates of UserId and the global rates.
+type ExchangeRateFilter struct {
+	UserId   uuid.UUID
+	Base     *domain.Currency
+	Quote    *domain.Currency
+	DateFrom *time.Time
+	DateTo   *time.Time
+}
+
+type ExchangeRateRepository interface {
+	// Save inserts the rate or replaces the rate of the same owner, pair and day
+	Save(ctx context.Context, r *domain.ExchangeRate) error
+	GetByIdAndUserId(ctx context.Context, id, userId uuid.UUID) (*domain.ExchangeRate, error)
+	FindByFilter(ctx context.Context, filter *ExchangeRateFilter) ([]*domain.ExchangeRate, error)
+	// FindLatest returns the rate of the pair on the latest day between from and to,
+	// a rate of the user wins over the global rate of the same day
+	FindLatest(ctx context.Context, userId uuid.UUID, base, quote domain.Currency, from, to time.Time) (*domain.ExchangeRate, error)
+	Delete(ctx context.Context, r *domain.ExchangeRate) error
+}
+
+func NewExchangeService(r Repository, c ExchangeRateConfig) *exchangeService {
+	if c.MaxAge <= 0 {
+		c.MaxAge = DEFAULT_EXCHANGE_RATE_MAX_AGE
+	}
+
+	return &exchangeService{repo: r, config: c}
+}
+
+// SetRate stores a manual rate of the user, it replaces the rate of the same pair and day.
+func (s *exchangeService) SetRate(ctx context.Context, request *ExchangeRateSetRequest) (*domain.ExchangeRate, error) {
+	date := request.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	rate, err := domain.NewExchangeRate(&request.UserId, request.Base, request.Quote, roundRat(request.Rate, EXCHANGE_RATE_SCALE), date, EXCHANGE_RATE_SOURCE_MANUAL)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repo.ExchangeRate().Save(ctx, rate)
+	if err != nil {
+		return nil, err
+	}
+
+	return rate, nil
+}
+
+func (s *exchangeService) GetList(ctx context.Context, request *ExchangeRateGetListRequest) ([]*domain.ExchangeRate, error) {
+	return s.repo.ExchangeRate().FindByFilter(ctx, &ExchangeRateFilter{
+		UserId:   request.UserId,
+		Base:     request.Base,
+		Quote:    request.Quote,
+		DateFrom: request.DateFrom,
+		DateTo:   request.DateTo,
+	})
+}
+
+// Delete removes a manual rate of the user, global rates can not be deleted.
+func (s *exchangeService) Delete(ctx context.Context, request *ExchangeRateDeleteRequest) error {
+	rate, err := s.repo.ExchangeRate().GetByIdAndUserId(ctx, request.RateId, request.UserId)
+	if err != nil {
+		return err
+	}
+
+	if rate == nil {
+		return domain.ErrExchangeRateNotFound
+	}
+
+	return s.repo.ExchangeRate().Delete(ctx, rate)
+}
+
+// GetRate returns the latest rate of the pair not older than MaxAge before
+// the date. A stored rate of the day is used as is, otherwise the provider
+// is asked and the newer of its rate and the latest stored rate wins.
+// A stored rate of the reverse pair is inverted.
+func (s *exchangeService) GetRate(ctx context.Context, request *ExchangeRateGetRequest) (*domain.ExchangeRate, error) {
+	day := rateDay(request.Date)
+	if request.Base == request.Quote {
+		return &domain.ExchangeRate{Base: request.Base, Quote: request.Quote, Rate: big.NewRat(1, 1), Date: day}, nil
+	}
+
+	from := day.Add(-s.config.MaxAge)
+
+	stored, err := s.findStored(ctx, request.UserId, request.Base, request.Quote, from, day)
+	if err != nil {
+		return nil, err
+	}
+
+	if stored != nil && stored.Date.Equal(day) {
+		return stored, nil
+	}
+
+	fetched, err := s.fetch(ctx, request.Base, request.Quote, from, day)
+	if err != nil {
+		return nil, err
+	}
+
+	if fetched != nil && (stored == nil || fetched.Date.After(stored.Date)) {
+		return fetched, nil
+	}
+
+	if stored == nil {
+		return nil, domain.ErrExchangeRateNotFound
+	}
+
+	return stored, nil
+}
+
+// Convert converts the amount at the given rate or at the rate of the day, rounded to the minor units of the target currency.
+func (s *exchangeService) Convert(ctx context.Context, request *ConvertRequest) (*Conversion, error) {
+	conversion := &Conversion{Original: request.Amount, Rate: request.Rate, RateDate: rateDay(request.Date), Source: EXCHANGE_RATE_SOURCE_MANUAL}
+
+	if request.Rate != nil {
+		if request.Rate.Sign() <= 0 {
+			return nil, domain.ErrInvalidExchangeRate
+		}
+		conversion.Rate = roundRat(request.Rate, EXCHANGE_RATE_SCALE)
+	} else {
+		rate, err := s.GetRate(ctx, &ExchangeRateGetRequest{UserId: request.UserId, Base: request.Amount.Currency(), Quote: request.To, Date: request.Date})
+		if err != nil {
+			return nil, err
+		}
+		conversion.Rate, conversion.RateDate, conversion.Source = rate.Rate, rate.Date, rate.Source
+	}
+
+	amount, err := domain.MoneyFromRatRounded(new(big.Rat).Mul(request.Amount.Rat(), conversion.Rate), request.To)
+	if err != nil {
+		return nil, err
+	}
+	conversion.Amount = amount
+
+	return conversion, nil
+}
+
+// findStored returns the newer of the stored rates of the pair and of the reverse pair, inverted.
+func (s *exchangeService) findStored(ctx context.Context, userId uuid.UUID, base, quote domain.Currency, from, to time.Time) (*domain.ExchangeRate, error) {
+	direct, err := s.repo.ExchangeRate().FindLatest(ctx, userId, base, quote, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	reverse, err := s.repo.ExchangeRate().FindLatest(ctx, userId, quote, base, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	if reverse == nil || (direct != nil && !reverse.Date.After(direct.Date)) {
+		return direct, nil
+	}
+
+	inverted := *reverse
+	inverted.Base, inverted.Quote = base, quote
+	inverted.Rate = roundRat(new(big.Rat).Inv(reverse.Rate), EXCHANGE_RATE_SCALE)
+
+	return &inverted, nil
+}
+
+// fetch asks the provider for the rate and stores it as a global rate.
+func (s *exchangeService) fetch(ctx context.Context, base, quote domain.Currency, from, to time.Time) (*domain.ExchangeRate, error) {
+	if s.config.Provider == nil {
+		return nil, nil
+	}
+
+	value, date, err := s.config.Provider.Rate(ctx, base, quote, to)
+	if err != nil {
+		return nil, err
+	}
+
+	if value == nil || date.Before(from) {
+		return nil, nil
+	}
+
+	rate, err := domain.NewExchangeRate(nil, base, quote, roundRat(value, EXCHANGE_RATE_SCALE), date, s.config.Provider.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	// the rate is usable without the cached copy, it is fetched again next time
+	err = s.repo.ExchangeRate().Save(ctx, rate)
+	if err != nil {
+		log.Printf("exchange rate %s/%s cache err %v", base.Code(), quote.Code(), err)
+	}
+
+	return rate, nil
+}
+
+// rateDay returns the UTC day of t, rates are kept per day.
+func rateDay(t time.Time) time.Time {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	t = t.UTC()
+
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"github.com/IMBgl/go-wallet-api/internal/domain"
+	"github.com/IMBgl/go-wallet-api/internal/export"
+	"github.com/google/uuid"
+	"io"
+	"time"
+)
+
+// EXPORT_PAGE_SIZE is the number of transactions loaded at once while streaming an export.
+const EXPORT_PAGE_SIZE = 500
+
+type exportService struct {
+	repo Repository
+}
+
+type ExportRequest struct {
+	UserId   uuid.UUID
+	Format   string
+	Entity   string
+	WalletId *uuid.UUID
+	DateFrom *time.Time
+	DateTo   *time.Time
+}
+
+func NewExportService(r Repository) *exportService {
+	return &exportService{repo: r}
+}
+
+// NewWriter checks the request and returns the writer for its format, so
+// errors are reported before anything is written to the response.
+func (s *exportService) NewWriter(request *ExportRequest, w io.Writer) (export.Writer, error) {
+	switch request.Format {
+	case export.FORMAT_CSV:
+		return export.NewCsvWriter(w, request.Entity)
+	case export.FORMAT_JSON:
+		return export.NewJsonWriter(w), nil
+	case export.FORMAT_LEDGER:
+		return export.NewLedgerWriter(w), nil
+	default:
+		return nil, export.ErrUnsupportedFormat
+	}
+}
+
+// Export streams the user data into the writer page by page.
+func (s *exportService) Export(ctx context.Context, request *ExportRequest, writer export.Writer) error {
+	user, err := s.repo.User().GetById(ctx, request.UserId)
+	if err != nil {
+		return err
+	}
+
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	if request.WalletId != nil {
+		wallet, err := s.repo.Wallet().GetByIdAndUserId(ctx, *request.WalletId, user.Id)
+		if err != nil {
+			return err
+		}
+
+		if wallet == nil {
+			return domain.ErrWalletNotFound
+		}
+	}
+
+	data := &export.Data{ExportedAt: time.Now().UTC(), WalletId: request.WalletId}
+
+	data.Wallets, err = s.repo.Wallet().FindByUserId(ctx, user.Id)
+	if err != nil {
+		return err
+	}
+
+	data.Categories, err = s.repo.Category().FindByUserId(ctx, user.Id)
+	if err != nil {
+		return err
+	}
+
+	data.Transfers, err = s.repo.Transfer().FindByUserId(ctx, user.Id, request.WalletId)
+	if err != nil {
+		return err
+	}
+
+	err = writer.WriteHeader(data)
+	if err != nil {
+		return err
+	}
+
+	filter := &TransactionFilter{
+		UserId:   user.Id,
+		WalletId: request.WalletId,
+		DateFrom: request.DateFrom,
+		DateTo:   request.DateTo,
+		Sort:     TransactionSort{Field: TransactionSortDate},
+		Limit:    EXPORT_PAGE_SIZE,
+	}
+
+	for {
+		list, err := s.repo.Transaction().FindByFilter(ctx, filter)
+		if err != nil {
+			return err
+		}
+
+		for _, t := range list {
+			err = writer.WriteTransaction(t)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(list) < EXPORT_PAGE_SIZE {
+			break
+		}
+
+		last := list[len(list)-1]
+		filter.After = &TransactionCursor{Sort: filter.Sort, Date: last.CreatedAt, Id: last.Id}
+	}
+
+	return writer.Close()
+}
@@ -0,0 +1,453 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/IMBgl/go-wallet-api/internal/domain"
+	"github.com/IMBgl/go-wallet-api/internal/statement"
+	"github.com/google/uuid"
+	"io"
+	"time"
+)
+
+const (
+	IMPORT_FORMAT_CSV = "csv"
+	IMPORT_FORMAT_OFX = "ofx"
+	IMPORT_FORMAT_QIF = "qif"
+)
+
+var ErrImportUnsupportedFormat = errors.New("Unsupported import format")
+var ErrImportNothingToCommit = errors.New("No rows to import")
+var ErrImportWalletRequired = errors.New("Wallet is required, statement account is not mapped to a wallet")
+var ErrImportDuplicateExternalId = errors.New("Entry id is repeated in the statement")
+var ErrImportAlreadyImported = errors.New("Entry was already imported into the wallet")
+var ErrImportInvalidClosingBalance = errors.New("Statement closing balance is not a valid amount of the wallet currency")
+
+type importService struct {
+	repo Repository
+}
+
+// ImportRequest describes an uploaded statement file. Lines lists the rows
+// accepted by the user after the preview, when empty every valid row that is
+// not a duplicate is imported. WalletId may be omitted for statements whose
+// account was imported into a wallet before.
+type ImportRequest struct {
+	UserId     uuid.UUID
+	WalletId   *uuid.UUID
+	CategoryId *uuid.UUID
+	Format     string
+	Data       io.Reader
+	CsvMapping statement.CsvMapping
+	DateFormat string
+	Lines      []int
+}
+
+// ImportRow is a parsed statement row ready to be booked into the wallet.
+// ExternalDuplicate is set when DuplicateOf has the same external id, such a
+// row can not be booked again.
+type ImportRow struct {
+	Line              int
+	Transaction       *domain.Transaction
+	DuplicateOf       *uuid.UUID
+	ExternalDuplicate bool
+	Err               error
+}
+
+// ImportReconciliation compares the statement closing balance with the
+// wallet balance the import results in at the end of the closing date.
+type ImportReconciliation struct {
+	Date             time.Time
+	StatementBalance domain.Money
+	WalletBalance    domain.Money
+	Difference       domain.Money
+	Reconciled       bool
+}
+
+type ImportPreview struct {
+	Wallet         *domain.Wallet
+	AccountId      string
+	Rows           []*ImportRow
+	Reconciliation *ImportReconciliation
+}
+
+type ImportResult struct {
+	Wallet         *domain.Wallet
+	Imported       []*domain.Transaction
+	Skipped        int
+	Reconciliation *ImportReconciliation
+}
+
+func NewImportService(r Repository) *importService {
+	return &importService{repo: r}
+}
+
+func (s *importService) Preview(ctx context.Context, request *ImportRequest) (*ImportPreview, error) {
+	preview, st, err := s.prepare(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	// the wallet is only used for the calculation, nothing is saved
+	wallet := *preview.Wallet
+	rows := selectImportRows(preview.Rows, nil)
+	for _, row := range rows {
+		err = wallet.ApplyTransaction(row.Transaction)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	preview.Reconciliation, err = s.reconcile(ctx, st, &wallet, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return preview, nil
+}
+
+// Commit books the accepted rows, updates the wallet balance once and maps
+// the statement account to the wallet, all in a single database transaction.
+func (s *importService) Commit(ctx context.Context, request *ImportRequest) (*ImportResult, error) {
+	preview, st, err := s.prepare(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	wallet := preview.Wallet
+	result := &ImportResult{Wallet: wallet, Imported: []*domain.Transaction{}}
+	rows := selectImportRows(preview.Rows, request.Lines)
+	for _, row := range rows {
+		if row.ExternalDuplicate {
+			return nil, ErrImportAlreadyImported
+		}
+
+		err = wallet.ApplyTransaction(row.Transaction)
+		if err != nil {
+			return nil, err
+		}
+		result.Imported = append(result.Imported, row.Transaction)
+	}
+	result.Skipped = len(preview.Rows) - len(result.Imported)
+
+	if len(result.Imported) == 0 {
+		return nil, ErrImportNothingToCommit
+	}
+
+	// the imported rows are not saved yet so they are not counted twice
+	result.Reconciliation, err = s.reconcile(ctx, st, wallet, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repo.Transaction().SaveImport(ctx, wallet, preview.AccountId, result.Imported)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *importService) prepare(ctx context.Context, request *ImportRequest) (*ImportPreview, *statement.Statement, error) {
+	user, err := s.repo.User().GetById(ctx, request.UserId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if user == nil {
+		return nil, nil, ErrUserNotFound
+	}
+
+	st, err := s.parse(request)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var wallet *domain.Wallet
+	if request.WalletId != nil {
+		wallet, err = s.repo.Wallet().GetByIdAndUserId(ctx, *request.WalletId, user.Id)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if wallet == nil {
+			return nil, nil, domain.ErrWalletNotFound
+		}
+	} else {
+		if st.AccountId == "" {
+			return nil, nil, ErrImportWalletRequired
+		}
+
+		wallet, err = s.repo.Wallet().GetByAccountIdAndUserId(ctx, st.AccountId, user.Id)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if wallet == nil {
+			return nil, nil, ErrImportWalletRequired
+		}
+	}
+
+	categoryId := uuid.Nil
+	if request.CategoryId != nil {
+		category, err := s.repo.Category().FindByIdAndUserId(ctx, *request.CategoryId, user.Id)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if category == nil {
+			return nil, nil, domain.ErrCategoryNotFound
+		}
+		categoryId = category.Id
+	}
+
+	preview := &ImportPreview{Wallet: wallet, AccountId: st.AccountId}
+	externalIds := map[string]bool{}
+	for _, entry := range st.Entries {
+		row := newImportRow(entry, wallet, categoryId)
+		if row.Err == nil && entry.ExternalId != "" {
+			if externalIds[entry.ExternalId] {
+				row.Err = ErrImportDuplicateExternalId
+			}
+			externalIds[entry.ExternalId] = true
+		}
+		preview.Rows = append(preview.Rows, row)
+	}
+
+	err = s.markDuplicates(ctx, wallet, preview.Rows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return preview, st, nil
+}
+
+func (s *importService) parse(request *ImportRequest) (*statement.Statement, error) {
+	switch request.Format {
+	case IMPORT_FORMAT_CSV:
+		return statement.ParseCsv(request.Data, request.CsvMapping)
+	case IMPORT_FORMAT_OFX:
+		return statement.ParseOfx(request.Data)
+	case IMPORT_FORMAT_QIF:
+		return statement.ParseQif(request.Data, request.DateFormat)
+	default:
+		return nil, ErrImportUnsupportedFormat
+	}
+}
+
+// markDuplicates flags rows already booked in the wallet. Rows with an
+// external id match it exactly. The other rows match manually entered
+// transactions by day, type and amount, every such transaction matching a
+// single row only, so two equal purchases on the same day are both kept when
+// only one is booked.
+func (s *importService) markDuplicates(ctx context.Context, wallet *domain.Wallet, rows []*ImportRow) error {
+	var from, to time.Time
+	var externalIds []string
+	for _, row := range rows {
+		if row.Err != nil {
+			continue
+		}
+
+		if row.Transaction.ExternalId != "" {
+			externalIds = append(externalIds, row.Transaction.ExternalId)
+		}
+
+		date := row.Transaction.CreatedAt
+		if from.IsZero() || date.Before(from) {
+			from = date
+		}
+		if to.IsZero() || date.After(to) {
+			to = date
+		}
+	}
+
+	if from.IsZero() {
+		return nil
+	}
+
+	byExternalId := map[string]uuid.UUID{}
+	if len(externalIds) > 0 {
+		imported, err := s.repo.Transaction().FindByFilter(ctx, &TransactionFilter{
+			UserId:      wallet.UserId,
+			WalletId:    &wallet.Id,
+			ExternalIds: externalIds,
+			Sort:        TransactionSort{Field: TransactionSortDate},
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, t := range imported {
+			byExternalId[t.ExternalId] = t.Id
+		}
+	}
+
+	before := to.AddDate(0, 0, 1)
+	existing, err := s.repo.Transaction().FindByFilter(ctx, &TransactionFilter{
+		UserId:     wallet.UserId,
+		WalletId:   &wallet.Id,
+		DateFrom:   &from,
+		DateBefore: &before,
+		Sort:       TransactionSort{Field: TransactionSortDate},
+	})
+	if err != nil {
+		return err
+	}
+
+	candidates := map[string][]uuid.UUID{}
+	for _, t := range existing {
+		if t.ExternalId != "" {
+			continue
+		}
+		key := duplicateKey(t)
+		candidates[key] = append(candidates[key], t.Id)
+	}
+
+	for _, row := range rows {
+		if row.Err != nil {
+			continue
+		}
+
+		if id, ok := byExternalId[row.Transaction.ExternalId]; ok && row.Transaction.ExternalId != "" {
+			row.DuplicateOf = &id
+			row.ExternalDuplicate = true
+			continue
+		}
+
+		key := duplicateKey(row.Transaction)
+		if ids := candidates[key]; len(ids) > 0 {
+			row.DuplicateOf = &ids[0]
+			candidates[key] = ids[1:]
+		}
+	}
+
+	return nil
+}
+
+// selectImportRows returns valid rows listed in lines, or every valid row that is not a duplicate when lines is empty.
+func selectImportRows(rows []*ImportRow, lines []int) []*ImportRow {
+	accepted := map[int]bool{}
+	for _, line := range lines {
+		accepted[line] = true
+	}
+
+	var list []*ImportRow
+	for _, row := range rows {
+		if row.Err != nil {
+			continue
+		}
+
+		if len(accepted) > 0 && !accepted[row.Line] {
+			continue
+		}
+
+		if len(accepted) == 0 && row.DuplicateOf != nil {
+			continue
+		}
+
+		list = append(list, row)
+	}
+
+	return list
+}
+
+// reconcile compares the statement closing balance with the balance of the
+// wallet at the end of the closing date. The wallet has the rows applied but
+// not saved, the saved transactions and the rows dated after the closing date
+// are taken back from its balance. A closing balance that does not fit the
+// wallet currency fails the import instead of leaving the reconciliation out.
+func (s *importService) reconcile(ctx context.Context, st *statement.Statement, wallet *domain.Wallet, rows []*ImportRow) (*ImportReconciliation, error) {
+	if st.ClosingBalance == nil {
+		return nil, nil
+	}
+
+	statementBalance, err := domain.MoneyFromRat(st.ClosingBalance, wallet.Balance.Currency())
+	if err != nil {
+		return nil, ErrImportInvalidClosingBalance
+	}
+
+	balance := wallet.Balance
+	if !st.ClosingDate.IsZero() {
+		end := st.ClosingDate.AddDate(0, 0, 1)
+		changes, err := s.repo.Report().WalletChangesAt(ctx, wallet.Id, end)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range changes {
+			if c.Start.Before(end) {
+				continue
+			}
+
+			balance, err = balance.Sub(c.Amount)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		for _, row := range rows {
+			if row.Transaction.CreatedAt.Before(end) {
+				continue
+			}
+
+			change, err := row.Transaction.BalanceChange()
+			if err != nil {
+				return nil, err
+			}
+
+			balance, err = balance.Sub(change)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	difference, err := balance.Sub(statementBalance)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ImportReconciliation{
+		Date:             st.ClosingDate,
+		StatementBalance: statementBalance,
+		WalletBalance:    balance,
+		Difference:       difference,
+		Reconciled:       difference.IsZero(),
+	}, nil
+}
+
+func duplicateKey(t *domain.Transaction) string {
+	return t.CreatedAt.Format("2006-01-02") + "|" + t.Type.Val() + "|" + t.Amount.String()
+}
+
+func newImportRow(entry *statement.Entry, wallet *domain.Wallet, categoryId uuid.UUID) *ImportRow {
+	row := &ImportRow{Line: entry.Line, Err: entry.Err}
+	if row.Err != nil {
+		return row
+	}
+
+	currency := wallet.Balance.Currency()
+	if entry.Currency != "" {
+		entryCurrency, err := domain.CurrencyFromCode(entry.Currency)
+		if err != nil || !entryCurrency.Equals(&currency) {
+			row.Err = domain.ErrTransactionWalletCurrencyMismatch
+			return row
+		}
+	}
+
+	amount, err := domain.MoneyFromRat(entry.Amount, currency)
+	if err != nil {
+		row.Err = err
+		return row
+	}
+
+	transactionType := domain.TransactionTypeIn()
+	if amount.IsNegative() {
+		transactionType = domain.TransactionTypeOut()
+	}
+
+	row.Transaction = domain.NewTransaction(entry.Comment, amount.Abs(), transactionType, wallet.UserId, categoryId, wallet.Id)
+	row.Transaction.CreatedAt = entry.Date
+	row.Transaction.ExternalId = entry.ExternalId
+
+	return row
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+	"github.com/IMBgl/go-wallet-api/internal/domain"
+	"github.com/IMBgl/go-wallet-api/internal/statement"
+	"github.com/google/uuid"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestImportReconcileAtClosingDate(t *testing.T) {
+	usd := func(minor int64) domain.Money {
+		return domain.NewMoney(minor, domain.CurrencyUSD())
+	}
+	day := func(m time.Month, d int) time.Time {
+		return time.Date(2022, m, d, 0, 0, 0, 0, time.UTC)
+	}
+	row := func(date time.Time, minor int64) *ImportRow {
+		return &ImportRow{Transaction: &domain.Transaction{Type: domain.TransactionTypeIn(), Amount: usd(minor), CreatedAt: date}}
+	}
+
+	// 120.00 with the rows applied, -30.00 saved and +20.00 imported after the closing date
+	wallet := &domain.Wallet{Id: uuid.New(), Balance: usd(12000)}
+	repo := &reportTestReportRepository{changesAt: []*WalletChange{
+		{WalletId: wallet.Id, Start: day(time.January, 10), Amount: usd(1000)},
+		{WalletId: wallet.Id, Start: day(time.April, 2), Amount: usd(-3000)},
+	}}
+	s := NewImportService(&reportTestRepository{report: repo})
+
+	st := &statement.Statement{ClosingBalance: big.NewRat(130, 1), ClosingDate: day(time.March, 31)}
+	r, err := s.reconcile(context.Background(), st, wallet, []*ImportRow{row(day(time.March, 31), 5000), row(day(time.April, 1), 2000)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if repo.at != day(time.April, 1) {
+		t.Errorf("WalletChangesAt(%s), want the end of the closing date", repo.at)
+	}
+	if r.WalletBalance.Minor() != 13000 || !r.Reconciled {
+		t.Errorf("reconcile() = %s, want 130.00 reconciled", r.WalletBalance)
+	}
+}
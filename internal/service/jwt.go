@@ -0,0 +1,97 @@
+package service
+
+import (
+	"github.com/IMBgl/go-wallet-api/pkg/jwt"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+// JwtConfig switches access tokens to signed JWTs when Keys is set. JWT
+// access tokens are not stored, they are verified by signature only and
+// stay valid until they expire even after sign out, so keep
+// TokenConfig.AccessTokenLifetime short. Refresh tokens stay opaque.
+type JwtConfig struct {
+	Issuer string
+	Keys   *jwt.KeySet
+}
+
+func (s *tokenServiсe) jwtEnabled() bool {
+	return s.jwt.Keys != nil
+}
+
+func (s *tokenServiсe) newJwtAccessToken(userId, familyId uuid.UUID, scopes []string) (*UserToken, error) {
+	now := time.Now()
+	token := &UserToken{
+		Id:        uuid.New(),
+		UserId:    userId,
+		Kind:      TOKEN_KIND_ACCESS,
+		FamilyId:  familyId,
+		Scopes:    scopes,
+		Exp:       now.Add(s.config.AccessTokenLifetime),
+		Stateless: true,
+		CreatedAt: now,
+	}
+
+	value, err := s.jwt.Keys.Sign(&jwt.Claims{
+		Id:        token.Id.String(),
+		Issuer:    s.jwt.Issuer,
+		Subject:   userId.String(),
+		SessionId: familyId.String(),
+		Scope:     strings.Join(scopes, " "),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: token.Exp.Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	token.Value = value
+	token.Hash = HashTokenValue(value)
+
+	return token, nil
+}
+
+func (s *tokenServiсe) parseJwtAccessToken(value string) (*UserToken, error) {
+	claims, err := s.jwt.Keys.Verify(value, time.Now())
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	if s.jwt.Issuer != "" && claims.Issuer != s.jwt.Issuer {
+		return nil, ErrNotFound
+	}
+
+	id, err := uuid.Parse(claims.Id)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	userId, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	familyId, err := uuid.Parse(claims.SessionId)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	return &UserToken{
+		Id:        id,
+		UserId:    userId,
+		Kind:      TOKEN_KIND_ACCESS,
+		FamilyId:  familyId,
+		Scopes:    claims.Scopes(),
+		Exp:       time.Unix(claims.ExpiresAt, 0),
+		Stateless: true,
+		Value:     value,
+		Hash:      HashTokenValue(value),
+		CreatedAt: time.Unix(claims.IssuedAt, 0),
+	}, nil
+}
+
+// Jwks returns the public keys other services use to verify access tokens.
+func (s *tokenServiсe) Jwks() *jwt.Jwks {
+	return s.jwt.Keys.Jwks()
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	MAIL_TRANSPORT_SMTP = "smtp"
+	MAIL_TRANSPORT_LOG  = "log"
+)
+
+const DEFAULT_MAIL_FROM = "wallet@localhost"
+
+// Mailer delivers the emails of the account flows.
+type Mailer interface {
+	Send(ctx context.Context, m *MailMessage) error
+}
+
+type MailMessage struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// MailConfig selects the transport. The log transport writes messages to
+// LogDir for local development, when it is empty only the recipient and the
+// subject are logged so tokens in the body never end up in the log.
+type MailConfig struct {
+	Transport    string
+	From         string
+	SmtpHost     string
+	SmtpPort     int
+	SmtpUsername string
+	SmtpPassword string
+	LogDir       string
+}
+
+func DefaultMailConfig() MailConfig {
+	return MailConfig{Transport: MAIL_TRANSPORT_LOG, From: DEFAULT_MAIL_FROM, SmtpPort: 587}
+}
+
+func NewMailer(c MailConfig) Mailer {
+	if c.From == "" {
+		c.From = DEFAULT_MAIL_FROM
+	}
+
+	if c.Transport == MAIL_TRANSPORT_SMTP {
+		return NewSmtpMailer(c)
+	}
+
+	return NewLogMailer(c.From, c.LogDir)
+}
+
+type smtpMailer struct {
+	config MailConfig
+}
+
+func NewSmtpMailer(c MailConfig) *smtpMailer {
+	return &smtpMailer{config: c}
+}
+
+// Send uses STARTTLS when the server offers it, the credentials are only
+// sent over TLS or to localhost as enforced by smtp.PlainAuth.
+func (m *smtpMailer) Send(ctx context.Context, msg *MailMessage) error {
+	addr := net.JoinHostPort(m.config.SmtpHost, strconv.Itoa(m.config.SmtpPort))
+
+	var auth smtp.Auth
+	if m.config.SmtpUsername != "" {
+		auth = smtp.PlainAuth("", m.config.SmtpUsername, m.config.SmtpPassword, m.config.SmtpHost)
+	}
+
+	return smtp.SendMail(addr, auth, m.config.From, []string{msg.To}, buildMail(m.config.From, msg))
+}
+
+type logMailer struct {
+	from string
+	dir  string
+}
+
+func NewLogMailer(from, dir string) *logMailer {
+	return &logMailer{from: from, dir: dir}
+}
+
+func (m *logMailer) Send(ctx context.Context, msg *MailMessage) error {
+	if m.dir == "" {
+		log.Printf("mail to %s: %s", msg.To, msg.Subject)
+		return nil
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), msg.To)
+
+	return os.WriteFile(filepath.Join(m.dir, filepath.Base(name)), buildMail(m.from, msg), 0600)
+}
+
+func buildMail(from string, msg *MailMessage) []byte {
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "From: %s\r\n", from)
+	fmt.Fprintf(b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(msg.Body)
+
+	return b.Bytes()
+}
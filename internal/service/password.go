@@ -0,0 +1,180 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"sync"
+)
+
+const (
+	PASSWORD_ALGORITHM_ARGON2ID = "argon2id"
+	PASSWORD_ALGORITHM_BCRYPT   = "bcrypt"
+)
+
+const PASSWORD_SALT_LENGTH = 16
+
+var ErrInvalidPasswordHash = errors.New("Invalid password hash")
+
+// PasswordConfig selects the algorithm for new hashes and its cost. Hashes
+// made with other settings still verify and are rehashed on the next sign in.
+type PasswordConfig struct {
+	Algorithm       string
+	Argon2Time      uint32
+	Argon2MemoryKiB uint32
+	Argon2Threads   uint8
+	Argon2KeyLength uint32
+	BcryptCost      int
+}
+
+func DefaultPasswordConfig() PasswordConfig {
+	return PasswordConfig{
+		Algorithm:       PASSWORD_ALGORITHM_ARGON2ID,
+		Argon2Time:      3,
+		Argon2MemoryKiB: 64 * 1024,
+		Argon2Threads:   2,
+		Argon2KeyLength: 32,
+		BcryptCost:      12,
+	}
+}
+
+type passwordHasher struct {
+	config PasswordConfig
+
+	dummyOnce sync.Once
+	dummyHash string
+}
+
+func NewPasswordHasher(c PasswordConfig) *passwordHasher {
+	d := DefaultPasswordConfig()
+	if c.Algorithm == "" {
+		c.Algorithm = d.Algorithm
+	}
+	if c.Argon2Time == 0 {
+		c.Argon2Time = d.Argon2Time
+	}
+	if c.Argon2MemoryKiB == 0 {
+		c.Argon2MemoryKiB = d.Argon2MemoryKiB
+	}
+	if c.Argon2Threads == 0 {
+		c.Argon2Threads = d.Argon2Threads
+	}
+	if c.Argon2KeyLength == 0 {
+		c.Argon2KeyLength = d.Argon2KeyLength
+	}
+	if c.BcryptCost == 0 {
+		c.BcryptCost = d.BcryptCost
+	}
+
+	return &passwordHasher{config: c}
+}
+
+// VerifyDummy takes as long as a failed Verify of a current hash, a sign in
+// with an unknown email is answered as slowly as one with a wrong password.
+func (h *passwordHasher) VerifyDummy(password string) {
+	h.dummyOnce.Do(func() {
+		h.dummyHash, _ = h.Hash("dummy password of an unknown user")
+	})
+
+	h.Verify(password, h.dummyHash)
+}
+
+// Hash returns the encoded hash with algorithm, parameters and a random salt,
+// e.g. $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>.
+func (h *passwordHasher) Hash(password string) (string, error) {
+	if h.config.Algorithm == PASSWORD_ALGORITHM_BCRYPT {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.config.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+
+		return string(hash), nil
+	}
+
+	salt := make([]byte, PASSWORD_SALT_LENGTH)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.config.Argon2Time, h.config.Argon2MemoryKiB, h.config.Argon2Threads, h.config.Argon2KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, h.config.Argon2MemoryKiB, h.config.Argon2Time, h.config.Argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify checks the password against an encoded hash. needsRehash is set when
+// the hash is a legacy one or was made with other settings than configured.
+func (h *passwordHasher) Verify(password, encoded string) (ok bool, needsRehash bool, err error) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		var version int
+		var memory, time uint32
+		var threads uint8
+		parts := strings.Split(encoded, "$")
+		if len(parts) != 6 {
+			return false, false, ErrInvalidPasswordHash
+		}
+
+		_, err = fmt.Sscanf(parts[2], "v=%d", &version)
+		if err != nil || version != argon2.Version {
+			return false, false, ErrInvalidPasswordHash
+		}
+
+		_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads)
+		if err != nil {
+			return false, false, ErrInvalidPasswordHash
+		}
+
+		salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+		if err != nil {
+			return false, false, ErrInvalidPasswordHash
+		}
+
+		key, err := base64.RawStdEncoding.DecodeString(parts[5])
+		if err != nil || len(key) == 0 {
+			return false, false, ErrInvalidPasswordHash
+		}
+
+		actual := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+		ok = subtle.ConstantTimeCompare(actual, key) == 1
+		needsRehash = h.config.Algorithm != PASSWORD_ALGORITHM_ARGON2ID || memory != h.config.Argon2MemoryKiB ||
+			time != h.config.Argon2Time || threads != h.config.Argon2Threads || uint32(len(key)) != h.config.Argon2KeyLength
+
+		return ok, needsRehash, nil
+	case strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$"):
+		err = bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return false, false, nil
+		}
+		if err != nil {
+			return false, false, ErrInvalidPasswordHash
+		}
+
+		cost, _ := bcrypt.Cost([]byte(encoded))
+
+		return true, h.config.Algorithm != PASSWORD_ALGORITHM_BCRYPT || cost != h.config.BcryptCost, nil
+	case len(encoded) == sha256.Size*2:
+		ok = subtle.ConstantTimeCompare([]byte(legacyHashPassword(password)), []byte(strings.ToLower(encoded))) == 1
+
+		return ok, true, nil
+	default:
+		return false, false, ErrInvalidPasswordHash
+	}
+}
+
+// legacyHashPassword is the unsalted SHA-256 hash stored by earlier versions,
+// kept only to verify and upgrade such passwords.
+func legacyHashPassword(pass string) string {
+	h := sha256.New()
+	h.Write([]byte(pass))
+
+	return hex.EncodeToString(h.Sum(nil))
+}
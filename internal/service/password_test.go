@@ -0,0 +1,56 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPasswordHasher(t *testing.T) {
+	argon := NewPasswordHasher(PasswordConfig{Argon2Time: 1, Argon2MemoryKiB: 1024, Argon2Threads: 1})
+
+	hash, err := argon.Hash("secret-password")
+	if err != nil {
+		t.Fatalf("Hash err %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("Hash = %s", hash)
+	}
+
+	if again, _ := argon.Hash("secret-password"); again == hash {
+		t.Errorf("hashes of the same password must use different salts")
+	}
+
+	if ok, rehash, err := argon.Verify("secret-password", hash); !ok || rehash || err != nil {
+		t.Errorf("Verify = %v, %v, %v", ok, rehash, err)
+	}
+
+	if ok, _, _ := argon.Verify("wrong", hash); ok {
+		t.Errorf("wrong password verified")
+	}
+
+	// the dummy hash of unknown users costs as much as a real one
+	argon.VerifyDummy("secret-password")
+	if !strings.HasPrefix(argon.dummyHash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("dummy hash = %s", argon.dummyHash)
+	}
+
+	// a cost change requires rehashing
+	stronger := NewPasswordHasher(PasswordConfig{Argon2Time: 2, Argon2MemoryKiB: 1024, Argon2Threads: 1})
+	if ok, rehash, _ := stronger.Verify("secret-password", hash); !ok || !rehash {
+		t.Errorf("Verify with changed cost = %v, %v", ok, rehash)
+	}
+
+	// legacy unsalted hashes verify and are marked for upgrade
+	if ok, rehash, _ := argon.Verify("secret-password", legacyHashPassword("secret-password")); !ok || !rehash {
+		t.Errorf("Verify legacy = %v, %v", ok, rehash)
+	}
+
+	bcryptHasher := NewPasswordHasher(PasswordConfig{Algorithm: PASSWORD_ALGORITHM_BCRYPT, BcryptCost: 4})
+	bcryptHash, _ := bcryptHasher.Hash("secret-password")
+	if ok, rehash, _ := bcryptHasher.Verify("secret-password", bcryptHash); !ok || rehash {
+		t.Errorf("Verify bcrypt = %v, %v", ok, rehash)
+	}
+	if ok, rehash, _ := argon.Verify("secret-password", bcryptHash); !ok || !rehash {
+		t.Errorf("Verify bcrypt with argon2id configured = %v, %v", ok, rehash)
+	}
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"github.com/IMBgl/go-wallet-api/internal/domain"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+// Profile is the user with the state of its security settings.
+type Profile struct {
+	User             *domain.User
+	TwoFactorEnabled bool
+}
+
+type ProfileGetRequest struct {
+	UserId uuid.UUID
+}
+
+// ProfileUpdateRequest changes the set fields only, the email is changed with RequestEmailChange.
+type ProfileUpdateRequest struct {
+	UserId       uuid.UUID
+	Name         *string
+	BaseCurrency *domain.Currency
+	TimeZone     *time.Location
+}
+
+// PasswordChangeRequest keeps the session SessionId signed in, all other sessions are revoked.
+type PasswordChangeRequest struct {
+	UserId          uuid.UUID
+	SessionId       uuid.UUID
+	CurrentPassword string
+	NewPassword     string
+}
+
+// EmailChangeRequest sends a confirmation link to Email, the current email
+// stays until the link is used.
+type EmailChangeRequest struct {
+	UserId   uuid.UUID
+	Email    string
+	Password string
+}
+
+// AccountDeleteRequest needs the password, and a code or a recovery code
+// when two-factor authentication is enabled.
+type AccountDeleteRequest struct {
+	UserId       uuid.UUID
+	Password     string
+	Code         string
+	RecoveryCode string
+}
+
+func (s *userService) GetProfile(ctx context.Context, request *ProfileGetRequest) (*Profile, error) {
+	user, err := s.getUser(ctx, request.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.profile(ctx, user)
+}
+
+func (s *userService) UpdateProfile(ctx context.Context, request *ProfileUpdateRequest) (*Profile, error) {
+	user, err := s.getUser(ctx, request.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.Name != nil {
+		user.Name = *request.Name
+	}
+	if request.BaseCurrency != nil {
+		user.BaseCurrency = *request.BaseCurrency
+	}
+	if request.TimeZone != nil {
+		user.TimeZone = request.TimeZone
+	}
+
+	err = s.repo.User().Save(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.profile(ctx, user)
+}
+
+func (s *userService) ChangePassword(ctx context.Context, request *PasswordChangeRequest) error {
+	user, err := s.getUser(ctx, request.UserId)
+	if err != nil {
+		return err
+	}
+
+	err = s.CheckUserPassword(ctx, user, request.CurrentPassword)
+	if err != nil {
+		return err
+	}
+
+	password, err := s.passwords.Hash(request.NewPassword)
+	if err != nil {
+		return err
+	}
+	user.Password = password
+
+	return s.repo.User().ChangePassword(ctx, user, request.SessionId)
+}
+
+func (s *userService) RequestEmailChange(ctx context.Context, request *EmailChangeRequest) error {
+	user, err := s.getUser(ctx, request.UserId)
+	if err != nil {
+		return err
+	}
+
+	err = s.CheckUserPassword(ctx, user, request.Password)
+	if err != nil {
+		return err
+	}
+
+	email := strings.TrimSpace(request.Email)
+	if strings.EqualFold(email, user.Email) {
+		return ErrEmailAlreadyInUse
+	}
+
+	found, err := s.repo.User().GetByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+
+	if found != nil {
+		return ErrEmailAlreadyInUse
+	}
+
+	return s.account.SendEmailChange(ctx, user, email)
+}
+
+// DeleteAccount removes the user with all its wallets, categories, transactions and tokens.
+func (s *userService) DeleteAccount(ctx context.Context, request *AccountDeleteRequest) error {
+	user, err := s.getUser(ctx, request.UserId)
+	if err != nil {
+		return err
+	}
+
+	err = s.CheckUserPassword(ctx, user, request.Password)
+	if err != nil {
+		return err
+	}
+
+	totp, err := s.repo.TwoFactor().GetTotpByUserId(ctx, user.Id)
+	if err != nil {
+		return err
+	}
+
+	if totp != nil && totp.Enabled {
+		err = s.checkSecondFactor(ctx, totp, request.Code, request.RecoveryCode)
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.repo.User().Delete(ctx, user)
+}
+
+func (s *userService) profile(ctx context.Context, user *domain.User) (*Profile, error) {
+	totp, err := s.repo.TwoFactor().GetTotpByUserId(ctx, user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Profile{User: user, TwoFactorEnabled: totp != nil && totp.Enabled}, nil
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"github.com/IMBgl/go-wallet-api/internal/domain"
+	"io"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const CSV_RATE_PROVIDER_NAME = "csv"
+
+type datedRate struct {
+	date time.Time
+	rate *big.Rat
+}
+
+// csvRateProvider serves the rates of a CSV file with date,base,quote,rate
+// rows, for example "2024-01-31,usd,eur,0.9214". The file is read once, so
+// the provider works offline.
+type csvRateProvider struct {
+	rates map[string][]datedRate
+}
+
+// NewCsvRateProvider reads the rates file at path.
+func NewCsvRateProvider(path string) (*csvRateProvider, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseCsvRates(f)
+}
+
+// ParseCsvRates reads date,base,quote,rate rows, a first row starting with "date" is a header.
+func ParseCsvRates(r io.Reader) (*csvRateProvider, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 4
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	p := &csvRateProvider{rates: map[string][]datedRate{}}
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if line == 1 && strings.EqualFold(strings.TrimSpace(record[0]), "date") {
+			continue
+		}
+
+		date, err := time.Parse("2006-01-02", strings.TrimSpace(record[0]))
+		if err != nil {
+			return nil, fmt.Errorf("rates line %d: invalid date %q", line, record[0])
+		}
+
+		base, err := domain.CurrencyFromCode(strings.TrimSpace(record[1]))
+		if err != nil {
+			return nil, fmt.Errorf("rates line %d: %w", line, err)
+		}
+
+		quote, err := domain.CurrencyFromCode(strings.TrimSpace(record[2]))
+		if err != nil {
+			return nil, fmt.Errorf("rates line %d: %w", line, err)
+		}
+
+		rate, ok := new(big.Rat).SetString(strings.TrimSpace(record[3]))
+		if !ok || rate.Sign() <= 0 || base == quote {
+			return nil, fmt.Errorf("rates line %d: %w", line, domain.ErrInvalidExchangeRate)
+		}
+
+		key := ratePairKey(base, quote)
+		p.rates[key] = append(p.rates[key], datedRate{date: date, rate: rate})
+	}
+
+	for _, list := range p.rates {
+		sort.SliceStable(list, func(i, j int) bool {
+			return list[i].date.Before(list[j].date)
+		})
+	}
+
+	return p, nil
+}
+
+func (p *csvRateProvider) Name() string {
+	return CSV_RATE_PROVIDER_NAME
+}
+
+// Rate returns the latest rate of the pair, or the inverted rate of the
+// reverse pair when it is newer.
+func (p *csvRateProvider) Rate(ctx context.Context, base, quote domain.Currency, date time.Time) (*big.Rat, time.Time, error) {
+	direct := p.latest(ratePairKey(base, quote), date)
+	reverse := p.latest(ratePairKey(quote, base), date)
+
+	if reverse == nil || (direct != nil && !reverse.date.After(direct.date)) {
+		if direct == nil {
+			return nil, time.Time{}, nil
+		}
+
+		return direct.rate, direct.date, nil
+	}
+
+	return new(big.Rat).Inv(reverse.rate), reverse.date, nil
+}
+
+func (p *csvRateProvider) latest(key string, date time.Time) *datedRate {
+	list := p.rates[key]
+	i := sort.Search(len(list), func(i int) bool {
+		return list[i].date.After(date)
+	})
+
+	if i == 0 {
+		return nil
+	}
+
+	return &list[i-1]
+}
+
+func ratePairKey(base, quote domain.Currency) string {
+	return base.Val() + "/" + quote.Val()
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"github.com/IMBgl/go-wallet-api/internal/domain"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCsvRateProvider(t *testing.T) {
+	p, err := ParseCsvRates(strings.NewReader("date,base,quote,rate\n2024-01-03,usd,eur,0.9\n2024-01-01,USD,EUR,0.8\n2024-01-02,eur,rub,100\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	day := func(d string) time.Time {
+		v, _ := time.Parse("2006-01-02", d)
+		return v
+	}
+
+	tests := []struct {
+		base, quote domain.Currency
+		date        string
+		rate        string
+		rateDate    string
+	}{
+		{domain.CurrencyUSD(), domain.CurrencyEUR(), "2024-01-02", "4/5", "2024-01-01"},
+		{domain.CurrencyUSD(), domain.CurrencyEUR(), "2024-02-01", "9/10", "2024-01-03"},
+		{domain.CurrencyEUR(), domain.CurrencyUSD(), "2024-01-03", "10/9", "2024-01-03"},
+		{domain.CurrencyRUB(), domain.CurrencyEUR(), "2024-01-05", "1/100", "2024-01-02"},
+		{domain.CurrencyUSD(), domain.CurrencyEUR(), "2023-12-31", "", ""},
+		{domain.CurrencyUSD(), domain.CurrencyRUB(), "2024-01-05", "", ""},
+	}
+
+	for _, tt := range tests {
+		rate, date, err := p.Rate(context.Background(), tt.base, tt.quote, day(tt.date))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if tt.rate == "" {
+			if rate != nil {
+				t.Errorf("Rate(%s, %s, %s) = %s, want none", tt.base.Code(), tt.quote.Code(), tt.date, rate.RatString())
+			}
+			continue
+		}
+
+		want, _ := new(big.Rat).SetString(tt.rate)
+		if rate == nil || rate.Cmp(want) != 0 || !date.Equal(day(tt.rateDate)) {
+			t.Errorf("Rate(%s, %s, %s) = %v on %s, want %s on %s", tt.base.Code(), tt.quote.Code(), tt.date, rate, date.Format("2006-01-02"), tt.rate, tt.rateDate)
+		}
+	}
+
+	for _, invalid := range []string{"2024-01-01,usd,usd,1\n", "2024-01-01,usd,eur,0\n", "01.01.2024,usd,eur,1\n", "2024-01-01,usd,xxx,1\n"} {
+		if _, err := ParseCsvRates(strings.NewReader(invalid)); err == nil {
+			t.Errorf("ParseCsvRates(%q) accepted", invalid)
+		}
+	}
+}
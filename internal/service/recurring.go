@@ -0,0 +1,492 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/IMBgl/go-wallet-api/internal/domain"
+	"github.com/google/uuid"
+	"log"
+	"math/big"
+	"time"
+)
+
+const RECURRING_PREVIEW_DEFAULT_LIMIT = 12
+const RECURRING_PREVIEW_MAX_LIMIT = 366
+
+type recurringService struct {
+	repo               Repository
+	transactionService TransactionService
+}
+
+type RecurringCreateRequest struct {
+	UserId          uuid.UUID
+	WalletId        uuid.UUID
+	CategoryId      uuid.UUID
+	Amount          domain.Money
+	TransactionType domain.TransactionType
+	Comment         string
+	Frequency       domain.Frequency
+	Interval        int
+	DayOfMonth      int
+	StartDate       time.Time
+	EndDate         *time.Time
+	Count           *int
+}
+
+// RecurringUpdateRequest changes the template for all occurrences that are not posted yet.
+type RecurringUpdateRequest struct {
+	UserId      uuid.UUID
+	RecurringId uuid.UUID
+	CategoryId  *uuid.UUID
+	Amount      *big.Rat
+	Comment     *string
+}
+
+type RecurringGetListRequest struct {
+	UserId uuid.UUID
+}
+
+type RecurringGetOneRequest struct {
+	UserId      uuid.UUID
+	RecurringId uuid.UUID
+}
+
+type RecurringDeleteRequest struct {
+	UserId      uuid.UUID
+	RecurringId uuid.UUID
+}
+
+type RecurringPreviewRequest struct {
+	UserId      uuid.UUID
+	RecurringId uuid.UUID
+	From        time.Time
+	To          time.Time
+	Limit       int
+}
+
+type OccurrenceSkipRequest struct {
+	UserId      uuid.UUID
+	RecurringId uuid.UUID
+	Date        time.Time
+}
+
+// OccurrenceUpdateRequest overrides amount or comment of one occurrence only.
+type OccurrenceUpdateRequest struct {
+	UserId      uuid.UUID
+	RecurringId uuid.UUID
+	Date        time.Time
+	Amount      *big.Rat
+	Comment     *string
+}
+
+// Occurrence is a resolved occurrence of a recurring transaction with overrides applied.
+type Occurrence struct {
+	Date          time.Time
+	Status        domain.OccurrenceStatus
+	Amount        domain.Money
+	Comment       string
+	TransactionId *uuid.UUID
+	Error         *string
+}
+
+type RecurringRepository interface {
+	Save(ctx context.Context, r *domain.RecurringTransaction) error
+	Delete(ctx context.Context, r *domain.RecurringTransaction) error
+	GetByIdAndUserId(ctx context.Context, id, userId uuid.UUID) (*domain.RecurringTransaction, error)
+	FindByUserId(ctx context.Context, userId uuid.UUID) ([]*domain.RecurringTransaction, error)
+	FindDue(ctx context.Context, at time.Time) ([]*domain.RecurringTransaction, error)
+	SaveOccurrence(ctx context.Context, o *domain.RecurringOccurrence) error
+	GetOccurrence(ctx context.Context, recurringId uuid.UUID, date time.Time) (*domain.RecurringOccurrence, error)
+	FindOccurrences(ctx context.Context, recurringId uuid.UUID, from, to time.Time) ([]*domain.RecurringOccurrence, error)
+}
+
+func NewRecurringService(r Repository, ts TransactionService) *recurringService {
+	return &recurringService{repo: r, transactionService: ts}
+}
+
+func (s *recurringService) Create(ctx context.Context, request *RecurringCreateRequest) (*domain.RecurringTransaction, error) {
+	user, err := s.repo.User().GetById(ctx, request.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	wallet, err := s.repo.Wallet().GetByIdAndUserId(ctx, request.WalletId, user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if wallet == nil {
+		return nil, domain.ErrWalletNotFound
+	}
+
+	if !wallet.Balance.SameCurrency(request.Amount) {
+		return nil, domain.ErrTransactionWalletCurrencyMismatch
+	}
+
+	category, err := s.repo.Category().FindByIdAndUserId(ctx, request.CategoryId, user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if category == nil {
+		return nil, domain.ErrCategoryNotFound
+	}
+
+	schedule, err := domain.NewSchedule(request.Frequency, request.Interval, request.DayOfMonth, request.StartDate, request.EndDate, request.Count)
+	if err != nil {
+		return nil, err
+	}
+
+	recurring := domain.NewRecurringTransaction(request.Comment, request.Amount, request.TransactionType, schedule, user.Id, category.Id, wallet.Id)
+
+	err = s.repo.Recurring().Save(ctx, recurring)
+	if err != nil {
+		return nil, err
+	}
+
+	return recurring, nil
+}
+
+func (s *recurringService) Update(ctx context.Context, request *RecurringUpdateRequest) (*domain.RecurringTransaction, error) {
+	recurring, err := s.GetOne(ctx, &RecurringGetOneRequest{UserId: request.UserId, RecurringId: request.RecurringId})
+	if err != nil {
+		return nil, err
+	}
+
+	if request.CategoryId != nil {
+		category, err := s.repo.Category().FindByIdAndUserId(ctx, *request.CategoryId, recurring.UserId)
+		if err != nil {
+			return nil, err
+		}
+
+		if category == nil {
+			return nil, domain.ErrCategoryNotFound
+		}
+		recurring.CategoryId = category.Id
+	}
+
+	if request.Amount != nil {
+		recurring.Amount, err = domain.MoneyFromRat(request.Amount, recurring.Amount.Currency())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if request.Comment != nil {
+		recurring.Comment = *request.Comment
+	}
+
+	err = s.repo.Recurring().Save(ctx, recurring)
+	if err != nil {
+		return nil, err
+	}
+
+	return recurring, nil
+}
+
+func (s *recurringService) GetList(ctx context.Context, request *RecurringGetListRequest) ([]*domain.RecurringTransaction, error) {
+	user, err := s.repo.User().GetById(ctx, request.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	return s.repo.Recurring().FindByUserId(ctx, user.Id)
+}
+
+func (s *recurringService) GetOne(ctx context.Context, request *RecurringGetOneRequest) (*domain.RecurringTransaction, error) {
+	user, err := s.repo.User().GetById(ctx, request.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	recurring, err := s.repo.Recurring().GetByIdAndUserId(ctx, request.RecurringId, user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if recurring == nil {
+		return nil, domain.ErrRecurringNotFound
+	}
+
+	return recurring, nil
+}
+
+func (s *recurringService) Delete(ctx context.Context, request *RecurringDeleteRequest) error {
+	recurring, err := s.GetOne(ctx, &RecurringGetOneRequest{UserId: request.UserId, RecurringId: request.RecurringId})
+	if err != nil {
+		return err
+	}
+
+	return s.repo.Recurring().Delete(ctx, recurring)
+}
+
+func (s *recurringService) Preview(ctx context.Context, request *RecurringPreviewRequest) ([]*Occurrence, error) {
+	recurring, err := s.GetOne(ctx, &RecurringGetOneRequest{UserId: request.UserId, RecurringId: request.RecurringId})
+	if err != nil {
+		return nil, err
+	}
+
+	limit := request.Limit
+	if limit <= 0 {
+		limit = RECURRING_PREVIEW_DEFAULT_LIMIT
+	}
+	if limit > RECURRING_PREVIEW_MAX_LIMIT {
+		limit = RECURRING_PREVIEW_MAX_LIMIT
+	}
+
+	from := request.From
+	if from.IsZero() {
+		from = time.Now().UTC().Truncate(24 * time.Hour)
+	}
+
+	to := request.To
+	if to.IsZero() {
+		to = from.AddDate(10, 0, 0)
+	}
+
+	dates := recurring.Schedule.Between(from, to, limit)
+	if len(dates) == 0 {
+		return []*Occurrence{}, nil
+	}
+
+	overrides, err := s.repo.Recurring().FindOccurrences(ctx, recurring.Id, dates[0], dates[len(dates)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	byDate := map[string]*domain.RecurringOccurrence{}
+	for _, o := range overrides {
+		byDate[o.Date.Format("2006-01-02")] = o
+	}
+
+	list := []*Occurrence{}
+	for _, date := range dates {
+		list = append(list, resolveOccurrence(recurring, date, byDate[date.Format("2006-01-02")]))
+	}
+
+	return list, nil
+}
+
+func (s *recurringService) Skip(ctx context.Context, request *OccurrenceSkipRequest) (*Occurrence, error) {
+	recurring, occurrence, err := s.getOccurrence(ctx, request.UserId, request.RecurringId, request.Date)
+	if err != nil {
+		return nil, err
+	}
+
+	occurrence.Status, occurrence.Error = domain.OccurrenceSkipped(), nil
+
+	err = s.repo.Recurring().SaveOccurrence(ctx, occurrence)
+	if err != nil {
+		return nil, err
+	}
+
+	return resolveOccurrence(recurring, occurrence.Date, occurrence), nil
+}
+
+func (s *recurringService) UpdateOccurrence(ctx context.Context, request *OccurrenceUpdateRequest) (*Occurrence, error) {
+	recurring, occurrence, err := s.getOccurrence(ctx, request.UserId, request.RecurringId, request.Date)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.Amount != nil {
+		amount, err := domain.MoneyFromRat(request.Amount, recurring.Amount.Currency())
+		if err != nil {
+			return nil, err
+		}
+		occurrence.Amount = &amount
+	}
+
+	if request.Comment != nil {
+		occurrence.Comment = request.Comment
+	}
+
+	// the scheduler has passed a failed occurrence, it is posted again right away
+	if occurrence.Status.IsFailed() {
+		err = s.post(ctx, recurring, occurrence)
+		if err != nil {
+			return nil, err
+		}
+
+		return resolveOccurrence(recurring, occurrence.Date, occurrence), nil
+	}
+
+	occurrence.Status = domain.OccurrenceScheduled()
+
+	err = s.repo.Recurring().SaveOccurrence(ctx, occurrence)
+	if err != nil {
+		return nil, err
+	}
+
+	return resolveOccurrence(recurring, occurrence.Date, occurrence), nil
+}
+
+// getOccurrence loads the stored state of a not yet posted occurrence or a
+// fresh one if it was never changed.
+func (s *recurringService) getOccurrence(ctx context.Context, userId, recurringId uuid.UUID, date time.Time) (*domain.RecurringTransaction, *domain.RecurringOccurrence, error) {
+	recurring, err := s.GetOne(ctx, &RecurringGetOneRequest{UserId: userId, RecurringId: recurringId})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !recurring.Schedule.Includes(date) {
+		return nil, nil, domain.ErrOccurrenceNotFound
+	}
+
+	occurrence, err := s.repo.Recurring().GetOccurrence(ctx, recurring.Id, date)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if occurrence == nil {
+		occurrence = &domain.RecurringOccurrence{RecurringId: recurring.Id, Date: date, Status: domain.OccurrenceScheduled()}
+	}
+
+	if occurrence.Status.IsPosted() {
+		return nil, nil, domain.ErrOccurrenceAlreadyPosted
+	}
+
+	return recurring, occurrence, nil
+}
+
+// ProcessDue posts every occurrence due at the given time. Each occurrence is
+// booked with a transaction id derived from its date, so running it again
+// after a crash or restart never creates duplicates.
+func (s *recurringService) ProcessDue(ctx context.Context, at time.Time) error {
+	list, err := s.repo.Recurring().FindDue(ctx, at)
+	if err != nil {
+		return err
+	}
+
+	for _, recurring := range list {
+		err = s.processRecurring(ctx, recurring, at)
+		if err != nil {
+			log.Printf("recurring transaction %s processing err %v", recurring.Id, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *recurringService) processRecurring(ctx context.Context, recurring *domain.RecurringTransaction, at time.Time) error {
+	for recurring.NextDate != nil && !recurring.NextDate.After(at) {
+		date := *recurring.NextDate
+
+		occurrence, err := s.repo.Recurring().GetOccurrence(ctx, recurring.Id, date)
+		if err != nil {
+			return err
+		}
+
+		if occurrence == nil {
+			occurrence = &domain.RecurringOccurrence{RecurringId: recurring.Id, Date: date, Status: domain.OccurrenceScheduled()}
+		}
+
+		if occurrence.Status.IsScheduled() {
+			err = s.post(ctx, recurring, occurrence)
+			if err != nil && !permanentPostError(err) {
+				return err
+			}
+
+			// a later run would fail the same way, the occurrence is marked and the schedule moves on
+			if err != nil {
+				log.Printf("recurring transaction %s occurrence %s failed: %v", recurring.Id, date.Format("2006-01-02"), err)
+				reason := err.Error()
+				occurrence.Status, occurrence.Error = domain.OccurrenceFailed(), &reason
+
+				err = s.repo.Recurring().SaveOccurrence(ctx, occurrence)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		recurring.NextDate = nil
+		if next, ok := recurring.Schedule.NextAfter(date); ok {
+			recurring.NextDate = &next
+		}
+
+		err = s.repo.Recurring().Save(ctx, recurring)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *recurringService) post(ctx context.Context, recurring *domain.RecurringTransaction, occurrence *domain.RecurringOccurrence) error {
+	resolved := resolveOccurrence(recurring, occurrence.Date, occurrence)
+	transactionId := recurring.OccurrenceTransactionId(occurrence.Date)
+
+	existing, err := s.repo.Transaction().GetByIdAndUserId(ctx, transactionId, recurring.UserId)
+	if err != nil {
+		return err
+	}
+
+	if existing == nil {
+		_, err = s.transactionService.Create(ctx, &TransactionCreateRequest{
+			Id:              transactionId,
+			Date:            occurrence.Date,
+			UserId:          recurring.UserId,
+			WalletId:        recurring.WalletId,
+			CategoryId:      recurring.CategoryId,
+			Comment:         resolved.Comment,
+			Amount:          resolved.Amount,
+			TransactionType: recurring.Type,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	occurrence.Status = domain.OccurrencePosted()
+	occurrence.TransactionId = &transactionId
+	occurrence.Error = nil
+
+	return s.repo.Recurring().SaveOccurrence(ctx, occurrence)
+}
+
+// permanentPostError reports whether posting failed for a reason retrying
+// does not fix, like a deleted wallet or a currency no longer enabled.
+func permanentPostError(err error) bool {
+	var domainErr *domain.DomainError
+
+	return errors.As(err, &domainErr) || errors.Is(err, ErrUserNotFound)
+}
+
+func resolveOccurrence(recurring *domain.RecurringTransaction, date time.Time, stored *domain.RecurringOccurrence) *Occurrence {
+	occurrence := &Occurrence{
+		Date:    date,
+		Status:  domain.OccurrenceScheduled(),
+		Amount:  recurring.Amount,
+		Comment: recurring.Comment,
+	}
+
+	if stored == nil {
+		return occurrence
+	}
+
+	occurrence.Status = stored.Status
+	occurrence.TransactionId = stored.TransactionId
+	occurrence.Error = stored.Error
+	if stored.Amount != nil {
+		occurrence.Amount = *stored.Amount
+	}
+	if stored.Comment != nil {
+		occurrence.Comment = *stored.Comment
+	}
+
+	return occurrence
+}
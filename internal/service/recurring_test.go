@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"github.com/IMBgl/go-wallet-api/internal/domain"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+type recurringTestRepository struct {
+	Repository
+	recurring   *recurringTestRecurringRepository
+	transaction *transactionTestTransactionRepository
+}
+
+func (r *recurringTestRepository) Recurring() RecurringRepository     { return r.recurring }
+func (r *recurringTestRepository) Transaction() TransactionRepository { return r.transaction }
+
+type recurringTestRecurringRepository struct {
+	RecurringRepository
+	occurrences []*domain.RecurringOccurrence
+}
+
+func (r *recurringTestRecurringRepository) GetOccurrence(ctx context.Context, recurringId uuid.UUID, date time.Time) (*domain.RecurringOccurrence, error) {
+	return nil, nil
+}
+
+func (r *recurringTestRecurringRepository) SaveOccurrence(ctx context.Context, o *domain.RecurringOccurrence) error {
+	r.occurrences = append(r.occurrences, o)
+	return nil
+}
+
+func (r *recurringTestRecurringRepository) Save(ctx context.Context, t *domain.RecurringTransaction) error {
+	return nil
+}
+
+type recurringTestTransactionService struct {
+	TransactionService
+	err error
+}
+
+func (s *recurringTestTransactionService) Create(ctx context.Context, request *TransactionCreateRequest) (*domain.Transaction, error) {
+	return nil, s.err
+}
+
+func TestProcessDueMarksFailedOccurrences(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	schedule, err := domain.NewSchedule(domain.FrequencyDaily(), 1, 0, start, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recurring := &domain.RecurringTransaction{Id: uuid.New(), Schedule: schedule, NextDate: &start}
+
+	// the wallet of the template was deleted
+	repo := &recurringTestRecurringRepository{}
+	s := NewRecurringService(&recurringTestRepository{
+		recurring:   repo,
+		transaction: &transactionTestTransactionRepository{},
+	}, &recurringTestTransactionService{err: domain.ErrWalletNotFound})
+
+	err = s.processRecurring(context.Background(), recurring, start.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !recurring.NextDate.Equal(start.AddDate(0, 0, 2)) {
+		t.Errorf("NextDate = %s, want %s", recurring.NextDate, start.AddDate(0, 0, 2))
+	}
+	if len(repo.occurrences) != 2 || !repo.occurrences[0].Status.IsFailed() || *repo.occurrences[0].Error != domain.ErrWalletNotFound.Error() {
+		t.Errorf("occurrences = %v, want two failed", repo.occurrences)
+	}
+}
@@ -0,0 +1,1033 @@
+package service
+
+import (
+	"context"
+	"github.com/IMBgl/go-wallet-api/internal/domain"
+	"github.com/google/uuid"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// REPORT_MAX_INTERVALS limits the number of intervals of a report series.
+const REPORT_MAX_INTERVALS = 400
+
+type reportService struct {
+	repo     Repository
+	exchange ExchangeService
+}
+
+type NetWorthRequest struct {
+	UserId uuid.UUID
+	// Currency is optional: the base currency of the user is used when unset
+	Currency *domain.Currency
+	// Date is optional: the balances at the end of the day, today when unset
+	Date *time.Time
+}
+
+type NetWorthHistoryRequest struct {
+	UserId   uuid.UUID
+	Currency *domain.Currency
+	DateFrom time.Time
+	DateTo   time.Time
+	Interval domain.ReportInterval
+}
+
+// WalletWorth is the balance of a wallet and its conversion at Rate.
+type WalletWorth struct {
+	Wallet  *domain.Wallet
+	Balance domain.Money
+	Amount  domain.Money
+	Rate    *big.Rat
+}
+
+type NetWorth struct {
+	Currency domain.Currency
+	Date     time.Time
+	Total    domain.Money
+	Wallets  []*WalletWorth
+}
+
+// NetWorthPoint is the net worth at the end of the interval, End is exclusive.
+type NetWorthPoint struct {
+	Start time.Time
+	End   time.Time
+	Total domain.Money
+}
+
+type NetWorthHistory struct {
+	Currency domain.Currency
+	Interval domain.ReportInterval
+	Points   []*NetWorthPoint
+}
+
+// WalletChangesFilter selects the transactions of the user, of one wallet
+// when WalletId is set, summed per interval in Location.
+type WalletChangesFilter struct {
+	UserId   uuid.UUID
+	WalletId *uuid.UUID
+	Interval domain.ReportInterval
+	Location *time.Location
+}
+
+// WalletChange is the sum of the transactions of a wallet in the interval
+// starting at Start, outgoing transactions count negative.
+type WalletChange struct {
+	WalletId uuid.UUID
+	Start    time.Time
+	Amount   domain.Money
+}
+
+type CategoryReportRequest struct {
+	UserId uuid.UUID
+	// CategoryId limits the report to the category and its subcategories
+	CategoryId *uuid.UUID
+	WalletId   *uuid.UUID
+	// Currency is optional: the currency of the wallet or the base currency of the user is used when unset
+	Currency *domain.Currency
+	DateFrom time.Time
+	// DateTo is exclusive
+	DateTo time.Time
+}
+
+// CategoryAmount is the total of one transaction type in a category. Own
+// counts the transactions of the category itself, Total adds its
+// subcategories. Percent is the share of Total in the total of the report,
+// ChangePercent is nil when there was nothing in the previous period.
+type CategoryAmount struct {
+	Own           domain.Money
+	Total         domain.Money
+	Previous      domain.Money
+	Percent       *big.Rat
+	Change        domain.Money
+	ChangePercent *big.Rat
+}
+
+// CategoryReportNode is a category of the tree GetOne returns with its amounts.
+type CategoryReportNode struct {
+	*domain.Category
+	Parent   *domain.Category
+	Children []*CategoryReportNode
+	In       *CategoryAmount
+	Out      *CategoryAmount
+}
+
+// CategoryReport compares the period with the previous one of the same
+// length, whole months are compared with the months before. Transfers
+// between wallets are not income or spending and are left out.
+type CategoryReport struct {
+	Currency     domain.Currency
+	DateFrom     time.Time
+	DateTo       time.Time
+	PreviousFrom time.Time
+	PreviousTo   time.Time
+	In           *CategoryAmount
+	Out          *CategoryAmount
+	Items        []*CategoryReportNode
+}
+
+// CashflowRequest covers the intervals from DateFrom to DateTo inclusive,
+// the dates are days in the time zone of the user.
+type CashflowRequest struct {
+	UserId uuid.UUID
+	// Currency is optional: the base currency of the user is used when unset
+	Currency *domain.Currency
+	DateFrom time.Time
+	DateTo   time.Time
+	Interval domain.ReportInterval
+	GroupBy  domain.ReportGroup
+}
+
+// CashflowAmount is the income and spending, transfers between the wallets
+// of the user are neither: Transfer is their balance, zero in the period
+// totals up to conversion. Net is In - Out + Transfer.
+type CashflowAmount struct {
+	In       domain.Money
+	Out      domain.Money
+	Transfer domain.Money
+	Net      domain.Money
+}
+
+// CashflowGroup is a wallet or a category, Id is nil for the transactions without a category.
+type CashflowGroup struct {
+	Id   *uuid.UUID
+	Name string
+	CashflowAmount
+}
+
+type CashflowPeriod struct {
+	Start  time.Time
+	End    time.Time
+	Groups []*CashflowGroup
+	CashflowAmount
+}
+
+// Cashflow is the money in and out per interval, converted at the rates of
+// the last day of each interval.
+type Cashflow struct {
+	Currency domain.Currency
+	Interval domain.ReportInterval
+	GroupBy  domain.ReportGroup
+	TimeZone *time.Location
+	Items    []*CashflowPeriod
+}
+
+// CashflowTotal is the sum of the transactions of one type and currency in
+// an interval starting at Start in the time zone of the filter.
+type CashflowTotal struct {
+	Start   time.Time
+	GroupId *uuid.UUID
+	Type    domain.TransactionType
+	// Transfer is set for the sums of transfer transactions
+	Transfer bool
+	Amount   domain.Money
+}
+
+// CashflowFilter selects the transactions made from DateFrom until DateTo, exclusive. The dates are in UTC like created_at.
+type CashflowFilter struct {
+	UserId   uuid.UUID
+	Interval domain.ReportInterval
+	Location *time.Location
+	GroupBy  domain.ReportGroup
+	DateFrom time.Time
+	DateTo   time.Time
+}
+
+type WalletBalanceRequest struct {
+	UserId   uuid.UUID
+	WalletId uuid.UUID
+	// At is optional: a time in the time zone of the user, the transactions
+	// made before it are counted. The current balance is returned when unset
+	At *time.Time
+}
+
+type WalletBalance struct {
+	Wallet  *domain.Wallet
+	At      time.Time
+	Balance domain.Money
+}
+
+// WalletBalanceHistoryRequest covers the intervals from DateFrom to DateTo
+// inclusive, the dates are days in the time zone of the user.
+type WalletBalanceHistoryRequest struct {
+	UserId   uuid.UUID
+	WalletId uuid.UUID
+	DateFrom time.Time
+	DateTo   time.Time
+	Interval domain.ReportInterval
+}
+
+// WalletBalancePoint is the balance at the end of the interval and its change during the interval.
+type WalletBalancePoint struct {
+	Start   time.Time
+	End     time.Time
+	Change  domain.Money
+	Balance domain.Money
+}
+
+// WalletBalanceHistory is the running balance of a wallet in its own
+// currency, Opening is the balance at the start of the first interval.
+type WalletBalanceHistory struct {
+	Wallet   *domain.Wallet
+	Interval domain.ReportInterval
+	Opening  domain.Money
+	Points   []*WalletBalancePoint
+}
+
+// CategoryTotal is the sum of the transactions of one type and currency in a category.
+type CategoryTotal struct {
+	CategoryId uuid.UUID
+	Type       domain.TransactionType
+	Amount     domain.Money
+}
+
+// CategoryTotalsFilter selects the transactions made from DateFrom until DateTo, exclusive. The dates are in UTC like created_at.
+type CategoryTotalsFilter struct {
+	UserId   uuid.UUID
+	WalletId *uuid.UUID
+	DateFrom time.Time
+	DateTo   time.Time
+}
+
+type ReportRepository interface {
+	// WalletChanges sums the transactions per wallet and interval
+	WalletChanges(ctx context.Context, filter *WalletChangesFilter) ([]*WalletChange, error)
+	// WalletChangesAt sums the transactions of the wallet before at and since at, at is in UTC like created_at
+	WalletChangesAt(ctx context.Context, walletId uuid.UUID, at time.Time) ([]*WalletChange, error)
+	// CategoryTotals sums the categorized transactions which are not transfers
+	CategoryTotals(ctx context.Context, filter *CategoryTotalsFilter) ([]*CategoryTotal, error)
+	// Cashflow sums the transactions per interval in the location of the filter and wallet or category
+	Cashflow(ctx context.Context, filter *CashflowFilter) ([]*CashflowTotal, error)
+}
+
+func NewReportService(r Repository, es ExchangeService) *reportService {
+	return &reportService{repo: r, exchange: es}
+}
+
+// NetWorth converts the balance of every wallet to the currency at the rate of the day.
+func (s *reportService) NetWorth(ctx context.Context, request *NetWorthRequest) (*NetWorth, error) {
+	user, err := s.getUser(ctx, request.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	currency := user.BaseCurrency
+	if request.Currency != nil {
+		currency = *request.Currency
+	}
+
+	now := time.Now()
+	date := now.In(user.TimeZone)
+	if request.Date != nil {
+		date = inZone(*request.Date, user.TimeZone)
+	}
+	_, at := domain.ReportIntervalDay().Bounds(date)
+
+	ledgers, err := s.walletLedgers(ctx, user.Id, domain.ReportIntervalDay(), user.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+
+	rateDate := reportRateDate(at, now)
+	rates := map[domain.Currency]*big.Rat{}
+	netWorth := &NetWorth{Currency: currency, Date: rateDate, Total: domain.ZeroMoney(currency), Wallets: []*WalletWorth{}}
+	for _, l := range ledgers {
+		if !l.exists(at) {
+			continue
+		}
+
+		balance, err := l.balanceAt(at)
+		if err != nil {
+			return nil, err
+		}
+
+		amount, rate, err := s.convert(ctx, user.Id, rates, balance, currency, rateDate)
+		if err != nil {
+			return nil, err
+		}
+
+		netWorth.Total, err = netWorth.Total.Add(amount)
+		if err != nil {
+			return nil, err
+		}
+		netWorth.Wallets = append(netWorth.Wallets, &WalletWorth{Wallet: l.wallet, Balance: balance, Amount: amount, Rate: rate})
+	}
+
+	return netWorth, nil
+}
+
+// NetWorthHistory returns the net worth at the end of every interval
+// between the dates, the balances are rebuilt from the transactions and
+// converted at the rates of the last day of each interval.
+func (s *reportService) NetWorthHistory(ctx context.Context, request *NetWorthHistoryRequest) (*NetWorthHistory, error) {
+	user, err := s.getUser(ctx, request.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	currency := user.BaseCurrency
+	if request.Currency != nil {
+		currency = *request.Currency
+	}
+
+	bounds, err := reportIntervals(request.Interval, request.DateFrom, request.DateTo, user.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+
+	ledgers, err := s.walletLedgers(ctx, user.Id, request.Interval, user.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	history := &NetWorthHistory{Currency: currency, Interval: request.Interval, Points: []*NetWorthPoint{}}
+	for _, b := range bounds {
+		rateDate := reportRateDate(b[1], now)
+		rates := map[domain.Currency]*big.Rat{}
+		point := &NetWorthPoint{Start: b[0], End: b[1], Total: domain.ZeroMoney(currency)}
+
+		for _, l := range ledgers {
+			if !l.exists(b[1]) {
+				continue
+			}
+
+			balance, err := l.balanceAt(b[1])
+			if err != nil {
+				return nil, err
+			}
+
+			amount, _, err := s.convert(ctx, user.Id, rates, balance, currency, rateDate)
+			if err != nil {
+				return nil, err
+			}
+
+			point.Total, err = point.Total.Add(amount)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		history.Points = append(history.Points, point)
+	}
+
+	return history, nil
+}
+
+// CategoryReport returns the in and out totals of the categories rolled up through the parents.
+func (s *reportService) CategoryReport(ctx context.Context, request *CategoryReportRequest) (*CategoryReport, error) {
+	user, err := s.getUser(ctx, request.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	dateFrom, dateTo := inZone(request.DateFrom, user.TimeZone), inZone(request.DateTo, user.TimeZone)
+	if !dateFrom.Before(dateTo) {
+		return nil, domain.ErrInvalidReportRange
+	}
+
+	currency := user.BaseCurrency
+	if request.WalletId != nil {
+		wallet, err := s.getWallet(ctx, *request.WalletId, user.Id)
+		if err != nil {
+			return nil, err
+		}
+		currency = wallet.Balance.Currency()
+	}
+	if request.Currency != nil {
+		currency = *request.Currency
+	}
+
+	categories, err := s.repo.Category().FindByUserId(ctx, user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	roots, err := categoryReportTrees(categories, request.CategoryId)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &CategoryReport{Currency: currency, DateFrom: dateFrom, DateTo: dateTo, Items: roots}
+	report.PreviousFrom, report.PreviousTo = previousPeriod(dateFrom, dateTo)
+
+	current, err := s.categoryTotals(ctx, user.Id, request.WalletId, currency, report.DateFrom, report.DateTo)
+	if err != nil {
+		return nil, err
+	}
+
+	previous, err := s.categoryTotals(ctx, user.Id, request.WalletId, currency, report.PreviousFrom, report.PreviousTo)
+	if err != nil {
+		return nil, err
+	}
+
+	report.In, report.Out = newCategoryAmount(currency), newCategoryAmount(currency)
+	for _, root := range roots {
+		err = rollUpCategory(root, current, previous, currency)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, amounts := range [][2]*CategoryAmount{{report.In, root.In}, {report.Out, root.Out}} {
+			err = amounts[0].add(amounts[1])
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	report.In.Own = report.In.Total
+	report.Out.Own = report.Out.Total
+	for _, root := range roots {
+		compareCategory(root, report.In.Total, report.Out.Total)
+	}
+	report.In.compare(report.In.Total)
+	report.Out.compare(report.Out.Total)
+
+	return report, nil
+}
+
+// categoryTotals returns the in and out sums per category converted to the
+// currency at the rate of the last day of the period.
+func (s *reportService) categoryTotals(ctx context.Context, userId uuid.UUID, walletId *uuid.UUID, currency domain.Currency, from, to time.Time) (map[uuid.UUID]*[2]domain.Money, error) {
+	// created_at is stored without a zone, the bounds are bound as UTC
+	totals, err := s.repo.Report().CategoryTotals(ctx, &CategoryTotalsFilter{UserId: userId, WalletId: walletId, DateFrom: from.UTC(), DateTo: to.UTC()})
+	if err != nil {
+		return nil, err
+	}
+
+	rateDate := reportRateDate(to, time.Now())
+	rates := map[domain.Currency]*big.Rat{}
+	sums := map[uuid.UUID]*[2]domain.Money{}
+	for _, t := range totals {
+		amount, _, err := s.convert(ctx, userId, rates, t.Amount, currency, rateDate)
+		if err != nil {
+			return nil, err
+		}
+
+		sum, ok := sums[t.CategoryId]
+		if !ok {
+			sum = &[2]domain.Money{domain.ZeroMoney(currency), domain.ZeroMoney(currency)}
+			sums[t.CategoryId] = sum
+		}
+
+		i := 1
+		if t.Type.IsIn() {
+			i = 0
+		}
+		sum[i], err = sum[i].Add(amount)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return sums, nil
+}
+
+// categoryReportTrees links the categories into trees, the tree of rootId
+// or all trees of the user when it is nil. Children are ordered by name.
+func categoryReportTrees(categories []*domain.Category, rootId *uuid.UUID) ([]*CategoryReportNode, error) {
+	nodes := map[uuid.UUID]*CategoryReportNode{}
+	for _, c := range categories {
+		nodes[c.Id] = &CategoryReportNode{Category: c, Children: []*CategoryReportNode{}}
+	}
+
+	sort.Slice(categories, func(i, j int) bool {
+		return categories[i].Name < categories[j].Name
+	})
+
+	roots := []*CategoryReportNode{}
+	for _, c := range categories {
+		node := nodes[c.Id]
+		if c.ParentId != nil {
+			if parent, ok := nodes[*c.ParentId]; ok {
+				node.Parent = parent.Category
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+
+	if rootId == nil {
+		return roots, nil
+	}
+
+	root, ok := nodes[*rootId]
+	if !ok {
+		return nil, ErrCategoryNotFound
+	}
+
+	return []*CategoryReportNode{root}, nil
+}
+
+func newCategoryAmount(currency domain.Currency) *CategoryAmount {
+	zero := domain.ZeroMoney(currency)
+
+	return &CategoryAmount{Own: zero, Total: zero, Previous: zero, Change: zero, Percent: new(big.Rat)}
+}
+
+func (a *CategoryAmount) add(o *CategoryAmount) (err error) {
+	a.Total, err = a.Total.Add(o.Total)
+	if err != nil {
+		return err
+	}
+
+	a.Previous, err = a.Previous.Add(o.Previous)
+
+	return err
+}
+
+// compare sets the share of the total and the change since the previous period.
+func (a *CategoryAmount) compare(total domain.Money) {
+	if !total.IsZero() {
+		a.Percent = new(big.Rat).Mul(new(big.Rat).Quo(a.Total.Rat(), total.Rat()), big.NewRat(100, 1))
+	}
+
+	a.Change, _ = a.Total.Sub(a.Previous)
+	if !a.Previous.IsZero() {
+		a.ChangePercent = new(big.Rat).Mul(new(big.Rat).Quo(a.Change.Rat(), a.Previous.Rat()), big.NewRat(100, 1))
+	}
+}
+
+// rollUpCategory sets the own amounts of the node and adds up the amounts of its children.
+func rollUpCategory(node *CategoryReportNode, current, previous map[uuid.UUID]*[2]domain.Money, currency domain.Currency) error {
+	node.In, node.Out = newCategoryAmount(currency), newCategoryAmount(currency)
+	for i, amount := range []*CategoryAmount{node.In, node.Out} {
+		if sum, ok := current[node.Id]; ok {
+			amount.Own = sum[i]
+		}
+		amount.Total = amount.Own
+
+		if sum, ok := previous[node.Id]; ok {
+			amount.Previous = sum[i]
+		}
+	}
+
+	for _, child := range node.Children {
+		err := rollUpCategory(child, current, previous, currency)
+		if err != nil {
+			return err
+		}
+
+		err = node.In.add(child.In)
+		if err != nil {
+			return err
+		}
+
+		err = node.Out.add(child.Out)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func compareCategory(node *CategoryReportNode, totalIn, totalOut domain.Money) {
+	node.In.compare(totalIn)
+	node.Out.compare(totalOut)
+
+	for _, child := range node.Children {
+		compareCategory(child, totalIn, totalOut)
+	}
+}
+
+// previousPeriod returns the period of the same length right before from.
+// A period of whole months is compared with as many months before it.
+func previousPeriod(from, to time.Time) (time.Time, time.Time) {
+	if from.Day() == 1 && to.Day() == 1 && isMidnight(from) && isMidnight(to) {
+		months := (to.Year()-from.Year())*12 + int(to.Month()-from.Month())
+		return from.AddDate(0, -months, 0), from
+	}
+
+	return from.Add(-to.Sub(from)), from
+}
+
+func isMidnight(t time.Time) bool {
+	return t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0
+}
+
+// Cashflow returns the in and out totals of every interval between the
+// dates split by wallet or category. The sums come from the database, the
+// transactions are not loaded.
+func (s *reportService) Cashflow(ctx context.Context, request *CashflowRequest) (*Cashflow, error) {
+	user, err := s.getUser(ctx, request.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	currency := user.BaseCurrency
+	if request.Currency != nil {
+		currency = *request.Currency
+	}
+
+	bounds, err := reportIntervals(request.Interval, request.DateFrom, request.DateTo, user.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+
+	totals, err := s.repo.Report().Cashflow(ctx, &CashflowFilter{
+		UserId:   user.Id,
+		Interval: request.Interval,
+		Location: user.TimeZone,
+		GroupBy:  request.GroupBy,
+		DateFrom: bounds[0][0].UTC(),
+		DateTo:   bounds[len(bounds)-1][1].UTC(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := s.groupNames(ctx, user.Id, request.GroupBy)
+	if err != nil {
+		return nil, err
+	}
+
+	cashflow := &Cashflow{Currency: currency, Interval: request.Interval, GroupBy: request.GroupBy, TimeZone: user.TimeZone, Items: []*CashflowPeriod{}}
+	periods := map[int64]*CashflowPeriod{}
+	for _, b := range bounds {
+		period := &CashflowPeriod{Start: b[0], End: b[1], Groups: []*CashflowGroup{}, CashflowAmount: newCashflowAmount(currency)}
+		periods[b[0].Unix()] = period
+		cashflow.Items = append(cashflow.Items, period)
+	}
+
+	now := time.Now()
+	rates := map[int64]map[domain.Currency]*big.Rat{}
+	for _, t := range totals {
+		period, ok := periods[t.Start.Unix()]
+		if !ok {
+			continue
+		}
+
+		if rates[t.Start.Unix()] == nil {
+			rates[t.Start.Unix()] = map[domain.Currency]*big.Rat{}
+		}
+		amount, _, err := s.convert(ctx, user.Id, rates[t.Start.Unix()], t.Amount, currency, reportRateDate(period.End, now))
+		if err != nil {
+			return nil, err
+		}
+
+		group := period.group(t.GroupId, names, currency)
+		err = group.add(t.Type, amount, t.Transfer)
+		if err != nil {
+			return nil, err
+		}
+
+		err = period.add(t.Type, amount, t.Transfer)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, period := range cashflow.Items {
+		sort.SliceStable(period.Groups, func(i, j int) bool {
+			// the transactions without a category come last
+			if (period.Groups[i].Id == nil) != (period.Groups[j].Id == nil) {
+				return period.Groups[j].Id == nil
+			}
+			return period.Groups[i].Name < period.Groups[j].Name
+		})
+	}
+
+	return cashflow, nil
+}
+
+// groupNames returns the names of the wallets or the categories of the user.
+func (s *reportService) groupNames(ctx context.Context, userId uuid.UUID, groupBy domain.ReportGroup) (map[uuid.UUID]string, error) {
+	names := map[uuid.UUID]string{}
+	if groupBy == domain.ReportGroupCategory() {
+		categories, err := s.repo.Category().FindByUserId(ctx, userId)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range categories {
+			names[c.Id] = c.Name
+		}
+
+		return names, nil
+	}
+
+	wallets, err := s.repo.Wallet().FindByUserId(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, w := range wallets {
+		names[w.Id] = w.Name
+	}
+
+	return names, nil
+}
+
+func newCashflowAmount(currency domain.Currency) CashflowAmount {
+	zero := domain.ZeroMoney(currency)
+
+	return CashflowAmount{In: zero, Out: zero, Transfer: zero, Net: zero}
+}
+
+func (a *CashflowAmount) add(t domain.TransactionType, amount domain.Money, transfer bool) (err error) {
+	change := amount
+	if t.IsOut() {
+		change, err = domain.ZeroMoney(amount.Currency()).Sub(amount)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case transfer:
+		a.Transfer, err = a.Transfer.Add(change)
+	case t.IsIn():
+		a.In, err = a.In.Add(amount)
+	default:
+		a.Out, err = a.Out.Add(amount)
+	}
+	if err != nil {
+		return err
+	}
+
+	a.Net, err = a.Net.Add(change)
+	return err
+}
+
+// group returns the group of the period with the id, it is added when missing.
+func (p *CashflowPeriod) group(id *uuid.UUID, names map[uuid.UUID]string, currency domain.Currency) *CashflowGroup {
+	for _, g := range p.Groups {
+		if (g.Id == nil && id == nil) || (g.Id != nil && id != nil && *g.Id == *id) {
+			return g
+		}
+	}
+
+	g := &CashflowGroup{Id: id, CashflowAmount: newCashflowAmount(currency)}
+	if id != nil {
+		g.Name = names[*id]
+	}
+	p.Groups = append(p.Groups, g)
+
+	return g
+}
+
+// WalletBalance returns the balance of the wallet at a time, rebuilt from the transactions made since.
+func (s *reportService) WalletBalance(ctx context.Context, request *WalletBalanceRequest) (*WalletBalance, error) {
+	user, err := s.getUser(ctx, request.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	wallet, err := s.getWallet(ctx, request.WalletId, user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.At == nil {
+		return &WalletBalance{Wallet: wallet, At: time.Now().In(user.TimeZone), Balance: wallet.Balance}, nil
+	}
+	at := inZone(*request.At, user.TimeZone)
+
+	changes, err := s.repo.Report().WalletChangesAt(ctx, wallet.Id, at.UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := newWalletLedger(wallet, changes)
+	if err != nil {
+		return nil, err
+	}
+
+	balance, err := l.balanceAt(at)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WalletBalance{Wallet: wallet, At: at, Balance: balance}, nil
+}
+
+// WalletBalanceHistory returns the balance of the wallet at the end of every interval between the dates.
+func (s *reportService) WalletBalanceHistory(ctx context.Context, request *WalletBalanceHistoryRequest) (*WalletBalanceHistory, error) {
+	user, err := s.getUser(ctx, request.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	wallet, err := s.getWallet(ctx, request.WalletId, user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds, err := reportIntervals(request.Interval, request.DateFrom, request.DateTo, user.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := s.repo.Report().WalletChanges(ctx, &WalletChangesFilter{UserId: user.Id, WalletId: &wallet.Id, Interval: request.Interval, Location: user.TimeZone})
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := newWalletLedger(wallet, changes)
+	if err != nil {
+		return nil, err
+	}
+
+	history := &WalletBalanceHistory{Wallet: wallet, Interval: request.Interval, Points: []*WalletBalancePoint{}}
+	history.Opening, err = l.balanceAt(bounds[0][0])
+	if err != nil {
+		return nil, err
+	}
+
+	previous := history.Opening
+	for _, b := range bounds {
+		point := &WalletBalancePoint{Start: b[0], End: b[1]}
+		point.Balance, err = l.balanceAt(b[1])
+		if err != nil {
+			return nil, err
+		}
+
+		point.Change, err = point.Balance.Sub(previous)
+		if err != nil {
+			return nil, err
+		}
+		previous = point.Balance
+
+		history.Points = append(history.Points, point)
+	}
+
+	return history, nil
+}
+
+func (s *reportService) getWallet(ctx context.Context, walletId, userId uuid.UUID) (*domain.Wallet, error) {
+	wallet, err := s.repo.Wallet().GetByIdAndUserId(ctx, walletId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if wallet == nil {
+		return nil, ErrWalletNotFound
+	}
+
+	return wallet, nil
+}
+
+// convert converts the amount at the rate of the day, the rates found are kept in rates.
+func (s *reportService) convert(ctx context.Context, userId uuid.UUID, rates map[domain.Currency]*big.Rat, amount domain.Money, to domain.Currency, date time.Time) (domain.Money, *big.Rat, error) {
+	if amount.Currency() == to {
+		return amount, big.NewRat(1, 1), nil
+	}
+
+	// an empty wallet needs no rate
+	if amount.IsZero() {
+		return domain.ZeroMoney(to), nil, nil
+	}
+
+	rate, ok := rates[amount.Currency()]
+	if !ok {
+		exchangeRate, err := s.exchange.GetRate(ctx, &ExchangeRateGetRequest{UserId: userId, Base: amount.Currency(), Quote: to, Date: date})
+		if err != nil {
+			return domain.Money{}, nil, err
+		}
+		rate = exchangeRate.Rate
+		rates[amount.Currency()] = rate
+	}
+
+	converted, err := domain.MoneyFromRatRounded(new(big.Rat).Mul(amount.Rat(), rate), to)
+	if err != nil {
+		return domain.Money{}, nil, err
+	}
+
+	return converted, rate, nil
+}
+
+func (s *reportService) getUser(ctx context.Context, userId uuid.UUID) (*domain.User, error) {
+	user, err := s.repo.User().GetById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	return user, nil
+}
+
+// walletLedger rebuilds past balances of a wallet from its current balance.
+// The opening balance the wallet was created with is the part of the
+// balance no transaction explains, it counts from the creation time.
+type walletLedger struct {
+	wallet  *domain.Wallet
+	opening domain.Money
+	changes []*WalletChange
+}
+
+func (s *reportService) walletLedgers(ctx context.Context, userId uuid.UUID, interval domain.ReportInterval, loc *time.Location) ([]*walletLedger, error) {
+	wallets, err := s.repo.Wallet().FindByUserId(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := s.repo.Report().WalletChanges(ctx, &WalletChangesFilter{UserId: userId, Interval: interval, Location: loc})
+	if err != nil {
+		return nil, err
+	}
+
+	byWallet := map[uuid.UUID][]*WalletChange{}
+	for _, c := range changes {
+		byWallet[c.WalletId] = append(byWallet[c.WalletId], c)
+	}
+
+	ledgers := []*walletLedger{}
+	for _, w := range wallets {
+		l, err := newWalletLedger(w, byWallet[w.Id])
+		if err != nil {
+			return nil, err
+		}
+
+		ledgers = append(ledgers, l)
+	}
+
+	return ledgers, nil
+}
+
+func newWalletLedger(wallet *domain.Wallet, changes []*WalletChange) (*walletLedger, error) {
+	l := &walletLedger{wallet: wallet, opening: wallet.Balance, changes: changes}
+	sort.Slice(l.changes, func(i, j int) bool {
+		return l.changes[i].Start.Before(l.changes[j].Start)
+	})
+
+	var err error
+	for _, c := range l.changes {
+		l.opening, err = l.opening.Sub(c.Amount)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return l, nil
+}
+
+// exists reports whether the wallet had a balance before end: it was created or got a transaction.
+func (l *walletLedger) exists(end time.Time) bool {
+	return l.wallet.CreatedAt.Before(end) || (len(l.changes) > 0 && l.changes[0].Start.Before(end))
+}
+
+// balanceAt returns the balance before end, the changes of the intervals
+// starting at or after end are taken back from the current balance. end
+// must be an interval boundary of the changes.
+func (l *walletLedger) balanceAt(end time.Time) (domain.Money, error) {
+	balance := l.wallet.Balance
+
+	var err error
+	for _, c := range l.changes {
+		if c.Start.Before(end) {
+			continue
+		}
+
+		balance, err = balance.Sub(c.Amount)
+		if err != nil {
+			return domain.Money{}, err
+		}
+	}
+
+	if !l.wallet.CreatedAt.Before(end) {
+		return balance.Sub(l.opening)
+	}
+
+	return balance, nil
+}
+
+// reportIntervals returns the start and end of the intervals between the dates, the dates are days in loc.
+func reportIntervals(interval domain.ReportInterval, from, to time.Time, loc *time.Location) ([][2]time.Time, error) {
+	if to.Before(from) {
+		return nil, domain.ErrInvalidReportRange
+	}
+
+	bounds := [][2]time.Time{}
+	last := inZone(to, loc)
+	start, end := interval.Bounds(inZone(from, loc))
+	for !start.After(last) {
+		if len(bounds) == REPORT_MAX_INTERVALS {
+			return nil, domain.ErrReportRangeTooLarge
+		}
+
+		bounds = append(bounds, [2]time.Time{start, end})
+		start, end = interval.Bounds(end)
+	}
+
+	return bounds, nil
+}
+
+// reportRateDate is the last moment before end, rates of the future are not known yet.
+func reportRateDate(end, now time.Time) time.Time {
+	if end.After(now) {
+		return now
+	}
+
+	return end.Add(-time.Nanosecond)
+}
+
+// inZone reads the date and clock of t as a time in loc, a date parsed without a zone is the same date in loc.
+func inZone(t time.Time, loc *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+}
@@ -0,0 +1,339 @@
+package service
+
+import (
+	"context"
+	"github.com/IMBgl/go-wallet-api/internal/domain"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func TestWalletLedgerBalanceAt(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC)
+	}
+	usd := func(minor int64) domain.Money {
+		return domain.NewMoney(minor, domain.CurrencyUSD())
+	}
+
+	// opened with 100.00 on the 5th, +50.00 on the 3rd (imported), -30.00 on the 10th
+	wallet := &domain.Wallet{Balance: usd(12000), CreatedAt: day(5).Add(time.Hour)}
+	l := &walletLedger{wallet: wallet, opening: usd(10000), changes: []*WalletChange{
+		{Start: day(3), Amount: usd(5000)},
+		{Start: day(10), Amount: usd(-3000)},
+	}}
+
+	tests := []struct {
+		end    time.Time
+		want   int64
+		exists bool
+	}{
+		{day(3), 0, false},
+		{day(4), 5000, true},
+		{day(6), 15000, true},
+		{day(11), 12000, true},
+	}
+
+	for _, tt := range tests {
+		if got := l.exists(tt.end); got != tt.exists {
+			t.Errorf("exists(%s) = %v, want %v", tt.end.Format("2006-01-02"), got, tt.exists)
+		}
+
+		balance, err := l.balanceAt(tt.end)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if balance.Minor() != tt.want {
+			t.Errorf("balanceAt(%s) = %s, want %d", tt.end.Format("2006-01-02"), balance, tt.want)
+		}
+	}
+}
+
+func TestReportIntervals(t *testing.T) {
+	from := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	bounds, err := reportIntervals(domain.ReportIntervalMonth(), from, to, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bounds) != 3 || !bounds[0][0].Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) || !bounds[2][1].Equal(time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("reportIntervals(month) = %v", bounds)
+	}
+
+	bounds, err = reportIntervals(domain.ReportIntervalWeek(), from, from, time.FixedZone("CET", 3600))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bounds) != 1 || !bounds[0][0].Equal(time.Date(2024, 1, 14, 23, 0, 0, 0, time.UTC)) {
+		t.Errorf("reportIntervals(week, CET) = %v", bounds)
+	}
+
+	if _, err := reportIntervals(domain.ReportIntervalDay(), to, from, time.UTC); err != domain.ErrInvalidReportRange {
+		t.Errorf("reversed range err = %v", err)
+	}
+
+	if _, err := reportIntervals(domain.ReportIntervalDay(), from, from.AddDate(2, 0, 0), time.UTC); err != domain.ErrReportRangeTooLarge {
+		t.Errorf("long range err = %v", err)
+	}
+}
+
+func TestPreviousPeriod(t *testing.T) {
+	date := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		from, to time.Time
+		want     time.Time
+	}{
+		{date(2024, 3, 1), date(2024, 4, 1), date(2024, 2, 1)},
+		{date(2024, 1, 1), date(2024, 4, 1), date(2023, 10, 1)},
+		{date(2024, 3, 10), date(2024, 3, 17), date(2024, 3, 3)},
+	}
+
+	for _, tt := range tests {
+		from, to := previousPeriod(tt.from, tt.to)
+		if !from.Equal(tt.want) || !to.Equal(tt.from) {
+			t.Errorf("previousPeriod(%s, %s) = %s, %s", tt.from.Format("2006-01-02"), tt.to.Format("2006-01-02"), from.Format("2006-01-02"), to.Format("2006-01-02"))
+		}
+	}
+}
+
+func TestRollUpCategory(t *testing.T) {
+	usd := func(minor int64) domain.Money {
+		return domain.NewMoney(minor, domain.CurrencyUSD())
+	}
+
+	food := &domain.Category{Id: uuid.New(), Name: "Food"}
+	cafe := &domain.Category{Id: uuid.New(), Name: "Cafe", ParentId: &food.Id}
+	roots, err := categoryReportTrees([]*domain.Category{cafe, food}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	current := map[uuid.UUID]*[2]domain.Money{
+		food.Id: {usd(0), usd(3000)},
+		cafe.Id: {usd(0), usd(1000)},
+	}
+	previous := map[uuid.UUID]*[2]domain.Money{
+		cafe.Id: {usd(0), usd(2000)},
+	}
+
+	if len(roots) != 1 || len(roots[0].Children) != 1 {
+		t.Fatalf("categoryReportTrees() = %v", roots)
+	}
+	root := roots[0]
+	if err := rollUpCategory(root, current, previous, domain.CurrencyUSD()); err != nil {
+		t.Fatal(err)
+	}
+	compareCategory(root, root.In.Total, root.Out.Total)
+
+	child := root.Children[0].Out
+	if root.Out.Own.Minor() != 3000 || root.Out.Total.Minor() != 4000 || root.Out.Previous.Minor() != 2000 {
+		t.Errorf("root out = %s own, %s total, %s previous", root.Out.Own, root.Out.Total, root.Out.Previous)
+	}
+	if child.Percent.FloatString(2) != "25.00" || child.ChangePercent.FloatString(2) != "-50.00" {
+		t.Errorf("child out percent = %s, change = %s", child.Percent.FloatString(2), child.ChangePercent.FloatString(2))
+	}
+	if root.In.ChangePercent != nil {
+		t.Errorf("root in change percent = %s, want none", root.In.ChangePercent.FloatString(2))
+	}
+}
+
+func TestCashflowAmountAdd(t *testing.T) {
+	a := newCashflowAmount(domain.CurrencyUSD())
+	for _, change := range []struct {
+		t        domain.TransactionType
+		amount   int64
+		transfer bool
+	}{
+		{domain.TransactionTypeIn(), 10000, false},
+		{domain.TransactionTypeOut(), 2500, false},
+		{domain.TransactionTypeOut(), 500, false},
+		{domain.TransactionTypeOut(), 4000, true},
+		{domain.TransactionTypeIn(), 1000, true},
+	} {
+		if err := a.add(change.t, domain.NewMoney(change.amount, domain.CurrencyUSD()), change.transfer); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if a.In.Minor() != 10000 || a.Out.Minor() != 3000 || a.Transfer.Minor() != -3000 || a.Net.Minor() != 4000 {
+		t.Errorf("cashflow = %s in, %s out, %s transfer, %s net", a.In, a.Out, a.Transfer, a.Net)
+	}
+}
+
+func TestWalletLedgerBalanceAtTime(t *testing.T) {
+	usd := func(minor int64) domain.Money {
+		return domain.NewMoney(minor, domain.CurrencyUSD())
+	}
+	created := time.Date(2024, 1, 5, 10, 0, 0, 0, time.UTC)
+	at := time.Date(2024, 3, 31, 22, 0, 0, 0, time.UTC)
+
+	// opened with 100.00, +20.00 before at and -50.00 after it, split as WalletChangesAt returns them
+	wallet := &domain.Wallet{Balance: usd(7000), CreatedAt: created}
+	l, err := newWalletLedger(wallet, []*WalletChange{
+		{Start: at.Add(time.Hour), Amount: usd(-5000)},
+		{Start: created.Add(time.Hour), Amount: usd(2000)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l.opening.Minor() != 10000 {
+		t.Errorf("opening = %s, want 100.00", l.opening)
+	}
+
+	for _, tt := range []struct {
+		at   time.Time
+		want int64
+	}{
+		{at, 12000},
+		{created, 0},
+	} {
+		balance, err := l.balanceAt(tt.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if balance.Minor() != tt.want {
+			t.Errorf("balanceAt(%s) = %s, want %d", tt.at, balance, tt.want)
+		}
+	}
+}
+
+// reportTestRepository serves a single user and records the filters the
+// reports query with, the repositories a test does not need stay nil.
+type reportTestRepository struct {
+	Repository
+	user   *domain.User
+	wallet *domain.Wallet
+	report *reportTestReportRepository
+}
+
+func (r *reportTestRepository) User() UserRepository { return &reportTestUserRepository{user: r.user} }
+func (r *reportTestRepository) Wallet() WalletRepository {
+	return &reportTestWalletRepository{wallet: r.wallet}
+}
+func (r *reportTestRepository) Category() CategoryRepository { return &reportTestCategoryRepository{} }
+func (r *reportTestRepository) Report() ReportRepository     { return r.report }
+
+type reportTestUserRepository struct {
+	UserRepository
+	user *domain.User
+}
+
+func (r *reportTestUserRepository) GetById(ctx context.Context, id uuid.UUID) (*domain.User, error) {
+	return r.user, nil
+}
+
+type reportTestWalletRepository struct {
+	WalletRepository
+	wallet *domain.Wallet
+}
+
+func (r *reportTestWalletRepository) GetByIdAndUserId(ctx context.Context, id, userId uuid.UUID) (*domain.Wallet, error) {
+	return r.wallet, nil
+}
+
+func (r *reportTestWalletRepository) FindByUserId(ctx context.Context, userId uuid.UUID) ([]*domain.Wallet, error) {
+	return []*domain.Wallet{}, nil
+}
+
+type reportTestCategoryRepository struct {
+	CategoryRepository
+}
+
+func (r *reportTestCategoryRepository) FindByUserId(ctx context.Context, userId uuid.UUID) ([]*domain.Category, error) {
+	return []*domain.Category{}, nil
+}
+
+type reportTestReportRepository struct {
+	ReportRepository
+	cashflowFilter  *CashflowFilter
+	categoryFilters []*CategoryTotalsFilter
+	changesAt       []*WalletChange
+	at              time.Time
+}
+
+func (r *reportTestReportRepository) WalletChangesAt(ctx context.Context, walletId uuid.UUID, at time.Time) ([]*WalletChange, error) {
+	r.at = at
+	return r.changesAt, nil
+}
+
+func (r *reportTestReportRepository) Cashflow(ctx context.Context, filter *CashflowFilter) ([]*CashflowTotal, error) {
+	r.cashflowFilter = filter
+	return []*CashflowTotal{}, nil
+}
+
+func (r *reportTestReportRepository) CategoryTotals(ctx context.Context, filter *CategoryTotalsFilter) ([]*CategoryTotal, error) {
+	r.categoryFilters = append(r.categoryFilters, filter)
+	return []*CategoryTotal{}, nil
+}
+
+func TestReportBoundsInUserTimeZone(t *testing.T) {
+	user := domain.NewUser("reporter", "reporter@example.com", "")
+	user.TimeZone = time.FixedZone("CET", 3600)
+	repo := &reportTestReportRepository{}
+	s := NewReportService(&reportTestRepository{user: user, report: repo}, nil)
+
+	january := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	wantFrom := time.Date(2023, 12, 31, 23, 0, 0, 0, time.UTC)
+	wantTo := time.Date(2024, 1, 31, 23, 0, 0, 0, time.UTC)
+
+	_, err := s.Cashflow(context.Background(), &CashflowRequest{
+		UserId:   user.Id,
+		DateFrom: january,
+		DateTo:   january.AddDate(0, 0, 30),
+		Interval: domain.ReportIntervalMonth(),
+		GroupBy:  domain.ReportGroupWallet(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// != compares the locations too, the bounds must be UTC
+	f := repo.cashflowFilter
+	if f.DateFrom != wantFrom || f.DateTo != wantTo {
+		t.Errorf("Cashflow filter = %s - %s, want %s - %s", f.DateFrom, f.DateTo, wantFrom, wantTo)
+	}
+
+	_, err = s.CategoryReport(context.Background(), &CategoryReportRequest{UserId: user.Id, DateFrom: january, DateTo: january.AddDate(0, 1, 0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := repo.categoryFilters[0]
+	if c.DateFrom != wantFrom || c.DateTo != wantTo {
+		t.Errorf("CategoryTotals filter = %s - %s, want %s - %s", c.DateFrom, c.DateTo, wantFrom, wantTo)
+	}
+}
+
+func TestWalletBalanceAtInUserTimeZone(t *testing.T) {
+	usd := func(minor int64) domain.Money {
+		return domain.NewMoney(minor, domain.CurrencyUSD())
+	}
+
+	user := domain.NewUser("reporter", "reporter@example.com", "")
+	user.TimeZone = time.FixedZone("EST", -5*3600)
+	wallet := &domain.Wallet{Id: uuid.New(), Balance: usd(5000), CreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	// opened with 100.00, -50.00 at 2024-03-31 20:00 local: after the end of the day in UTC but before it in EST
+	spent := time.Date(2024, 4, 1, 1, 0, 0, 0, time.UTC)
+	repo := &reportTestReportRepository{changesAt: []*WalletChange{{WalletId: wallet.Id, Start: spent, Amount: usd(-5000)}}}
+	s := NewReportService(&reportTestRepository{user: user, wallet: wallet, report: repo}, nil)
+
+	at := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	balance, err := s.WalletBalance(context.Background(), &WalletBalanceRequest{UserId: user.Id, WalletId: wallet.Id, At: &at})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := time.Date(2024, 4, 1, 5, 0, 0, 0, time.UTC)
+	if repo.at != want {
+		t.Errorf("WalletChangesAt(%s), want %s", repo.at, want)
+	}
+	if balance.Balance.Minor() != 5000 {
+		t.Errorf("WalletBalance() = %s, want 50.00", balance.Balance)
+	}
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const SCHEDULER_INTERVAL = time.Minute
+
+// Scheduler periodically posts due recurring transactions until its context is cancelled.
+type Scheduler struct {
+	recurring RecurringService
+	interval  time.Duration
+}
+
+func NewScheduler(rs RecurringService, interval time.Duration) *Scheduler {
+	if interval <= 0 {
+		interval = SCHEDULER_INTERVAL
+	}
+
+	return &Scheduler{recurring: rs, interval: interval}
+}
+
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		err := s.recurring.ProcessDue(ctx, time.Now().UTC())
+		if err != nil {
+			log.Printf("scheduler process due err %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
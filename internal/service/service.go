@@ -3,6 +3,10 @@ package service
 import (
 	"context"
 	"github.com/IMBgl/go-wallet-api/internal/domain"
+	"github.com/IMBgl/go-wallet-api/internal/export"
+	"github.com/IMBgl/go-wallet-api/pkg/jwt"
+	"io"
+	"time"
 )
 
 type service struct {
@@ -12,6 +16,15 @@ type service struct {
 	wallet      WalletService
 	category    CategoryService
 	transaction TransactionService
+	transfer    TransferService
+	budget      BudgetService
+	recurring   RecurringService
+	importer    ImportService
+	export      ExportService
+	apiKey      ApiKeyService
+	account     AccountService
+	exchange    ExchangeService
+	report      ReportService
 }
 
 type Service interface {
@@ -20,6 +33,15 @@ type Service interface {
 	Wallet() WalletService
 	Category() CategoryService
 	Transaction() TransactionService
+	Transfer() TransferService
+	Budget() BudgetService
+	Recurring() RecurringService
+	Import() ImportService
+	Export() ExportService
+	ApiKey() ApiKeyService
+	Account() AccountService
+	Exchange() ExchangeService
+	Report() ReportService
 }
 
 type Repository interface {
@@ -28,16 +50,40 @@ type Repository interface {
 	Wallet() WalletRepository
 	Category() CategoryRepository
 	Transaction() TransactionRepository
+	Transfer() TransferRepository
+	Budget() BudgetRepository
+	Recurring() RecurringRepository
+	ApiKey() ApiKeyRepository
+	TwoFactor() TwoFactorRepository
+	ActionToken() ActionTokenRepository
+	ExchangeRate() ExchangeRateRepository
+	Report() ReportRepository
 }
 
 type UserService interface {
-	SingUp(ctx context.Context, request SignUpRequest) (*domain.User, *UserToken, error)
-	SingIn(ctx context.Context, request SignInRequest) (*domain.User, *UserToken, error)
+	SingUp(ctx context.Context, request SignUpRequest) (*domain.User, *TokenPair, error)
+	SingIn(ctx context.Context, request SignInRequest) (*SignInResult, error)
+	SignInVerify(ctx context.Context, request *SignInVerifyRequest) (*SignInResult, error)
+	SignInChallengeUser(ctx context.Context, value string) (*domain.User, error)
+	SignOut(ctx context.Context, request *SignOutRequest) error
+	SignOutAll(ctx context.Context, request *SignOutAllRequest) error
+	GetSessions(ctx context.Context, request *SessionGetListRequest) ([]*UserToken, error)
+	EnrollTotp(ctx context.Context, request *TwoFactorEnrollRequest) (*TotpEnrollment, error)
+	ConfirmTotp(ctx context.Context, request *TwoFactorConfirmRequest) ([]string, error)
+	DisableTotp(ctx context.Context, request *TwoFactorDisableRequest) error
+	RegenerateRecoveryCodes(ctx context.Context, request *RecoveryCodesRegenerateRequest) ([]string, error)
+	GetProfile(ctx context.Context, request *ProfileGetRequest) (*Profile, error)
+	UpdateProfile(ctx context.Context, request *ProfileUpdateRequest) (*Profile, error)
+	ChangePassword(ctx context.Context, request *PasswordChangeRequest) error
+	RequestEmailChange(ctx context.Context, request *EmailChangeRequest) error
+	DeleteAccount(ctx context.Context, request *AccountDeleteRequest) error
 }
 
 type TokenService interface {
-	CreateForUser(u *domain.User) *UserToken
+	CreateForUser(u *domain.User) (*TokenPair, error)
+	Refresh(ctx context.Context, value string) (*TokenPair, error)
 	GetValidByValue(ctx context.Context, v string) (*UserToken, error)
+	Jwks() *jwt.Jwks
 }
 
 type WalletService interface {
@@ -57,7 +103,81 @@ type CategoryService interface {
 
 type TransactionService interface {
 	Create(ctx context.Context, request *TransactionCreateRequest) (*domain.Transaction, error)
-	GetAmount(transactionList []*domain.Transaction) float32
+	Update(ctx context.Context, request *TransactionUpdateRequest) (*domain.Transaction, error)
+	Delete(ctx context.Context, request *TransactionDeleteRequest) error
+	GetList(ctx context.Context, request *TransactionGetListRequest) (*TransactionList, error)
+	GetAmount(currency domain.Currency, transactionList []*domain.Transaction) (domain.Money, error)
+}
+
+type TransferService interface {
+	Create(ctx context.Context, request *TransferCreateRequest) (*TransferDetails, error)
+	GetList(ctx context.Context, request *TransferGetListRequest) ([]*TransferDetails, error)
+	GetOne(ctx context.Context, request *TransferGetOneRequest) (*TransferDetails, error)
+	Reverse(ctx context.Context, request *TransferReverseRequest) (*TransferDetails, error)
+}
+
+type BudgetService interface {
+	Create(ctx context.Context, request *BudgetCreateRequest) (*domain.Budget, error)
+	Update(ctx context.Context, request *BudgetUpdateRequest) (*domain.Budget, error)
+	GetList(ctx context.Context, request *BudgetGetListRequest) ([]*domain.Budget, error)
+	GetOne(ctx context.Context, request *BudgetGetOneRequest) (*domain.Budget, error)
+	Delete(ctx context.Context, request *BudgetDeleteRequest) error
+	Status(ctx context.Context, request *BudgetStatusRequest) ([]*BudgetStatus, error)
+}
+
+type ImportService interface {
+	Preview(ctx context.Context, request *ImportRequest) (*ImportPreview, error)
+	Commit(ctx context.Context, request *ImportRequest) (*ImportResult, error)
+}
+
+type ExportService interface {
+	NewWriter(request *ExportRequest, w io.Writer) (export.Writer, error)
+	Export(ctx context.Context, request *ExportRequest, writer export.Writer) error
+}
+
+type ApiKeyService interface {
+	Create(ctx context.Context, request *ApiKeyCreateRequest) (*ApiKey, error)
+	GetList(ctx context.Context, request *ApiKeyGetListRequest) ([]*ApiKey, error)
+	Revoke(ctx context.Context, request *ApiKeyRevokeRequest) error
+}
+
+type AccountService interface {
+	RequestEmailVerification(ctx context.Context, request *EmailVerificationRequest) error
+	SendEmailVerification(ctx context.Context, user *domain.User) error
+	ConfirmEmail(ctx context.Context, request *EmailConfirmRequest) (*domain.User, error)
+	RequestPasswordReset(ctx context.Context, request *PasswordResetRequest) error
+	ResetPassword(ctx context.Context, request *PasswordResetConfirmRequest) error
+	SendEmailChange(ctx context.Context, user *domain.User, email string) error
+	ConfirmEmailChange(ctx context.Context, request *EmailConfirmRequest) (*domain.User, error)
+}
+
+type ExchangeService interface {
+	SetRate(ctx context.Context, request *ExchangeRateSetRequest) (*domain.ExchangeRate, error)
+	GetList(ctx context.Context, request *ExchangeRateGetListRequest) ([]*domain.ExchangeRate, error)
+	Delete(ctx context.Context, request *ExchangeRateDeleteRequest) error
+	GetRate(ctx context.Context, request *ExchangeRateGetRequest) (*domain.ExchangeRate, error)
+	Convert(ctx context.Context, request *ConvertRequest) (*Conversion, error)
+}
+
+type ReportService interface {
+	NetWorth(ctx context.Context, request *NetWorthRequest) (*NetWorth, error)
+	NetWorthHistory(ctx context.Context, request *NetWorthHistoryRequest) (*NetWorthHistory, error)
+	CategoryReport(ctx context.Context, request *CategoryReportRequest) (*CategoryReport, error)
+	Cashflow(ctx context.Context, request *CashflowRequest) (*Cashflow, error)
+	WalletBalance(ctx context.Context, request *WalletBalanceRequest) (*WalletBalance, error)
+	WalletBalanceHistory(ctx context.Context, request *WalletBalanceHistoryRequest) (*WalletBalanceHistory, error)
+}
+
+type RecurringService interface {
+	Create(ctx context.Context, request *RecurringCreateRequest) (*domain.RecurringTransaction, error)
+	Update(ctx context.Context, request *RecurringUpdateRequest) (*domain.RecurringTransaction, error)
+	GetList(ctx context.Context, request *RecurringGetListRequest) ([]*domain.RecurringTransaction, error)
+	GetOne(ctx context.Context, request *RecurringGetOneRequest) (*domain.RecurringTransaction, error)
+	Delete(ctx context.Context, request *RecurringDeleteRequest) error
+	Preview(ctx context.Context, request *RecurringPreviewRequest) ([]*Occurrence, error)
+	Skip(ctx context.Context, request *OccurrenceSkipRequest) (*Occurrence, error)
+	UpdateOccurrence(ctx context.Context, request *OccurrenceUpdateRequest) (*Occurrence, error)
+	ProcessDue(ctx context.Context, at time.Time) error
 }
 
 func (s *service) User() UserService {
@@ -80,12 +200,62 @@ func (s *service) Transaction() TransactionService {
 	return s.transaction
 }
 
-func New(repo Repository) *service {
-	ts := &tokenServiсe{repo: repo}
-	us := NewUserService(repo, ts)
+func (s *service) Transfer() TransferService {
+	return s.transfer
+}
+
+func (s *service) Budget() BudgetService {
+	return s.budget
+}
+
+func (s *service) Recurring() RecurringService {
+	return s.recurring
+}
+
+func (s *service) Import() ImportService {
+	return s.importer
+}
+
+func (s *service) Export() ExportService {
+	return s.export
+}
+
+func (s *service) ApiKey() ApiKeyService {
+	return s.apiKey
+}
+
+func (s *service) Account() AccountService {
+	return s.account
+}
+
+func (s *service) Exchange() ExchangeService {
+	return s.exchange
+}
+
+func (s *service) Report() ReportService {
+	return s.report
+}
+
+func New(repo Repository, config *Config) *service {
+	if config == nil {
+		config = DefaultConfig()
+	}
+
+	ts := NewTokenService(repo, config.Token, config.Jwt)
+	ph := NewPasswordHasher(config.Password)
+	as := NewAccountService(repo, ph, NewMailer(config.Mail), config.Account)
+	us := NewUserService(repo, ts, ph, config.TwoFactor, as)
 	ws := NewWalletService(repo)
 	cs := NewCategoryService(repo)
-	trs := NewTransactionService(repo)
+	xs := NewExchangeService(repo, config.ExchangeRate)
+	trs := NewTransactionService(repo, xs)
+	tfs := NewTransferService(repo)
+	bs := NewBudgetService(repo, xs)
+	rs := NewRecurringService(repo, trs)
+	is := NewImportService(repo)
+	es := NewExportService(repo)
+	aks := NewApiKeyService(repo)
+	rps := NewReportService(repo, xs)
 
 	return &service{
 		repo:        repo,
@@ -94,5 +264,14 @@ func New(repo Repository) *service {
 		wallet:      ws,
 		category:    cs,
 		transaction: trs,
+		transfer:    tfs,
+		budget:      bs,
+		recurring:   rs,
+		importer:    is,
+		export:      es,
+		apiKey:      aks,
+		account:     as,
+		exchange:    xs,
+		report:      rps,
 	}
 }
@@ -2,78 +2,303 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"github.com/IMBgl/go-wallet-api/internal/domain"
+	"github.com/IMBgl/go-wallet-api/pkg/jwt"
 	"github.com/google/uuid"
-	"math/rand"
+	"log"
+	"strings"
 	"time"
 )
 
-const TOKEN_LENGHT = 50
+// TOKEN_LENGHT is the number of random bytes in a token value.
+const TOKEN_LENGHT = 32
+
+const (
+	TOKEN_KIND_ACCESS  = "access"
+	TOKEN_KIND_REFRESH = "refresh"
+)
+
+// SCOPE_ALL is the scope of the tokens issued by sign in.
+const SCOPE_ALL = "*"
+
+const (
+	DEFAULT_ACCESS_TOKEN_LIFETIME  = time.Minute * 15
+	DEFAULT_REFRESH_TOKEN_LIFETIME = time.Hour * 24 * 30
+	DEFAULT_REFRESH_REUSE_WINDOW   = time.Hour * 24
+)
+
+var ErrInvalidRefreshToken = errors.New("Invalid refresh token")
+var ErrRefreshTokenReused = errors.New("Refresh token was already used, the session is revoked")
+
+// TokenConfig sets how long issued tokens live. Every refresh issues a new
+// refresh token, so a session lasts while it is used at least once per
+// RefreshTokenLifetime. A used refresh token is kept for RefreshReuseWindow
+// to detect its reuse, later it is just an invalid token.
+type TokenConfig struct {
+	AccessTokenLifetime  time.Duration
+	RefreshTokenLifetime time.Duration
+	RefreshReuseWindow   time.Duration
+}
+
+func DefaultTokenConfig() TokenConfig {
+	return TokenConfig{
+		AccessTokenLifetime:  DEFAULT_ACCESS_TOKEN_LIFETIME,
+		RefreshTokenLifetime: DEFAULT_REFRESH_TOKEN_LIFETIME,
+		RefreshReuseWindow:   DEFAULT_REFRESH_REUSE_WINDOW,
+	}
+}
 
 type tokenServiсe struct {
-	repo Repository
+	repo   Repository
+	config TokenConfig
+	jwt    JwtConfig
 }
 
+// UserToken is an opaque API token. Only Hash is stored, Value is known
+// right after the token is created and is never loaded back.
+// Tokens issued by one sign in and all its refreshes share FamilyId.
+// Stateless tokens are JWTs that are never stored.
 type UserToken struct {
-	Id        uuid.UUID
-	UserId    uuid.UUID
+	Id       uuid.UUID
+	UserId   uuid.UUID
+	Kind     string
+	FamilyId uuid.UUID
+	Scopes   []string
+	// WalletIds restrict an api key to these wallets when not empty
+	WalletIds []uuid.UUID
+	Stateless bool
 	Exp       time.Time
+	UsedAt    *time.Time
 	Value     string
+	Hash      string
 	CreatedAt time.Time
 }
 
+// TokenPair is a short-lived access token and the refresh token to get the next pair.
+type TokenPair struct {
+	Access  *UserToken
+	Refresh *UserToken
+}
+
+// Stored returns the tokens of the pair to save.
+func (p *TokenPair) Stored() []*UserToken {
+	if p.Access.Stateless {
+		return []*UserToken{p.Refresh}
+	}
+
+	return []*UserToken{p.Access, p.Refresh}
+}
+
 type TokenRepository interface {
 	GetById(ctx context.Context, id uuid.UUID) (*UserToken, error)
+	GetByIdAndUserId(ctx context.Context, id, userId uuid.UUID) (*UserToken, error)
+	// GetByValue finds the token by the hash of value
 	GetByValue(ctx context.Context, value string) (*UserToken, error)
 	FindByUser(ctx context.Context, user *domain.User) ([]*UserToken, error)
-	Save(ctx context.Context, token *UserToken) error
+	Save(ctx context.Context, tokens ...*UserToken) error
 	Delete(ctx context.Context, token *UserToken) error
-	DeleteAllForUserAndSave(ctx context.Context, user *domain.User, token *UserToken) error
+	DeleteFamily(ctx context.Context, userId, familyId uuid.UUID) error
+	DeleteAllForUser(ctx context.Context, userId uuid.UUID) error
+	DeleteAllForUserAndSave(ctx context.Context, user *domain.User, tokens ...*UserToken) error
+	// Rotate marks the refresh token used and saves the next pair of its family,
+	// the tokens of the family used before usedBefore are deleted.
+	// It returns ErrRefreshTokenReused if the token was used concurrently.
+	Rotate(ctx context.Context, used *UserToken, pair *TokenPair, usedBefore time.Time) error
 }
 
-func NewTokenService(r Repository) *tokenServiсe {
-	return &tokenServiсe{repo: r}
+func NewTokenService(r Repository, c TokenConfig, j JwtConfig) *tokenServiсe {
+	d := DefaultTokenConfig()
+	if c.AccessTokenLifetime <= 0 {
+		c.AccessTokenLifetime = d.AccessTokenLifetime
+	}
+	if c.RefreshTokenLifetime <= 0 {
+		c.RefreshTokenLifetime = d.RefreshTokenLifetime
+	}
+	if c.RefreshReuseWindow <= 0 {
+		c.RefreshReuseWindow = d.RefreshReuseWindow
+	}
+
+	return &tokenServiсe{repo: r, config: c, jwt: j}
 }
 
-func NewUserToken(value string, userId uuid.UUID) *UserToken {
+func NewUserToken(value string, userId uuid.UUID, kind string, familyId uuid.UUID, lifetime time.Duration) *UserToken {
 	return &UserToken{
 		Id:        uuid.New(),
 		UserId:    userId,
-		Exp:       time.Now().Add(time.Hour * 24),
+		Kind:      kind,
+		FamilyId:  familyId,
+		Scopes:    []string{SCOPE_ALL},
+		Exp:       time.Now().Add(lifetime),
 		Value:     value,
+		Hash:      HashTokenValue(value),
 		CreatedAt: time.Now(),
 	}
 }
 
-func (s *tokenServiсe) CreateForUser(u *domain.User) *UserToken {
-	return NewUserToken(GenerateTokenValue(), u.Id)
+// CreateForUser creates the token pair of a new session.
+func (s *tokenServiсe) CreateForUser(u *domain.User) (*TokenPair, error) {
+	return s.createPair(u.Id, uuid.New())
+}
+
+func (s *tokenServiсe) createPair(userId, familyId uuid.UUID) (*TokenPair, error) {
+	var access *UserToken
+	if s.jwtEnabled() {
+		token, err := s.newJwtAccessToken(userId, familyId, []string{SCOPE_ALL})
+		if err != nil {
+			return nil, err
+		}
+		access = token
+	} else {
+		accessValue, err := GenerateTokenValue()
+		if err != nil {
+			return nil, err
+		}
+		access = NewUserToken(accessValue, userId, TOKEN_KIND_ACCESS, familyId, s.config.AccessTokenLifetime)
+	}
+
+	refreshValue, err := GenerateTokenValue()
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		Access:  access,
+		Refresh: NewUserToken(refreshValue, userId, TOKEN_KIND_REFRESH, familyId, s.config.RefreshTokenLifetime),
+	}, nil
+}
+
+// Refresh exchanges a refresh token for a new pair. A refresh token is
+// accepted once, presenting it again means it leaked, so the whole family
+// is revoked and both the attacker and the user have to sign in again.
+func (s *tokenServiсe) Refresh(ctx context.Context, value string) (*TokenPair, error) {
+	token, err := s.repo.Token().GetByValue(ctx, value)
+	if err != nil {
+		return nil, err
+	}
+
+	if token == nil || token.Kind != TOKEN_KIND_REFRESH || !token.Matches(value) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if token.UsedAt != nil {
+		return nil, s.revokeFamily(ctx, token)
+	}
+
+	if !token.IsValid() {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	pair, err := s.createPair(token.UserId, token.FamilyId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repo.Token().Rotate(ctx, token, pair, time.Now().Add(-s.config.RefreshReuseWindow))
+	if err == ErrRefreshTokenReused {
+		return nil, s.revokeFamily(ctx, token)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return pair, nil
+}
+
+func (s *tokenServiсe) revokeFamily(ctx context.Context, token *UserToken) error {
+	log.Printf("refresh token %s of user %s reused, revoking family %s", token.Id, token.UserId, token.FamilyId)
+	err := s.repo.Token().DeleteFamily(ctx, token.UserId, token.FamilyId)
+	if err != nil {
+		return err
+	}
+
+	return ErrRefreshTokenReused
 }
 
-func GenerateTokenValue() string {
-	h := sha256.New()
+func GenerateTokenValue() (string, error) {
 	b := make([]byte, TOKEN_LENGHT)
-	rand.Read(b)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
 
-	hash := hex.EncodeToString(h.Sum(b))
+// HashTokenValue returns the stored form of a token value. Token values are
+// random, so a fast hash is enough to make a leaked table useless.
+func HashTokenValue(value string) string {
+	h := sha256.Sum256([]byte(value))
 
-	return hash
+	return hex.EncodeToString(h[:])
 }
 
+// GetValidByValue returns a not expired access token, refresh tokens do not authenticate requests.
+// In JWT mode a signed token is verified without the database, opaque tokens keep working.
 func (s *tokenServiсe) GetValidByValue(ctx context.Context, v string) (*UserToken, error) {
+	if s.jwtEnabled() && jwt.IsJwt(v) {
+		return s.parseJwtAccessToken(v)
+	}
+
+	if strings.HasPrefix(v, API_KEY_PREFIX) {
+		return s.getApiKeyToken(ctx, v)
+	}
+
 	token, err := s.repo.Token().GetByValue(ctx, v)
 	if err != nil {
 		return nil, err
 	}
 
-	if token == nil || !token.IsValid() {
+	if token == nil || token.Kind != TOKEN_KIND_ACCESS || !token.IsValid() {
 		return nil, ErrNotFound
 	}
 
+	// session tokens grant every scope
+	token.Scopes = []string{SCOPE_ALL}
+
 	return token, nil
 }
 
+// Matches compares the value with the stored hash in constant time.
+func (t *UserToken) Matches(value string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashTokenValue(value)), []byte(t.Hash)) == 1
+}
+
+// HasScope reports whether the token grants scope.
+func (t *UserToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == SCOPE_ALL || s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AllowsWallet reports whether the token may access the wallet.
+func (t *UserToken) AllowsWallet(walletId uuid.UUID) bool {
+	return walletAllowed(t.WalletIds, walletId)
+}
+
+// IsWalletRestricted reports whether the token is limited to some of the wallets of the user.
+func (t *UserToken) IsWalletRestricted() bool {
+	return len(t.WalletIds) > 0
+}
+
+func (t *UserToken) IsApiKey() bool {
+	return t.Kind == TOKEN_KIND_API_KEY
+}
+
 func (t *UserToken) IsValid() bool {
 	return t.Exp.After(time.Now())
 }
+
+// IsActiveSession reports whether the token is the current refresh token of its family.
+func (t *UserToken) IsActiveSession() bool {
+	return t.Kind == TOKEN_KIND_REFRESH && t.UsedAt == nil && t.IsValid()
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTotpCode(t *testing.T) {
+	// RFC 6238 appendix B, SHA1 secret, last 6 of the 8 digit values
+	secret := []byte("12345678901234567890")
+	cases := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1234567890:  "005924",
+		20000000000: "353130",
+	}
+
+	for unix, expected := range cases {
+		if code := totpCode(secret, totpStep(time.Unix(unix, 0))); code != expected {
+			t.Errorf("%d: expected %s, got %s", unix, expected, code)
+		}
+	}
+}
+
+func TestVerifyTotp(t *testing.T) {
+	secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+	now := time.Unix(59, 0)
+
+	step, ok := verifyTotp(secret, "287082", now, 0)
+	if !ok || step != 1 {
+		t.Fatalf("expected valid code of step 1, got %d %v", step, ok)
+	}
+
+	if _, ok = verifyTotp(secret, "287082", now, step); ok {
+		t.Fatal("used code must not verify again")
+	}
+
+	if _, ok = verifyTotp(secret, "287082", now.Add(time.Minute*2), 0); ok {
+		t.Fatal("code outside of the skew window must not verify")
+	}
+
+	uri := totpUri("Wallet", "user@example.com", secret)
+	if !strings.HasPrefix(uri, "otpauth://totp/Wallet:user@example.com?") || !strings.Contains(uri, "secret="+secret) {
+		t.Fatalf("unexpected uri %s", uri)
+	}
+}
@@ -2,37 +2,140 @@ package service
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"github.com/IMBgl/go-wallet-api/internal/domain"
 	"github.com/google/uuid"
+	"math/big"
+	"time"
 )
 
 type transactionService struct {
-	repo Repository
+	repo     Repository
+	exchange ExchangeService
 }
 
 type TransactionCreateRequest struct {
+	// Id and Date are optional: a new id and the current time are used when unset
+	Id              uuid.UUID
+	Date            time.Time
 	UserId          uuid.UUID
 	WalletId        uuid.UUID
 	CategoryId      uuid.UUID
-	Currency        domain.Currency
 	Comment         string
-	Amount          float32
+	Amount          domain.Money
 	TransactionType domain.TransactionType
+	// Rate converts an Amount in a currency other than the wallet's instead of the rate of the day
+	Rate *big.Rat
+}
+
+// TransactionUpdateRequest changes only the fields that are set.
+type TransactionUpdateRequest struct {
+	UserId uuid.UUID
+	// AllowedWalletIds limits the change to transactions of these wallets when not empty
+	AllowedWalletIds []uuid.UUID
+	TransactionId    uuid.UUID
+	WalletId         *uuid.UUID
+	CategoryId       *uuid.UUID
+	Comment          *string
+	Amount           *big.Rat
+	Currency         *domain.Currency
+	TransactionType  *domain.TransactionType
+	Rate             *big.Rat
+}
+
+type TransactionDeleteRequest struct {
+	UserId           uuid.UUID
+	TransactionId    uuid.UUID
+	AllowedWalletIds []uuid.UUID
 }
 
 type TransactionGetListRequest struct {
-	UserId   uuid.UUID
-	WalletId uuid.UUID
+	UserId               uuid.UUID
+	WalletId             *uuid.UUID
+	CategoryId           *uuid.UUID
+	IncludeSubcategories bool
+	Type                 *domain.TransactionType
+	Currency             *domain.Currency
+	AmountFrom           *big.Rat
+	AmountTo             *big.Rat
+	DateFrom             *time.Time
+	DateTo               *time.Time
+	Comment              string
+	Sort                 TransactionSort
+	Limit                int
+	Cursor               string
+}
+
+type TransactionList struct {
+	Items      []*domain.Transaction
+	NextCursor string
+}
+
+type TransactionSortField string
+
+const (
+	TransactionSortDate   TransactionSortField = "date"
+	TransactionSortAmount TransactionSortField = "amount"
+)
+
+type TransactionSort struct {
+	Field TransactionSortField
+	Desc  bool
+}
+
+const TRANSACTION_LIST_DEFAULT_LIMIT = 50
+const TRANSACTION_LIST_MAX_LIMIT = 200
+
+// TransactionCursor points at the last transaction of a page: the sort key
+// value and the id used as a tie breaker.
+type TransactionCursor struct {
+	Sort   TransactionSort `json:"s"`
+	Date   time.Time       `json:"d,omitempty"`
+	Amount string          `json:"a,omitempty"`
+	Id     uuid.UUID       `json:"i"`
+}
+
+// TransactionFilter is the repository level query built from TransactionGetListRequest.
+type TransactionFilter struct {
+	UserId      uuid.UUID
+	WalletId    *uuid.UUID
+	CategoryIds []uuid.UUID
+	Type        *domain.TransactionType
+	Currency    *domain.Currency
+	AmountFrom  *big.Rat
+	AmountTo    *big.Rat
+	DateFrom    *time.Time
+	DateTo      *time.Time
+	DateBefore  *time.Time
+	Comment     string
+	ExternalIds []string
+	Sort        TransactionSort
+	After       *TransactionCursor
+	Limit       int
 }
 
 type TransactionRepository interface {
 	Save(ctx context.Context, t *domain.Transaction) error
 	FindByWalletIdAndUserId(ctx context.Context, walletId, userId uuid.UUID) ([]*domain.Transaction, error)
-	SaveAndUpdateWalletBalance(ctx context.Context, t *domain.Transaction, balance float32) error
+	FindByFilter(ctx context.Context, filter *TransactionFilter) ([]*domain.Transaction, error)
+	SumByFilter(ctx context.Context, filter *TransactionFilter) (domain.Money, error)
+	GetByIdAndUserId(ctx context.Context, id, userId uuid.UUID) (*domain.Transaction, error)
+	// SaveAndUpdateWalletBalance saves the transaction and adds it to the stored wallet balance
+	SaveAndUpdateWalletBalance(ctx context.Context, t *domain.Transaction) error
+	// UpdateAndUpdateWalletBalances replaces old with t and moves the difference between the wallet balances.
+	// It returns domain.ErrTransactionChanged if the stored transaction is no longer old.
+	UpdateAndUpdateWalletBalances(ctx context.Context, old, t *domain.Transaction) error
+	// DeleteAndUpdateWalletBalance deletes t and takes it back from the wallet balance.
+	// It returns domain.ErrTransactionNotFound if t was deleted or changed meanwhile.
+	DeleteAndUpdateWalletBalance(ctx context.Context, t *domain.Transaction) error
+	// SaveImport saves the imported transactions, adds them to the wallet balance
+	// and maps accountId to the wallet when it is not empty
+	SaveImport(ctx context.Context, w *domain.Wallet, accountId string, list []*domain.Transaction) error
 }
 
-func NewTransactionService(r Repository) TransactionService {
-	return &transactionService{repo: r}
+func NewTransactionService(r Repository, es ExchangeService) TransactionService {
+	return &transactionService{repo: r, exchange: es}
 }
 
 func (s *transactionService) Create(ctx context.Context, request *TransactionCreateRequest) (*domain.Transaction, error) {
@@ -41,19 +144,20 @@ func (s *transactionService) Create(ctx context.Context, request *TransactionCre
 		return nil, err
 	}
 
-	category, err := s.repo.Category().GetById(ctx, request.CategoryId)
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	category, err := s.repo.Category().FindByIdAndUserId(ctx, request.CategoryId, user.Id)
 	if err != nil {
 		return nil, err
 	}
 
-	wallet, err := s.repo.Wallet().GetById(ctx, request.WalletId)
+	wallet, err := s.repo.Wallet().GetByIdAndUserId(ctx, request.WalletId, user.Id)
 	if err != nil {
 		return nil, err
 	}
 
-	if user == nil {
-		return nil, ErrUserNotFound
-	}
 	if category == nil {
 		return nil, domain.ErrCategoryNotFound
 	}
@@ -61,11 +165,25 @@ func (s *transactionService) Create(ctx context.Context, request *TransactionCre
 		return nil, domain.ErrWalletNotFound
 	}
 
-	transaction := domain.NewTransaction(request.Comment, request.Amount, request.Currency, request.TransactionType, request.UserId, request.CategoryId, request.WalletId)
-	walletTransactions, err := s.repo.Transaction().FindByWalletIdAndUserId(ctx, wallet.Id, user.Id)
-	amount := s.GetAmount(append(walletTransactions, transaction))
+	transaction := domain.NewTransaction(request.Comment, request.Amount, request.TransactionType, request.UserId, request.CategoryId, request.WalletId)
+	if request.Id != uuid.Nil {
+		transaction.Id = request.Id
+	}
+	if !request.Date.IsZero() {
+		transaction.CreatedAt = request.Date.UTC()
+	}
+
+	err = s.book(ctx, transaction, request.Amount, wallet, request.Rate)
+	if err != nil {
+		return nil, err
+	}
+
+	err = wallet.ApplyTransaction(transaction)
+	if err != nil {
+		return nil, err
+	}
 
-	err = s.repo.Transaction().SaveAndUpdateWalletBalance(ctx, transaction, amount)
+	err = s.repo.Transaction().SaveAndUpdateWalletBalance(ctx, transaction)
 	if err != nil {
 		return nil, err
 	}
@@ -73,18 +191,364 @@ func (s *transactionService) Create(ctx context.Context, request *TransactionCre
 	return transaction, nil
 }
 
-func (s *transactionService) GetList() {
+func (s *transactionService) Update(ctx context.Context, request *TransactionUpdateRequest) (*domain.Transaction, error) {
+	user, err := s.repo.User().GetById(ctx, request.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	transaction, err := s.repo.Transaction().GetByIdAndUserId(ctx, request.TransactionId, user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if transaction == nil || !walletAllowed(request.AllowedWalletIds, transaction.WalletId) {
+		return nil, domain.ErrTransactionNotFound
+	}
+
+	err = s.checkNotTransfer(ctx, transaction)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.WalletId != nil && !walletAllowed(request.AllowedWalletIds, *request.WalletId) {
+		return nil, domain.ErrWalletNotFound
+	}
+
+	oldWallet, err := s.repo.Wallet().GetByIdAndUserId(ctx, transaction.WalletId, user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if oldWallet == nil {
+		return nil, domain.ErrWalletNotFound
+	}
+
+	updated := *transaction
+
+	if request.CategoryId != nil {
+		category, err := s.repo.Category().FindByIdAndUserId(ctx, *request.CategoryId, user.Id)
+		if err != nil {
+			return nil, err
+		}
+
+		if category == nil {
+			return nil, domain.ErrCategoryNotFound
+		}
+		updated.CategoryId = category.Id
+	}
+
+	if request.Comment != nil {
+		updated.Comment = *request.Comment
+	}
+
+	if request.TransactionType != nil {
+		updated.Type = *request.TransactionType
+	}
+
+	entered := transaction.EnteredAmount()
+	if request.Amount != nil || request.Currency != nil {
+		amount := entered.Rat()
+		if request.Amount != nil {
+			amount = request.Amount
+		}
+
+		currency := entered.Currency()
+		if request.Currency != nil {
+			currency = *request.Currency
+		}
+
+		entered, err = domain.MoneyFromRat(amount, currency)
+		if err != nil {
+			return nil, err
+		}
+
+		if !entered.IsPositive() {
+			return nil, domain.ErrInvalidAmount
+		}
+	}
+
+	newWallet := oldWallet
+	if request.WalletId != nil && *request.WalletId != oldWallet.Id {
+		newWallet, err = s.repo.Wallet().GetByIdAndUserId(ctx, *request.WalletId, user.Id)
+		if err != nil {
+			return nil, err
+		}
+
+		if newWallet == nil {
+			return nil, domain.ErrWalletNotFound
+		}
+		updated.WalletId = newWallet.Id
+	}
+
+	if request.Amount != nil || request.Currency != nil || request.Rate != nil || newWallet != oldWallet {
+		// the recorded rate is kept while the pair stays the same, the rate of the day would rewrite history
+		rate := request.Rate
+		if rate == nil && transaction.Rate != nil && entered.SameCurrency(*transaction.OriginalAmount) && newWallet.Balance.SameCurrency(transaction.Amount) {
+			rate = transaction.Rate
+		}
+
+		err = s.book(ctx, &updated, entered, newWallet, rate)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = oldWallet.RevertTransaction(transaction)
+	if err != nil {
+		return nil, err
+	}
+
+	err = newWallet.ApplyTransaction(&updated)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repo.Transaction().UpdateAndUpdateWalletBalances(ctx, transaction, &updated)
+	if err != nil {
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+// book sets the amount of the transaction to the entered amount, converted
+// to the wallet currency when it differs. The entered amount and the
+// applied rate are recorded on the transaction.
+func (s *transactionService) book(ctx context.Context, t *domain.Transaction, entered domain.Money, wallet *domain.Wallet, rate *big.Rat) error {
+	if entered.SameCurrency(wallet.Balance) {
+		t.Amount, t.OriginalAmount, t.Rate = entered, nil, nil
+
+		return nil
+	}
+
+	conversion, err := s.exchange.Convert(ctx, &ConvertRequest{
+		UserId: t.UserId,
+		Amount: entered,
+		To:     wallet.Balance.Currency(),
+		Date:   t.CreatedAt,
+		Rate:   rate,
+	})
+	if err != nil {
+		return err
+	}
+
+	// an amount too small for the wallet currency would not change the balance
+	if !conversion.Amount.IsPositive() {
+		return domain.ErrInvalidAmount
+	}
+
+	t.Amount, t.OriginalAmount, t.Rate = conversion.Amount, &entered, conversion.Rate
+
+	return nil
+}
+
+func (s *transactionService) Delete(ctx context.Context, request *TransactionDeleteRequest) error {
+	user, err := s.repo.User().GetById(ctx, request.UserId)
+	if err != nil {
+		return err
+	}
+
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	transaction, err := s.repo.Transaction().GetByIdAndUserId(ctx, request.TransactionId, user.Id)
+	if err != nil {
+		return err
+	}
+
+	if transaction == nil || !walletAllowed(request.AllowedWalletIds, transaction.WalletId) {
+		return domain.ErrTransactionNotFound
+	}
+
+	err = s.checkNotTransfer(ctx, transaction)
+	if err != nil {
+		return err
+	}
+
+	wallet, err := s.repo.Wallet().GetByIdAndUserId(ctx, transaction.WalletId, user.Id)
+	if err != nil {
+		return err
+	}
+
+	if wallet == nil {
+		return domain.ErrWalletNotFound
+	}
+
+	err = wallet.RevertTransaction(transaction)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.Transaction().DeleteAndUpdateWalletBalance(ctx, transaction)
+}
+
+// checkNotTransfer rejects the sides of a transfer, changing one side alone
+// would break the amounts and the rate of the transfer.
+func (s *transactionService) checkNotTransfer(ctx context.Context, t *domain.Transaction) error {
+	transfer, err := s.repo.Transfer().GetByTransactionId(ctx, t.Id)
+	if err != nil {
+		return err
+	}
+
+	if transfer != nil {
+		return domain.ErrTransactionIsTransfer
+	}
+
+	return nil
+}
+
+func (s *transactionService) GetList(ctx context.Context, request *TransactionGetListRequest) (*TransactionList, error) {
+	user, err := s.repo.User().GetById(ctx, request.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	filter := &TransactionFilter{
+		UserId:     user.Id,
+		WalletId:   request.WalletId,
+		Type:       request.Type,
+		Currency:   request.Currency,
+		AmountFrom: request.AmountFrom,
+		AmountTo:   request.AmountTo,
+		DateFrom:   request.DateFrom,
+		DateTo:     request.DateTo,
+		Comment:    request.Comment,
+		Sort:       request.Sort,
+		Limit:      request.Limit,
+	}
+
+	if filter.Sort.Field == "" {
+		filter.Sort = TransactionSort{Field: TransactionSortDate, Desc: true}
+	}
+	if filter.Sort.Field != TransactionSortDate && filter.Sort.Field != TransactionSortAmount {
+		return nil, ErrInvalidSort
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = TRANSACTION_LIST_DEFAULT_LIMIT
+	}
+	if filter.Limit > TRANSACTION_LIST_MAX_LIMIT {
+		filter.Limit = TRANSACTION_LIST_MAX_LIMIT
+	}
+
+	if request.WalletId != nil {
+		wallet, err := s.repo.Wallet().GetByIdAndUserId(ctx, *request.WalletId, user.Id)
+		if err != nil {
+			return nil, err
+		}
+
+		if wallet == nil {
+			return nil, ErrWalletNotFound
+		}
+	}
+
+	if request.CategoryId != nil {
+		category, err := s.repo.Category().FindByIdAndUserId(ctx, *request.CategoryId, user.Id)
+		if err != nil {
+			return nil, err
+		}
+
+		if category == nil {
+			return nil, ErrCategoryNotFound
+		}
+
+		filter.CategoryIds = []uuid.UUID{category.Id}
+		if request.IncludeSubcategories {
+			filter.CategoryIds, err = categoryIdsWithDescendants(ctx, s.repo.Category(), category)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if request.Cursor != "" {
+		cursor, err := DecodeTransactionCursor(request.Cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		if cursor.Sort != filter.Sort {
+			return nil, ErrInvalidCursor
+		}
+		filter.After = cursor
+	}
+
+	// one extra row tells whether there is a next page
+	filter.Limit++
+	items, err := s.repo.Transaction().FindByFilter(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &TransactionList{Items: items}
+	if len(items) == filter.Limit {
+		list.Items = items[:len(items)-1]
+		list.NextCursor, err = EncodeTransactionCursor(filter.Sort, list.Items[len(list.Items)-1])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return list, nil
+}
+
+func EncodeTransactionCursor(sort TransactionSort, last *domain.Transaction) (string, error) {
+	cursor := &TransactionCursor{Sort: sort, Id: last.Id}
+	if sort.Field == TransactionSortAmount {
+		cursor.Amount = last.Amount.String()
+	} else {
+		cursor.Date = last.CreatedAt
+	}
+
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func DecodeTransactionCursor(value string) (*TransactionCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	cursor := &TransactionCursor{}
+	err = json.Unmarshal(data, cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	if cursor.Sort.Field == TransactionSortAmount {
+		if _, ok := new(big.Rat).SetString(cursor.Amount); !ok {
+			return nil, ErrInvalidCursor
+		}
+	}
 
+	return cursor, nil
 }
 
-func (s *transactionService) GetAmount(transactionList []*domain.Transaction) float32 {
-	amount := float32(0.0)
+func (s *transactionService) GetAmount(currency domain.Currency, transactionList []*domain.Transaction) (domain.Money, error) {
+	amount := domain.ZeroMoney(currency)
 
-	if len(transactionList) > 0 {
-		for _, t := range (transactionList) {
-			amount += t.Amount
+	for _, t := range transactionList {
+		var err error
+		amount, err = amount.Add(t.Amount)
+		if err != nil {
+			return domain.Money{}, err
 		}
 	}
 
-	return amount
+	return amount, nil
 }
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"github.com/IMBgl/go-wallet-api/internal/domain"
+	"github.com/google/uuid"
+	"math/big"
+	"testing"
+)
+
+// transactionTestRepository serves the wallets of one user and records the
+// transactions the service writes, the wallet balances are not changed.
+type transactionTestRepository struct {
+	Repository
+	user        *domain.User
+	wallets     map[uuid.UUID]*domain.Wallet
+	transaction *transactionTestTransactionRepository
+}
+
+func (r *transactionTestRepository) User() UserRepository {
+	return &reportTestUserRepository{user: r.user}
+}
+func (r *transactionTestRepository) Wallet() WalletRepository {
+	return &transactionTestWalletRepository{wallets: r.wallets}
+}
+func (r *transactionTestRepository) Transaction() TransactionRepository { return r.transaction }
+func (r *transactionTestRepository) Transfer() TransferRepository {
+	return &transactionTestTransferRepository{}
+}
+
+type transactionTestWalletRepository struct {
+	WalletRepository
+	wallets map[uuid.UUID]*domain.Wallet
+}
+
+func (r *transactionTestWalletRepository) GetByIdAndUserId(ctx context.Context, id, userId uuid.UUID) (*domain.Wallet, error) {
+	if w, ok := r.wallets[id]; ok {
+		copied := *w
+		return &copied, nil
+	}
+
+	return nil, nil
+}
+
+type transactionTestTransferRepository struct {
+	TransferRepository
+}
+
+func (r *transactionTestTransferRepository) GetByTransactionId(ctx context.Context, id uuid.UUID) (*domain.Transfer, error) {
+	return nil, nil
+}
+
+type transactionTestTransactionRepository struct {
+	TransactionRepository
+	stored            *domain.Transaction
+	applied, reverted []*domain.Transaction
+}
+
+func (r *transactionTestTransactionRepository) GetByIdAndUserId(ctx context.Context, id, userId uuid.UUID) (*domain.Transaction, error) {
+	if r.stored == nil {
+		return nil, nil
+	}
+
+	copied := *r.stored
+	return &copied, nil
+}
+
+func (r *transactionTestTransactionRepository) UpdateAndUpdateWalletBalances(ctx context.Context, old, t *domain.Transaction) error {
+	r.applied, r.reverted = []*domain.Transaction{t}, []*domain.Transaction{old}
+	return nil
+}
+
+func (r *transactionTestTransactionRepository) DeleteAndUpdateWalletBalance(ctx context.Context, t *domain.Transaction) error {
+	r.applied, r.reverted = nil, []*domain.Transaction{t}
+	return nil
+}
+
+func TestTransactionBalanceChanges(t *testing.T) {
+	usd := func(minor int64) domain.Money {
+		return domain.NewMoney(minor, domain.CurrencyUSD())
+	}
+
+	user := domain.NewUser("owner", "owner@example.com", "")
+	cash := &domain.Wallet{Id: uuid.New(), UserId: user.Id, Balance: usd(10000)}
+	card := &domain.Wallet{Id: uuid.New(), UserId: user.Id, Balance: usd(10000)}
+	in := domain.TransactionTypeIn()
+
+	tests := []struct {
+		name    string
+		request *TransactionUpdateRequest
+		delete  bool
+		want    map[uuid.UUID]int64
+	}{
+		{"move to another wallet", &TransactionUpdateRequest{WalletId: &card.Id}, false, map[uuid.UUID]int64{cash.Id: 3000, card.Id: -3000}},
+		{"flip to in with a new amount", &TransactionUpdateRequest{TransactionType: &in, Amount: big.NewRat(45, 1)}, false, map[uuid.UUID]int64{cash.Id: 7500}},
+		{"flip to in and move", &TransactionUpdateRequest{TransactionType: &in, WalletId: &card.Id}, false, map[uuid.UUID]int64{cash.Id: 3000, card.Id: 3000}},
+		{"delete", nil, true, map[uuid.UUID]int64{cash.Id: 3000}},
+	}
+
+	for _, tt := range tests {
+		// 30.00 spent from cash
+		stored := domain.NewTransaction("", usd(3000), domain.TransactionTypeOut(), user.Id, uuid.Nil, cash.Id)
+		repo := &transactionTestTransactionRepository{stored: stored}
+		s := NewTransactionService(&transactionTestRepository{
+			user:        user,
+			wallets:     map[uuid.UUID]*domain.Wallet{cash.Id: cash, card.Id: card},
+			transaction: repo,
+		}, nil)
+
+		var err error
+		if tt.delete {
+			err = s.Delete(context.Background(), &TransactionDeleteRequest{UserId: user.Id, TransactionId: stored.Id})
+		} else {
+			tt.request.UserId, tt.request.TransactionId = user.Id, stored.Id
+			_, err = s.Update(context.Background(), tt.request)
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		changes, err := domain.WalletBalanceChanges(repo.applied, repo.reverted)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		for walletId, want := range tt.want {
+			if got := changes[walletId]; got.Minor() != want {
+				t.Errorf("%s: change of wallet %s = %s, want %d", tt.name, walletId, got, want)
+			}
+		}
+		if len(changes) != len(tt.want) {
+			t.Errorf("%s: changed %d wallets, want %d", tt.name, len(changes), len(tt.want))
+		}
+	}
+}
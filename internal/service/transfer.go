@@ -0,0 +1,317 @@
+package service
+
+import (
+	"context"
+	"github.com/IMBgl/go-wallet-api/internal/domain"
+	"github.com/google/uuid"
+	"math/big"
+)
+
+// TRANSFER_RATE_SCALE is the number of fractional digits a computed exchange rate is rounded to.
+const TRANSFER_RATE_SCALE = 10
+
+type transferService struct {
+	repo Repository
+}
+
+type TransferCreateRequest struct {
+	UserId         uuid.UUID
+	FromWalletId   uuid.UUID
+	ToWalletId     uuid.UUID
+	CategoryId     *uuid.UUID
+	Comment        string
+	Amount         *big.Rat
+	Rate           *big.Rat
+	ReceivedAmount *big.Rat
+}
+
+type TransferGetListRequest struct {
+	UserId   uuid.UUID
+	WalletId *uuid.UUID
+}
+
+type TransferGetOneRequest struct {
+	UserId     uuid.UUID
+	TransferId uuid.UUID
+}
+
+type TransferReverseRequest struct {
+	UserId     uuid.UUID
+	TransferId uuid.UUID
+}
+
+// TransferDetails is a transfer together with its outgoing and incoming transactions.
+type TransferDetails struct {
+	*domain.Transfer
+	Out *domain.Transaction
+	In  *domain.Transaction
+}
+
+type TransferRepository interface {
+	GetByIdAndUserId(ctx context.Context, id, userId uuid.UUID) (*domain.Transfer, error)
+	GetByReversalOfId(ctx context.Context, id uuid.UUID) (*domain.Transfer, error)
+	// GetByTransactionId returns the transfer the transaction is a side of
+	GetByTransactionId(ctx context.Context, transactionId uuid.UUID) (*domain.Transfer, error)
+	FindByUserId(ctx context.Context, userId uuid.UUID, walletId *uuid.UUID) ([]*domain.Transfer, error)
+	// SaveWithTransactions saves the transfer and its transactions and adds them to the stored wallet balances
+	SaveWithTransactions(ctx context.Context, t *domain.Transfer, out, in *domain.Transaction) error
+}
+
+func NewTransferService(r Repository) *transferService {
+	return &transferService{repo: r}
+}
+
+func (s *transferService) Create(ctx context.Context, request *TransferCreateRequest) (*TransferDetails, error) {
+	user, err := s.repo.User().GetById(ctx, request.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	if request.FromWalletId == request.ToWalletId {
+		return nil, domain.ErrTransferSameWallet
+	}
+
+	from, err := s.repo.Wallet().GetByIdAndUserId(ctx, request.FromWalletId, user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := s.repo.Wallet().GetByIdAndUserId(ctx, request.ToWalletId, user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if from == nil || to == nil {
+		return nil, domain.ErrWalletNotFound
+	}
+
+	categoryId := uuid.Nil
+	if request.CategoryId != nil {
+		category, err := s.repo.Category().FindByIdAndUserId(ctx, *request.CategoryId, user.Id)
+		if err != nil {
+			return nil, err
+		}
+
+		if category == nil {
+			return nil, domain.ErrCategoryNotFound
+		}
+		categoryId = category.Id
+	}
+
+	sent, err := domain.MoneyFromRat(request.Amount, from.Balance.Currency())
+	if err != nil {
+		return nil, err
+	}
+
+	if !sent.IsPositive() {
+		return nil, domain.ErrInvalidAmount
+	}
+
+	received, rate, err := s.receivedAmount(sent, to.Balance.Currency(), request.Rate, request.ReceivedAmount)
+	if err != nil {
+		return nil, err
+	}
+
+	out := domain.NewTransaction(request.Comment, sent, domain.TransactionTypeOut(), user.Id, categoryId, from.Id)
+	in := domain.NewTransaction(request.Comment, received, domain.TransactionTypeIn(), user.Id, categoryId, to.Id)
+	transfer := domain.NewTransfer(user.Id, out, in, rate)
+
+	return s.save(ctx, transfer, out, in, from, to)
+}
+
+// receivedAmount resolves how much arrives in the destination currency: same
+// currency transfers are 1:1, otherwise an explicit rate or received amount is required.
+func (s *transferService) receivedAmount(sent domain.Money, currency domain.Currency, rate, received *big.Rat) (domain.Money, *big.Rat, error) {
+	if sent.Currency().Equals(&currency) {
+		if (rate != nil && rate.Cmp(big.NewRat(1, 1)) != 0) || (received != nil && received.Cmp(sent.Rat()) != 0) {
+			return domain.Money{}, nil, domain.ErrTransferInvalidRate
+		}
+
+		return sent, nil, nil
+	}
+
+	if rate != nil && rate.Sign() <= 0 {
+		return domain.Money{}, nil, domain.ErrTransferInvalidRate
+	}
+
+	switch {
+	case received != nil:
+		amount, err := domain.MoneyFromRat(received, currency)
+		if err != nil {
+			return domain.Money{}, nil, err
+		}
+
+		if !amount.IsPositive() {
+			return domain.Money{}, nil, domain.ErrInvalidAmount
+		}
+
+		effective := roundRat(new(big.Rat).Quo(amount.Rat(), sent.Rat()), TRANSFER_RATE_SCALE)
+		if rate != nil && rate.Cmp(effective) != 0 {
+			expected, err := domain.MoneyFromRatRounded(new(big.Rat).Mul(sent.Rat(), rate), currency)
+			if err != nil || !expected.Equals(amount) {
+				return domain.Money{}, nil, domain.ErrTransferInvalidRate
+			}
+			effective = rate
+		}
+
+		return amount, effective, nil
+	case rate != nil:
+		amount, err := domain.MoneyFromRatRounded(new(big.Rat).Mul(sent.Rat(), rate), currency)
+		if err != nil {
+			return domain.Money{}, nil, err
+		}
+
+		if !amount.IsPositive() {
+			return domain.Money{}, nil, domain.ErrInvalidAmount
+		}
+
+		return amount, rate, nil
+	}
+
+	return domain.Money{}, nil, domain.ErrTransferRateRequired
+}
+
+func (s *transferService) save(ctx context.Context, transfer *domain.Transfer, out, in *domain.Transaction, from, to *domain.Wallet) (*TransferDetails, error) {
+	err := from.ApplyTransaction(out)
+	if err != nil {
+		return nil, err
+	}
+
+	err = to.ApplyTransaction(in)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repo.Transfer().SaveWithTransactions(ctx, transfer, out, in)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TransferDetails{Transfer: transfer, Out: out, In: in}, nil
+}
+
+func (s *transferService) GetList(ctx context.Context, request *TransferGetListRequest) ([]*TransferDetails, error) {
+	user, err := s.repo.User().GetById(ctx, request.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	transfers, err := s.repo.Transfer().FindByUserId(ctx, user.Id, request.WalletId)
+	if err != nil {
+		return nil, err
+	}
+
+	list := []*TransferDetails{}
+	for _, transfer := range transfers {
+		details, err := s.getDetails(ctx, transfer)
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, details)
+	}
+
+	return list, nil
+}
+
+func (s *transferService) GetOne(ctx context.Context, request *TransferGetOneRequest) (*TransferDetails, error) {
+	user, err := s.repo.User().GetById(ctx, request.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	transfer, err := s.repo.Transfer().GetByIdAndUserId(ctx, request.TransferId, user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if transfer == nil {
+		return nil, domain.ErrTransferNotFound
+	}
+
+	return s.getDetails(ctx, transfer)
+}
+
+// Reverse books a new transfer moving the same amounts back from the
+// destination to the source wallet, leaving the original transfer intact.
+func (s *transferService) Reverse(ctx context.Context, request *TransferReverseRequest) (*TransferDetails, error) {
+	original, err := s.GetOne(ctx, &TransferGetOneRequest{UserId: request.UserId, TransferId: request.TransferId})
+	if err != nil {
+		return nil, err
+	}
+
+	if original.ReversalOfId != nil {
+		return nil, domain.ErrTransferAlreadyReversed
+	}
+
+	reversal, err := s.repo.Transfer().GetByReversalOfId(ctx, original.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if reversal != nil {
+		return nil, domain.ErrTransferAlreadyReversed
+	}
+
+	from, err := s.repo.Wallet().GetByIdAndUserId(ctx, original.In.WalletId, original.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := s.repo.Wallet().GetByIdAndUserId(ctx, original.Out.WalletId, original.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if from == nil || to == nil {
+		return nil, domain.ErrWalletNotFound
+	}
+
+	var rate *big.Rat
+	if original.Rate != nil {
+		rate = roundRat(new(big.Rat).Inv(original.Rate), TRANSFER_RATE_SCALE)
+	}
+
+	out := domain.NewTransaction(original.In.Comment, original.In.Amount, domain.TransactionTypeOut(), original.UserId, original.In.CategoryId, from.Id)
+	in := domain.NewTransaction(original.Out.Comment, original.Out.Amount, domain.TransactionTypeIn(), original.UserId, original.Out.CategoryId, to.Id)
+	transfer := domain.NewTransfer(original.UserId, out, in, rate)
+	transfer.ReversalOfId = &original.Id
+
+	return s.save(ctx, transfer, out, in, from, to)
+}
+
+func (s *transferService) getDetails(ctx context.Context, transfer *domain.Transfer) (*TransferDetails, error) {
+	out, err := s.repo.Transaction().GetByIdAndUserId(ctx, transfer.OutTransactionId, transfer.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	in, err := s.repo.Transaction().GetByIdAndUserId(ctx, transfer.InTransactionId, transfer.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if out == nil || in == nil {
+		return nil, domain.ErrTransactionNotFound
+	}
+
+	return &TransferDetails{Transfer: transfer, Out: out, In: in}, nil
+}
+
+func roundRat(r *big.Rat, scale int) *big.Rat {
+	rounded, _ := new(big.Rat).SetString(r.FloatString(scale))
+
+	return rounded
+}
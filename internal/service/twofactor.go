@@ -0,0 +1,426 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"github.com/IMBgl/go-wallet-api/internal/domain"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+const RECOVERY_CODES_COUNT = 10
+
+// RECOVERY_CODE_LENGTH characters of base32, 80 random bits
+const RECOVERY_CODE_LENGTH = 16
+
+const (
+	SIGN_IN_CHALLENGE_LIFETIME     = time.Minute * 5
+	SIGN_IN_CHALLENGE_MAX_ATTEMPTS = 5
+)
+
+const DEFAULT_TOTP_ISSUER = "Wallet"
+
+var ErrTwoFactorAlreadyEnabled = errors.New("Two-factor authentication already enabled")
+var ErrTwoFactorNotEnabled = errors.New("Two-factor authentication not enabled")
+var ErrTwoFactorNotEnrolled = errors.New("Two-factor authentication enrollment not started")
+var ErrInvalidTwoFactorCode = errors.New("Invalid two-factor code")
+var ErrInvalidChallenge = errors.New("Invalid or expired sign in challenge")
+
+// TwoFactorConfig sets the issuer name authenticator apps show next to the account.
+type TwoFactorConfig struct {
+	Issuer string
+}
+
+func DefaultTwoFactorConfig() TwoFactorConfig {
+	return TwoFactorConfig{Issuer: DEFAULT_TOTP_ISSUER}
+}
+
+// UserTotp is the TOTP secret of a user, it signs in with a code only once Enabled.
+type UserTotp struct {
+	UserId    uuid.UUID
+	Secret    string
+	Enabled   bool
+	LastStep  int64
+	CreatedAt time.Time
+}
+
+// RecoveryCode replaces a TOTP code once when the authenticator is lost.
+type RecoveryCode struct {
+	Id        uuid.UUID
+	UserId    uuid.UUID
+	Hash      string
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// SignInChallenge is issued instead of tokens after the password of a user
+// with two-factor authentication is verified.
+type SignInChallenge struct {
+	Id        uuid.UUID
+	UserId    uuid.UUID
+	Value     string
+	Hash      string
+	Attempts  int
+	Exp       time.Time
+	CreatedAt time.Time
+}
+
+// SignInResult holds either the tokens or the challenge to complete with SignInVerify.
+type SignInResult struct {
+	User      *domain.User
+	Tokens    *TokenPair
+	Challenge *SignInChallenge
+}
+
+type TotpEnrollment struct {
+	Secret string
+	Uri    string
+}
+
+type TwoFactorEnrollRequest struct {
+	UserId uuid.UUID
+}
+
+type TwoFactorConfirmRequest struct {
+	UserId uuid.UUID
+	Code   string
+}
+
+// TwoFactorDisableRequest needs the password and a code or a recovery code.
+type TwoFactorDisableRequest struct {
+	UserId       uuid.UUID
+	Password     string
+	Code         string
+	RecoveryCode string
+}
+
+type RecoveryCodesRegenerateRequest struct {
+	UserId uuid.UUID
+	Code   string
+}
+
+type SignInVerifyRequest struct {
+	Challenge    string
+	Code         string
+	RecoveryCode string
+}
+
+type TwoFactorRepository interface {
+	GetTotpByUserId(ctx context.Context, userId uuid.UUID) (*UserTotp, error)
+	SaveTotp(ctx context.Context, t *UserTotp) error
+	// UseTotpStep stores the step of an accepted code, ok is false when the step or a later one was used already
+	UseTotpStep(ctx context.Context, userId uuid.UUID, step int64) (bool, error)
+	// DeleteTotp removes the secret with the recovery codes of the user
+	DeleteTotp(ctx context.Context, userId uuid.UUID) error
+	// ReplaceRecoveryCodes deletes the codes of the user and saves the new ones
+	ReplaceRecoveryCodes(ctx context.Context, userId uuid.UUID, codes []*RecoveryCode) error
+	// UseRecoveryCode marks the unused code with the hash of value used, ok is false if there is none
+	UseRecoveryCode(ctx context.Context, userId uuid.UUID, value string) (bool, error)
+	SaveChallenge(ctx context.Context, c *SignInChallenge) error
+	// GetChallengeByValue finds the challenge by the hash of value
+	GetChallengeByValue(ctx context.Context, value string) (*SignInChallenge, error)
+	// IncrementChallengeAttempts counts an attempt while the challenge has less than max, ok is false otherwise
+	IncrementChallengeAttempts(ctx context.Context, c *SignInChallenge, max int) (bool, error)
+	DeleteChallenge(ctx context.Context, c *SignInChallenge) error
+}
+
+// EnrollTotp creates a new secret, it is not required at sign in until ConfirmTotp.
+func (s *userService) EnrollTotp(ctx context.Context, request *TwoFactorEnrollRequest) (*TotpEnrollment, error) {
+	user, err := s.getUser(ctx, request.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	totp, err := s.repo.TwoFactor().GetTotpByUserId(ctx, user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if totp != nil && totp.Enabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := generateTotpSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repo.TwoFactor().SaveTotp(ctx, &UserTotp{UserId: user.Id, Secret: secret, CreatedAt: time.Now()})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TotpEnrollment{Secret: secret, Uri: totpUri(s.twoFactor.Issuer, user.Email, secret)}, nil
+}
+
+// ConfirmTotp enables two-factor authentication once the user proves the
+// authenticator works and returns the recovery codes, they are not shown again.
+func (s *userService) ConfirmTotp(ctx context.Context, request *TwoFactorConfirmRequest) ([]string, error) {
+	totp, err := s.repo.TwoFactor().GetTotpByUserId(ctx, request.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if totp == nil {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+
+	if totp.Enabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	err = s.checkTotpCode(ctx, totp, request.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	totp.Enabled = true
+	err = s.repo.TwoFactor().SaveTotp(ctx, totp)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.replaceRecoveryCodes(ctx, request.UserId)
+}
+
+func (s *userService) DisableTotp(ctx context.Context, request *TwoFactorDisableRequest) error {
+	user, err := s.getUser(ctx, request.UserId)
+	if err != nil {
+		return err
+	}
+
+	err = s.CheckUserPassword(ctx, user, request.Password)
+	if err != nil {
+		return err
+	}
+
+	totp, err := s.repo.TwoFactor().GetTotpByUserId(ctx, user.Id)
+	if err != nil {
+		return err
+	}
+
+	if totp == nil || !totp.Enabled {
+		return ErrTwoFactorNotEnabled
+	}
+
+	err = s.checkSecondFactor(ctx, totp, request.Code, request.RecoveryCode)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.TwoFactor().DeleteTotp(ctx, user.Id)
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes, used or not.
+func (s *userService) RegenerateRecoveryCodes(ctx context.Context, request *RecoveryCodesRegenerateRequest) ([]string, error) {
+	totp, err := s.repo.TwoFactor().GetTotpByUserId(ctx, request.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if totp == nil || !totp.Enabled {
+		return nil, ErrTwoFactorNotEnabled
+	}
+
+	err = s.checkTotpCode(ctx, totp, request.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.replaceRecoveryCodes(ctx, request.UserId)
+}
+
+// SignInChallengeUser returns the user a valid challenge was created for.
+func (s *userService) SignInChallengeUser(ctx context.Context, value string) (*domain.User, error) {
+	challenge, err := s.repo.TwoFactor().GetChallengeByValue(ctx, value)
+	if err != nil {
+		return nil, err
+	}
+
+	if challenge == nil || !challenge.Exp.After(time.Now()) {
+		return nil, ErrInvalidChallenge
+	}
+
+	return s.getUser(ctx, challenge.UserId)
+}
+
+// SignInVerify completes the sign in of a user with two-factor authentication.
+// The challenge is dropped after SIGN_IN_CHALLENGE_MAX_ATTEMPTS wrong codes.
+func (s *userService) SignInVerify(ctx context.Context, request *SignInVerifyRequest) (*SignInResult, error) {
+	challenge, err := s.repo.TwoFactor().GetChallengeByValue(ctx, request.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	if challenge == nil || !challenge.Exp.After(time.Now()) {
+		return nil, ErrInvalidChallenge
+	}
+
+	// the attempt is counted before the code is checked, parallel guesses cannot pass the limit
+	ok, err := s.repo.TwoFactor().IncrementChallengeAttempts(ctx, challenge, SIGN_IN_CHALLENGE_MAX_ATTEMPTS)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, ErrInvalidChallenge
+	}
+
+	totp, err := s.repo.TwoFactor().GetTotpByUserId(ctx, challenge.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if totp == nil || !totp.Enabled {
+		return nil, ErrInvalidChallenge
+	}
+
+	err = s.checkSecondFactor(ctx, totp, request.Code, request.RecoveryCode)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repo.TwoFactor().DeleteChallenge(ctx, challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.getUser(ctx, challenge.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	pair, err := s.GetTokenForUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SignInResult{User: user, Tokens: pair}, nil
+}
+
+func (s *userService) createSignInChallenge(ctx context.Context, user *domain.User) (*SignInChallenge, error) {
+	value, err := GenerateTokenValue()
+	if err != nil {
+		return nil, err
+	}
+
+	challenge := &SignInChallenge{
+		Id:        uuid.New(),
+		UserId:    user.Id,
+		Value:     value,
+		Hash:      HashTokenValue(value),
+		Exp:       time.Now().Add(SIGN_IN_CHALLENGE_LIFETIME),
+		CreatedAt: time.Now(),
+	}
+
+	err = s.repo.TwoFactor().SaveChallenge(ctx, challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	return challenge, nil
+}
+
+func (s *userService) checkSecondFactor(ctx context.Context, totp *UserTotp, code, recoveryCode string) error {
+	if code != "" {
+		return s.checkTotpCode(ctx, totp, code)
+	}
+
+	if recoveryCode == "" {
+		return ErrInvalidTwoFactorCode
+	}
+
+	ok, err := s.repo.TwoFactor().UseRecoveryCode(ctx, totp.UserId, normalizeRecoveryCode(recoveryCode))
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+
+	return nil
+}
+
+func (s *userService) checkTotpCode(ctx context.Context, totp *UserTotp, code string) error {
+	step, ok := verifyTotp(totp.Secret, strings.TrimSpace(code), time.Now(), totp.LastStep)
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+
+	// a code accepted by parallel requests is used by the first one only
+	used, err := s.repo.TwoFactor().UseTotpStep(ctx, totp.UserId, step)
+	if err != nil {
+		return err
+	}
+
+	if !used {
+		return ErrInvalidTwoFactorCode
+	}
+	totp.LastStep = step
+
+	return nil
+}
+
+func (s *userService) replaceRecoveryCodes(ctx context.Context, userId uuid.UUID) ([]string, error) {
+	values := []string{}
+	codes := []*RecoveryCode{}
+	for i := 0; i < RECOVERY_CODES_COUNT; i++ {
+		value, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+
+		values = append(values, value)
+		codes = append(codes, &RecoveryCode{
+			Id:        uuid.New(),
+			UserId:    userId,
+			Hash:      HashTokenValue(normalizeRecoveryCode(value)),
+			CreatedAt: time.Now(),
+		})
+	}
+
+	err := s.repo.TwoFactor().ReplaceRecoveryCodes(ctx, userId, codes)
+	if err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+// generateRecoveryCode returns a code like abcd-efgh-ijkl-mnop.
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, RECOVERY_CODE_LENGTH*5/8)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	code := strings.ToLower(totpEncoding.EncodeToString(b))
+	parts := []string{}
+	for i := 0; i < len(code); i += 4 {
+		parts = append(parts, code[i:i+4])
+	}
+
+	return strings.Join(parts, "-"), nil
+}
+
+// normalizeRecoveryCode ignores case, spaces and dashes of the typed code.
+func normalizeRecoveryCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(code))
+}
+
+func (s *userService) getUser(ctx context.Context, userId uuid.UUID) (*domain.User, error) {
+	user, err := s.repo.User().GetById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	return user, nil
+}
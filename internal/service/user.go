@@ -2,8 +2,6 @@ package service
 
 import (
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
 	"github.com/IMBgl/go-wallet-api/internal/domain"
 	"github.com/google/uuid"
 	"log"
@@ -14,6 +12,9 @@ const MAX_USER_TOKENS_COUNT = 5
 type userService struct {
 	repo         Repository
 	tokenService TokenService
+	passwords    *passwordHasher
+	twoFactor    TwoFactorConfig
+	account      AccountService
 }
 
 type SignUpRequest struct {
@@ -27,27 +28,39 @@ type SignInRequest struct {
 	Password string
 }
 
+type SignOutRequest struct {
+	UserId    uuid.UUID
+	SessionId uuid.UUID
+}
+
+type SignOutAllRequest struct {
+	UserId uuid.UUID
+}
+
+type SessionGetListRequest struct {
+	UserId uuid.UUID
+}
+
 type UserRepository interface {
 	GetById(ctx context.Context, id uuid.UUID) (*domain.User, error)
 	Save(ctx context.Context, u *domain.User) error
+	// Delete removes the user with everything it owns in one transaction
 	Delete(ctx context.Context, u *domain.User) error
-	SaveUserWithToken(ctx context.Context, u *domain.User, t *UserToken) error
+	// ChangePassword saves the password and revokes the sessions except keepSessionId
+	ChangePassword(ctx context.Context, u *domain.User, keepSessionId uuid.UUID) error
+	SaveUserWithToken(ctx context.Context, u *domain.User, tokens ...*UserToken) error
 	GetByEmail(ctx context.Context, email string) (*domain.User, error)
 }
 
-func NewUserService(r Repository, ts TokenService) *userService {
-	return &userService{repo: r, tokenService: ts}
-}
-
-func HashPassword(pass string) string {
-	h := sha256.New()
-	h.Write([]byte(pass))
-	hash := hex.EncodeToString(h.Sum(nil))
+func NewUserService(r Repository, ts TokenService, ph *passwordHasher, tf TwoFactorConfig, as AccountService) *userService {
+	if tf.Issuer == "" {
+		tf.Issuer = DEFAULT_TOTP_ISSUER
+	}
 
-	return hash
+	return &userService{repo: r, tokenService: ts, passwords: ph, twoFactor: tf, account: as}
 }
 
-func (s *userService) SingUp(ctx context.Context, signUp SignUpRequest) (*domain.User, *UserToken, error) {
+func (s *userService) SingUp(ctx context.Context, signUp SignUpRequest) (*domain.User, *TokenPair, error) {
 	found, err := s.repo.User().GetByEmail(ctx, signUp.Email)
 	if err != nil {
 		return nil, nil, err
@@ -57,65 +70,185 @@ func (s *userService) SingUp(ctx context.Context, signUp SignUpRequest) (*domain
 		return nil, nil, ErrEmailAlreadyInUse
 	}
 
-	user := domain.NewUser(signUp.Name, signUp.Email, HashPassword(signUp.Password))
-	token := s.tokenService.CreateForUser(user)
+	password, err := s.passwords.Hash(signUp.Password)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	err = s.repo.User().SaveUserWithToken(ctx, user, token)
+	user := domain.NewUser(signUp.Name, signUp.Email, password)
+	pair, err := s.tokenService.CreateForUser(user)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	return user, token, nil
+	err = s.repo.User().SaveUserWithToken(ctx, user, pair.Stored()...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the account works without a verified email, a failed delivery can be retried by the user
+	err = s.account.SendEmailVerification(ctx, user)
+	if err != nil {
+		log.Printf("user %s email verification err %v", user.Id, err)
+	}
+
+	return user, pair, nil
 }
 
-func (s *userService) SingIn(ctx context.Context, request SignInRequest) (*domain.User, *UserToken, error) {
+// SingIn checks the password and returns the tokens, or a challenge to
+// complete with SignInVerify when the user has two-factor authentication.
+func (s *userService) SingIn(ctx context.Context, request SignInRequest) (*SignInResult, error) {
 	user, err := s.repo.User().GetByEmail(ctx, request.Email)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
+	// unknown email and wrong password are not distinguished to not leak
+	// registered emails, neither by the error nor by the time taken
 	if user == nil {
-		return nil, nil, ErrUserNotFound
+		s.passwords.VerifyDummy(request.Password)
+		return nil, ErrInvalidCredentials
 	}
 
-	err = s.CheckUserPassword(user, request.Password)
+	err = s.CheckUserPassword(ctx, user, request.Password)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	token, err := s.GetTokenForUser(ctx, user)
+	totp, err := s.repo.TwoFactor().GetTotpByUserId(ctx, user.Id)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	return user, token, nil
+	if totp != nil && totp.Enabled {
+		challenge, err := s.createSignInChallenge(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+
+		return &SignInResult{User: user, Challenge: challenge}, nil
+	}
+
+	pair, err := s.GetTokenForUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SignInResult{User: user, Tokens: pair}, nil
 }
 
-func (s *userService) GetTokenForUser(ctx context.Context, user *domain.User) (*UserToken, error) {
+// GetTokenForUser starts a new session, all sessions are revoked once the user has MAX_USER_TOKENS_COUNT of them.
+func (s *userService) GetTokenForUser(ctx context.Context, user *domain.User) (*TokenPair, error) {
 	tokenList, err := s.repo.Token().FindByUser(ctx, user)
 	if err != nil {
 		return nil, err
 	}
 
-	token := s.tokenService.CreateForUser(user)
-	log.Printf("deletion error %+v", tokenList)
-	if len(tokenList) > MAX_USER_TOKENS_COUNT-1 {
-		err = s.repo.Token().DeleteAllForUserAndSave(ctx, user, token)
-		log.Printf("deletion error %v", err)
+	pair, err := s.tokenService.CreateForUser(user)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionCount := 0
+	for _, t := range tokenList {
+		if t.IsActiveSession() {
+			sessionCount++
+		}
+	}
+
+	if sessionCount > MAX_USER_TOKENS_COUNT-1 {
+		err = s.repo.Token().DeleteAllForUserAndSave(ctx, user, pair.Stored()...)
 	} else {
-		err = s.repo.Token().Save(ctx, token)
+		err = s.repo.Token().Save(ctx, pair.Stored()...)
 	}
 	if err != nil {
 		return nil, err
 	}
 
-	return token, nil
+	return pair, nil
 }
 
-func (s *userService) CheckUserPassword(u *domain.User, p string) error {
-	if u.Password != HashPassword(p) {
+// CheckUserPassword verifies the password and upgrades a legacy or outdated
+// hash of the user to the configured algorithm.
+func (s *userService) CheckUserPassword(ctx context.Context, u *domain.User, p string) error {
+	ok, needsRehash, err := s.passwords.Verify(p, u.Password)
+	if err != nil {
+		log.Printf("user %s password verification err %v", u.Id, err)
 		return ErrInvalidCredentials
 	}
 
+	if !ok {
+		return ErrInvalidCredentials
+	}
+
+	if needsRehash {
+		password, err := s.passwords.Hash(p)
+		if err != nil {
+			return err
+		}
+
+		u.Password = password
+		err = s.repo.User().Save(ctx, u)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
+
+// SignOut revokes a single session of the user, the current one or any other,
+// with the access and refresh tokens of its family.
+func (s *userService) SignOut(ctx context.Context, request *SignOutRequest) error {
+	user, err := s.repo.User().GetById(ctx, request.UserId)
+	if err != nil {
+		return err
+	}
+
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	tokenList, err := s.repo.Token().FindByUser(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range tokenList {
+		if t.FamilyId == request.SessionId {
+			return s.repo.Token().DeleteFamily(ctx, request.UserId, request.SessionId)
+		}
+	}
+
+	return ErrNotFound
+}
+
+func (s *userService) SignOutAll(ctx context.Context, request *SignOutAllRequest) error {
+	return s.repo.Token().DeleteAllForUser(ctx, request.UserId)
+}
+
+// GetSessions returns the current refresh token of every active session of the user, newest first.
+func (s *userService) GetSessions(ctx context.Context, request *SessionGetListRequest) ([]*UserToken, error) {
+	user, err := s.repo.User().GetById(ctx, request.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	tokenList, err := s.repo.Token().FindByUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := []*UserToken{}
+	for i := len(tokenList) - 1; i >= 0; i-- {
+		if tokenList[i].IsActiveSession() {
+			sessions = append(sessions, tokenList[i])
+		}
+	}
+
+	return sessions, nil
+}
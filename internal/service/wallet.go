@@ -11,10 +11,9 @@ type walletService struct {
 }
 
 type WalletCreateRequest struct {
-	Name     string
-	UserId   uuid.UUID
-	Currency string
-	Balance  float32
+	Name    string
+	UserId  uuid.UUID
+	Balance domain.Money
 }
 
 type WalletUpdateRequest struct {
@@ -38,6 +37,7 @@ type WalletRepository interface {
 	GetById(ctx context.Context, id uuid.UUID) (*domain.Wallet, error)
 	GetByIdAndUserId(ctx context.Context, id, userId uuid.UUID) (*domain.Wallet, error)
 	FindByUserId(ctx context.Context, userId uuid.UUID) ([]*domain.Wallet, error)
+	GetByAccountIdAndUserId(ctx context.Context, accountId string, userId uuid.UUID) (*domain.Wallet, error)
 }
 
 func NewWalletService(r Repository) *walletService {
@@ -54,18 +54,7 @@ func (s *walletService) Create(ctx context.Context, request *WalletCreateRequest
 		return nil, ErrUserNotFound
 	}
 
-	currency, err := domain.CurrencyFromString(request.Currency)
-	if err != nil {
-		return nil, err
-	}
-
-	wallet := &domain.Wallet{
-		Id:       uuid.New(),
-		Name:     request.Name,
-		Currency: currency,
-		Balance:  request.Balance,
-		UserId:   request.UserId,
-	}
+	wallet := domain.NewWallet(request.Name, request.Balance, request.UserId)
 
 	err = s.repo.Wallet().Save(ctx, wallet)
 	if err != nil {
@@ -0,0 +1,265 @@
+package statement
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// SIGN_NEGATIVE_OUT means a single signed amount column where negative values are expenses.
+	SIGN_NEGATIVE_OUT = "negative_out"
+	// SIGN_NEGATIVE_IN means a single signed amount column where negative values are income.
+	SIGN_NEGATIVE_IN = "negative_in"
+	// SIGN_SPLIT means separate unsigned debit and credit columns.
+	SIGN_SPLIT = "split"
+)
+
+const CSV_DEFAULT_DATE_FORMAT = "YYYY-MM-DD"
+
+// CsvMapping tells which columns of a CSV file hold transaction fields.
+// Columns are header names when HasHeader is set, otherwise zero based indexes.
+type CsvMapping struct {
+	Delimiter        string `json:"delimiter"`
+	HasHeader        bool   `json:"hasHeader"`
+	DateColumn       string `json:"date"`
+	DateFormat       string `json:"dateFormat"`
+	AmountColumn     string `json:"amount"`
+	DebitColumn      string `json:"debit"`
+	CreditColumn     string `json:"credit"`
+	Sign             string `json:"sign"`
+	DecimalSeparator string `json:"decimalSeparator"`
+	CommentColumn    string `json:"comment"`
+	CurrencyColumn   string `json:"currency"`
+}
+
+type csvColumns struct {
+	date, amount, debit, credit, comment, currency int
+}
+
+// ParseCsv reads the whole file. Rows that can not be parsed are returned with
+// Err set so they can be shown to the user instead of failing the import.
+func ParseCsv(r io.Reader, m CsvMapping) (*Statement, error) {
+	if m.Sign == "" {
+		m.Sign = SIGN_NEGATIVE_OUT
+	}
+	if m.DateFormat == "" {
+		m.DateFormat = CSV_DEFAULT_DATE_FORMAT
+	}
+	if m.DecimalSeparator == "" {
+		m.DecimalSeparator = "."
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if m.Delimiter != "" {
+		delimiter := []rune(m.Delimiter)
+		if len(delimiter) != 1 {
+			return nil, ErrInvalidMapping
+		}
+		reader.Comma = delimiter[0]
+	}
+
+	var header []string
+	if m.HasHeader {
+		record, err := reader.Read()
+		if err != nil {
+			if err == io.EOF {
+				return nil, ErrEmptyStatement
+			}
+			return nil, err
+		}
+		header = record
+	}
+
+	columns, err := m.columns(header)
+	if err != nil {
+		return nil, err
+	}
+
+	layout := dateLayout(m.DateFormat)
+	statement := &Statement{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		if isBlankRecord(record) {
+			continue
+		}
+
+		entry := &Entry{Line: line}
+		entry.Err = m.fill(entry, record, columns, layout)
+		statement.Entries = append(statement.Entries, entry)
+	}
+
+	if len(statement.Entries) == 0 {
+		return nil, ErrEmptyStatement
+	}
+
+	return statement, nil
+}
+
+func (m CsvMapping) columns(header []string) (*csvColumns, error) {
+	var err error
+	c := &csvColumns{}
+
+	if c.date, err = findColumn(m.DateColumn, header, true); err != nil {
+		return nil, err
+	}
+
+	if m.Sign == SIGN_SPLIT {
+		if c.debit, err = findColumn(m.DebitColumn, header, true); err != nil {
+			return nil, err
+		}
+		if c.credit, err = findColumn(m.CreditColumn, header, true); err != nil {
+			return nil, err
+		}
+		c.amount = -1
+	} else if m.Sign == SIGN_NEGATIVE_OUT || m.Sign == SIGN_NEGATIVE_IN {
+		if c.amount, err = findColumn(m.AmountColumn, header, true); err != nil {
+			return nil, err
+		}
+		c.debit, c.credit = -1, -1
+	} else {
+		return nil, fmt.Errorf("%w: sign must be one of '%s', '%s', '%s'", ErrInvalidMapping, SIGN_NEGATIVE_OUT, SIGN_NEGATIVE_IN, SIGN_SPLIT)
+	}
+
+	if c.comment, err = findColumn(m.CommentColumn, header, false); err != nil {
+		return nil, err
+	}
+	if c.currency, err = findColumn(m.CurrencyColumn, header, false); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (m CsvMapping) fill(entry *Entry, record []string, c *csvColumns, layout string) error {
+	date, err := time.Parse(layout, field(record, c.date))
+	if err != nil {
+		return fmt.Errorf("date value must be in format %s", m.DateFormat)
+	}
+	entry.Date = date
+
+	if m.Sign == SIGN_SPLIT {
+		debit, err := parseCsvAmount(field(record, c.debit), m.DecimalSeparator, true)
+		if err != nil {
+			return err
+		}
+		credit, err := parseCsvAmount(field(record, c.credit), m.DecimalSeparator, true)
+		if err != nil {
+			return err
+		}
+		entry.Amount = new(big.Rat).Sub(new(big.Rat).Abs(credit), new(big.Rat).Abs(debit))
+	} else {
+		amount, err := parseCsvAmount(field(record, c.amount), m.DecimalSeparator, false)
+		if err != nil {
+			return err
+		}
+		if m.Sign == SIGN_NEGATIVE_IN {
+			amount.Neg(amount)
+		}
+		entry.Amount = amount
+	}
+
+	if entry.Amount.Sign() == 0 {
+		return errors.New("amount value must not be zero")
+	}
+
+	entry.Comment = field(record, c.comment)
+	entry.Currency = strings.ToLower(field(record, c.currency))
+
+	return nil
+}
+
+// findColumn resolves a mapped column to its index, -1 stands for an unmapped optional column.
+func findColumn(column string, header []string, required bool) (int, error) {
+	column = strings.TrimSpace(column)
+	if column == "" {
+		if required {
+			return -1, ErrInvalidMapping
+		}
+		return -1, nil
+	}
+
+	for i, name := range header {
+		if strings.EqualFold(strings.TrimSpace(name), column) {
+			return i, nil
+		}
+	}
+
+	index, err := strconv.Atoi(column)
+	if err != nil || index < 0 {
+		return -1, fmt.Errorf("%w: column '%s' not found", ErrInvalidMapping, column)
+	}
+
+	return index, nil
+}
+
+func field(record []string, index int) string {
+	if index < 0 || index >= len(record) {
+		return ""
+	}
+
+	return strings.TrimSpace(record[index])
+}
+
+func isBlankRecord(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// parseCsvAmount parses numbers like "-1 234,50" or "(12.00)". An empty value
+// is zero when allowEmpty is set, as in split debit/credit columns.
+func parseCsvAmount(value, decimalSeparator string, allowEmpty bool) (*big.Rat, error) {
+	value = strings.NewReplacer(" ", "", "\u00a0", "", "'", "").Replace(value)
+	if value == "" && allowEmpty {
+		return new(big.Rat), nil
+	}
+
+	negative := false
+	if strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")") {
+		negative = true
+		value = value[1 : len(value)-1]
+	}
+
+	if decimalSeparator == "," {
+		value = strings.ReplaceAll(value, ".", "")
+		value = strings.ReplaceAll(value, ",", ".")
+	} else {
+		value = strings.ReplaceAll(value, ",", "")
+	}
+
+	amount, ok := new(big.Rat).SetString(value)
+	if !ok || strings.ContainsAny(value, "/eE") {
+		return nil, errors.New("amount value must be decimal number")
+	}
+
+	if negative {
+		amount.Neg(amount)
+	}
+
+	return amount, nil
+}
+
+// dateLayout converts a human date format like DD.MM.YYYY to a Go time layout.
+func dateLayout(format string) string {
+	return strings.NewReplacer("YYYY", "2006", "YY", "06", "MM", "01", "DD", "02").Replace(format)
+}
@@ -0,0 +1,69 @@
+package statement
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCsv(t *testing.T) {
+	data := `Date;Description;Debit;Credit
+01.03.2022;Coffee;3,50;
+02.03.2022;Salary;;1.200,00
+
+bad;Broken;1;
+`
+	mapping := CsvMapping{
+		Delimiter:        ";",
+		HasHeader:        true,
+		DateColumn:       "date",
+		DateFormat:       "DD.MM.YYYY",
+		DebitColumn:      "Debit",
+		CreditColumn:     "Credit",
+		Sign:             SIGN_SPLIT,
+		DecimalSeparator: ",",
+		CommentColumn:    "Description",
+	}
+
+	st, err := ParseCsv(strings.NewReader(data), mapping)
+	if err != nil {
+		t.Fatalf("ParseCsv err %v", err)
+	}
+
+	if len(st.Entries) != 3 {
+		t.Fatalf("ParseCsv returned %d entries, want 3", len(st.Entries))
+	}
+
+	coffee := st.Entries[0]
+	if coffee.Line != 2 || coffee.Amount.FloatString(2) != "-3.50" || coffee.Comment != "Coffee" || !coffee.Date.Equal(time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("first entry = %+v", coffee)
+	}
+
+	if salary := st.Entries[1]; salary.Amount.FloatString(2) != "1200.00" {
+		t.Errorf("second entry amount = %s", salary.Amount.FloatString(2))
+	}
+
+	if broken := st.Entries[2]; broken.Err == nil || broken.Line != 5 {
+		t.Errorf("broken entry = %+v", broken)
+	}
+}
+
+func TestParseCsvSignedAmount(t *testing.T) {
+	st, err := ParseCsv(strings.NewReader("2022-03-01,-10.00,usd\n2022-03-02,(2.5),usd\n"), CsvMapping{
+		DateColumn:     "0",
+		AmountColumn:   "1",
+		CurrencyColumn: "2",
+		Sign:           SIGN_NEGATIVE_IN,
+	})
+	if err != nil {
+		t.Fatalf("ParseCsv err %v", err)
+	}
+
+	if st.Entries[0].Amount.FloatString(2) != "10.00" || st.Entries[1].Amount.FloatString(2) != "2.50" || st.Entries[0].Currency != "usd" {
+		t.Errorf("entries = %+v %+v", st.Entries[0], st.Entries[1])
+	}
+
+	if _, err := ParseCsv(strings.NewReader("2022-03-01,1\n"), CsvMapping{DateColumn: "0"}); err == nil {
+		t.Errorf("mapping without amount column must fail")
+	}
+}
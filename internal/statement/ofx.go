@@ -0,0 +1,186 @@
+package statement
+
+import (
+	"errors"
+	"html"
+	"io"
+	"strings"
+	"time"
+)
+
+// ParseOfx reads OFX and QFX files, both the SGML based 1.x and the XML based
+// 2.x versions. Every STMTTRN becomes an entry with its FITID as external id.
+func ParseOfx(r io.Reader) (*Statement, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	content := string(data)
+	start := strings.Index(strings.ToUpper(content), "<OFX>")
+	if start < 0 {
+		return nil, ErrInvalidFormat
+	}
+
+	statement := &Statement{}
+	var entry *Entry
+	var path []string
+	var balance, balanceDate string
+
+	for _, t := range ofxTokens(content[start:]) {
+		switch {
+		case t.close:
+			if t.tag == "STMTTRN" && entry != nil {
+				if entry.Err == nil && (entry.Amount == nil || entry.Date.IsZero()) {
+					entry.Err = errors.New("transaction must have date and amount")
+				}
+				statement.Entries = append(statement.Entries, entry)
+				entry = nil
+			}
+			path = closeOfxTag(path, t.tag)
+		case t.value == "":
+			path = append(path, t.tag)
+			if t.tag == "STMTTRN" {
+				entry = &Entry{Line: len(statement.Entries) + 1}
+			}
+		case entry != nil:
+			fillOfxEntry(entry, t.tag, t.value)
+		default:
+			parent := ""
+			if len(path) > 0 {
+				parent = path[len(path)-1]
+			}
+
+			switch {
+			case t.tag == "CURDEF":
+				statement.Currency = strings.ToLower(t.value)
+			case t.tag == "ACCTID" && (parent == "BANKACCTFROM" || parent == "CCACCTFROM"):
+				if statement.AccountId != "" && statement.AccountId != t.value {
+					return nil, ErrMultipleAccounts
+				}
+				statement.AccountId = t.value
+			case t.tag == "BALAMT" && parent == "LEDGERBAL":
+				balance = t.value
+			case t.tag == "DTASOF" && parent == "LEDGERBAL":
+				balanceDate = t.value
+			}
+		}
+	}
+
+	if len(statement.Entries) == 0 {
+		return nil, ErrEmptyStatement
+	}
+
+	for _, e := range statement.Entries {
+		e.Currency = statement.Currency
+	}
+
+	if balance != "" {
+		statement.ClosingBalance, err = parseCsvAmount(balance, ".", false)
+		if err != nil {
+			return nil, ErrInvalidFormat
+		}
+		statement.ClosingDate, _ = parseOfxDate(balanceDate)
+	}
+
+	return statement, nil
+}
+
+type ofxToken struct {
+	tag   string
+	value string
+	close bool
+}
+
+// ofxTokens splits the document into tags. SGML leaf elements have no
+// closing tag, so the text following an opening tag is its value.
+func ofxTokens(content string) []ofxToken {
+	var tokens []ofxToken
+	for {
+		open := strings.IndexByte(content, '<')
+		if open < 0 {
+			return tokens
+		}
+		end := strings.IndexByte(content[open:], '>')
+		if end < 0 {
+			return tokens
+		}
+		end += open
+
+		tag := strings.TrimSpace(content[open+1 : end])
+		content = content[end+1:]
+		if tag == "" || tag[0] == '?' || tag[0] == '!' {
+			continue
+		}
+
+		if tag[0] == '/' {
+			tokens = append(tokens, ofxToken{tag: strings.ToUpper(tag[1:]), close: true})
+			continue
+		}
+
+		value := content
+		if next := strings.IndexByte(content, '<'); next >= 0 {
+			value = content[:next]
+		}
+
+		tokens = append(tokens, ofxToken{tag: strings.ToUpper(tag), value: html.UnescapeString(strings.TrimSpace(value))})
+	}
+}
+
+func closeOfxTag(path []string, tag string) []string {
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i] == tag {
+			return path[:i]
+		}
+	}
+
+	return path
+}
+
+func fillOfxEntry(entry *Entry, tag, value string) {
+	var err error
+	switch tag {
+	case "FITID":
+		entry.ExternalId = value
+	case "DTPOSTED":
+		entry.Date, err = parseOfxDate(value)
+	case "TRNAMT":
+		entry.Amount, err = parseCsvAmount(value, ".", false)
+		if err == nil && entry.Amount.Sign() == 0 {
+			err = errors.New("amount value must not be zero")
+		}
+	case "NAME", "PAYEE":
+		entry.Comment = joinComment(value, entry.Comment)
+	case "MEMO":
+		entry.Comment = joinComment(entry.Comment, value)
+	}
+
+	if err != nil && entry.Err == nil {
+		entry.Err = err
+	}
+}
+
+func joinComment(first, second string) string {
+	if first == "" || first == second {
+		return second
+	}
+	if second == "" {
+		return first
+	}
+
+	return first + " " + second
+}
+
+// parseOfxDate reads the date part of values like 20220301120000.000[-5:EST].
+func parseOfxDate(value string) (time.Time, error) {
+	if len(value) < 8 {
+		return time.Time{}, errors.New("date value must be in format YYYYMMDD")
+	}
+
+	date, err := time.Parse("20060102", value[:8])
+	if err != nil {
+		return time.Time{}, errors.New("date value must be in format YYYYMMDD")
+	}
+
+	return date, nil
+}
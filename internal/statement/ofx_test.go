@@ -0,0 +1,60 @@
+package statement
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseOfxSgml(t *testing.T) {
+	data := `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+
+<OFX>
+<BANKMSGSRSV1><STMTTRNRS><STMTRS>
+<CURDEF>USD
+<BANKACCTFROM><BANKID>123<ACCTID>98765<ACCTTYPE>CHECKING</BANKACCTFROM>
+<BANKTRANLIST>
+<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20220301120000.000[-5:EST]<TRNAMT>-12.50<FITID>A1<NAME>Grocery &amp; Co<MEMO>card</STMTTRN>
+<STMTTRN><TRNTYPE>CREDIT<DTPOSTED>20220302<TRNAMT>100<FITID>A2<NAME>Salary</STMTTRN>
+</BANKTRANLIST>
+<LEDGERBAL><BALAMT>587.50<DTASOF>20220331</LEDGERBAL>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>`
+
+	st, err := ParseOfx(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("ParseOfx err %v", err)
+	}
+
+	if st.AccountId != "98765" || st.Currency != "usd" || st.ClosingBalance.FloatString(2) != "587.50" || !st.ClosingDate.Equal(time.Date(2022, 3, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("statement = %+v", st)
+	}
+
+	if len(st.Entries) != 2 {
+		t.Fatalf("ParseOfx returned %d entries, want 2", len(st.Entries))
+	}
+
+	first := st.Entries[0]
+	if first.ExternalId != "A1" || first.Amount.FloatString(2) != "-12.50" || first.Comment != "Grocery & Co card" || !first.Date.Equal(time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)) || first.Currency != "usd" {
+		t.Errorf("first entry = %+v", first)
+	}
+}
+
+func TestParseOfxXml(t *testing.T) {
+	data := `<?xml version="1.0"?><?OFX OFXHEADER="200"?>
+<OFX><CREDITCARDMSGSRSV1><CCSTMTTRNRS><CCSTMTRS><CURDEF>EUR</CURDEF>
+<CCACCTFROM><ACCTID>4111</ACCTID></CCACCTFROM>
+<BANKTRANLIST><STMTTRN><DTPOSTED>20220305</DTPOSTED><TRNAMT>-3.00</TRNAMT><FITID>X</FITID><NAME>Coffee</NAME></STMTTRN></BANKTRANLIST>
+</CCSTMTRS></CCSTMTTRNRS></CREDITCARDMSGSRSV1></OFX>`
+
+	st, err := ParseOfx(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("ParseOfx err %v", err)
+	}
+
+	if st.AccountId != "4111" || st.ClosingBalance != nil || len(st.Entries) != 1 || st.Entries[0].Comment != "Coffee" {
+		t.Errorf("statement = %+v", st)
+	}
+}
@@ -0,0 +1,154 @@
+package statement
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const QIF_DEFAULT_DATE_FORMAT = "MM/DD/YYYY"
+
+// ParseQif reads a QIF bank or credit card export. QIF has no transaction ids,
+// so the external id is a hash of the entry fields and its position among
+// equal entries, which stays the same when an overlapping file is imported again.
+func ParseQif(r io.Reader, dateFormat string) (*Statement, error) {
+	if dateFormat == "" {
+		dateFormat = QIF_DEFAULT_DATE_FORMAT
+	}
+	layout := dateLayout(dateFormat)
+
+	statement := &Statement{}
+	scanner := bufio.NewScanner(r)
+	inAccount := false
+	var entry *Entry
+	var fields []string
+	seen := map[string]int{}
+	line := 0
+
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		if strings.HasPrefix(text, "!") {
+			inAccount = strings.EqualFold(text, "!Account")
+			continue
+		}
+
+		code, value := text[0], strings.TrimSpace(text[1:])
+
+		if inAccount {
+			if code == 'N' {
+				if statement.AccountId != "" && statement.AccountId != value {
+					return nil, ErrMultipleAccounts
+				}
+				statement.AccountId = value
+			}
+			continue
+		}
+
+		if code == '^' {
+			if entry != nil {
+				finishQifEntry(entry, fields, seen)
+				statement.Entries = append(statement.Entries, entry)
+			}
+			entry, fields = nil, nil
+			continue
+		}
+
+		if entry == nil {
+			entry = &Entry{Line: line}
+		}
+
+		switch code {
+		case 'D':
+			date, err := parseQifDate(value, layout)
+			if err != nil && entry.Err == nil {
+				entry.Err = fmt.Errorf("date value must be in format %s", dateFormat)
+			}
+			entry.Date = date
+		case 'T', 'U':
+			if entry.Amount != nil {
+				continue
+			}
+			amount, err := parseCsvAmount(value, ".", false)
+			if err != nil {
+				if entry.Err == nil {
+					entry.Err = err
+				}
+				continue
+			}
+			entry.Amount = amount
+		case 'P':
+			entry.Comment = joinComment(value, entry.Comment)
+		case 'M':
+			entry.Comment = joinComment(entry.Comment, value)
+		default:
+			continue
+		}
+		fields = append(fields, text)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if entry != nil {
+		finishQifEntry(entry, fields, seen)
+		statement.Entries = append(statement.Entries, entry)
+	}
+
+	if len(statement.Entries) == 0 {
+		return nil, ErrEmptyStatement
+	}
+
+	return statement, nil
+}
+
+func finishQifEntry(entry *Entry, fields []string, seen map[string]int) {
+	if entry.Err == nil && (entry.Amount == nil || entry.Date.IsZero()) {
+		entry.Err = errors.New("transaction must have date and amount")
+	}
+	if entry.Err == nil && entry.Amount.Sign() == 0 {
+		entry.Err = errors.New("amount value must not be zero")
+	}
+
+	sum := sha1.Sum([]byte(strings.Join(fields, "\n")))
+	key := hex.EncodeToString(sum[:])
+	seen[key]++
+	entry.ExternalId = "qif:" + key + ":" + strconv.Itoa(seen[key])
+}
+
+// parseQifDate accepts the apostrophe year separator Quicken uses for years
+// after 1999, space padded parts like " 1/ 5'22" and two digit years.
+func parseQifDate(value, layout string) (time.Time, error) {
+	value = strings.ReplaceAll(strings.ReplaceAll(value, " ", ""), "'", "/")
+
+	parts := strings.FieldsFunc(value, func(r rune) bool { return r < '0' || r > '9' })
+	separators := strings.FieldsFunc(value, func(r rune) bool { return r >= '0' && r <= '9' })
+	if len(parts) != 3 || len(separators) != 2 {
+		return time.Time{}, errors.New("invalid date")
+	}
+
+	for i, part := range parts {
+		if len(part) == 1 {
+			parts[i] = "0" + part
+		}
+	}
+
+	value = parts[0] + separators[0] + parts[1] + separators[1] + parts[2]
+	date, err := time.Parse(layout, value)
+	if err == nil {
+		return date, nil
+	}
+
+	return time.Parse(strings.Replace(layout, "2006", "06", 1), value)
+}
@@ -0,0 +1,51 @@
+package statement
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseQif(t *testing.T) {
+	data := `!Account
+NChecking
+TBank
+^
+!Type:Bank
+D 3/ 1'22
+T-1,250.00
+PRent
+^
+D03/02/2022
+T-4.00
+PCoffee
+^
+D03/02/2022
+T-4.00
+PCoffee
+^
+`
+
+	st, err := ParseQif(strings.NewReader(data), "")
+	if err != nil {
+		t.Fatalf("ParseQif err %v", err)
+	}
+
+	if st.AccountId != "Checking" || len(st.Entries) != 3 {
+		t.Fatalf("statement = %+v", st)
+	}
+
+	rent := st.Entries[0]
+	if rent.Err != nil || rent.Amount.FloatString(2) != "-1250.00" || !rent.Date.Equal(time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)) || rent.Line != 6 {
+		t.Errorf("rent entry = %+v", rent)
+	}
+
+	if st.Entries[1].ExternalId == st.Entries[2].ExternalId {
+		t.Errorf("equal entries must get different external ids")
+	}
+
+	again, _ := ParseQif(strings.NewReader(data), "")
+	if again.Entries[2].ExternalId != st.Entries[2].ExternalId {
+		t.Errorf("external ids must be stable between imports")
+	}
+}
@@ -0,0 +1,38 @@
+// Package statement parses bank statement files into entries that can be
+// imported as wallet transactions.
+package statement
+
+import (
+	"errors"
+	"math/big"
+	"time"
+)
+
+var (
+	ErrInvalidMapping   = errors.New("statement: invalid column mapping")
+	ErrEmptyStatement   = errors.New("statement: no entries found")
+	ErrInvalidFormat    = errors.New("statement: invalid file format")
+	ErrMultipleAccounts = errors.New("statement: file contains more than one account")
+)
+
+// Entry is a single statement line. Amount is signed: positive values are
+// incoming and negative values are outgoing money.
+type Entry struct {
+	Line       int
+	ExternalId string
+	Date       time.Time
+	Amount     *big.Rat
+	Comment    string
+	Currency   string
+	Err        error
+}
+
+// Statement is a parsed statement file. AccountId, Currency and the closing
+// balance are only known for formats that carry them.
+type Statement struct {
+	AccountId      string
+	Currency       string
+	ClosingBalance *big.Rat
+	ClosingDate    time.Time
+	Entries        []*Entry
+}
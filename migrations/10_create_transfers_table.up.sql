@@ -0,0 +1,19 @@
+CREATE TABLE public.transfers (
+	id uuid NOT NULL,
+	user_id uuid NOT NULL,
+	out_transaction_id uuid NOT NULL,
+	in_transaction_id uuid NOT NULL,
+	rate numeric(30, 10) NULL,
+	reversal_of_id uuid NULL,
+	created_at timestamp NOT NULL,
+	updated_at timestamp NOT NULL,
+	deleted_at timestamp NULL,
+	CONSTRAINT transfers_pk PRIMARY KEY (id),
+	CONSTRAINT transfers_users_fk FOREIGN KEY (user_id) REFERENCES public.users(id),
+	CONSTRAINT transfers_out_transactions_fk FOREIGN KEY (out_transaction_id) REFERENCES public.transactions(id),
+	CONSTRAINT transfers_in_transactions_fk FOREIGN KEY (in_transaction_id) REFERENCES public.transactions(id),
+	CONSTRAINT transfers_reversal_fk FOREIGN KEY (reversal_of_id) REFERENCES public.transfers(id),
+	CONSTRAINT transfers_reversal_uq UNIQUE (reversal_of_id)
+);
+
+CREATE INDEX transfers_user_created_at_idx ON public.transfers (user_id, created_at);
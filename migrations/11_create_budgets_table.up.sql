@@ -0,0 +1,20 @@
+CREATE TABLE public.budgets (
+	id uuid NOT NULL,
+	"name" varchar NOT NULL,
+	user_id uuid NOT NULL,
+	category_id uuid NOT NULL,
+	limit_amount numeric(20, 2) NOT NULL,
+	currency varchar NOT NULL,
+	period varchar NOT NULL,
+	start_date timestamp NOT NULL,
+	end_date timestamp NULL,
+	rollover bool NOT NULL DEFAULT false,
+	created_at timestamp NOT NULL,
+	updated_at timestamp NOT NULL,
+	deleted_at timestamp NULL,
+	CONSTRAINT budgets_pk PRIMARY KEY (id),
+	CONSTRAINT budgets_users_fk FOREIGN KEY (user_id) REFERENCES public.users(id),
+	CONSTRAINT budgets_categories_fk FOREIGN KEY (category_id) REFERENCES public.categories(id) ON DELETE CASCADE
+);
+
+CREATE INDEX budgets_user_idx ON public.budgets (user_id);
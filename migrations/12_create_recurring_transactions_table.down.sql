@@ -0,0 +1,2 @@
+DROP TABLE public.recurring_occurrences;
+DROP TABLE public.recurring_transactions;
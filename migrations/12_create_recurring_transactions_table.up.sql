@@ -0,0 +1,38 @@
+CREATE TABLE public.recurring_transactions (
+	id uuid NOT NULL,
+	user_id uuid NOT NULL,
+	wallet_id uuid NOT NULL,
+	category_id uuid NOT NULL,
+	amount numeric(20, 2) NOT NULL,
+	currency varchar NOT NULL,
+	"type" varchar NOT NULL,
+	"comment" varchar NULL,
+	frequency varchar NOT NULL,
+	"interval" int4 NOT NULL DEFAULT 1,
+	day_of_month int4 NOT NULL DEFAULT 0,
+	start_date timestamp NOT NULL,
+	end_date timestamp NULL,
+	count int4 NULL,
+	next_date timestamp NULL,
+	created_at timestamp NOT NULL,
+	updated_at timestamp NOT NULL,
+	CONSTRAINT recurring_transactions_pk PRIMARY KEY (id),
+	CONSTRAINT recurring_transactions_users_fk FOREIGN KEY (user_id) REFERENCES public.users(id),
+	CONSTRAINT recurring_transactions_wallets_fk FOREIGN KEY (wallet_id) REFERENCES public.wallets(id) ON DELETE CASCADE,
+	CONSTRAINT recurring_transactions_categories_fk FOREIGN KEY (category_id) REFERENCES public.categories(id) ON DELETE CASCADE
+);
+
+CREATE INDEX recurring_transactions_user_idx ON public.recurring_transactions (user_id);
+CREATE INDEX recurring_transactions_next_date_idx ON public.recurring_transactions (next_date);
+
+CREATE TABLE public.recurring_occurrences (
+	recurring_id uuid NOT NULL,
+	occurrence_date timestamp NOT NULL,
+	status varchar NOT NULL,
+	amount numeric(20, 2) NULL,
+	"comment" varchar NULL,
+	transaction_id uuid NULL,
+	updated_at timestamp NOT NULL,
+	CONSTRAINT recurring_occurrences_pk PRIMARY KEY (recurring_id, occurrence_date),
+	CONSTRAINT recurring_occurrences_recurring_fk FOREIGN KEY (recurring_id) REFERENCES public.recurring_transactions(id) ON DELETE CASCADE
+);
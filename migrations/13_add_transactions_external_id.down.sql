@@ -0,0 +1,5 @@
+DROP TABLE public.wallet_accounts;
+
+DROP INDEX public.transactions_wallet_external_idx;
+
+ALTER TABLE public.transactions DROP COLUMN external_id;
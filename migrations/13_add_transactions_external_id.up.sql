@@ -0,0 +1,13 @@
+ALTER TABLE public.transactions ADD external_id varchar NULL;
+
+CREATE UNIQUE INDEX transactions_wallet_external_idx ON public.transactions (wallet_id, external_id) WHERE external_id IS NOT NULL;
+
+CREATE TABLE public.wallet_accounts (
+	user_id uuid NOT NULL,
+	account_id varchar NOT NULL,
+	wallet_id uuid NOT NULL,
+	created_at timestamp NOT NULL,
+	CONSTRAINT wallet_accounts_pk PRIMARY KEY (user_id, account_id),
+	CONSTRAINT wallet_accounts_users_fk FOREIGN KEY (user_id) REFERENCES public.users(id),
+	CONSTRAINT wallet_accounts_wallets_fk FOREIGN KEY (wallet_id) REFERENCES public.wallets(id) ON DELETE CASCADE
+);
@@ -0,0 +1,4 @@
+DROP INDEX public.user_tokens_hash_idx;
+
+-- hashed tokens can not be restored, every session is revoked
+DELETE FROM public.user_tokens;
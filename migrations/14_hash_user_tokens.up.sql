@@ -0,0 +1,4 @@
+-- tokens were stored as is, keep existing sessions valid by hashing them in place
+UPDATE public.user_tokens SET hash = encode(sha256(convert_to(hash, 'UTF8')), 'hex');
+
+CREATE UNIQUE INDEX user_tokens_hash_idx ON public.user_tokens (hash);
@@ -0,0 +1,7 @@
+DELETE FROM public.user_tokens WHERE kind = 'refresh';
+
+DROP INDEX public.user_tokens_family_id_idx;
+
+ALTER TABLE public.user_tokens DROP COLUMN used_at;
+ALTER TABLE public.user_tokens DROP COLUMN family_id;
+ALTER TABLE public.user_tokens DROP COLUMN kind;
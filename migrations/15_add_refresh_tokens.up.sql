@@ -0,0 +1,9 @@
+ALTER TABLE public.user_tokens ADD kind varchar NOT NULL DEFAULT 'access';
+ALTER TABLE public.user_tokens ADD family_id uuid NULL;
+ALTER TABLE public.user_tokens ADD used_at timestamp NULL;
+
+-- every token issued before is a session of its own
+UPDATE public.user_tokens SET family_id = id;
+ALTER TABLE public.user_tokens ALTER COLUMN family_id SET NOT NULL;
+
+CREATE INDEX user_tokens_family_id_idx ON public.user_tokens (family_id);
@@ -0,0 +1,18 @@
+CREATE TABLE public.api_keys (
+	id uuid NOT NULL,
+	user_id uuid NOT NULL,
+	"name" varchar NOT NULL,
+	prefix varchar NOT NULL,
+	hash varchar NOT NULL,
+	scopes varchar[] NOT NULL,
+	wallet_ids uuid[] NOT NULL DEFAULT '{}',
+	expires_at timestamp NULL,
+	last_used_at timestamp NULL,
+	created_at timestamp NOT NULL,
+	updated_at timestamp NOT NULL,
+	CONSTRAINT api_keys_pk PRIMARY KEY (id),
+	CONSTRAINT api_keys_users_fk FOREIGN KEY (user_id) REFERENCES public.users(id)
+);
+
+CREATE UNIQUE INDEX api_keys_hash_idx ON public.api_keys (hash);
+CREATE INDEX api_keys_user_id_idx ON public.api_keys (user_id);
@@ -0,0 +1,3 @@
+DROP TABLE public.sign_in_challenges;
+DROP TABLE public.user_recovery_codes;
+DROP TABLE public.user_totp;
@@ -0,0 +1,35 @@
+CREATE TABLE public.user_totp (
+	user_id uuid NOT NULL,
+	secret varchar NOT NULL,
+	enabled bool NOT NULL DEFAULT false,
+	last_step int8 NOT NULL DEFAULT 0,
+	created_at timestamp NOT NULL,
+	updated_at timestamp NOT NULL,
+	CONSTRAINT user_totp_pk PRIMARY KEY (user_id),
+	CONSTRAINT user_totp_users_fk FOREIGN KEY (user_id) REFERENCES public.users(id)
+);
+
+CREATE TABLE public.user_recovery_codes (
+	id uuid NOT NULL,
+	user_id uuid NOT NULL,
+	hash varchar NOT NULL,
+	used_at timestamp NULL,
+	created_at timestamp NOT NULL,
+	CONSTRAINT user_recovery_codes_pk PRIMARY KEY (id),
+	CONSTRAINT user_recovery_codes_users_fk FOREIGN KEY (user_id) REFERENCES public.users(id)
+);
+
+CREATE INDEX user_recovery_codes_user_id_idx ON public.user_recovery_codes (user_id);
+
+CREATE TABLE public.sign_in_challenges (
+	id uuid NOT NULL,
+	user_id uuid NOT NULL,
+	hash varchar NOT NULL,
+	attempts int4 NOT NULL DEFAULT 0,
+	expires_at timestamp NOT NULL,
+	created_at timestamp NOT NULL,
+	CONSTRAINT sign_in_challenges_pk PRIMARY KEY (id),
+	CONSTRAINT sign_in_challenges_users_fk FOREIGN KEY (user_id) REFERENCES public.users(id)
+);
+
+CREATE UNIQUE INDEX sign_in_challenges_hash_idx ON public.sign_in_challenges (hash);
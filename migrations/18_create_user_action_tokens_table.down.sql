@@ -0,0 +1,3 @@
+DROP TABLE public.user_action_tokens;
+
+ALTER TABLE public.users DROP COLUMN email_verified_at;
@@ -0,0 +1,15 @@
+ALTER TABLE public.users ADD email_verified_at timestamp NULL;
+
+CREATE TABLE public.user_action_tokens (
+	id uuid NOT NULL,
+	user_id uuid NOT NULL,
+	"action" varchar NOT NULL,
+	email varchar NOT NULL,
+	expires_at timestamp NOT NULL,
+	used_at timestamp NULL,
+	created_at timestamp NOT NULL,
+	CONSTRAINT user_action_tokens_pk PRIMARY KEY (id),
+	CONSTRAINT user_action_tokens_users_fk FOREIGN KEY (user_id) REFERENCES public.users(id)
+);
+
+CREATE INDEX user_action_tokens_user_id_idx ON public.user_action_tokens (user_id, "action");
@@ -0,0 +1,8 @@
+CREATE TABLE public.rate_limits (
+	"key" varchar NOT NULL,
+	count int4 NOT NULL,
+	reset_at timestamp NOT NULL,
+	CONSTRAINT rate_limits_pk PRIMARY KEY ("key")
+);
+
+CREATE INDEX rate_limits_reset_at_idx ON public.rate_limits (reset_at);
@@ -0,0 +1,23 @@
+alter table recurring_occurrences
+    alter column amount type numeric(20, 2) using round(amount, 2);
+
+alter table recurring_transactions
+    alter column amount type numeric(20, 2) using round(amount, 2);
+
+alter table budgets
+    alter column limit_amount type numeric(20, 2) using round(limit_amount, 2);
+
+alter table categories
+    alter column balance type numeric(20, 2) using round(balance, 2);
+
+alter table transactions
+    alter column amount type numeric(20, 2) using round(amount, 2);
+
+alter table wallets
+    alter column balance type numeric(20, 2) using round(balance, 2);
+
+update recurring_transactions set currency = 'rur' where currency = 'rub';
+update budgets set currency = 'rur' where currency = 'rub';
+update transactions set currency = 'rur' where currency = 'rub';
+update categories set currency = 'rur' where currency = 'rub';
+update wallets set currency = 'rur' where currency = 'rub';
@@ -0,0 +1,24 @@
+update wallets set currency = 'rub' where currency = 'rur';
+update categories set currency = 'rub' where currency = 'rur';
+update transactions set currency = 'rub' where currency = 'rur';
+update budgets set currency = 'rub' where currency = 'rur';
+update recurring_transactions set currency = 'rub' where currency = 'rur';
+
+-- ISO 4217 minor units go up to 4 digits
+alter table wallets
+    alter column balance type numeric(22, 4);
+
+alter table transactions
+    alter column amount type numeric(22, 4);
+
+alter table categories
+    alter column balance type numeric(22, 4);
+
+alter table budgets
+    alter column limit_amount type numeric(22, 4);
+
+alter table recurring_transactions
+    alter column amount type numeric(22, 4);
+
+alter table recurring_occurrences
+    alter column amount type numeric(22, 4);
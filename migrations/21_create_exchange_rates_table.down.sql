@@ -0,0 +1,5 @@
+ALTER TABLE public.transactions DROP COLUMN rate;
+ALTER TABLE public.transactions DROP COLUMN original_currency;
+ALTER TABLE public.transactions DROP COLUMN original_amount;
+
+DROP TABLE public.exchange_rates;
@@ -0,0 +1,21 @@
+CREATE TABLE public.exchange_rates (
+	id uuid NOT NULL,
+	user_id uuid NULL,
+	base varchar NOT NULL,
+	quote varchar NOT NULL,
+	rate numeric(30, 10) NOT NULL,
+	rate_date date NOT NULL,
+	"source" varchar NOT NULL,
+	created_at timestamp NOT NULL,
+	updated_at timestamp NOT NULL,
+	CONSTRAINT exchange_rates_pk PRIMARY KEY (id),
+	CONSTRAINT exchange_rates_users_fk FOREIGN KEY (user_id) REFERENCES public.users(id)
+);
+
+-- user rates override the global rates of the provider
+CREATE UNIQUE INDEX exchange_rates_user_pair_date_idx ON public.exchange_rates (user_id, base, quote, rate_date) WHERE user_id IS NOT NULL;
+CREATE UNIQUE INDEX exchange_rates_pair_date_idx ON public.exchange_rates (base, quote, rate_date) WHERE user_id IS NULL;
+
+ALTER TABLE public.transactions ADD original_amount numeric(22, 4) NULL;
+ALTER TABLE public.transactions ADD original_currency varchar NULL;
+ALTER TABLE public.transactions ADD rate numeric(30, 10) NULL;
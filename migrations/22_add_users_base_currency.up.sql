@@ -0,0 +1,9 @@
+ALTER TABLE public.users ADD base_currency varchar NULL;
+
+-- existing users report in the currency of their first wallet
+UPDATE public.users u SET base_currency = coalesce(
+	(SELECT w.currency FROM public.wallets w WHERE w.user_id = u.id ORDER BY w.created_at LIMIT 1),
+	'usd'
+);
+
+ALTER TABLE public.users ALTER COLUMN base_currency SET NOT NULL;
@@ -0,0 +1 @@
+ALTER TABLE public.users ADD time_zone varchar NOT NULL DEFAULT 'UTC';
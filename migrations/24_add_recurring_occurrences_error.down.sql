@@ -0,0 +1 @@
+ALTER TABLE public.recurring_occurrences DROP COLUMN error;
@@ -0,0 +1 @@
+ALTER TABLE public.recurring_occurrences ADD error varchar NULL;
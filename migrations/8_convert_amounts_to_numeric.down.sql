@@ -0,0 +1,9 @@
+alter table wallets
+    alter column balance type float4 using balance::float4;
+
+alter table transactions
+    alter column amount type float4 using amount::float4;
+
+alter table categories
+    alter column balance drop default,
+    alter column balance type float4 using balance::float4;
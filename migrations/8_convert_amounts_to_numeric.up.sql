@@ -0,0 +1,9 @@
+alter table wallets
+    alter column balance type numeric(20, 2) using round(balance::numeric, 2);
+
+alter table transactions
+    alter column amount type numeric(20, 2) using round(amount::numeric, 2);
+
+alter table categories
+    alter column balance type numeric(20, 2) using round(balance::numeric, 2),
+    alter column balance set default 0;
@@ -0,0 +1,9 @@
+drop index if exists categories_parent_idx;
+drop index if exists transactions_user_amount_idx;
+drop index if exists transactions_user_created_at_idx;
+
+alter table transactions
+    drop column if exists category_id;
+
+alter table categories
+    drop column if exists parent_id;
@@ -0,0 +1,11 @@
+alter table categories
+    add column if not exists parent_id uuid null
+        constraint categories_parent_fk references categories (id) on delete cascade;
+
+alter table transactions
+    add column if not exists category_id uuid null
+        constraint transactions_categories_fk references categories (id);
+
+create index transactions_user_created_at_idx on transactions (user_id, created_at, id);
+create index transactions_user_amount_idx on transactions (user_id, amount, id);
+create index categories_parent_idx on categories (parent_id);
@@ -0,0 +1,169 @@
+// Package jwt signs and verifies compact JSON Web Tokens with HS256, RS256
+// and EdDSA keys. Keys are identified by kid, so new keys can be added for
+// signing while tokens signed with older keys still verify.
+package jwt
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+var ErrTokenExpired = errors.New("token expired")
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// Claims are the registered claims used by the api plus the space separated scope.
+type Claims struct {
+	Id        string `json:"jti,omitempty"`
+	Issuer    string `json:"iss,omitempty"`
+	Subject   string `json:"sub"`
+	SessionId string `json:"sid,omitempty"`
+	Scope     string `json:"scope,omitempty"`
+	IssuedAt  int64  `json:"iat"`
+	NotBefore int64  `json:"nbf,omitempty"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+type header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ,omitempty"`
+	KeyId     string `json:"kid,omitempty"`
+}
+
+func (c *Claims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// IsJwt tells a compact JWT from an opaque token, which never contains dots.
+func IsJwt(value string) bool {
+	return strings.Count(value, ".") == 2
+}
+
+// KeySet holds every key accepted for verification, one of them signs new tokens.
+type KeySet struct {
+	signing *Key
+	keys    map[string]*Key
+	order   []string
+}
+
+func NewKeySet(signingKeyId string, keys ...*Key) (*KeySet, error) {
+	ks := &KeySet{keys: map[string]*Key{}}
+	for _, k := range keys {
+		if _, ok := ks.keys[k.Id]; ok {
+			return nil, errors.New("duplicate key id " + k.Id)
+		}
+		ks.keys[k.Id] = k
+		ks.order = append(ks.order, k.Id)
+	}
+
+	signing, ok := ks.keys[signingKeyId]
+	if !ok {
+		return nil, errors.New("signing key " + signingKeyId + " not found")
+	}
+	if !signing.canSign() {
+		return nil, errors.New("signing key " + signingKeyId + " has no private key")
+	}
+	ks.signing = signing
+
+	return ks, nil
+}
+
+func (ks *KeySet) Sign(c *Claims) (string, error) {
+	h, err := json.Marshal(header{Algorithm: ks.signing.Algorithm, Type: "JWT", KeyId: ks.signing.Id})
+	if err != nil {
+		return "", err
+	}
+
+	p, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	input := encode(h) + "." + encode(p)
+	sig, err := ks.signing.sign([]byte(input))
+	if err != nil {
+		return "", err
+	}
+
+	return input + "." + encode(sig), nil
+}
+
+// Verify checks the signature with the key named by kid and the time claims.
+// The algorithm of the header must match the key, so a public RSA key can
+// not be abused as an HMAC secret.
+func (ks *KeySet) Verify(token string, now time.Time) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	h := header{}
+	if err := decodeJson(parts[0], &h); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	key, err := ks.lookup(h.KeyId)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.Algorithm != key.Algorithm {
+		return nil, ErrInvalidToken
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if !key.verify([]byte(parts[0]+"."+parts[1]), sig) {
+		return nil, ErrInvalidToken
+	}
+
+	c := &Claims{}
+	if err := decodeJson(parts[1], c); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if c.ExpiresAt == 0 || now.Unix() >= c.ExpiresAt {
+		return nil, ErrTokenExpired
+	}
+
+	if c.NotBefore != 0 && now.Unix() < c.NotBefore {
+		return nil, ErrInvalidToken
+	}
+
+	return c, nil
+}
+
+func (ks *KeySet) lookup(kid string) (*Key, error) {
+	if kid == "" {
+		if len(ks.keys) == 1 {
+			return ks.signing, nil
+		}
+		return nil, ErrUnknownKey
+	}
+
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	return key, nil
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeJson(part string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,109 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testKeys(t *testing.T) []*Key {
+	hs, err := NewHmacKey("hs", []byte(strings.Repeat("s", 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed, err := NewKey("ed", edPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsaPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, err := NewKey("rs", rsaPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return []*Key{hs, ed, rs}
+}
+
+func TestSignVerify(t *testing.T) {
+	keys := testKeys(t)
+	now := time.Now()
+	claims := &Claims{Subject: "user", Scope: "wallet:read report:read", IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix()}
+
+	for _, k := range keys {
+		ks, err := NewKeySet(k.Id, keys...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		token, err := ks.Sign(claims)
+		if err != nil {
+			t.Fatalf("%s: %v", k.Algorithm, err)
+		}
+
+		if !IsJwt(token) {
+			t.Fatalf("%s: %s is not a jwt", k.Algorithm, token)
+		}
+
+		got, err := ks.Verify(token, now)
+		if err != nil {
+			t.Fatalf("%s: %v", k.Algorithm, err)
+		}
+
+		if got.Subject != "user" || len(got.Scopes()) != 2 {
+			t.Fatalf("%s: unexpected claims %+v", k.Algorithm, got)
+		}
+
+		_, err = ks.Verify(token, now.Add(time.Minute))
+		if err != ErrTokenExpired {
+			t.Fatalf("%s: expected expired, got %v", k.Algorithm, err)
+		}
+
+		tampered := token[:len(token)-2] + "AA"
+		if _, err = ks.Verify(tampered, now); err == nil {
+			t.Fatalf("%s: tampered token verified", k.Algorithm)
+		}
+	}
+}
+
+func TestRotation(t *testing.T) {
+	keys := testKeys(t)
+	now := time.Now()
+	claims := &Claims{Subject: "user", ExpiresAt: now.Add(time.Minute).Unix()}
+
+	old, _ := NewKeySet("hs", keys[0])
+	token, err := old.Sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := NewKeySet("ed", keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = rotated.Verify(token, now); err != nil {
+		t.Fatalf("token of the old key must verify, got %v", err)
+	}
+
+	withoutOld, _ := NewKeySet("ed", keys[1])
+	if _, err = withoutOld.Verify(token, now); err != ErrUnknownKey {
+		t.Fatalf("expected unknown key, got %v", err)
+	}
+
+	jwks := rotated.Jwks()
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("expected public ed and rs keys only, got %+v", jwks.Keys)
+	}
+}
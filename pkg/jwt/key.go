@@ -0,0 +1,144 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"strings"
+)
+
+const (
+	ALGORITHM_HS256 = "HS256"
+	ALGORITHM_RS256 = "RS256"
+	ALGORITHM_EDDSA = "EdDSA"
+)
+
+// HMAC_MIN_SECRET_LENGTH follows RFC 7518, the secret is at least as long as the hash.
+const HMAC_MIN_SECRET_LENGTH = 32
+
+// RSA_MIN_BITS rejects keys too short for RS256.
+const RSA_MIN_BITS = 2048
+
+var ErrInvalidKey = errors.New("invalid key")
+var ErrUnsupportedAlgorithm = errors.New("unsupported algorithm")
+
+// Key is a signing or a verify-only key. A key without its private part
+// keeps verifying the tokens signed before it was rotated out.
+type Key struct {
+	Id        string
+	Algorithm string
+	secret    []byte
+	private   crypto.Signer
+	public    crypto.PublicKey
+}
+
+func NewHmacKey(id string, secret []byte) (*Key, error) {
+	if len(secret) < HMAC_MIN_SECRET_LENGTH {
+		return nil, errors.New("hmac secret must be at least 32 bytes")
+	}
+
+	return &Key{Id: id, Algorithm: ALGORITHM_HS256, secret: secret}, nil
+}
+
+// ParseKey reads an HS256 secret or a PEM encoded RS256/EdDSA key. Private
+// keys are PKCS#8 (or PKCS#1 for RSA), public keys are PKIX.
+func ParseKey(id, algorithm string, data []byte) (*Key, error) {
+	switch algorithm {
+	case ALGORITHM_HS256:
+		return NewHmacKey(id, []byte(strings.TrimSpace(string(data))))
+	case ALGORITHM_RS256, ALGORITHM_EDDSA:
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidKey
+	}
+
+	key := &Key{Id: id, Algorithm: algorithm}
+	if parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		signer, ok := parsed.(crypto.Signer)
+		if !ok {
+			return nil, ErrInvalidKey
+		}
+		key.private = signer
+		key.public = signer.Public()
+	} else if parsed, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		key.private = parsed
+		key.public = parsed.Public()
+	} else if parsed, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		key.public = parsed
+	} else {
+		return nil, ErrInvalidKey
+	}
+
+	switch pub := key.public.(type) {
+	case *rsa.PublicKey:
+		if algorithm != ALGORITHM_RS256 || pub.N.BitLen() < RSA_MIN_BITS {
+			return nil, ErrInvalidKey
+		}
+	case ed25519.PublicKey:
+		if algorithm != ALGORITHM_EDDSA {
+			return nil, ErrInvalidKey
+		}
+	default:
+		return nil, ErrInvalidKey
+	}
+
+	return key, nil
+}
+
+// NewKey wraps a generated private key, mostly for tests and key tooling.
+func NewKey(id string, private crypto.Signer) (*Key, error) {
+	switch private.Public().(type) {
+	case *rsa.PublicKey:
+		return &Key{Id: id, Algorithm: ALGORITHM_RS256, private: private, public: private.Public()}, nil
+	case ed25519.PublicKey:
+		return &Key{Id: id, Algorithm: ALGORITHM_EDDSA, private: private, public: private.Public()}, nil
+	}
+
+	return nil, ErrUnsupportedAlgorithm
+}
+
+func (k *Key) canSign() bool {
+	return k.secret != nil || k.private != nil
+}
+
+func (k *Key) sign(input []byte) ([]byte, error) {
+	switch k.Algorithm {
+	case ALGORITHM_HS256:
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(input)
+		return mac.Sum(nil), nil
+	case ALGORITHM_RS256:
+		h := sha256.Sum256(input)
+		return k.private.Sign(rand.Reader, h[:], crypto.SHA256)
+	case ALGORITHM_EDDSA:
+		return k.private.Sign(rand.Reader, input, crypto.Hash(0))
+	}
+
+	return nil, ErrUnsupportedAlgorithm
+}
+
+func (k *Key) verify(input, sig []byte) bool {
+	switch k.Algorithm {
+	case ALGORITHM_HS256:
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(input)
+		return hmac.Equal(sig, mac.Sum(nil))
+	case ALGORITHM_RS256:
+		h := sha256.Sum256(input)
+		return rsa.VerifyPKCS1v15(k.public.(*rsa.PublicKey), crypto.SHA256, h[:], sig) == nil
+	case ALGORITHM_EDDSA:
+		return ed25519.Verify(k.public.(ed25519.PublicKey), input, sig)
+	}
+
+	return false
+}
@@ -1,21 +1,25 @@
 package validator
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"math/big"
 	"strconv"
+	"strings"
 )
 
-const TYPE_FLOAT = "float"
+const TYPE_DECIMAL = "decimal"
+const MAX_DECIMAL_PLACES = 18
 const TYPE_STRING = "string"
 const TYPE_UUID = "uuid"
 const DEFAULT_MESSAGE = "validation error"
 
 var DefaultMessages map[string]string = map[string]string{
-	"float":  "%s value must be float",
-	"string": "%s value must be string",
-	"uuid":   "%s value must be uuid",
+	"decimal": "%s value must be decimal number",
+	"string":  "%s value must be string",
+	"uuid":    "%s value must be uuid",
 }
 
 func GetMessage(validationType string) string {
@@ -34,21 +38,43 @@ func GetFieldMessage(validationType, fieldName string) string {
 	return msg
 }
 
-func Float32(value interface{}, name string) (float32, error) {
+// Decimal returns the exact decimal representation of a number given as
+// a JSON number or a string. Float values are accepted for compatibility
+// and converted using their shortest representation.
+func Decimal(value interface{}, name string) (string, error) {
+	var val string
 	switch t := value.(type) {
 	case string:
-		floatVal, err := strconv.ParseFloat(t, 32)
-		if err != nil {
-			return 0.0, errors.New(GetFieldMessage(TYPE_FLOAT, name))
-		}
-		return float32(floatVal), nil
-	case float32:
-		return t, nil
+		val = t
+	case json.Number:
+		val = t.String()
 	case float64:
-		return float32(t), nil
+		val = strconv.FormatFloat(t, 'f', -1, 64)
+	case float32:
+		val = strconv.FormatFloat(float64(t), 'f', -1, 32)
+	default:
+		return "", errors.New(GetFieldMessage(TYPE_DECIMAL, name))
+	}
+
+	if strings.Contains(val, "/") {
+		return "", errors.New(GetFieldMessage(TYPE_DECIMAL, name))
+	}
+
+	rat, ok := new(big.Rat).SetString(val)
+	if !ok {
+		return "", errors.New(GetFieldMessage(TYPE_DECIMAL, name))
+	}
+
+	scaled := new(big.Rat).Set(rat)
+	ten := big.NewRat(10, 1)
+	for places := 0; places <= MAX_DECIMAL_PLACES; places++ {
+		if scaled.IsInt() {
+			return rat.FloatString(places), nil
+		}
+		scaled.Mul(scaled, ten)
 	}
 
-	return 0.0, errors.New(fmt.Sprintf("%s value must be float", name))
+	return "", errors.New(GetFieldMessage(TYPE_DECIMAL, name))
 }
 
 func Uuid(value interface{}, name string) (uuid.UUID, error) {